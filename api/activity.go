@@ -0,0 +1,52 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// lastActiveThrottle is how often last_active_at is written per user, requests in between don't touch the database
+	lastActiveThrottle = 5 * time.Minute
+	// activeNowWindow is how recently a user must have been active to count as active now. It's longer than the
+	// throttle so a user making requests doesn't drop out between two writes
+	activeNowWindow = 15 * time.Minute
+)
+
+// trackActivity records that the user made a request, failing to do so doesn't fail the request
+func (api *API) trackActivity(userID int) {
+	if ok, _ := api.activityLimiter.Allow(strconv.Itoa(userID)); !ok {
+		return
+	}
+
+	if err := api.userRepo.TouchLastActive(userID); err != nil {
+		log.Printf("last active of user %d: %v", userID, err)
+	}
+}
+
+// ReadActiveUsers lists the users active within the last activeNowWindow
+func (api API) ReadActiveUsers(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.activeUsersPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := api.userRepo.FetchActiveUsers(time.Now().Add(-activeNowWindow), api.getUserIDAvoidPanic(c), limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type BanUserRequest struct {
+	Until  time.Time `json:"until" binding:"required"`
+	Reason string    `json:"reason" binding:"required"`
+}
+
+func (api *API) BanUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	var banUserRequest BanUserRequest
+	err = c.ShouldBindJSON(&banUserRequest)
+	if err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			c.AbortWithStatusJSON(
+				http.StatusBadRequest,
+				gin.H{"errors": helper.GetErrorMessage(ve)},
+			)
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if !banUserRequest.Until.After(time.Now()) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "until must be in the future"})
+		return
+	}
+
+	err = api.userRepo.BanUser(userID, banUserRequest.Until, banUserRequest.Reason)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionBanUser, repository.AuditTargetUser, userID, gin.H{
+		"until":  banUserRequest.Until,
+		"reason": banUserRequest.Reason,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ban User Successful"})
+}
+
+func (api *API) UnbanUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	err = api.userRepo.UnbanUser(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionUnbanUser, repository.AuditTargetUser, userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unban User Successful"})
+}
+
+type UserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// SetUserRole gives a user another role. Their current token keeps the old role until it expires
+func (api *API) SetUserRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	var request UserRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := repository.NormalizeRole(request.Role)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := api.userRepo.UpdateUserRole(adminID, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrCannotChangeSelf):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionChangeRole, repository.AuditTargetUser, userID, gin.H{
+		"from": previous,
+		"to":   role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role Updated", "role": role})
+}
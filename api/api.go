@@ -1,56 +1,173 @@
 package api
 
 import (
+	"expvar"
+	"net/http"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 
+	"github.com/althafariq/discusspedia-be/config"
 	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 type API struct {
-	commentRepo       repository.CommentRepository
-	likeRepo          repository.LikeRepository
-	notifRepo         repository.NotificationRepository
-	postRepo          repository.PostRepository
-	userRepo          repository.UserRepository
-	categoryRepo      repository.CategoryRepository
-	questionnaireRepo repository.QuestionnaireRepository
+	commentRepo       repository.CommentRepo
+	followRepo        repository.FollowRepo
+	idempotencyRepo   repository.IdempotencyRepo
+	likeRepo          repository.LikeRepo
+	mentionRepo       repository.MentionRepo
+	notifRepo         repository.NotificationRepo
+	postRepo          repository.PostRepo
+	userRepo          repository.UserRepo
+	categoryRepo      repository.CategoryRepo
+	questionnaireRepo repository.QuestionnaireRepo
+	auditRepo         repository.AuditRepo
+	webhookRepo       repository.WebhookRepo
+	webhookClient     *http.Client
+	commentHub        *hub
+	feedHub           *hub
+	exportLimiter     *rateLimiter
+	feedCache         *responseCache
+	validateLimiter   *rateLimiter
+	activityLimiter   *rateLimiter
 	router            *gin.Engine
+
+	postsPage              pageConfig
+	relatedPostsPage       pageConfig
+	userCommentsPage       pageConfig
+	userQuestionnairesPage pageConfig
+	notificationsPage      pageConfig
+	searchPage             pageConfig
+	followsPage            pageConfig
+	activeUsersPage        pageConfig
+	commentTreePage        pageConfig
+	commentsPage           pageConfig
+	auditPage              pageConfig
+	mentionsPage           pageConfig
+	webhookDeliveriesPage  pageConfig
+	postRevisionsPage      pageConfig
+	leaderboardPage        pageConfig
+
+	port                           string
+	jwtKey                         []byte
+	jwtMethod                      jwt.SigningMethod
+	jwtIssuer                      string
+	jwtAudience                    string
+	jwtExpiry                      time.Duration
+	jwtLeeway                      time.Duration
+	mediaDir                       string
+	mediaCleanupInterval           time.Duration
+	mediaCleanupGrace              time.Duration
+	mediaCleanupMu                 *sync.Mutex
+	mediaSigningKey                []byte
+	signedMediaTTL                 time.Duration
+	uploadConcurrency              int
+	uploadFileNameStrategy         string
+	deleteContentOnAccountDeletion bool
+	preModeration                  bool
+	commentMaxDepth                int
+	postRevisionsPublic            bool
+	commentDepthMode               string
 }
 
 func NewAPI(
-	commentRepo repository.CommentRepository,
-	likeRepo repository.LikeRepository,
-	notifRepo repository.NotificationRepository,
-	postRepo repository.PostRepository,
-	userRepo repository.UserRepository,
-	categoryRepo repository.CategoryRepository,
-	questionnaireRepo repository.QuestionnaireRepository,
+	cfg config.Config,
+	commentRepo repository.CommentRepo,
+	followRepo repository.FollowRepo,
+	idempotencyRepo repository.IdempotencyRepo,
+	likeRepo repository.LikeRepo,
+	mentionRepo repository.MentionRepo,
+	notifRepo repository.NotificationRepo,
+	postRepo repository.PostRepo,
+	userRepo repository.UserRepo,
+	categoryRepo repository.CategoryRepo,
+	questionnaireRepo repository.QuestionnaireRepo,
+	auditRepo repository.AuditRepo,
+	webhookRepo repository.WebhookRepo,
 ) API {
 	router := gin.Default()
 
 	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
+	if len(cfg.CORSAllowedOrigins) == 1 && cfg.CORSAllowedOrigins[0] == "*" {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOrigins = cfg.CORSAllowedOrigins
+	}
 	config.AllowCredentials = true
-	config.AddAllowHeaders("Authorization")
+	config.AddAllowHeaders("Authorization", "Idempotency-Key")
+	config.AddExposeHeaders("X-Page-Limit", "X-Cache", "Deprecation", "Sunset", "Link")
 	router.Use(cors.New(config))
+	router.Use(securityHeaders(cfg))
 	router.RedirectTrailingSlash = false
+	router.HandleMethodNotAllowed = true
 	
 	
 	api := API{
 		router:            router,
 		commentRepo:       commentRepo,
+		followRepo:        followRepo,
+		idempotencyRepo:   idempotencyRepo,
 		likeRepo:          likeRepo,
+		mentionRepo:       mentionRepo,
 		notifRepo:         notifRepo,
 		postRepo:          postRepo,
 		userRepo:          userRepo,
 		categoryRepo:      categoryRepo,
 		questionnaireRepo: questionnaireRepo,
+		auditRepo:         auditRepo,
+		webhookRepo:       webhookRepo,
+		webhookClient:     &http.Client{Timeout: cfg.WebhookTimeout},
+		commentHub:        newHub(),
+		feedHub:           newHub(),
+		exportLimiter:     newRateLimiter(cfg.ExportLimitPerDay, 24*time.Hour),
+		feedCache:         newResponseCache(10*time.Second, 1000, feedCacheHits, feedCacheMisses),
+		validateLimiter:   newRateLimiter(30, time.Minute),
+		activityLimiter:   newRateLimiter(1, lastActiveThrottle),
+
+		postsPage:              pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		relatedPostsPage:       pageConfig{DefaultLimit: 5, MaxLimit: 20},
+		userCommentsPage:       pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		userQuestionnairesPage: pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		notificationsPage:      pageConfig{DefaultLimit: 10, MaxLimit: 50},
+		searchPage:             pageConfig{DefaultLimit: 20, MaxLimit: 50},
+		followsPage:            pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		activeUsersPage:        pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		commentTreePage:        pageConfig{DefaultLimit: 200, MaxLimit: 500},
+		commentsPage:           pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		auditPage:              pageConfig{DefaultLimit: 50, MaxLimit: 200},
+		mentionsPage:           pageConfig{DefaultLimit: 20, MaxLimit: 50},
+		webhookDeliveriesPage:  pageConfig{DefaultLimit: 50, MaxLimit: 200},
+		postRevisionsPage:      pageConfig{DefaultLimit: 20, MaxLimit: 100},
+		leaderboardPage:        pageConfig{DefaultLimit: 10, MaxLimit: repository.LeaderboardSize},
+
+		port:                           cfg.Port,
+		jwtKey:                         []byte(cfg.JWTSecret),
+		jwtMethod:                      jwt.GetSigningMethod(cfg.JWTAlgorithm),
+		jwtIssuer:                      cfg.JWTIssuer,
+		jwtAudience:                    cfg.JWTAudience,
+		jwtExpiry:                      cfg.JWTExpiry,
+		jwtLeeway:                      cfg.JWTLeeway,
+		mediaDir:                       cfg.MediaDir,
+		mediaCleanupInterval:           cfg.MediaCleanupInterval,
+		mediaCleanupGrace:              cfg.MediaCleanupGrace,
+		mediaCleanupMu:                 &sync.Mutex{},
+		mediaSigningKey:                []byte(cfg.MediaSigningKey),
+		signedMediaTTL:                 cfg.SignedMediaTTL,
+		uploadConcurrency:              cfg.UploadConcurrency,
+		uploadFileNameStrategy:         cfg.UploadFileNameStrategy,
+		deleteContentOnAccountDeletion: cfg.DeleteContentOnAccountDeletion,
+		preModeration:                  cfg.PreModeration,
+		commentMaxDepth:                cfg.CommentMaxDepth,
+		postRevisionsPublic:            cfg.PostRevisionsPublic,
+		commentDepthMode:               cfg.CommentDepthMode,
 	}
 
 	// Untuk validasi request dengan mengembalikan nama dari tag json jika ada
@@ -64,66 +181,191 @@ func NewAPI(
 		})
 	}
 
-	router.Static("/media", "./media")
+	router.GET("/media/post/:filename", api.servePostImage)
+	router.HEAD("/media/post/:filename", api.servePostImage)
+	router.GET("/media/questionnaire/:filename", api.serveQuestionnaireImage)
+	router.HEAD("/media/questionnaire/:filename", api.serveQuestionnaireImage)
+	router.GET("/media/avatar/:filename", api.serveAvatar)
+	router.HEAD("/media/avatar/:filename", api.serveAvatar)
+	router.GET("/media/signed/:folder/:filename", api.serveSignedMedia)
+	router.HEAD("/media/signed/:folder/:filename", api.serveSignedMedia)
+
+	api.registerRoutes(router.Group(apiV1Prefix))
+	api.registerRoutes(router.Group("/api", deprecated(cfg.UnversionedAPISunset, unversionedSuccessor)))
+
+	router.NoRoute(api.notFound)
+	router.NoMethod(api.methodNotAllowed)
+
+	return api
+}
 
-	router.POST("/api/login", api.login)
-	router.POST("/api/register", api.register)
-	router.GET("/api/category", api.GetAllCategories)
+// registerRoutes adds every route of the API to the group, it's done once for each version the routes are served under
+func (api *API) registerRoutes(r *gin.RouterGroup) {
+	r.POST("/media/sign", api.AuthMiddleware(), api.RequireRole(repository.RoleAdmin, repository.RoleModerator), api.SignMediaURL)
 
-	profileRouter := router.Group("/api/profile", AuthMiddleware())
+	r.POST("/login", api.login)
+	r.POST("/register", api.register)
+	r.GET("/category", api.GetAllCategories)
+	r.GET("/category/counts", api.ReadCategoryPostCounts)
+	r.GET("/category/:id", api.ReadCategory)
+	r.GET("/search", api.Search)
+	r.POST("/validate", api.ValidateContent)
+
+	profileRouter := r.Group("/profile", api.AuthMiddleware())
 	{
 		profileRouter.GET("", api.getProfile)
 		profileRouter.PATCH("", api.updateProfile)
 		profileRouter.PUT("/avatar", api.changeAvatar)
 	}
 
-	router.GET("/api/post", api.readPosts)
-	router.GET("/api/post/:id", api.readPost)
-	postRouter := router.Group("/api/post", AuthMiddleware())
+	r.GET("/post", api.readPosts)
+	r.GET("/post/random", api.readRandomPost)
+	r.GET("/post/:id", api.readPost)
+	r.GET("/post/:id/related", api.readRelatedPosts)
+	r.GET("/post/:id/activity", api.readPostActivity)
+	r.GET("/post/:id/revisions", api.readPostRevisions)
+	postRouter := r.Group("/post", api.AuthMiddleware())
 	{
 		postRouter.POST("", api.createPost)
 		postRouter.PUT("", api.updatePost)
+		postRouter.POST("/with-images", api.createPostWithImages)
 		postRouter.POST("/images/:id", api.uploadPostImages)
+		postRouter.PUT("/:id/images/order", api.reorderPostImages)
+		postRouter.POST("/:id/lock-comments", api.toggleCommentsLock)
 		postRouter.DELETE("/:id", api.deletePost)
+		postRouter.POST("/:id/undo", api.undoPost)
 	}
 
-	router.GET("/api/comments", api.ReadAllComment)
-	commentRoutersWithAuth := router.Group("/api/comments", AuthMiddleware())
+	r.GET("/post/:id/comments/ws", api.CommentsWebSocket)
+	r.GET("/post/:id/comment-tree", api.ReadCommentTree)
+	r.GET("/post/:id/comments", api.ReadCommentPage)
+
+	r.GET("/comments", api.ReadAllComment)
+	commentRoutersWithAuth := r.Group("/comments", api.AuthMiddleware())
 	{
 		commentRoutersWithAuth.POST("", api.CreateComment)
 		commentRoutersWithAuth.PUT("", api.UpdateComment)
 		commentRoutersWithAuth.DELETE("/:id", api.DeleteComment)
+		commentRoutersWithAuth.POST("/:id/highlight", api.HighlightComment)
+		commentRoutersWithAuth.DELETE("/:id/highlight", api.UnhighlightComment)
 	}
 
-	postLikeRouters := router.Group("/api/post/:id/likes", AuthMiddleware())
+	postPinRouters := r.Group("/post/:id/pin", api.AuthMiddleware(), api.RequireScopedRole(api.postCategoryScope, repository.RoleAdmin, repository.RoleModerator))
+	{
+		postPinRouters.POST("", api.pinPost)
+		postPinRouters.DELETE("", api.unpinPost)
+	}
+
+	postLikeRouters := r.Group("/post/:id/likes", api.AuthMiddleware())
 	{
 		postLikeRouters.POST("", api.CreatePostLike)
 		postLikeRouters.DELETE("", api.DeletePostLike)
 	}
 
-	commentLikeRouters := router.Group("/api/comments/:id/likes", AuthMiddleware())
+	r.GET("/post/:id/reactions", api.ReadPostReactions)
+	postReactionRouters := r.Group("/post/:id/reaction", api.AuthMiddleware())
+	{
+		postReactionRouters.PUT("", api.SetPostReaction)
+		postReactionRouters.DELETE("", api.DeletePostReaction)
+	}
+
+	commentLikeRouters := r.Group("/comments/:id/likes", api.AuthMiddleware())
 	{
 		commentLikeRouters.POST("", api.CreateCommentLike)
 		commentLikeRouters.DELETE("", api.DeleteCommentLike)
 	}
 
-	// router.GET("api/notifications", api.GetAllNotifications)
-	notifRouter := router.Group("/api/notifications", AuthMiddleware())
+	// r.GET("/notifications", api.GetAllNotifications)
+	notifRouter := r.Group("/notifications", api.AuthMiddleware())
 	{
 		notifRouter.GET("", api.GetAllNotifications)
 		notifRouter.PUT("/read", api.SetReadNotif)
 	}
 
-	router.GET("/api/questionnaires", api.ReadAllQuestionnaires)
-	router.GET("/api/questionnaires/:id", api.ReadAllQuestionnaireByID)
-	questionnaireRoutersWithAuth := router.Group("/api/questionnaires", AuthMiddleware())
+	meRouter := r.Group("/me", api.AuthMiddleware())
+	{
+		meRouter.GET("", api.getMe)
+		meRouter.DELETE("", api.deleteAccount)
+		meRouter.GET("/export", api.ExportUserData)
+		meRouter.GET("/feed/stream", api.StreamFeed)
+		meRouter.GET("/notifications", api.GetAllNotifications)
+		meRouter.GET("/notifications/unread-count", api.CountUnreadNotifications)
+		meRouter.GET("/notification-preferences", api.GetNotificationPreferences)
+		meRouter.PUT("/notification-preferences", api.UpdateNotificationPreferences)
+		meRouter.POST("/posts/bulk-delete", api.bulkDeletePosts)
+		meRouter.GET("/questionnaires", api.ReadMyQuestionnaires)
+		meRouter.GET("/questionnaire-feed", api.ReadQuestionnaireFeed)
+		meRouter.GET("/questionnaire-draft", api.ReadQuestionnaireDraft)
+		meRouter.GET("/blocks", api.ReadBlockedUsers)
+		meRouter.GET("/mentions", api.ReadMyMentions)
+		meRouter.POST("/mentions/read-all", api.ReadAllMentions)
+		meRouter.PUT("/questionnaire-draft", api.SaveQuestionnaireDraft)
+		meRouter.PUT("/privacy", api.updatePrivacy)
+		meRouter.POST("/notifications/read-all", api.ReadAllNotifications)
+		meRouter.POST("/notifications/:id/read", api.ReadNotification)
+		meRouter.POST("/saved-searches", api.CreateSavedSearch)
+		meRouter.GET("/saved-searches", api.ReadSavedSearches)
+		meRouter.GET("/saved-searches/:id/posts", api.RunSavedSearch)
+		meRouter.DELETE("/saved-searches/:id", api.DeleteSavedSearch)
+	}
+
+	r.GET("/users/active", api.ReadActiveUsers)
+	r.POST("/users/batch", api.ReadUsersBatch)
+	r.GET("/users/:id/stats", api.GetUserStats)
+	r.GET("/leaderboard", api.GetLeaderboard)
+	r.GET("/users/:id/comments", api.ReadCommentsByAuthor)
+	r.GET("/users/:id/questionnaires", api.ReadQuestionnairesByAuthor)
+	r.GET("/users/:id/likes", api.ReadLikedPosts)
+	r.GET("/users/:id/followers", api.ReadFollowers)
+	r.GET("/users/:id/following", api.ReadFollowing)
+	userRouter := r.Group("/users", api.AuthMiddleware())
+	{
+		userRouter.POST("/:id/follow", api.FollowUser)
+		userRouter.DELETE("/:id/follow", api.UnfollowUser)
+		userRouter.POST("/:id/block", api.BlockUser)
+		userRouter.DELETE("/:id/block", api.UnblockUser)
+	}
+
+	r.GET("/questionnaires", api.ReadAllQuestionnaires)
+	r.GET("/questionnaires/:id", api.ReadAllQuestionnaireByID)
+	questionnaireRoutersWithAuth := r.Group("/questionnaires", api.AuthMiddleware())
 	{
 		questionnaireRoutersWithAuth.POST("/", api.CreateQuestionnaire)
 		questionnaireRoutersWithAuth.PUT("/", api.UpdateQuestionnaire)
 		questionnaireRoutersWithAuth.DELETE("/:id", api.DeleteQuestionnaire)
+		questionnaireRoutersWithAuth.POST("/:id/images", api.UploadQuestionnaireImages)
 	}
 
-	return api
+	adminRouter := r.Group("/admin", api.AuthMiddleware(), api.RequireRole(repository.RoleAdmin))
+	{
+		adminRouter.POST("/users/:id/ban", api.BanUser)
+		adminRouter.DELETE("/users/:id/ban", api.UnbanUser)
+		adminRouter.PUT("/users/:id/role", api.SetUserRole)
+		adminRouter.GET("/metrics", gin.WrapH(expvar.Handler()))
+		adminRouter.GET("/stats", api.GetPlatformStats)
+		adminRouter.POST("/media/cleanup", api.CleanupMedia)
+		adminRouter.POST("/recount", api.RecountLikes)
+		adminRouter.GET("/profanity/locales", api.ReadProfanityLocales)
+		adminRouter.PUT("/profanity/locales/:locale", api.SetProfanityLocale)
+		adminRouter.GET("/audit", api.ReadAuditLog)
+		adminRouter.GET("/categories/:id/moderators", api.ReadCategoryModerators)
+		adminRouter.POST("/categories/:id/moderators/:user_id", api.AssignCategoryModerator)
+		adminRouter.DELETE("/categories/:id/moderators/:user_id", api.RevokeCategoryModerator)
+		adminRouter.PUT("/categories/:id/anonymous", api.SetCategoryAnonymous)
+		adminRouter.POST("/posts/import", api.ImportPosts)
+		adminRouter.POST("/posts/:id/merge", api.MergePost)
+		adminRouter.GET("/webhooks", api.ReadWebhooks)
+		adminRouter.POST("/webhooks", api.CreateWebhook)
+		adminRouter.DELETE("/webhooks/:id", api.DeleteWebhook)
+		adminRouter.GET("/webhooks/:id/deliveries", api.ReadWebhookDeliveries)
+	}
+
+	moderationRouter := r.Group("/admin/posts", api.AuthMiddleware())
+	{
+		moderationRouter.GET("/pending", api.RequireScopedRole(api.anyCategoryScope, repository.RoleAdmin, repository.RoleModerator), api.ReadPendingPosts)
+		moderationRouter.POST("/:id/approve", api.RequireScopedRole(api.postCategoryScope, repository.RoleAdmin, repository.RoleModerator), api.ApprovePost)
+		moderationRouter.POST("/:id/reject", api.RequireScopedRole(api.postCategoryScope, repository.RoleAdmin, repository.RoleModerator), api.RejectPost)
+	}
 }
 
 func (api *API) Handler() *gin.Engine {
@@ -131,5 +373,9 @@ func (api *API) Handler() *gin.Engine {
 }
 
 func (api *API) Start() {
-	api.Handler().Run()
+	if api.mediaCleanupInterval > 0 {
+		go api.runMediaCleanup()
+	}
+
+	api.Handler().Run(":" + api.port)
 }
@@ -0,0 +1,22 @@
+package api_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The bad words lists are read relative to the working directory, the server runs from the repo root
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "API Suite")
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit stores a moderation action done by the caller. The action already happened, so a failure is only logged
+func (api *API) recordAudit(c *gin.Context, action, targetType string, targetID int, metadata gin.H) {
+	actorID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		log.Printf("audit %s: %v", action, err)
+		return
+	}
+
+	var encoded json.RawMessage
+	if metadata != nil {
+		if encoded, err = json.Marshal(metadata); err != nil {
+			log.Printf("audit %s: %v", action, err)
+			return
+		}
+	}
+
+	if err := api.auditRepo.InsertAuditEntry(actorID, action, targetType, targetID, encoded); err != nil {
+		log.Printf("audit %s: %v", action, err)
+	}
+}
+
+// ReadAuditLog lists the moderation actions, newest first. It can be filtered by actor_id, action and a from/to
+// range in RFC3339
+func (api *API) ReadAuditLog(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.auditPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := repository.AuditFilter{Action: c.Query("action")}
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		if filter.ActorID, err = strconv.Atoi(actorID); err != nil || filter.ActorID <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid actor_id"})
+			return
+		}
+	}
+
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid from, use RFC3339"})
+			return
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid to, use RFC3339"})
+			return
+		}
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "from must not be later than to"})
+		return
+	}
+
+	entries, err := api.auditRepo.FetchAuditEntries(filter, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
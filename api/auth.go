@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v4"
@@ -26,7 +26,7 @@ type RegisterReqBody struct {
 	Name      string  `json:"name" binding:"required"`
 	Email     string  `json:"email" binding:"required"`
 	Password  string  `json:"password" binding:"required"`
-	Role      string  `json:"role" binding:"required,lowercase,oneof=siswa mahasiswa"`
+	Role      string  `json:"role" binding:"required"`
 	Institute string  `json:"institute" binding:"required"`
 	Major     *string `json:"major" binding:"required_if=Role mahasiswa"`
 	Batch     *int    `json:"batch" binding:"required_if=Role mahasiswa"`
@@ -45,8 +45,6 @@ type RegisterSuccessResponse struct {
 	Token   string `json:"token"`
 }
 
-var jwtKey = []byte("key")
-
 type Claims struct {
 	Id    int
 	Email string
@@ -72,6 +70,12 @@ func (api *API) register(c *gin.Context) {
 		return
 	}
 
+	// "Mahasiswa" registers as mahasiswa, InsertNewUser refuses the roles users can't pick themselves
+	if input.Role, err = repository.NormalizeRole(input.Role); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	userId, responseCode, err := api.userRepo.InsertNewUser(input.Name, input.Email, input.Password, input.Role, input.Institute, input.Major, input.Batch)
 	if err != nil {
 		c.AbortWithStatusJSON(responseCode, gin.H{"error": err.Error()})
@@ -160,15 +164,10 @@ func (api *API) changeAvatar(c *gin.Context) {
 
 	oldFileName := userData.Avatar
 
-	folderPath := "media/avatar"
-	err = os.MkdirAll(folderPath, os.ModePerm)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	folderPath := filepath.Join(api.mediaDir, "avatar")
 
 	splitFilename := strings.Split(input.Avatar.Filename, ".")
-	fileName := fmt.Sprintf("%s_%d.%s", userData.Name, time.Now().Unix(), splitFilename[len(splitFilename)-1])
+	fileName := helper.SafeFileName(fmt.Sprintf("%s_%d.%s", userData.Name, time.Now().Unix(), splitFilename[len(splitFilename)-1]))
 	filePath := filepath.Join(folderPath, fileName)
 	err = c.SaveUploadedFile(input.Avatar, filePath)
 
@@ -190,7 +189,7 @@ func (api *API) changeAvatar(c *gin.Context) {
 	if c.Request.TLS != nil {
 		scheme = "https"
 	}
-	imgUrl := fmt.Sprintf("%s://%s/%s/%s", scheme, c.Request.Host, folderPath, url.PathEscape(fileName))
+	imgUrl := fmt.Sprintf("%s://%s/%s", scheme, c.Request.Host, mediaURL("avatar", filePath))
 	c.JSON(http.StatusOK, gin.H{"message": "success",
 		"data": struct {
 			Avatar string `json:"avatar"`
@@ -202,45 +201,86 @@ func (api *API) changeAvatar(c *gin.Context) {
 }
 
 func (api *API) getUserIdFromToken(c *gin.Context) (int, error) {
-	tokenString := c.GetHeader("Authorization")[(len("Bearer ")):]
-	claim := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claim, func(t *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	claims, err := api.getClaimsFromToken(c)
 	if err != nil {
 		return -1, err
 	}
 
-	if token.Valid {
-		claim := token.Claims.(*Claims)
-		return claim.Id, nil
+	return claims.Id, nil
+}
 
-	} else {
-		return -1, errors.New("invalid token")
+// getClaimsFromToken is getUserIdFromToken for handlers that also need the caller's role
+func (api *API) getClaimsFromToken(c *gin.Context) (*Claims, error) {
+	token, err := api.ValidateToken(c.GetHeader("Authorization")[(len("Bearer ")):])
+	if err != nil {
+		return nil, err
 	}
+
+	return token.Claims.(*Claims), nil
 }
 
-func ValidateToken(tokenString string) (*jwt.Token, error) {
-	claim := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claim, func(t *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
+// TokenError is returned by ValidateToken for every token that can't be trusted, the auth middleware maps it to 401
+type TokenError struct {
+	Reason string
+	Err    error
+}
+
+func (e *TokenError) Error() string {
+	return e.Reason
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateToken only returns a token without error when its signature, algorithm, issuer and audience match the
+// config and its exp, iat and nbf claims hold within the leeway
+func (api *API) ValidateToken(tokenString string) (*jwt.Token, error) {
+	// The claims are checked below, jwt's own check has no leeway
+	parser := jwt.Parser{ValidMethods: []string{api.jwtMethod.Alg()}, SkipClaimsValidation: true}
+
+	token, err := parser.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		return api.jwtKey, nil
 	})
-	return token, err
+	if err != nil {
+		return nil, &TokenError{Reason: "Invalid token", Err: err}
+	}
+
+	claims := token.Claims.(*Claims)
+	now := time.Now()
+
+	switch {
+	case !claims.VerifyExpiresAt(now.Add(-api.jwtLeeway).Unix(), true):
+		return nil, &TokenError{Reason: "Token expired"}
+	case !claims.VerifyIssuedAt(now.Add(api.jwtLeeway).Unix(), false):
+		return nil, &TokenError{Reason: "Token used before issued"}
+	case !claims.VerifyNotBefore(now.Add(api.jwtLeeway).Unix(), false):
+		return nil, &TokenError{Reason: "Token is not valid yet"}
+	case !claims.VerifyIssuer(api.jwtIssuer, true):
+		return nil, &TokenError{Reason: "Invalid token issuer"}
+	case !claims.VerifyAudience(api.jwtAudience, true):
+		return nil, &TokenError{Reason: "Invalid token audience"}
+	}
+
+	return token, nil
 }
 
 func (api API) generateJWT(userId *int, role *string) (string, error) {
-	expTime := time.Now().Add(60 * time.Minute)
+	now := time.Now()
 
 	claims := &Claims{
 		Id:   *userId,
 		Role: *role,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expTime.Unix(),
+			Issuer:    api.jwtIssuer,
+			Audience:  api.jwtAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(api.jwtExpiry).Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(api.jwtMethod, claims)
 
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := token.SignedString(api.jwtKey)
 	return tokenString, err
 }
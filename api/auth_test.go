@@ -0,0 +1,171 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/golang-jwt/jwt/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// newToken signs a token the default config accepts, after change has edited its claims
+func newToken(id int, change func(claims *api.Claims)) string {
+	cfg := config.Default()
+	claims := &api.Claims{
+		Id:   id,
+		Role: "mahasiswa",
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    cfg.JWTIssuer,
+			Audience:  cfg.JWTAudience,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}
+	if change != nil {
+		change(claims)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+	Expect(err).ToNot(HaveOccurred())
+	return token
+}
+
+var _ = Describe("Auth Test", func() {
+	var mainAPI api.API
+
+	BeforeEach(func() {
+		mainAPI = newTestAPI(mockRepos{post: &mockPostRepo{}, user: &mockUserRepo{}})
+	})
+
+	expectTokenError := func(token, reason string) {
+		_, err := mainAPI.ValidateToken(token)
+		var tokenErr *api.TokenError
+		Expect(errors.As(err, &tokenErr)).To(BeTrue())
+		Expect(tokenErr.Reason).To(Equal(reason))
+	}
+
+	Describe("ValidateToken", func() {
+		It("should accept a token of the config", func() {
+			token, err := mainAPI.ValidateToken(newToken(1, nil))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token.Claims.(*api.Claims).Id).To(Equal(1))
+		})
+
+		When("token expired within the leeway", func() {
+			It("should accept it", func() {
+				_, err := mainAPI.ValidateToken(newToken(1, func(claims *api.Claims) {
+					claims.ExpiresAt = time.Now().Add(-config.Default().JWTLeeway / 2).Unix()
+				}))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		When("token expired beyond the leeway", func() {
+			It("should return a TokenError", func() {
+				expectTokenError(newToken(1, func(claims *api.Claims) {
+					claims.ExpiresAt = time.Now().Add(-config.Default().JWTLeeway - time.Minute).Unix()
+				}), "Token expired")
+			})
+		})
+
+		When("token has another issuer", func() {
+			It("should return a TokenError", func() {
+				expectTokenError(newToken(1, func(claims *api.Claims) {
+					claims.Issuer = "someone-else"
+				}), "Invalid token issuer")
+			})
+		})
+
+		When("token has another audience", func() {
+			It("should return a TokenError", func() {
+				expectTokenError(newToken(1, func(claims *api.Claims) {
+					claims.Audience = ""
+				}), "Invalid token audience")
+			})
+		})
+
+		When("token is signed with another algorithm", func() {
+			It("should return a TokenError", func() {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, &api.Claims{
+					Id: 1,
+					StandardClaims: jwt.StandardClaims{
+						Issuer:    config.Default().JWTIssuer,
+						Audience:  config.Default().JWTAudience,
+						ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					},
+				}).SignedString([]byte(config.Default().JWTSecret))
+				Expect(err).ToNot(HaveOccurred())
+
+				expectTokenError(token, "Invalid token")
+			})
+		})
+	})
+
+	Describe("AuthMiddleware", func() {
+		It("should return 401 for expired and wrong issuer tokens", func() {
+			for _, token := range []string{
+				newToken(1, func(claims *api.Claims) { claims.ExpiresAt = time.Now().Add(-time.Hour).Unix() }),
+				newToken(1, func(claims *api.Claims) { claims.Issuer = "someone-else" }),
+			} {
+				req := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(`{"category_id":1,"title":"Title","description":"Description"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+token)
+
+				w := httptest.NewRecorder()
+				mainAPI.Handler().ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			}
+		})
+
+		It("should record the last activity at most once per throttle window", func() {
+			userRepo := &mockUserRepo{}
+			mainAPI = newTestAPI(mockRepos{post: &mockPostRepo{}, user: userRepo})
+
+			for i := 0; i < 3; i++ {
+				// Forbidden by the role check, which runs after the token was accepted
+				req := httptest.NewRequest(http.MethodPost, "/api/admin/recount", nil)
+				req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+				w := httptest.NewRecorder()
+				mainAPI.Handler().ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusForbidden))
+			}
+
+			Expect(userRepo.touched).To(Equal([]int{1}))
+		})
+	})
+
+	Describe("register", func() {
+		register := func(userRepo *mockUserRepo, role string) *httptest.ResponseRecorder {
+			mainAPI = newTestAPI(mockRepos{post: &mockPostRepo{}, user: userRepo})
+
+			body := `{"name":"Radit","email":"radit@gmail.com","password":"password","institute":"ITB","role":"` + role + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			mainAPI.Handler().ServeHTTP(w, req)
+			return w
+		}
+
+		It("should normalize the role before creating the user", func() {
+			userRepo := &mockUserRepo{}
+			Expect(register(userRepo, " Siswa").Code).To(Equal(http.StatusOK))
+			Expect(userRepo.registeredRoles).To(Equal([]string{"siswa"}))
+		})
+
+		It("should refuse unknown roles with 400", func() {
+			userRepo := &mockUserRepo{}
+			w := register(userRepo, "Teacher")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(w.Body.String()).To(ContainSubstring("role should be one of mahasiswa, siswa, lecturer, admin, moderator"))
+			Expect(userRepo.registeredRoles).To(BeEmpty())
+		})
+	})
+})
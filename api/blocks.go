@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/gin-gonic/gin"
+)
+
+// BlockUser hides the posts and comments of the user from the caller and removes the follows between them
+func (api API) BlockUser(c *gin.Context) {
+	blockedID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if blockedID == userID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "You can't block yourself"})
+		return
+	}
+
+	exists, err := api.userRepo.UserExists(blockedID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+		return
+	}
+
+	if _, err := api.followRepo.BlockUser(userID, blockedID); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Block User Successful"})
+}
+
+func (api API) UnblockUser(c *gin.Context) {
+	blockedID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	removed, err := api.followRepo.UnblockUser(userID, blockedID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "You haven't blocked this user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unblock User Successful"})
+}
+
+func (api API) ReadBlockedUsers(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.followsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := api.followRepo.FetchBlockedUsers(userID, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
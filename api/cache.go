@@ -0,0 +1,73 @@
+package api
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Published once per process, they're served by the admin metrics route
+var (
+	feedCacheHits   = expvar.NewInt("feed_cache_hits")
+	feedCacheMisses = expvar.NewInt("feed_cache_misses")
+)
+
+type cachedResponse struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// responseCache keeps encoded responses for a short time. It's bounded so unique query strings can't grow it forever,
+// once full nothing new is cached until entries expire
+type responseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]cachedResponse
+	hits       *expvar.Int
+	misses     *expvar.Int
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int, hits, misses *expvar.Int) *responseCache {
+	return &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cachedResponse),
+		hits:       hits,
+		misses:     misses,
+	}
+}
+
+func (r *responseCache) Get(key string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached, ok := r.entries[key]
+	if !ok || time.Now().After(cached.expiresAt) {
+		r.misses.Add(1)
+		return nil, false
+	}
+
+	r.hits.Add(1)
+	return cached.body, true
+}
+
+func (r *responseCache) Set(key string, body []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if len(r.entries) >= r.maxEntries {
+		for k, cached := range r.entries {
+			if now.After(cached.expiresAt) {
+				delete(r.entries, k)
+			}
+		}
+	}
+
+	if len(r.entries) >= r.maxEntries {
+		return
+	}
+
+	r.entries[key] = cachedResponse{body: body, expiresAt: now.Add(r.ttl)}
+}
@@ -1,11 +1,26 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/gin-gonic/gin"
 )
 
+type CategoryAnonymousRequest struct {
+	AllowAnonymous *bool `json:"allow_anonymous" binding:"required"`
+}
+
+type CategoryDetailResponse struct {
+	repository.Category
+	PostCount int                  `json:"post_count"`
+	Posts     []DetailPostResponse `json:"posts"`
+}
+
 func (api API) GetAllCategories(c *gin.Context) {
 	categories, err := api.categoryRepo.GetAllCategories()
 	if err != nil {
@@ -15,3 +30,123 @@ func (api API) GetAllCategories(c *gin.Context) {
 
 	c.JSON(http.StatusOK, categories)
 }
+
+// ReadCategoryPostCounts returns the number of published posts of every category, the empty ones only with
+// include_empty=true
+func (api *API) ReadCategoryPostCounts(c *gin.Context) {
+	includeEmpty, err := strconv.ParseBool(c.DefaultQuery("include_empty", "false"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "include_empty should be a bool"})
+		return
+	}
+
+	counts, err := api.categoryRepo.CountPostsByCategory(includeEmpty)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+// ReadCategory returns the category with a page of its posts, pinned ones first and then the newest
+func (api *API) ReadCategory(c *gin.Context) {
+	categoryID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.postsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := api.categoryRepo.CategoryByID(categoryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Category Not Found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	postCount, err := api.categoryRepo.CountCategoryPosts(categoryID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	viewerID := api.getUserIDAvoidPanic(c)
+	posts, err := api.postRepo.FetchAllPost(limit, offset, viewerID, "is_pinned DESC, created_at DESC",
+		fmt.Sprintf("AND category_id = %d", categoryID))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	response := CategoryDetailResponse{
+		Category:  category,
+		PostCount: postCount,
+		Posts:     []DetailPostResponse{},
+	}
+	if len(posts) > 0 {
+		response.Posts = buildPostListResponse(posts, viewerID, api.canSeeAnonymousAuthors(c))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SetCategoryAnonymous lets admins allow or forbid anonymous posts in a category
+func (api *API) SetCategoryAnonymous(c *gin.Context) {
+	categoryID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	var req CategoryAnonymousRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		return
+	}
+
+	if err := api.categoryRepo.SetCategoryAllowAnonymous(categoryID, *req.AllowAnonymous); err != nil {
+		if errors.Is(err, repository.ErrCategoryNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Category Not Found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionCategoryAnonymous, repository.AuditTargetCategory, categoryID, gin.H{
+		"allow_anonymous": *req.AllowAnonymous,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"allow_anonymous": *req.AllowAnonymous})
+}
+
+// validateCategory responds with 400 when the category doesn't exist, the database doesn't enforce the foreign key
+func (api *API) validateCategory(c *gin.Context, categoryID int) bool {
+	exists, err := api.categoryRepo.CategoryExists(categoryID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return false
+	}
+
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []helper.JSONRequestErrorResponse{
+			{Field: "category_id", Message: "Category doesn't exist"},
+		}})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Category API Test", func() {
+	var (
+		postRepo *mockPostRepo
+		handler  http.Handler
+	)
+
+	BeforeEach(func() {
+		postRepo = &mockPostRepo{}
+		mainAPI := newTestAPI(mockRepos{post: postRepo, category: &mockCategoryRepo{}})
+		handler = mainAPI.Handler()
+	})
+
+	readCategory := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/category/"+id, nil))
+		return w
+	}
+
+	Describe("ReadCategory", func() {
+		It("should return the category with its post count and recent posts", func() {
+			w := readCategory("1")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"id":1,"name":"Umum","allow_anonymous":false,"post_count":4,"posts":[]}`))
+			Expect(postRepo.orderBys).To(Equal([]string{"is_pinned DESC, created_at DESC"}))
+		})
+
+		It("should return 404 without fetching posts when the category doesn't exist", func() {
+			w := readCategory("9")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(postRepo.orderBys).To(BeEmpty())
+		})
+	})
+
+	Describe("ReadCategoryPostCounts", func() {
+		readCounts := func(query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/category/counts"+query, nil))
+			return w
+		}
+
+		It("should leave out the empty categories unless include_empty is set", func() {
+			w := readCounts("")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`[{"category_id":1,"name":"Umum","post_count":4}]`))
+
+			w = readCounts("?include_empty=true")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`[
+				{"category_id":1,"name":"Umum","post_count":4},
+				{"category_id":2,"name":"Sains","post_count":0}
+			]`))
+		})
+
+		It("should return 400 when include_empty isn't a bool", func() {
+			Expect(readCounts("?include_empty=maybe").Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
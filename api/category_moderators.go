@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+func (api *API) ReadCategoryModerators(c *gin.Context) {
+	categoryID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	moderators, err := api.categoryRepo.FetchCategoryModerators(categoryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"moderators": moderators})
+}
+
+// AssignCategoryModerator responds 201 when the user becomes a moderator and 200 when they already were one
+func (api *API) AssignCategoryModerator(c *gin.Context) {
+	categoryID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := helper.ParseID(c, "user_id")
+	if err != nil {
+		return
+	}
+
+	created, err := api.categoryRepo.AddCategoryModerator(categoryID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCategoryNotFound) || errors.Is(err, repository.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"message": "User Already Moderates The Category"})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionAssignCategoryModerator, repository.AuditTargetCategory, categoryID, gin.H{
+		"user_id": userID,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Category Moderator Assigned"})
+}
+
+func (api *API) RevokeCategoryModerator(c *gin.Context) {
+	categoryID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := helper.ParseID(c, "user_id")
+	if err != nil {
+		return
+	}
+
+	removed, err := api.categoryRepo.RemoveCategoryModerator(categoryID, userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !removed {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "user doesn't moderate the category"})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionRevokeCategoryModerator, repository.AuditTargetCategory, categoryID, gin.H{
+		"user_id": userID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category Moderator Revoked"})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type MediaCleanupResponse struct {
+	Removed []string `json:"removed"`
+}
+
+// mediaFolders are the folders under the media dir, with the lookup of the record each file belongs to
+func (api *API) mediaFolders() map[string]func(path string) (bool, error) {
+	return map[string]func(path string) (bool, error){
+		"post":          func(path string) (bool, error) { return api.postRepo.PostImageExists(path) },
+		"questionnaire": func(path string) (bool, error) { return api.questionnaireRepo.QuestionnaireImageExists(path) },
+		"avatar":        func(path string) (bool, error) { return api.userRepo.AvatarExists(path) },
+	}
+}
+
+func (api *API) runMediaCleanup() {
+	ticker := time.NewTicker(api.mediaCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := api.cleanupOrphanedMedia(); err != nil {
+			log.Printf("media cleanup: %v", err)
+		}
+	}
+}
+
+// cleanupOrphanedMedia removes the media files that no record points to. Files younger than the grace period are
+// kept since an upload writes the file before its record is inserted
+func (api *API) cleanupOrphanedMedia() ([]string, error) {
+	api.mediaCleanupMu.Lock()
+	defer api.mediaCleanupMu.Unlock()
+
+	removed := []string{}
+	cutoff := time.Now().Add(-api.mediaCleanupGrace)
+
+	for folder, exists := range api.mediaFolders() {
+		entries, err := os.ReadDir(filepath.Join(api.mediaDir, folder))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return removed, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				// Removed since the folder was listed
+				continue
+			}
+			if info.ModTime().After(cutoff) {
+				continue
+			}
+
+			path := filepath.Join(api.mediaDir, folder, entry.Name())
+			found, err := exists(path)
+			if err != nil {
+				return removed, err
+			}
+			if found {
+				continue
+			}
+
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("media cleanup: %v", err)
+				continue
+			}
+
+			log.Printf("media cleanup: removed orphaned file %s", path)
+			removed = append(removed, path)
+		}
+	}
+
+	return append(removed, api.cleanupResizedImages()...), nil
+}
+
+// cleanupResizedImages removes the resized copies of post images that were deleted
+func (api *API) cleanupResizedImages() []string {
+	removed := []string{}
+
+	for _, width := range resizeWidths {
+		entries, err := os.ReadDir(api.resizedDir(width))
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			if _, err := os.Stat(filepath.Join(api.mediaDir, "post", entry.Name())); !errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			path := filepath.Join(api.resizedDir(width), entry.Name())
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("media cleanup: %v", err)
+				continue
+			}
+			removed = append(removed, path)
+		}
+	}
+
+	return removed
+}
+
+// CleanupMedia runs the cleanup job right away, it waits for a run that's already in progress
+func (api *API) CleanupMedia(c *gin.Context) {
+	removed, err := api.cleanupOrphanedMedia()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionCleanupMedia, repository.AuditTargetSystem, 0, gin.H{"removed": len(removed)})
+
+	c.JSON(http.StatusOK, MediaCleanupResponse{Removed: removed})
+}
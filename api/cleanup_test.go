@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Media Cleanup Test", func() {
+	var (
+		postRepo  *mockPostRepo
+		auditRepo *mockAuditRepo
+		mediaDir  string
+		handler   http.Handler
+	)
+
+	writeFile := func(name string, age time.Duration) string {
+		path := filepath.Join(mediaDir, "post", name)
+		Expect(os.WriteFile(path, []byte("image"), 0644)).To(Succeed())
+		modified := time.Now().Add(-age)
+		Expect(os.Chtimes(path, modified, modified)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		mediaDir = GinkgoT().TempDir()
+		Expect(os.Mkdir(filepath.Join(mediaDir, "post"), 0755)).To(Succeed())
+
+		cfg := config.Default()
+		cfg.MediaDir = mediaDir
+		postRepo = &mockPostRepo{}
+		auditRepo = &mockAuditRepo{}
+		mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, user: &mockUserRepo{roles: map[int]string{3: "admin"}}, audit: auditRepo})
+		handler = mainAPI.Handler()
+	})
+
+	cleanup := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/media/cleanup", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	It("should remove only old files without a record", func() {
+		orphan := writeFile("1-orphan.jpg", 2*time.Hour)
+		used := writeFile("2-used.jpg", 2*time.Hour)
+		uploading := writeFile("3-uploading.jpg", time.Minute)
+		postRepo.imagePaths = []string{used}
+
+		w := cleanup(newToken(3, func(claims *api.Claims) { claims.Role = "admin" }))
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var response api.MediaCleanupResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Removed).To(Equal([]string{orphan}))
+
+		Expect(orphan).ToNot(BeAnExistingFile())
+		Expect(used).To(BeAnExistingFile())
+		Expect(uploading).To(BeAnExistingFile())
+
+		Expect(auditRepo.entries).To(HaveLen(1))
+		Expect(auditRepo.entries[0].ActorID).To(Equal(3))
+		Expect(auditRepo.entries[0].Action).To(Equal(repository.AuditActionCleanupMedia))
+		Expect(auditRepo.entries[0].Metadata).To(MatchJSON(`{"removed":1}`))
+	})
+
+	It("should be forbidden for users who aren't admin", func() {
+		orphan := writeFile("1-orphan.jpg", 2*time.Hour)
+
+		Expect(cleanup(newToken(1, nil)).Code).To(Equal(http.StatusForbidden))
+		Expect(orphan).To(BeAnExistingFile())
+		Expect(auditRepo.entries).To(BeEmpty())
+	})
+})
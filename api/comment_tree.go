@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCommentTreeDepth = 3
+	maxCommentTreeDepth     = 10
+)
+
+var errInvalidDepth = errors.New("Invalid Depth")
+
+// CommentTreeResponse is truncated when the post has more comments than were returned, the client loads the rest
+// through /api/comments. Replies deeper than the depth aren't nested either, total_reply still counts them
+type CommentTreeResponse struct {
+	Comments  []repository.Comment `json:"comments"`
+	Total     int                  `json:"total"`
+	Truncated bool                 `json:"truncated"`
+}
+
+// ReadCommentTree returns the comments of the post with their replies nested up to depth levels, top level included
+func (api API) ReadCommentTree(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	depth, err := strconv.Atoi(c.DefaultQuery("depth", strconv.Itoa(defaultCommentTreeDepth)))
+	if err != nil || depth < 1 || depth > maxCommentTreeDepth {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": errInvalidDepth.Error()})
+		return
+	}
+
+	limit, err := parseLimit(c, api.commentTreePage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := api.postAuthorOrAbort(c, postID); !ok {
+		return
+	}
+
+	comments, total, err := api.commentRepo.FetchCommentsOfPost(api.getUserIDAvoidPanic(c), postID, limit)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, CommentTreeResponse{
+		Comments:  buildCommentTree(comments, depth),
+		Total:     total,
+		Truncated: len(comments) < total,
+	})
+}
+
+// buildCommentTree nests the comments, ordered parents first, under the comment they reply to. Replies to a comment
+// that wasn't returned are left out
+func buildCommentTree(comments []repository.Comment, depth int) []repository.Comment {
+	replies := make(map[int][]int)
+	roots := []int{}
+	for i, comment := range comments {
+		if comment.ParentCommentID == nil {
+			roots = append(roots, i)
+			continue
+		}
+		replies[*comment.ParentCommentID] = append(replies[*comment.ParentCommentID], i)
+	}
+
+	var build func(i, level int) repository.Comment
+	build = func(i, level int) repository.Comment {
+		comment := comments[i]
+		comment.TotalReply = len(replies[comment.ID])
+		comment.Reply = []repository.Comment{}
+
+		if level < depth {
+			for _, reply := range replies[comment.ID] {
+				comment.Reply = append(comment.Reply, build(reply, level+1))
+			}
+		}
+		return comment
+	}
+
+	tree := make([]repository.Comment, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, build(root, 1))
+	}
+	return tree
+}
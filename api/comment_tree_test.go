@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Comment Tree API Test", func() {
+	var handler http.Handler
+
+	reply := func(id, parentID int) repository.Comment {
+		return repository.Comment{ID: id, PostID: 1, ParentCommentID: &parentID}
+	}
+
+	BeforeEach(func() {
+		// 1 > 2 > 3 > 4 and 5 > 6, ordered by id as the repository returns them
+		commentRepo := &mockCommentRepo{postComments: []repository.Comment{
+			{ID: 1, PostID: 1}, reply(2, 1), reply(3, 2), reply(4, 3), {ID: 5, PostID: 1}, reply(6, 5),
+		}}
+		mainAPI := newTestAPI(mockRepos{comment: commentRepo, post: &mockPostRepo{}, user: &mockUserRepo{}})
+		handler = mainAPI.Handler()
+	})
+
+	readTree := func(query string) (int, api.CommentTreeResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/post/1/comment-tree"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var response api.CommentTreeResponse
+		if w.Code == http.StatusOK {
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		}
+		return w.Code, response
+	}
+
+	It("should nest the replies up to the depth and keep counting the deeper ones", func() {
+		code, response := readTree("?depth=2")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(response.Total).To(Equal(6))
+		Expect(response.Truncated).To(BeFalse())
+
+		Expect(response.Comments).To(HaveLen(2))
+		first := response.Comments[0]
+		Expect(first.ID).To(Equal(1))
+		Expect(first.TotalReply).To(Equal(1))
+		Expect(first.Reply).To(HaveLen(1))
+
+		second := first.Reply[0]
+		Expect(second.ID).To(Equal(2))
+		Expect(second.TotalReply).To(Equal(1))
+		Expect(second.Reply).To(BeEmpty())
+
+		Expect(response.Comments[1].Reply[0].ID).To(Equal(6))
+	})
+
+	It("should say when the comments were truncated by the limit", func() {
+		code, response := readTree("?limit=4&depth=10")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(response.Total).To(Equal(6))
+		Expect(response.Truncated).To(BeTrue())
+		Expect(response.Comments).To(HaveLen(1))
+		Expect(response.Comments[0].Reply[0].Reply[0].Reply[0].ID).To(Equal(4))
+	})
+
+	It("should return 400 for a depth out of range", func() {
+		for _, depth := range []string{"0", "11", "deep"} {
+			code, _ := readTree("?depth=" + depth)
+			Expect(code).To(Equal(http.StatusBadRequest))
+		}
+	})
+})
+
+var _ = Describe("Comment Page API Test", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		commentRepo := &mockCommentRepo{postComments: []repository.Comment{{ID: 9, PostID: 1}, {ID: 8, PostID: 1}, {ID: 7, PostID: 1}}}
+		mainAPI := newTestAPI(mockRepos{comment: commentRepo, post: &mockPostRepo{}, user: &mockUserRepo{}})
+		handler = mainAPI.Handler()
+	})
+
+	readPage := func(query string) (int, api.CommentPageResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/post/1/comments"+query, nil))
+
+		var response api.CommentPageResponse
+		if w.Code == http.StatusOK {
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		}
+		return w.Code, response
+	}
+
+	It("should return the last comment as the cursor only when there are more", func() {
+		code, response := readPage("?limit=2")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(response.Comments).To(HaveLen(2))
+		Expect(response.NextCursor).To(Equal(&response.Comments[1].ID))
+
+		code, response = readPage("?before=10&limit=3")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(response.Comments).To(HaveLen(3))
+		Expect(response.NextCursor).To(BeNil())
+	})
+
+	It("should return 400 for an invalid cursor or both directions", func() {
+		for _, query := range []string{"?before=abc", "?after=0", "?before=3&after=5"} {
+			code, _ := readPage(query)
+			Expect(code).To(Equal(http.StatusBadRequest))
+		}
+	})
+})
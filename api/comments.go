@@ -1,10 +1,14 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/althafariq/discusspedia-be/config"
 	"github.com/althafariq/discusspedia-be/helper"
 	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/althafariq/discusspedia-be/service"
@@ -57,6 +61,94 @@ func (api *API) ReadAllComment(c *gin.Context) {
 	)
 }
 
+// CommentPageResponse has a next_cursor only when there are more comments in the same direction
+type CommentPageResponse struct {
+	Comments   []repository.Comment `json:"comments"`
+	NextCursor *int                 `json:"next_cursor"`
+}
+
+// ReadCommentPage pages through the comments of a post for infinite scroll. Without a cursor or with before it
+// loads older comments newest first, with after it loads the comments posted since, oldest first. The cursors are
+// comment ids, so comments posted in between don't shift the pages
+func (api API) ReadCommentPage(c *gin.Context) {
+	postID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	before, after := c.Query("before"), c.Query("after")
+	if before != "" && after != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "use either before or after"})
+		return
+	}
+
+	cursor := 0
+	if cursorQuery := before + after; cursorQuery != "" {
+		if cursor, err = strconv.Atoi(cursorQuery); err != nil || cursor < 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Cursor"})
+			return
+		}
+	}
+
+	limit, err := parseLimit(c, api.commentsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := api.postAuthorOrAbort(c, postID); !ok {
+		return
+	}
+
+	fetch := api.commentRepo.FetchCommentsBeforeCursor
+	if after != "" {
+		fetch = api.commentRepo.FetchCommentsAfterCursor
+	}
+
+	// One more than the limit tells whether there's a next page
+	comments, err := fetch(postID, cursor, limit+1, api.getUserIDAvoidPanic(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := CommentPageResponse{Comments: comments}
+	if len(comments) > limit {
+		response.Comments = comments[:limit]
+		response.NextCursor = &response.Comments[limit-1].ID
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (api API) ReadCommentsByAuthor(c *gin.Context) {
+	authorID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.userCommentsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comments, err := api.commentRepo.FetchCommentsByAuthor(authorID, api.getUserIDAvoidPanic(c), limit, offset, api.canSeeAnonymousAuthors(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
 func (api API) CreateComment(c *gin.Context) {
 	var createCommentRequest CreateCommentRequest
 	err := c.ShouldBind(&createCommentRequest)
@@ -76,7 +168,12 @@ func (api API) CreateComment(c *gin.Context) {
 		return
 	}
 
-	isCommentOK := service.GetValidationInstance().Validate(createCommentRequest.Comment)
+	if errs := validateCommentContent(&createCommentRequest.Comment); len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	isCommentOK := service.GetValidationInstance().Validate(createCommentRequest.Comment, requestLocales(c)...)
 	if !isCommentOK {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your comment contains bad words"})
 		return
@@ -88,9 +185,67 @@ func (api API) CreateComment(c *gin.Context) {
 		return
 	}
 
+	// Pending posts can't be commented on by anyone who can't see them
+	visible, err := api.postVisibleTo(c, createCommentRequest.PostID, userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !visible {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+		return
+	}
+
+	locked, err := api.postRepo.CommentsLocked(createCommentRequest.PostID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if locked {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "comments locked"})
+		return
+	}
+
+	depth, parentCommentID, ok := api.replyPlacement(c, createCommentRequest.PostID, createCommentRequest.ParentCommentID)
+	if !ok {
+		return
+	}
+
+	// A reply to someone who blocked the user, or a comment on their post, looks created to them but isn't stored
+	var recipientID int
+	if createCommentRequest.ParentCommentID != nil {
+		recipientID, err = api.commentRepo.FetchCommentAuthorId(*createCommentRequest.ParentCommentID)
+	} else {
+		recipientID, err = api.postRepo.FetchAuthorIDByPostID(createCommentRequest.PostID)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	blocked, err := api.followRepo.IsBlocked(recipientID, userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "Add Comment Successful",
+			"mentions":          []repository.Mention{},
+			"warnings":          service.ContentWarnings(createCommentRequest.Comment),
+			"depth":             depth,
+			"parent_comment_id": parentCommentID,
+		})
+		return
+	}
+
 	commentId, err := api.commentRepo.InsertComment(repository.Comment{
 		PostID:          createCommentRequest.PostID,
-		ParentCommentID: createCommentRequest.ParentCommentID,
+		ParentCommentID: parentCommentID,
 		AuthorID:        userID,
 		Comment:         createCommentRequest.Comment,
 	})
@@ -102,14 +257,81 @@ func (api API) CreateComment(c *gin.Context) {
 		return
 	}
 
-	api.notifRepo.CreateNotification(userID, int(commentId))
+	// The author of the comment replied to is notified even when the reply was flattened under an ancestor
+	api.notifyNewComment(userID, createCommentRequest.PostID, createCommentRequest.ParentCommentID, int(commentId))
+
+	commentID := int(commentId)
+	mentions := api.saveMentions(userID, createCommentRequest.PostID, &commentID, createCommentRequest.Comment, nil)
+
+	createdAt := time.Now()
+	newComment := repository.Comment{
+		ID:              commentID,
+		PostID:          createCommentRequest.PostID,
+		ParentCommentID: parentCommentID,
+		Comment:         createCommentRequest.Comment,
+		CreatedAt:       &createdAt,
+		AuthorID:        userID,
+		Reply:           []repository.Comment{},
+	}
+	if author, err := api.userRepo.GetUserData(userID); err == nil {
+		newComment.AuthorName = author.Name
+		newComment.AuthorAvatar = author.Avatar
+	}
+	api.commentHub.Publish(createCommentRequest.PostID, Event{Type: "comment_created", Data: newComment})
 
 	c.JSON(
 		http.StatusOK,
-		gin.H{"message": "Add Comment Successful"},
+		gin.H{
+			"message":           "Add Comment Successful",
+			"mentions":          mentions,
+			"warnings":          service.ContentWarnings(createCommentRequest.Comment),
+			"depth":             depth,
+			"parent_comment_id": parentCommentID,
+		},
 	)
 }
 
+// replyPlacement returns the depth of a new comment and the comment it's stored under. A reply deeper than
+// commentMaxDepth is attached to its deepest allowed ancestor, or refused when the depth mode is reject
+func (api API) replyPlacement(c *gin.Context, postID int, parentCommentID *int) (int, *int, bool) {
+	if parentCommentID == nil {
+		return 1, nil, true
+	}
+
+	parentPostID, path, err := api.commentRepo.FetchCommentPath(*parentCommentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return 0, nil, false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, nil, false
+	}
+
+	if parentPostID != postID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Parent comment belongs to another post"})
+		return 0, nil, false
+	}
+
+	depth := len(path) + 1
+	if depth <= api.commentMaxDepth {
+		return depth, parentCommentID, true
+	}
+
+	if api.commentDepthMode == config.CommentDepthReject {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Replies can be nested at most %d levels deep", api.commentMaxDepth),
+		})
+		return 0, nil, false
+	}
+
+	if api.commentMaxDepth == 1 {
+		return 1, nil, true
+	}
+	ancestorID := path[api.commentMaxDepth-2]
+	return api.commentMaxDepth, &ancestorID, true
+}
+
 func (api API) UpdateComment(c *gin.Context) {
 	var updateCommentRequest UpdateCommentRequest
 	err := c.ShouldBind(&updateCommentRequest)
@@ -129,7 +351,12 @@ func (api API) UpdateComment(c *gin.Context) {
 		return
 	}
 
-	isCommentOK := service.GetValidationInstance().Validate(updateCommentRequest.Comment)
+	if errs := validateCommentContent(&updateCommentRequest.Comment); len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	isCommentOK := service.GetValidationInstance().Validate(updateCommentRequest.Comment, requestLocales(c)...)
 	if !isCommentOK {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your comment contains bad words"})
 		return
@@ -172,9 +399,33 @@ func (api API) UpdateComment(c *gin.Context) {
 		return
 	}
 
+	postID, err := api.commentRepo.FetchCommentPostId(updateCommentRequest.CommentID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousMentions, err := api.mentionRepo.FetchCommentMentions(updateCommentRequest.CommentID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = api.mentionRepo.DeleteCommentMentions(updateCommentRequest.CommentID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	mentions := api.saveMentions(userID, postID, &updateCommentRequest.CommentID, updateCommentRequest.Comment, previousMentions)
+
 	c.JSON(
 		http.StatusOK,
-		gin.H{"message": "Update Comment Successful"},
+		gin.H{
+			"message":  "Update Comment Successful",
+			"mentions": mentions,
+			"warnings": service.ContentWarnings(updateCommentRequest.Comment),
+		},
 	)
 }
 
@@ -227,3 +478,83 @@ func (api *API) DeleteComment(c *gin.Context) {
 		gin.H{"message": "Delete Comment Successful"},
 	)
 }
+
+func (api *API) HighlightComment(c *gin.Context) {
+	api.setCommentHighlight(c, true)
+}
+
+func (api *API) UnhighlightComment(c *gin.Context) {
+	api.setCommentHighlight(c, false)
+}
+
+// setCommentHighlight lets the author of the post mark a top level comment as the best answer, which replaces the
+// previous one. The comment list shows it first
+func (api *API) setCommentHighlight(c *gin.Context, highlighted bool) {
+	commentID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	postID, err := api.commentRepo.FetchCommentPostId(commentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": repository.ErrCommentNotFound.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	postAuthorID, ok := api.postAuthorOrAbort(c, postID)
+	if !ok {
+		return
+	}
+	if postAuthorID != userID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only the author of the post can highlight a comment"})
+		return
+	}
+
+	if err := api.commentRepo.SetCommentHighlight(commentID, highlighted); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrCommentNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrCommentIsReply):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"is_highlighted": highlighted})
+}
+
+// notifyNewComment notifies the parent comment author about a reply and the post author about the comment,
+// without notifying the same user twice
+func (api API) notifyNewComment(actorID, postID int, parentCommentID *int, commentID int) {
+	parentAuthorID := 0
+	if parentCommentID != nil {
+		parentAuthorID, _ = api.commentRepo.FetchCommentAuthorId(*parentCommentID)
+		if parentAuthorID != 0 {
+			api.notifRepo.CreateNotification(parentAuthorID, actorID, repository.NotifTypeReply, commentID)
+		}
+	}
+
+	postAuthorID, err := api.postRepo.FetchAuthorIDByPostID(postID)
+	if err == nil && postAuthorID != parentAuthorID {
+		api.notifRepo.CreateNotification(postAuthorID, actorID, repository.NotifTypeComment, commentID)
+	}
+}
+
+// validateCommentContent trims the comment, so a whitespace only comment counts as empty
+func validateCommentContent(comment *string) []helper.JSONRequestErrorResponse {
+	return helper.ValidateTextFields(
+		helper.TextField{Name: "comment", Value: comment, Max: helper.MaxCommentLength, Required: true},
+	)
+}
@@ -0,0 +1,186 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Comment API Test", func() {
+	var (
+		commentRepo *mockCommentRepo
+		handler     http.Handler
+		token       string
+	)
+
+	BeforeEach(func() {
+		commentRepo = &mockCommentRepo{}
+		mainAPI := newTestAPI(mockRepos{comment: commentRepo, user: &mockUserRepo{}})
+		handler = mainAPI.Handler()
+		token = newToken(1, nil)
+	})
+
+	createComment := func(comment string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(map[string]interface{}{"post_id": 1, "comment": comment})
+		Expect(err).ToNot(HaveOccurred())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/comments", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	fieldErrors := func(w *httptest.ResponseRecorder) []helper.JSONRequestErrorResponse {
+		var response struct {
+			Errors []helper.JSONRequestErrorResponse `json:"errors"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		return response.Errors
+	}
+
+	Describe("CreateComment", func() {
+		When("comment is empty", func() {
+			It("should return 400 without inserting the comment", func() {
+				for _, comment := range []string{"", " \n\t "} {
+					w := createComment(comment)
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+				}
+				Expect(commentRepo.insertCommentCalls).To(Equal(0))
+
+				w := createComment("   ")
+				Expect(fieldErrors(w)).To(Equal([]helper.JSONRequestErrorResponse{{Field: "comment", Message: "This field is required"}}))
+			})
+		})
+
+		When("comment is too long", func() {
+			It("should return 400 without inserting the comment", func() {
+				w := createComment(strings.Repeat("a", helper.MaxCommentLength+1))
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(fieldErrors(w)).To(Equal([]helper.JSONRequestErrorResponse{
+					{Field: "comment", Message: fmt.Sprintf("Must be at most %d characters", helper.MaxCommentLength)},
+				}))
+				Expect(commentRepo.insertCommentCalls).To(Equal(0))
+			})
+		})
+
+		When("the post isn't visible to the user", func() {
+			It("should return 404 without inserting the comment", func() {
+				mainAPI := newTestAPI(mockRepos{comment: commentRepo, user: &mockUserRepo{}, post: &mockPostRepo{hidden: []int{1}}})
+				handler = mainAPI.Handler()
+
+				Expect(createComment("Hi").Code).To(Equal(http.StatusNotFound))
+				Expect(commentRepo.insertCommentCalls).To(Equal(0))
+			})
+		})
+
+		When("the author of the post blocked the user", func() {
+			It("should pretend the comment was added without inserting it", func() {
+				mainAPI := newTestAPI(mockRepos{
+					comment: commentRepo, user: &mockUserRepo{}, post: &mockPostRepo{}, follow: &mockFollowRepo{blockers: []int{1}},
+				})
+				handler = mainAPI.Handler()
+				token = newToken(2, nil)
+
+				w := createComment("Hi")
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(ContainSubstring("Add Comment Successful"))
+				Expect(commentRepo.insertCommentCalls).To(Equal(0))
+			})
+		})
+
+		Describe("replies", func() {
+			var notifRepo *mockNotifRepo
+
+			// Comment 4 is at depth 4: 1 <- 2 <- 3 <- 4
+			newAPI := func(cfg config.Config) {
+				commentRepo.parents = map[int]int{1: 0, 2: 1, 3: 2, 4: 3}
+				notifRepo = &mockNotifRepo{}
+				mainAPI := newTestAPIWithConfig(cfg, mockRepos{
+					comment: commentRepo, follow: &mockFollowRepo{}, notif: notifRepo, post: &mockPostRepo{},
+					user: &mockUserRepo{}, mention: &mockMentionRepo{},
+				})
+				handler = mainAPI.Handler()
+			}
+
+			reply := func(parentID int) *httptest.ResponseRecorder {
+				body := fmt.Sprintf(`{"post_id":1,"parent_comment_id":%d,"comment":"Reply"}`, parentID)
+				req := httptest.NewRequest(http.MethodPost, "/api/comments", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+token)
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				return w
+			}
+
+			decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+				var response map[string]interface{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				return response
+			}
+
+			It("should store a reply within the max depth under its parent", func() {
+				cfg := config.Default()
+				cfg.CommentMaxDepth = 5
+				newAPI(cfg)
+
+				w := reply(4)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(decode(w)).To(And(HaveKeyWithValue("depth", BeEquivalentTo(5)), HaveKeyWithValue("parent_comment_id", BeEquivalentTo(4))))
+				Expect(*commentRepo.inserted[0].ParentCommentID).To(Equal(4))
+			})
+
+			It("should attach a deeper reply to the deepest allowed ancestor and still notify the parent's author", func() {
+				cfg := config.Default()
+				cfg.CommentMaxDepth = 3
+				newAPI(cfg)
+
+				w := reply(4)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(decode(w)).To(And(HaveKeyWithValue("depth", BeEquivalentTo(3)), HaveKeyWithValue("parent_comment_id", BeEquivalentTo(2))))
+				Expect(*commentRepo.inserted[0].ParentCommentID).To(Equal(2))
+				Expect(notifRepo.created).To(ContainElement(repository.NotifTypeReply))
+			})
+
+			It("should store replies as top level comments when the max depth is 1", func() {
+				cfg := config.Default()
+				cfg.CommentMaxDepth = 1
+				newAPI(cfg)
+
+				w := reply(2)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(decode(w)).To(And(HaveKeyWithValue("depth", BeEquivalentTo(1)), HaveKeyWithValue("parent_comment_id", BeNil())))
+				Expect(commentRepo.inserted[0].ParentCommentID).To(BeNil())
+			})
+
+			It("should refuse a deeper reply in reject mode", func() {
+				cfg := config.Default()
+				cfg.CommentMaxDepth = 3
+				cfg.CommentDepthMode = config.CommentDepthReject
+				newAPI(cfg)
+
+				Expect(reply(2).Code).To(Equal(http.StatusOK))
+				Expect(reply(3).Code).To(Equal(http.StatusBadRequest))
+				Expect(commentRepo.insertCommentCalls).To(Equal(1))
+			})
+
+			It("should return 404 for a parent that doesn't exist", func() {
+				newAPI(config.Default())
+
+				Expect(reply(9).Code).To(Equal(http.StatusNotFound))
+				Expect(commentRepo.insertCommentCalls).To(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithETag tags the response with a hash of the whole serialized body, so any change that alters
+// the payload (edits, images, like/comment counts, the viewer's is_like) produces a new ETag.
+// A matching If-None-Match gets a 304 without a body.
+func respondWithETag(ctx *gin.Context, body interface{}) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(payload))
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "no-cache")
+
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", payload)
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type UserExportResponse struct {
+	ExportedAt      time.Time                  `json:"exported_at"`
+	Profile         repository.User            `json:"profile"`
+	Posts           []repository.Post          `json:"posts"`
+	Comments        []repository.Comment       `json:"comments"`
+	Questionnaires  []repository.Questionnaire `json:"questionnaires"`
+	LikedPostIDs    []int                      `json:"liked_post_ids"`
+	LikedCommentIDs []int                      `json:"liked_comment_ids"`
+}
+
+func (api *API) ExportUserData(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if allowed, retryAfter := api.exportLimiter.Allow(strconv.Itoa(userID)); !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many export requests, please try again later"})
+		return
+	}
+
+	profile, err := api.userRepo.GetUserData(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	posts, err := api.postRepo.FetchPostsByAuthor(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	comments, err := api.commentRepo.FetchAllCommentsByAuthor(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	questionnaires, err := api.questionnaireRepo.ReadAllQuestionnaires(userID, fmt.Sprintf("author_id = %d", userID), "created_at", -1, 0)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	likedPostIDs, err := api.likeRepo.FetchLikedPostIDs(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	likedCommentIDs, err := api.likeRepo.FetchLikedCommentIDs(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="discusspedia-export-%d.json"`, userID))
+	c.JSON(http.StatusOK, UserExportResponse{
+		ExportedAt:      time.Now(),
+		Profile:         *profile,
+		Posts:           posts,
+		Comments:        comments,
+		Questionnaires:  questionnaires,
+		LikedPostIDs:    likedPostIDs,
+		LikedCommentIDs: likedCommentIDs,
+	})
+}
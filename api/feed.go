@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	feedHeartbeatPeriod = 15 * time.Second
+	feedResumeLimit     = 100
+)
+
+// StreamFeed pushes posts of followed users as server-sent events, the event id is the post id
+// so a reconnecting client can resume through the Last-Event-ID header
+func (api API) StreamFeed(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lastEventID := 0
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		lastEventID, err = strconv.Atoi(header)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	sub := api.feedHub.Subscribe(userID)
+	defer api.feedHub.Unsubscribe(userID, sub)
+
+	// Subscribe before looking up missed posts so nothing published in between gets lost
+	var missedPosts []repository.FeedPost
+	if lastEventID > 0 {
+		missedPosts, err = api.postRepo.FetchFollowingPostsAfter(userID, lastEventID, feedResumeLimit)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, post := range missedPosts {
+		if err := writeFeedEvent(c, Event{ID: post.ID, Type: "post_created", Data: post}); err != nil {
+			return
+		}
+		lastEventID = post.ID
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(feedHeartbeatPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.send:
+			if !ok {
+				return
+			}
+
+			if event.ID <= lastEventID {
+				continue
+			}
+			lastEventID = event.ID
+
+			if err := writeFeedEvent(c, event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeFeedEvent(c *gin.Context, event Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// publishNewPost notifies the followers, in their notifications and on their feed stream, and the webhooks of the
+// category about a freshly created post. The webhooks get an anonymous post without its author and the followers
+// don't hear about it
+func (api API) publishNewPost(authorID, postID, categoryID int, title string, anonymous bool) {
+	post := repository.FeedPost{
+		ID:         postID,
+		AuthorID:   authorID,
+		CategoryID: categoryID,
+		Title:      title,
+		CreatedAt:  time.Now(),
+	}
+
+	if anonymous {
+		post.AuthorID, post.AuthorName = 0, anonymousAuthorName
+		go api.deliverWebhooks(repository.WebhookEventPostCreated, post)
+		return
+	}
+
+	if author, err := api.userRepo.GetUserData(authorID); err == nil {
+		post.AuthorName = author.Name
+	}
+
+	go api.deliverWebhooks(repository.WebhookEventPostCreated, post)
+
+	followerIDs, err := api.followRepo.FetchFollowerIDs(authorID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, followerID := range followerIDs {
+		api.feedHub.Publish(followerID, Event{ID: postID, Type: "post_created", Data: post})
+	}
+
+	// A popular author can have thousands of followers, the response doesn't wait for their notifications
+	if len(followerIDs) > 0 {
+		go func() {
+			if err := api.notifRepo.CreateNotifications(followerIDs, authorID, repository.NotifTypeNewPost, postID); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+}
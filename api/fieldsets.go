@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// postListFields are the fields of the full shape of GET /api/post that ?fields= can select
+var postListFields = fieldSet(
+	"id", "is_like", "is_author", "author", "category_id", "title", "description", "description_html",
+	"description_snippet", "created_at", "updated_at", "is_pinned", "comments_locked", "comment_count", "like_count",
+	"moderation_status", "is_anonymous", "images",
+)
+
+// postDetailFields are the fields of GET /api/post/:id, it has the mentions but no snippet
+var postDetailFields = fieldSet(
+	"id", "is_like", "is_author", "author", "category_id", "title", "description", "description_html",
+	"created_at", "updated_at", "is_pinned", "comments_locked", "comment_count", "like_count", "moderation_status",
+	"is_anonymous", "images", "mentions",
+)
+
+func fieldSet(fields ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		set[field] = struct{}{}
+	}
+	return set
+}
+
+// parseFields reads a comma separated list of field names, rejecting the ones that aren't allowed
+func parseFields(query string, allowed map[string]struct{}) ([]string, error) {
+	names := strings.Split(query, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if _, ok := allowed[names[i]]; !ok {
+			return nil, fmt.Errorf("Unknown Field %q", names[i])
+		}
+	}
+	return names, nil
+}
+
+// selectFields serializes body, a struct or a slice of structs, and keeps only the fields of every object
+func selectFields(body interface{}, fields []string) (interface{}, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(string(payload), "[") {
+		var objects []map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &objects); err != nil {
+			return nil, err
+		}
+
+		selected := make([]map[string]json.RawMessage, len(objects))
+		for i, object := range objects {
+			selected[i] = pickFields(object, fields)
+		}
+		return selected, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return nil, err
+	}
+	return pickFields(object, fields), nil
+}
+
+// pickFields leaves out the requested fields the object doesn't have, like an omitted description_snippet
+func pickFields(object map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := object[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
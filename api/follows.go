@@ -0,0 +1,151 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+func (api API) FollowUser(c *gin.Context) {
+	followingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if followingID == userID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "You can't follow yourself"})
+		return
+	}
+
+	if _, err := api.userRepo.GetUserRole(followingID); err != nil {
+		if err == sql.ErrNoRows {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	isExist, err := api.followRepo.CheckFollowIsExist(userID, followingID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if isExist {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "You already follow this user"})
+		return
+	}
+
+	// A blocked user isn't told about the block, the follow just isn't stored
+	blocked, err := api.followRepo.IsBlocked(followingID, userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusOK, gin.H{"message": "Follow User Successful"})
+		return
+	}
+
+	err = api.followRepo.InsertFollow(userID, followingID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.notifRepo.CreateNotification(followingID, userID, repository.NotifTypeFollow, userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Follow User Successful"})
+}
+
+func (api API) UnfollowUser(c *gin.Context) {
+	followingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isExist, err := api.followRepo.CheckFollowIsExist(userID, followingID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !isExist {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "No data with given id"})
+		return
+	}
+
+	err = api.followRepo.DeleteFollow(userID, followingID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unfollow User Successful"})
+}
+
+func (api API) ReadFollowers(c *gin.Context) {
+	api.respondWithFollowUsers(c, api.followRepo.FetchFollowers)
+}
+
+func (api API) ReadFollowing(c *gin.Context) {
+	api.respondWithFollowUsers(c, api.followRepo.FetchFollowing)
+}
+
+// respondWithFollowUsers pages through one side of the follows of the user, search narrows it down by name
+func (api API) respondWithFollowUsers(c *gin.Context, fetch func(userID, viewerID int, namePattern string, limit, offset int) ([]repository.UserCard, error)) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.followsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exists, err := api.userRepo.UserExists(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+		return
+	}
+
+	pattern := "%" + likeEscaper.Replace(strings.TrimSpace(c.Query("search"))) + "%"
+
+	users, err := fetch(userID, api.getUserIDAvoidPanic(c), pattern, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"sync"
+)
+
+type Event struct {
+	ID   int         `json:"-"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type subscriber struct {
+	send chan Event
+}
+
+// hub is an in-process pub/sub, events are published to every subscriber of a key (post id, user id, ...)
+type hub struct {
+	mu          sync.RWMutex
+	subscribers map[int]map[*subscriber]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: make(map[int]map[*subscriber]struct{}),
+	}
+}
+
+func (h *hub) Subscribe(key int) *subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &subscriber{send: make(chan Event, 16)}
+	if _, ok := h.subscribers[key]; !ok {
+		h.subscribers[key] = make(map[*subscriber]struct{})
+	}
+	h.subscribers[key][sub] = struct{}{}
+
+	return sub
+}
+
+func (h *hub) Unsubscribe(key int, sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[key][sub]; !ok {
+		return
+	}
+
+	delete(h.subscribers[key], sub)
+	if len(h.subscribers[key]) == 0 {
+		delete(h.subscribers, key)
+	}
+	close(sub.send)
+}
+
+// Publish never blocks, slow clients simply miss the event
+func (h *hub) Publish(key int, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers[key] {
+		select {
+		case sub.send <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyScopePost          = "post"
+	idempotencyScopeQuestionnaire = "questionnaire"
+)
+
+// beginIdempotentRequest reserves the Idempotency-Key header, if any. It returns false when the response has
+// already been written: either the replayed response of the original request or a conflict while it's in flight
+func (api *API) beginIdempotentRequest(c *gin.Context, userID int, scope string) bool {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return true
+	}
+
+	if len(key) > 255 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return false
+	}
+
+	reserved, err := api.idempotencyRepo.ReserveIdempotencyKey(userID, scope, key)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if reserved {
+		return true
+	}
+
+	record, err := api.idempotencyRepo.FetchIdempotencyKey(userID, scope, key)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if record.Response == nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		return false
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", record.Response)
+	return false
+}
+
+// completeIdempotentRequest stores the response for later retries and writes it
+func (api *API) completeIdempotentRequest(c *gin.Context, userID int, scope string, resourceID int, response interface{}) {
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		body, err := json.Marshal(response)
+		if err == nil {
+			err = api.idempotencyRepo.CompleteIdempotencyKey(userID, scope, key, resourceID, body)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// releaseIdempotentRequest lets the client retry a request that failed after reserving its key
+func (api *API) releaseIdempotentRequest(c *gin.Context, userID int, scope string) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return
+	}
+
+	if err := api.idempotencyRepo.ReleaseIdempotencyKey(userID, scope, key); err != nil {
+		log.Println(err)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -24,10 +25,7 @@ func (api API) CreatePostLike(c *gin.Context) {
 		return
 	}
 
-	isExist, err := api.likeRepo.CheckPostLikeIsExist(repository.PostLike{
-		PostID: postID,
-		UserID: userID,
-	})
+	liked, err := api.likeRepo.LikePost(postID, userID)
 	if err != nil {
 		c.AbortWithStatusJSON(
 			http.StatusInternalServerError,
@@ -35,7 +33,7 @@ func (api API) CreatePostLike(c *gin.Context) {
 		)
 		return
 	}
-	if isExist {
+	if !liked {
 		c.AbortWithStatusJSON(
 			http.StatusBadRequest,
 			gin.H{"error": "User with given id already like this post"},
@@ -43,18 +41,15 @@ func (api API) CreatePostLike(c *gin.Context) {
 		return
 	}
 
-	err = api.likeRepo.InsertPostLike(repository.PostLike{
-		PostID: postID,
-		UserID: userID,
-	})
-	if err != nil {
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			gin.H{"error": err.Error()},
-		)
-		return
+	if authorID, err := api.postRepo.FetchAuthorIDByPostID(postID); err == nil {
+		api.notifRepo.CreateNotification(authorID, userID, repository.NotifTypePostLike, postID)
 	}
 
+	api.commentHub.Publish(postID, Event{
+		Type: "post_liked",
+		Data: gin.H{"post_id": postID, "user_id": userID},
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Add Post Like Successful",
 	})
@@ -76,10 +71,8 @@ func (api API) DeletePostLike(c *gin.Context) {
 		return
 	}
 
-	isExist, err := api.likeRepo.CheckPostLikeIsExist(repository.PostLike{
-		PostID: postID,
-		UserID: userID,
-	})
+	// Checking first and deleting after would let a second request through in between
+	cleared, err := api.likeRepo.ClearPostReaction(postID, userID)
 	if err != nil {
 		c.AbortWithStatusJSON(
 			http.StatusInternalServerError,
@@ -87,7 +80,7 @@ func (api API) DeletePostLike(c *gin.Context) {
 		)
 		return
 	}
-	if !isExist {
+	if !cleared {
 		c.AbortWithStatusJSON(
 			http.StatusBadRequest,
 			gin.H{"error": "No data with given id"},
@@ -95,18 +88,6 @@ func (api API) DeletePostLike(c *gin.Context) {
 		return
 	}
 
-	err = api.likeRepo.DeletePostLike(repository.PostLike{
-		PostID: postID,
-		UserID: userID,
-	})
-	if err != nil {
-		c.AbortWithStatusJSON(
-			http.StatusInternalServerError,
-			gin.H{"error": err.Error()},
-		)
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Delete Post Like Successful",
 	})
@@ -159,6 +140,17 @@ func (api API) CreateCommentLike(c *gin.Context) {
 		return
 	}
 
+	if authorID, err := api.commentRepo.FetchCommentAuthorId(commentID); err == nil && authorID != 0 {
+		api.notifRepo.CreateNotification(authorID, userID, repository.NotifTypeCommentLike, commentID)
+	}
+
+	if postID, err := api.commentRepo.FetchCommentPostId(commentID); err == nil {
+		api.commentHub.Publish(postID, Event{
+			Type: "comment_liked",
+			Data: gin.H{"comment_id": commentID, "user_id": userID},
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Add Comment Like Successful",
 	})
@@ -215,3 +207,50 @@ func (api API) DeleteCommentLike(c *gin.Context) {
 		"message": "Delete Comment Like Successful",
 	})
 }
+
+// ReadLikedPosts is forbidden for everyone but the owner when the user keeps their likes private
+func (api API) ReadLikedPosts(c *gin.Context) {
+	viewerID := api.getUserIDAvoidPanic(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	privacy, err := api.userRepo.GetPrivacySettings(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !privacy.LikesPublic && viewerID != userID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This user's likes are private"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.postsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	posts, err := api.postRepo.FetchAllPost(limit, offset, viewerID, "created_at DESC",
+		"AND EXISTS (SELECT 1 FROM post_reactions WHERE post_id = p.id AND user_id = ?)", userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildPostListResponse(posts, viewerID, api.canSeeAnonymousAuthors(c)))
+}
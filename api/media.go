@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every upload gets a new name, so a cached file never changes
+const mediaCacheControl = "public, max-age=31536000, immutable"
+
+// CreateMediaDirs creates the folders of every kind of upload under the media dir, it's called once before the server
+// starts so the upload handlers can write to them right away
+func (api *API) CreateMediaDirs() error {
+	for folder := range api.mediaFolders() {
+		if err := os.MkdirAll(filepath.Join(api.mediaDir, folder), os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	for _, width := range resizeWidths {
+		if err := os.MkdirAll(api.resizedDir(width), os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mediaURL is the URL an upload stored at storedPath is served under, media/<folder>/<file> whatever MEDIA_DIR is.
+// It's relative to the host like the image URLs have always been
+func mediaURL(folder, storedPath string) string {
+	return path.Join("media", folder, url.PathEscape(filepath.Base(storedPath)))
+}
+
+// servePostImage serves a smaller copy of the image when the w query param is given, see resizedImage
+func (api *API) servePostImage(c *gin.Context) {
+	if c.Query("w") == "" {
+		api.serveMedia(c, "post", api.postRepo.PostImageExists, mediaCacheControl)
+		return
+	}
+
+	width, err := parseResizeWidth(c.Query("w"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path, ok := api.mediaPathOrAbort(c, "post", api.postRepo.PostImageExists)
+	if !ok {
+		return
+	}
+
+	resized, err := api.resizedImage(path, width)
+	if err != nil {
+		log.Printf("resize %s: %v", path, err)
+		resized = path
+	}
+
+	serveMediaFile(c, resized, mediaCacheControl)
+}
+
+func (api *API) serveQuestionnaireImage(c *gin.Context) {
+	api.serveMedia(c, "questionnaire", api.questionnaireRepo.QuestionnaireImageExists, mediaCacheControl)
+}
+
+func (api *API) serveAvatar(c *gin.Context) {
+	api.serveMedia(c, "avatar", api.userRepo.AvatarExists, mediaCacheControl)
+}
+
+// serveMedia only streams files that still have a record, looked up by the same path the upload stored
+func (api *API) serveMedia(c *gin.Context, folder string, exists func(path string) (bool, error), cacheControl string) {
+	if path, ok := api.mediaPathOrAbort(c, folder, exists); ok {
+		serveMediaFile(c, path, cacheControl)
+	}
+}
+
+// mediaPathOrAbort returns the path of the filename param, it responds with 400 or 404 when the name isn't a plain
+// file name or no record points to it
+func (api *API) mediaPathOrAbort(c *gin.Context, folder string, exists func(path string) (bool, error)) (string, bool) {
+	filename := c.Param("filename")
+	if filename == "" || strings.Contains(filename, "..") || strings.ContainsAny(filename, `/\`) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Filename"})
+		return "", false
+	}
+
+	path := filepath.Join(api.mediaDir, folder, filename)
+
+	found, err := exists(path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if !found {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File Not Found"})
+		return "", false
+	}
+
+	return path, true
+}
+
+func serveMediaFile(c *gin.Context, path, cacheControl string) {
+	filename := filepath.Base(path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File Not Found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType, err := mediaContentType(file)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Uploads are only checked by their Content-Type header, anything that doesn't sniff as an image is
+	// downloaded instead of rendered so an uploaded HTML file can't run in our origin
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = "application/octet-stream"
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(c.Writer, c.Request, filename, info.ModTime(), file)
+}
+
+// mediaContentType sniffs the content instead of trusting the extension, which would let an .svg with scripts render
+func mediaContentType(file io.ReadSeeker) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
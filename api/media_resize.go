@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/althafariq/discusspedia-be/service"
+)
+
+// resizeWidths are the only widths images are resized to, any other width is rounded up to one of them so the cache
+// holds at most one copy of an image per width
+var resizeWidths = []int{200, 400, 800, 1200}
+
+// maxResizePixels keeps a small file that decodes to a huge image from using up the memory
+const maxResizePixels = 40_000_000
+
+var errInvalidWidth = errors.New("Invalid Width")
+
+// parseResizeWidth rounds the width up to the next allowed one, wider requests get the widest
+func parseResizeWidth(query string) (int, error) {
+	width, err := strconv.Atoi(query)
+	if err != nil || width < 1 {
+		return 0, errInvalidWidth
+	}
+
+	for _, allowed := range resizeWidths {
+		if width <= allowed {
+			return allowed, nil
+		}
+	}
+	return resizeWidths[len(resizeWidths)-1], nil
+}
+
+func (api *API) resizedDir(width int) string {
+	return filepath.Join(api.mediaDir, "resized", strconv.Itoa(width))
+}
+
+// resizedImage returns the path of the image scaled down to width, generating it on the first request. The original
+// is returned as is when it isn't wider than that, and for GIFs which would lose their animation
+func (api *API) resizedImage(path string, width int) (string, error) {
+	cached := filepath.Join(api.resizedDir(width), filepath.Base(path))
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return "", err
+	}
+	if format == "gif" || config.Width <= width {
+		return path, nil
+	}
+	if config.Width*config.Height > maxResizePixels {
+		return "", errors.New("image is too large to resize")
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return "", err
+	}
+	resized := service.ResizeImage(src, width)
+
+	// Concurrent requests for the same copy each write their own temp file, the last rename wins
+	tmp, err := os.CreateTemp(api.resizedDir(width), ".resize-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if format == "jpeg" {
+		err = jpeg.Encode(tmp, resized, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(tmp, resized)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), cached); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
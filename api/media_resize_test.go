@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/althafariq/discusspedia-be/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resized Media Test", func() {
+	var (
+		mediaDir string
+		handler  http.Handler
+	)
+
+	BeforeEach(func() {
+		cfg := config.Default()
+		cfg.MediaDir = GinkgoT().TempDir()
+		mediaDir = cfg.MediaDir
+
+		mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: &mockPostRepo{imagePaths: []string{
+			filepath.Join(mediaDir, "post", "1-photo.png"),
+			filepath.Join(mediaDir, "post", "2-broken.png"),
+		}}, user: &mockUserRepo{}})
+		Expect(mainAPI.CreateMediaDirs()).To(Succeed())
+		handler = mainAPI.Handler()
+
+		photo := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+		for x := 0; x < 1000; x++ {
+			photo.Set(x, 0, color.RGBA{R: 255, A: 255})
+		}
+		file, err := os.Create(filepath.Join(mediaDir, "post", "1-photo.png"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(png.Encode(file, photo)).To(Succeed())
+		Expect(file.Close()).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(mediaDir, "post", "2-broken.png"), []byte("\x89PNG\r\n\x1a\nbroken"), 0644)).To(Succeed())
+	})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	decodedWidth := func(body []byte) int {
+		config, _, err := image.DecodeConfig(bytes.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		return config.Width
+	}
+
+	It("should round the width up to an allowed one and cache the copy", func() {
+		w := get("/media/post/1-photo.png?w=300")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Cache-Control")).To(ContainSubstring("immutable"))
+		Expect(decodedWidth(w.Body.Bytes())).To(Equal(400))
+		Expect(filepath.Join(mediaDir, "resized", "400", "1-photo.png")).To(BeAnExistingFile())
+
+		Expect(decodedWidth(get("/media/post/1-photo.png?w=400").Body.Bytes())).To(Equal(400))
+	})
+
+	It("should serve the original when it isn't wider or can't be resized", func() {
+		Expect(decodedWidth(get("/media/post/1-photo.png?w=5000").Body.Bytes())).To(Equal(1000))
+
+		w := get("/media/post/2-broken.png?w=200")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(HaveSuffix("broken"))
+	})
+
+	It("should reject invalid widths and unknown files", func() {
+		Expect(get("/media/post/1-photo.png?w=wide").Code).To(Equal(http.StatusBadRequest))
+		Expect(get("/media/post/1-photo.png?w=0").Code).To(Equal(http.StatusBadRequest))
+		Expect(get("/media/post/3-missing.png?w=200").Code).To(Equal(http.StatusNotFound))
+	})
+})
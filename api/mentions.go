@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/althafariq/discusspedia-be/service"
+	"github.com/gin-gonic/gin"
+)
+
+// mentionContextRadius is how much of the text around a mention is shown in the mentions list, in characters
+const mentionContextRadius = 80
+
+// UserMentionResponse is a mention of the authenticated user, Context is the text around the mention and Link the post
+// it's in, with the comment as the fragment when it's in a comment
+type UserMentionResponse struct {
+	repository.UserMention
+	Context string `json:"context"`
+	Link    string `json:"link"`
+}
+
+type MentionListResponse struct {
+	Mentions []UserMentionResponse `json:"mentions"`
+	Limit    int                   `json:"limit"`
+}
+
+// saveMentions resolves @name tokens against users, unknown names are left as plain text.
+// Only users that weren't already mentioned in the previous version of the text get notified.
+func (api API) saveMentions(authorID, postID int, commentID *int, text string, previous []repository.Mention) []repository.Mention {
+	alreadyMentioned := make(map[int]struct{})
+	for _, mention := range previous {
+		alreadyMentioned[mention.UserID] = struct{}{}
+	}
+
+	mentions := make([]repository.Mention, 0)
+	for _, token := range service.ParseMentions(text) {
+		userID, userName, err := api.mentionRepo.FetchUserByName(token.Name)
+		if err != nil {
+			continue
+		}
+
+		mentions = append(mentions, repository.Mention{
+			UserID:    userID,
+			UserName:  userName,
+			AuthorID:  authorID,
+			PostID:    postID,
+			CommentID: commentID,
+			Start:     token.Start,
+			End:       token.End,
+		})
+	}
+
+	if len(mentions) == 0 {
+		return mentions
+	}
+
+	if err := api.mentionRepo.InsertMentions(mentions); err != nil {
+		log.Println(err)
+		return []repository.Mention{}
+	}
+
+	notifType, targetID := repository.NotifTypePostMention, postID
+	if commentID != nil {
+		notifType, targetID = repository.NotifTypeCommentMention, *commentID
+	}
+
+	for _, mention := range mentions {
+		if _, ok := alreadyMentioned[mention.UserID]; ok {
+			continue
+		}
+		alreadyMentioned[mention.UserID] = struct{}{}
+		api.notifRepo.CreateNotification(mention.UserID, authorID, notifType, targetID)
+	}
+
+	return mentions
+}
+
+// ReadMyMentions lists the posts and comments mentioning the authenticated user, newest first. Reading the notification
+// of a mention marks it as read
+func (api API) ReadMyMentions(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.mentionsPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mentions, err := api.mentionRepo.FetchUserMentions(userID, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]UserMentionResponse, len(mentions))
+	for i, mention := range mentions {
+		link := fmt.Sprintf("%s/post/%d", apiV1Prefix, mention.PostID)
+		if mention.CommentID != nil {
+			link += fmt.Sprintf("#comment-%d", *mention.CommentID)
+		}
+
+		response[i] = UserMentionResponse{
+			UserMention: mention,
+			Context:     service.MentionContext(mention.Content, mention.Start, mention.End, mentionContextRadius),
+			Link:        link,
+		}
+	}
+
+	c.JSON(http.StatusOK, MentionListResponse{Mentions: response, Limit: limit})
+}
+
+func (api API) ReadAllMentions(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = api.notifRepo.SetReadNotificationsOfTypes(userID, repository.NotifTypePostMention, repository.NotifTypeCommentMention)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success"})
+}
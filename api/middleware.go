@@ -1,17 +1,26 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
 type AuthErrorResponse struct {
 	Error string `json:"error"`
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+type BannedErrorResponse struct {
+	Error       string    `json:"error"`
+	Reason      string    `json:"reason"`
+	BannedUntil time.Time `json:"banned_until"`
+}
+
+func (api *API) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
@@ -21,26 +30,144 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString = tokenString[(len("Bearer ")):]
 
-		token, err := ValidateToken(tokenString)
+		token, err := api.ValidateToken(tokenString)
 		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, AuthErrorResponse{Error: "Invalid token"})
+			var tokenErr *TokenError
+			if errors.As(err, &tokenErr) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, AuthErrorResponse{Error: tokenErr.Reason})
 				return
 			}
 			c.AbortWithStatusJSON(http.StatusBadRequest, AuthErrorResponse{Error: "Bad Request"})
 			return
 		}
 
-		if token.Valid {
-			// claims := token.Claims.(*Claims)
-			// fmt.Println(claims.Email)
-			c.Next()
-		} else {
+		claims := token.Claims.(*Claims)
+		if !api.checkAccountActive(c, claims.Id) {
+			return
+		}
+
+		api.trackActivity(claims.Id)
+
+		c.Next()
+	}
+}
+
+// checkAccountActive aborts the request with 403 when the user is banned, and with 401 when the account was deleted
+// since the token was issued
+func (api *API) checkAccountActive(c *gin.Context, userID int) bool {
+	ban, err := api.userRepo.GetActiveBan(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, AuthErrorResponse{Error: "Invalid token"})
+			return false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, AuthErrorResponse{Error: err.Error()})
+		return false
+	}
+
+	if ban != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, BannedErrorResponse{
+			Error:       "Your account is suspended",
+			Reason:      ban.Reason,
+			BannedUntil: ban.BannedUntil,
+		})
+		return false
+	}
+
+	return true
+}
+
+// categoryModeratorKey is set on the context when the user got through RequireScopedRole as a category moderator
+// rather than by role, handlers use it to limit what they show to the categories of the user
+const categoryModeratorKey = "category_moderator"
+
+// RoleScope tells whether the user may moderate what the request acts on without having one of the roles. It aborts
+// the request itself when it can't tell
+type RoleScope func(c *gin.Context, userID int) bool
+
+// RequireRole must be registered after AuthMiddleware so the token is already validated. The role is read from the
+// database, not from the token
+func (api *API) RequireRole(roles ...string) gin.HandlerFunc {
+	return api.RequireScopedRole(nil, roles...)
+}
+
+// RequireScopedRole is RequireRole that also lets in the users the scope allows, like the moderators of the category
+// of a post
+func (api *API) RequireScopedRole(scope RoleScope, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := api.ValidateToken(c.GetHeader("Authorization")[(len("Bearer ")):])
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, AuthErrorResponse{Error: "Invalid token"})
+			return
+		}
+
+		// The role of the token may be stale, a demoted admin or moderator loses the access right away
+		claims := token.Claims.(*Claims)
+		currentRole, err := api.userRepo.GetUserRole(claims.Id)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, AuthErrorResponse{Error: err.Error()})
+			return
+		}
+
+		for _, role := range roles {
+			if *currentRole == role {
+				c.Next()
+				return
+			}
+		}
+
+		if scope != nil {
+			if scope(c, claims.Id) {
+				c.Set(categoryModeratorKey, true)
+				c.Next()
+				return
+			}
+			if c.IsAborted() {
+				return
+			}
 		}
 
+		c.AbortWithStatusJSON(http.StatusForbidden, AuthErrorResponse{Error: "Forbidden"})
 	}
 }
 
+// postCategoryScope lets in the moderators of the category of the post in the id param
+func (api *API) postCategoryScope(c *gin.Context, userID int) bool {
+	postID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return false
+	}
+
+	categoryID, err := api.postRepo.FetchPostCategoryID(postID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, AuthErrorResponse{Error: "Post Not Found"})
+			return false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, AuthErrorResponse{Error: err.Error()})
+		return false
+	}
+
+	return api.isCategoryModeratorOrAbort(c, userID, categoryID)
+}
 
+// anyCategoryScope lets in the users moderating at least one category, for the endpoints that aren't about one post
+func (api *API) anyCategoryScope(c *gin.Context, userID int) bool {
+	categoryIDs, err := api.categoryRepo.FetchModeratedCategoryIDs(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, AuthErrorResponse{Error: err.Error()})
+		return false
+	}
+
+	return len(categoryIDs) > 0
+}
 
+func (api *API) isCategoryModeratorOrAbort(c *gin.Context, userID, categoryID int) bool {
+	moderator, err := api.categoryRepo.IsCategoryModerator(userID, categoryID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, AuthErrorResponse{Error: err.Error()})
+		return false
+	}
+
+	return moderator
+}
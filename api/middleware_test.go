@@ -0,0 +1,149 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scoped Role Test", func() {
+	var (
+		handler http.Handler
+		audit   *mockAuditRepo
+	)
+
+	BeforeEach(func() {
+		audit = &mockAuditRepo{}
+		mainAPI := newTestAPI(mockRepos{
+			post: &mockPostRepo{posts: []repository.Post{{ID: 1, CategoryID: 1}, {ID: 2, CategoryID: 2}}},
+			user: &mockUserRepo{roles: map[int]string{3: "moderator"}},
+			// User 2 moderates the first category
+			category: &mockCategoryRepo{moderators: map[int]int{2: 1}},
+			audit:    audit,
+		})
+		handler = mainAPI.Handler()
+	})
+
+	pin := func(postID string, userID int, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/post/"+postID+"/pin", nil)
+		req.Header.Set("Authorization", "Bearer "+newToken(userID, func(claims *api.Claims) { claims.Role = role }))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	It("should let category moderators pin the posts of their category only", func() {
+		Expect(pin("1", 2, "mahasiswa").Code).To(Equal(http.StatusOK))
+		Expect(pin("2", 2, "mahasiswa").Code).To(Equal(http.StatusForbidden))
+		Expect(pin("3", 2, "mahasiswa").Code).To(Equal(http.StatusNotFound))
+		Expect(pin("1", 1, "mahasiswa").Code).To(Equal(http.StatusForbidden))
+		Expect(audit.entries).To(HaveLen(1))
+	})
+
+	It("should let the roles through without checking the category", func() {
+		Expect(pin("2", 3, "moderator").Code).To(Equal(http.StatusOK))
+		Expect(pin("3", 3, "moderator").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should go by the current role rather than the one in the token", func() {
+		// User 4 was demoted since their token was issued
+		Expect(pin("2", 4, "moderator").Code).To(Equal(http.StatusForbidden))
+		// User 3 was promoted
+		Expect(pin("2", 3, "mahasiswa").Code).To(Equal(http.StatusOK))
+	})
+})
+
+var _ = Describe("Security Headers Test", func() {
+	get := func(cfg config.Config) http.Header {
+		mainAPI := newTestAPIWithConfig(cfg, mockRepos{category: &mockCategoryRepo{}})
+
+		w := httptest.NewRecorder()
+		mainAPI.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/does-not-exist", nil))
+		return w.Header()
+	}
+
+	It("should send the baseline headers by default", func() {
+		headers := get(config.Default())
+		Expect(headers.Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(headers.Get("X-Frame-Options")).To(Equal("DENY"))
+		Expect(headers.Get("Content-Security-Policy")).To(Equal("default-src 'none'; frame-ancestors 'none'"))
+		Expect(headers.Get("Referrer-Policy")).To(Equal("no-referrer"))
+	})
+
+	It("should leave out the headers that are turned off", func() {
+		cfg := config.Default()
+		cfg.ContentTypeNosniff = false
+		cfg.FrameOptions = ""
+		cfg.ReferrerPolicy = "strict-origin"
+
+		headers := get(cfg)
+		Expect(headers).ToNot(HaveKey("X-Content-Type-Options"))
+		Expect(headers).ToNot(HaveKey("X-Frame-Options"))
+		Expect(headers.Get("Referrer-Policy")).To(Equal("strict-origin"))
+	})
+})
+
+var _ = Describe("API Versioning Test", func() {
+	get := func(cfg config.Config, path string) *httptest.ResponseRecorder {
+		mainAPI := newTestAPIWithConfig(cfg, mockRepos{category: &mockCategoryRepo{}})
+
+		w := httptest.NewRecorder()
+		mainAPI.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	It("should serve the routes under v1 without deprecating them", func() {
+		w := get(config.Default(), "/api/v1/category/counts")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"name":"Umum"`))
+		Expect(w.Header()).ToNot(HaveKey("Deprecation"))
+		Expect(w.Header()).ToNot(HaveKey("Sunset"))
+	})
+
+	It("should keep the unversioned routes as deprecated aliases", func() {
+		w := get(config.Default(), "/api/category/counts")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"name":"Umum"`))
+		Expect(w.Header().Get("Deprecation")).To(Equal("true"))
+		Expect(w.Header().Get("Link")).To(Equal(`</api/v1/category/counts>; rel="successor-version"`))
+		Expect(w.Header()).ToNot(HaveKey("Sunset"))
+	})
+
+	It("should announce the sunset once it's configured", func() {
+		cfg := config.Default()
+		cfg.UnversionedAPISunset = time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+
+		w := get(cfg, "/api/category/counts")
+		Expect(w.Header().Get("Sunset")).To(Equal("Wed, 30 Jun 2027 00:00:00 GMT"))
+	})
+})
+
+var _ = Describe("Comments WebSocket Test", func() {
+	connect := func(user *mockUserRepo, post *mockPostRepo) *httptest.ResponseRecorder {
+		mainAPI := newTestAPI(mockRepos{post: post, user: user, category: &mockCategoryRepo{}})
+		req := httptest.NewRequest(http.MethodGet, "/api/post/1/comments/ws?token="+newToken(2, nil), nil)
+
+		w := httptest.NewRecorder()
+		mainAPI.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	It("should refuse banned users before upgrading", func() {
+		ban := &repository.UserBan{BannedUntil: time.Now().Add(time.Hour), Reason: "spam"}
+		w := connect(&mockUserRepo{ban: ban}, &mockPostRepo{})
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(w.Body.String()).To(ContainSubstring("spam"))
+	})
+
+	It("should not stream the comments of posts the user can't see", func() {
+		w := connect(&mockUserRepo{}, &mockPostRepo{hidden: []int{1}})
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,423 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/althafariq/discusspedia-be/repository"
+)
+
+// The mocks embed the repository interfaces so only the methods a test needs have to be implemented,
+// calling anything else panics
+
+type mockPostRepo struct {
+	repository.PostRepo
+	insertPostCalls int
+	anonymousPosts  []int
+	posts           []repository.Post
+	imagePaths      []string
+	originalNames   []string
+	orderBys        []string
+	filters         []string
+	filterArgs      [][]interface{}
+	savedSearches   []repository.SavedSearch
+	undoErr         error
+	undone          []int
+	compactPosts    []repository.PostDetail
+	detailPosts     []repository.PostDetail
+	revisions       []repository.PostRevision
+	updated         []int
+	hidden          []int
+}
+
+// PostVisibleTo hides the posts in hidden from everyone
+func (m *mockPostRepo) PostVisibleTo(postID, viewerID int, withPending bool) (bool, error) {
+	for _, id := range m.hidden {
+		if id == postID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *mockPostRepo) UpdatePost(postID, editorID, categoryID int, title, description string) error {
+	m.updated = append(m.updated, postID)
+	return nil
+}
+
+func (m *mockPostRepo) InsertPost(authorID, categoryID int, title, description string, anonymous bool) (int64, error) {
+	m.insertPostCalls++
+	if anonymous {
+		m.anonymousPosts = append(m.anonymousPosts, m.insertPostCalls)
+	}
+	m.posts = append(m.posts, repository.Post{ID: m.insertPostCalls, CategoryID: categoryID, Title: title, Description: description})
+	return int64(m.insertPostCalls), nil
+}
+
+func (m *mockPostRepo) InsertImportedPosts(posts []repository.ImportedPost) ([]int64, error) {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		m.insertPostCalls++
+		m.posts = append(m.posts, repository.Post{
+			ID: m.insertPostCalls, CategoryID: post.CategoryID, Title: post.Title,
+			Description: post.Description, CreatedAt: post.CreatedAt,
+		})
+		ids[i] = int64(m.insertPostCalls)
+	}
+	return ids, nil
+}
+
+func (m *mockPostRepo) InsertPostImage(postID int, path, originalName string) error {
+	m.imagePaths = append(m.imagePaths, path)
+	m.originalNames = append(m.originalNames, originalName)
+	return nil
+}
+
+func (m *mockPostRepo) PostImageExists(path string) (bool, error) {
+	for _, imagePath := range m.imagePaths {
+		if imagePath == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockPostRepo) FindRecentDuplicatePost(authorID int, title, desc string, within time.Duration) (int64, error) {
+	for _, post := range m.posts {
+		if post.Title == title && post.Description == desc {
+			return int64(post.ID), nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *mockPostRepo) FetchAuthorIDByPostID(postID int) (int, error) {
+	return 1, nil
+}
+
+func (m *mockPostRepo) FetchPostRevisions(postID, limit, offset int) ([]repository.PostRevision, error) {
+	// A copy, like the rows of a real query, the handler masks the editors of anonymous posts in place
+	return append([]repository.PostRevision(nil), m.revisions...), nil
+}
+
+func (m *mockPostRepo) FetchPostCategoryID(postID int) (int, error) {
+	for _, post := range m.posts {
+		if post.ID == postID {
+			return post.CategoryID, nil
+		}
+	}
+	return 0, repository.ErrPostNotFound
+}
+
+func (m *mockPostRepo) CommentsLocked(postID int) (bool, error) {
+	return false, nil
+}
+
+func (m *mockPostRepo) PinPost(postID int) error {
+	return nil
+}
+
+func (m *mockPostRepo) FetchAllPost(limit, offset, authorID int, orderBy, filter string, args ...interface{}) ([]repository.PostDetail, error) {
+	m.orderBys = append(m.orderBys, orderBy)
+	m.filters = append(m.filters, filter)
+	m.filterArgs = append(m.filterArgs, args)
+	return m.detailPosts, nil
+}
+
+func (m *mockPostRepo) UndoPost(postID, authorID int, within time.Duration) ([]string, error) {
+	if m.undoErr != nil {
+		return nil, m.undoErr
+	}
+	m.undone = append(m.undone, postID)
+	return []string{}, nil
+}
+
+func (m *mockPostRepo) InsertSavedSearch(userID int, name, query string) (repository.SavedSearch, error) {
+	search := repository.SavedSearch{ID: len(m.savedSearches) + 1, Name: name, Query: query}
+	m.savedSearches = append(m.savedSearches, search)
+	return search, nil
+}
+
+func (m *mockPostRepo) FetchSavedSearch(searchID, userID int) (repository.SavedSearch, error) {
+	for _, search := range m.savedSearches {
+		if search.ID == searchID {
+			return search, nil
+		}
+	}
+	return repository.SavedSearch{}, repository.ErrSavedSearchNotFound
+}
+
+func (m *mockPostRepo) FetchPostByID(postID, authorID int) ([]repository.PostDetail, error) {
+	rows := []repository.PostDetail{}
+	for _, post := range m.detailPosts {
+		if post.ID == postID {
+			rows = append(rows, post)
+		}
+	}
+	return rows, nil
+}
+
+func (m *mockPostRepo) FetchAllPostCompact(limit, offset, authorID int, orderBy, filter string, args ...interface{}) ([]repository.PostDetail, error) {
+	m.orderBys = append(m.orderBys, orderBy)
+	return m.compactPosts, nil
+}
+
+type mockMentionRepo struct {
+	repository.MentionRepo
+	inserted []repository.Mention
+}
+
+func (m *mockMentionRepo) FetchUserByName(name string) (int, string, error) {
+	return 2, name, nil
+}
+
+func (m *mockMentionRepo) InsertMentions(mentions []repository.Mention) error {
+	m.inserted = append(m.inserted, mentions...)
+	return nil
+}
+
+func (m *mockMentionRepo) DeletePostMentions(postID int) error {
+	return nil
+}
+
+func (m *mockMentionRepo) FetchPostMentions(postID int) ([]repository.Mention, error) {
+	return []repository.Mention{}, nil
+}
+
+type mockCommentRepo struct {
+	repository.CommentRepo
+	insertCommentCalls int
+	inserted           []repository.Comment
+	postComments       []repository.Comment
+	// parents maps the id of a comment on post 1 to the comment it replies to, 0 for a top level comment
+	parents map[int]int
+}
+
+func (m *mockCommentRepo) InsertComment(comment repository.Comment) (int64, error) {
+	m.insertCommentCalls++
+	m.inserted = append(m.inserted, comment)
+	return int64(m.insertCommentCalls), nil
+}
+
+func (m *mockCommentRepo) FetchCommentAuthorId(commentID int) (int, error) {
+	return 2, nil
+}
+
+func (m *mockCommentRepo) FetchCommentPath(commentID int) (int, []int, error) {
+	if _, ok := m.parents[commentID]; !ok {
+		return 0, nil, repository.ErrCommentNotFound
+	}
+
+	path := []int{}
+	for id := commentID; id != 0; id = m.parents[id] {
+		path = append([]int{id}, path...)
+	}
+	return 1, path, nil
+}
+
+func (m *mockCommentRepo) FetchCommentsOfPost(userID, postID, limit int) ([]repository.Comment, int, error) {
+	if limit > len(m.postComments) {
+		limit = len(m.postComments)
+	}
+	return m.postComments[:limit], len(m.postComments), nil
+}
+
+func (m *mockCommentRepo) FetchCommentsBeforeCursor(postID, cursor, limit, viewerID int) ([]repository.Comment, error) {
+	if limit > len(m.postComments) {
+		limit = len(m.postComments)
+	}
+	return m.postComments[:limit], nil
+}
+
+type mockUserRepo struct {
+	repository.UserRepo
+	touched         []int
+	registeredRoles []string
+	ban             *repository.UserBan
+	// roles are the current roles of the users, the others are mahasiswa
+	roles map[int]string
+}
+
+func (m *mockUserRepo) GetUserRole(id int) (*string, error) {
+	role := repository.RoleMahasiswa
+	if current, ok := m.roles[id]; ok {
+		role = current
+	}
+	return &role, nil
+}
+
+func (m *mockUserRepo) InsertNewUser(name string, email string, password string, role string, institute string, major *string, batch *int) (int, int, error) {
+	m.registeredRoles = append(m.registeredRoles, role)
+	return len(m.registeredRoles), http.StatusOK, nil
+}
+
+func (m *mockUserRepo) GetActiveBan(userID int) (*repository.UserBan, error) {
+	return m.ban, nil
+}
+
+func (m *mockUserRepo) GetUserData(id int) (*repository.User, error) {
+	return &repository.User{Id: id, Name: "Radit"}, nil
+}
+
+// UserExists knows the seeded users only
+func (m *mockUserRepo) UserExists(id int) (bool, error) {
+	return id >= 1 && id <= 3, nil
+}
+
+func (m *mockUserRepo) TouchLastActive(userID int) error {
+	m.touched = append(m.touched, userID)
+	return nil
+}
+
+type mockFollowRepo struct {
+	repository.FollowRepo
+	// blockers blocked every other user
+	blockers []int
+}
+
+func (m *mockFollowRepo) FetchFollowerIDs(userID int) ([]int, error) {
+	return nil, nil
+}
+
+func (m *mockFollowRepo) IsBlocked(blockerID, blockedID int) (bool, error) {
+	for _, id := range m.blockers {
+		if id == blockerID && id != blockedID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type mockNotifRepo struct {
+	repository.NotificationRepo
+	created []string
+}
+
+func (m *mockNotifRepo) CreateNotification(userId, actorId int, notifType string, targetId int) error {
+	m.created = append(m.created, notifType)
+	return nil
+}
+
+type mockCategoryRepo struct {
+	repository.CategoryRepo
+	moderators     map[int]int
+	allowAnonymous bool
+}
+
+// IsCategoryModerator reads moderators as a map of user id to the category they moderate
+func (m *mockCategoryRepo) IsCategoryModerator(userID, categoryID int) (bool, error) {
+	moderated, ok := m.moderators[userID]
+	return ok && moderated == categoryID, nil
+}
+
+func (m *mockCategoryRepo) CategoryExists(id int) (bool, error) {
+	return true, nil
+}
+
+func (m *mockCategoryRepo) CategoryByID(id int) (repository.Category, error) {
+	if id != 1 {
+		return repository.Category{}, repository.ErrCategoryNotFound
+	}
+	return repository.Category{ID: 1, Name: "Umum", AllowAnonymous: m.allowAnonymous}, nil
+}
+
+func (m *mockCategoryRepo) CountCategoryPosts(id int) (int, error) {
+	return 4, nil
+}
+
+func (m *mockCategoryRepo) CountPostsByCategory(includeEmpty bool) ([]repository.CategoryPostCount, error) {
+	counts := []repository.CategoryPostCount{{CategoryID: 1, Name: "Umum", PostCount: 4}}
+	if includeEmpty {
+		counts = append(counts, repository.CategoryPostCount{CategoryID: 2, Name: "Sains"})
+	}
+	return counts, nil
+}
+
+type mockRepos struct {
+	comment       repository.CommentRepo
+	follow        repository.FollowRepo
+	idempotency   repository.IdempotencyRepo
+	like          repository.LikeRepo
+	mention       repository.MentionRepo
+	notif         repository.NotificationRepo
+	post          repository.PostRepo
+	user          repository.UserRepo
+	category      repository.CategoryRepo
+	questionnaire repository.QuestionnaireRepo
+	audit         repository.AuditRepo
+	webhook       repository.WebhookRepo
+}
+
+func newTestAPI(repos mockRepos) api.API {
+	return newTestAPIWithConfig(config.Default(), repos)
+}
+
+func newTestAPIWithConfig(cfg config.Config, repos mockRepos) api.API {
+	// Webhooks are delivered in the background of every new post, a nil repo would crash the test binary
+	if repos.webhook == nil {
+		repos.webhook = &mockWebhookRepo{}
+	}
+
+	return api.NewAPI(
+		cfg,
+		repos.comment, repos.follow, repos.idempotency, repos.like, repos.mention,
+		repos.notif, repos.post, repos.user, repos.category, repos.questionnaire, repos.audit, repos.webhook,
+	)
+}
+
+type mockWebhookRepo struct {
+	repository.WebhookRepo
+	mu         sync.Mutex
+	webhooks   []repository.Webhook
+	deliveries []repository.WebhookDelivery
+}
+
+func (m *mockWebhookRepo) InsertWebhook(webhook repository.Webhook) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks = append(m.webhooks, webhook)
+	return int64(len(m.webhooks)), nil
+}
+
+func (m *mockWebhookRepo) FetchWebhooksForCategory(categoryID int) ([]repository.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhooks := []repository.Webhook{}
+	for i, webhook := range m.webhooks {
+		if webhook.CategoryID == nil || *webhook.CategoryID == categoryID {
+			webhook.ID = i + 1
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *mockWebhookRepo) InsertWebhookDelivery(delivery repository.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+func (m *mockWebhookRepo) Deliveries() []repository.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]repository.WebhookDelivery{}, m.deliveries...)
+}
+
+type mockAuditRepo struct {
+	repository.AuditRepo
+	entries []repository.AuditEntry
+}
+
+func (m *mockAuditRepo) InsertAuditEntry(actorID int, action, targetType string, targetID int, metadata json.RawMessage) error {
+	m.entries = append(m.entries, repository.AuditEntry{
+		ActorID: actorID, Action: action, TargetType: targetType, TargetID: targetID, Metadata: metadata,
+	})
+	return nil
+}
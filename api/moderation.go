@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ModeratePostResponse struct {
+	SuccessPostResponse
+	ModerationStatus string `json:"moderation_status"`
+}
+
+// ReadPendingPosts lists the posts waiting for a moderator, oldest first. Category moderators only see the posts of
+// their categories
+func (api *API) ReadPendingPosts(ctx *gin.Context) {
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(ctx, api.postsPage)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
+		return
+	}
+
+	var categoryIDs []int
+	if ctx.GetBool(categoryModeratorKey) {
+		userID, err := api.getUserIdFromToken(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+			return
+		}
+
+		categoryIDs, err = api.categoryRepo.FetchModeratedCategoryIDs(userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+			return
+		}
+	}
+
+	posts, err := api.postRepo.FetchPendingPosts(limit, offset, categoryIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, buildPostListResponse(posts, 0, true))
+}
+
+func (api *API) ApprovePost(ctx *gin.Context) {
+	api.moderatePost(ctx, repository.ModerationApproved)
+}
+
+func (api *API) RejectPost(ctx *gin.Context) {
+	api.moderatePost(ctx, repository.ModerationRejected)
+}
+
+// moderatePost stores the decision and notifies the author. The mentions and the feed event of a post are held back
+// while it's pending, so they are sent once it's approved
+func (api *API) moderatePost(ctx *gin.Context, status string) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	moderatorID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	post, err := api.postRepo.SetModerationStatus(postID, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrPostNotFound):
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		case errors.Is(err, repository.ErrPostApproved):
+			ctx.JSON(http.StatusConflict, ErrorPostResponse{Message: "Approved posts can't be rejected, delete them instead"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		}
+		return
+	}
+
+	notifType, message, action := repository.NotifTypePostRejected, "Post Rejected", repository.AuditActionRejectPost
+	if status == repository.ModerationApproved {
+		notifType, message, action = repository.NotifTypePostApproved, "Post Approved", repository.AuditActionApprovePost
+	}
+
+	api.recordAudit(ctx, action, repository.AuditTargetPost, postID, gin.H{"previous_status": post.PreviousStatus})
+
+	if post.PreviousStatus != status {
+		api.notifRepo.CreateNotification(post.AuthorID, moderatorID, notifType, postID)
+
+		if status == repository.ModerationApproved {
+			if !post.IsAnonymous {
+				api.saveMentions(post.AuthorID, postID, nil, post.Description, nil)
+			}
+			api.publishNewPost(post.AuthorID, postID, post.CategoryID, post.Title, post.IsAnonymous)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, ModeratePostResponse{
+		SuccessPostResponse: SuccessPostResponse{Message: message},
+		ModerationStatus:    status,
+	})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (api *API) notFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+}
+
+// methodNotAllowed lists the methods registered for the path in the Allow header and the body
+func (api *API) methodNotAllowed(c *gin.Context) {
+	allowed := api.allowedMethods(c.Request.URL.Path)
+
+	c.Header("Allow", strings.Join(allowed, ", "))
+	c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "method not allowed", "allowed_methods": allowed})
+}
+
+// allowedMethods matches the path against every route, gin doesn't expose its own lookup
+func (api *API) allowedMethods(path string) []string {
+	seen := make(map[string]struct{})
+	allowed := []string{}
+
+	for _, route := range api.router.Routes() {
+		if _, ok := seen[route.Method]; ok || !routeMatches(route.Path, path) {
+			continue
+		}
+		seen[route.Method] = struct{}{}
+		allowed = append(allowed, route.Method)
+	}
+
+	sort.Strings(allowed)
+	return allowed
+}
+
+// routeMatches compares the path segment by segment, a :param matches any one segment and a *param the rest
+func routeMatches(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if !strings.HasPrefix(segment, ":") && segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
+}
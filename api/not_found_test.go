@@ -0,0 +1,37 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unknown Route Test", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		mainAPI := newTestAPI(mockRepos{})
+		handler = mainAPI.Handler()
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	It("should return a JSON 404 for a path without routes", func() {
+		w := serve(http.MethodGet, "/api/does-not-exist")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(w.Body.String()).To(MatchJSON(`{"error":"not found"}`))
+	})
+
+	It("should return 405 with the methods of the path", func() {
+		w := serve(http.MethodPatch, "/api/post/5/reaction")
+		Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(w.Header().Get("Allow")).To(Equal("DELETE, PUT"))
+		Expect(w.Body.String()).To(MatchJSON(`{"error":"method not allowed","allowed_methods":["DELETE","PUT"]}`))
+	})
+})
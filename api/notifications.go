@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,6 +12,12 @@ type ReadNotifRequest struct {
 	NotifId *int `json:"notif_id" form:"notif_id"`
 }
 
+type NotificationListResponse struct {
+	Notifications []repository.Notification `json:"notifications"`
+	UnreadCount   int                       `json:"unread_count"`
+	Limit         int                       `json:"limit"`
+}
+
 func (api API) GetAllNotifications(c *gin.Context) {
 	userId, err := api.getUserIdFromToken(c)
 
@@ -20,26 +27,37 @@ func (api API) GetAllNotifications(c *gin.Context) {
 	}
 
 	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Page"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.notificationsPage)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	notifs, err := api.notifRepo.GetAllNotifications(userId, page, limit)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	notifs, err := api.notifRepo.GetAllNotifications(userId, page, limit)
+	unreadCount, err := api.notifRepo.CountUnreadNotifications(userId)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, notifs)
+	c.JSON(http.StatusOK, NotificationListResponse{
+		Notifications: notifs,
+		UnreadCount:   unreadCount,
+		Limit:         limit,
+	})
 }
 
+// SetReadNotif marks every notification as read when notif_id isn't given
 func (api API) SetReadNotif(c *gin.Context) {
 	var reqBody ReadNotifRequest
 
@@ -69,3 +87,112 @@ func (api API) SetReadNotif(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "success"})
 }
+
+func (api API) ReadNotification(c *gin.Context) {
+	notifId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	userId, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = api.notifRepo.SetReadNotification(userId, notifId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+func (api API) ReadAllNotifications(c *gin.Context) {
+	userId, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = api.notifRepo.SetReadAllNotification(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+func (api API) CountUnreadNotifications(c *gin.Context) {
+	userId, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := api.notifRepo.CountUnreadNotifications(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// GetNotificationPreferences maps every notification type to whether the user gets it
+func (api API) GetNotificationPreferences(c *gin.Context) {
+	userId, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preferences, err := api.notifRepo.FetchNotificationPreferences(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences takes the types to turn on or off, the ones left out keep their setting
+func (api API) UpdateNotificationPreferences(c *gin.Context) {
+	userId, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var preferences map[string]bool
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		return
+	}
+
+	for notifType := range preferences {
+		if !isNotificationType(notifType) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unknown Notification Type " + notifType})
+			return
+		}
+	}
+
+	if err := api.notifRepo.UpdateNotificationPreferences(userId, preferences); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.GetNotificationPreferences(c)
+}
+
+func isNotificationType(notifType string) bool {
+	for _, known := range repository.NotificationTypes {
+		if known == notifType {
+			return true
+		}
+	}
+	return false
+}
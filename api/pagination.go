@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidLimit = errors.New("Invalid Limit")
+
+type pageConfig struct {
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// parseLimit reads the limit query param, falling back to the route group's default. Limits above the max are
+// clamped instead of rejected and the applied limit is echoed in the X-Page-Limit header
+func parseLimit(c *gin.Context, config pageConfig) (int, error) {
+	limit := config.DefaultLimit
+	if query := c.Query("limit"); query != "" {
+		parsed, err := strconv.Atoi(query)
+		if err != nil || parsed < 1 {
+			return 0, errInvalidLimit
+		}
+		limit = parsed
+	}
+
+	if limit > config.MaxLimit {
+		limit = config.MaxLimit
+	}
+
+	c.Header("X-Page-Limit", strconv.Itoa(limit))
+	return limit, nil
+}
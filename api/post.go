@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,45 +15,112 @@ import (
 	"sync"
 	"time"
 
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/althafariq/discusspedia-be/helper"
 	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/althafariq/discusspedia-be/service"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
+// snippetLength is how many characters of the description the post lists show
+const snippetLength = 200
+
+// duplicatePostWindow is how long the same content from the same author is taken as a double submit
+const duplicatePostWindow = 5 * time.Minute
+
+// postUndoWindow is how long after creating a post its author can still undo it
+const postUndoWindow = time.Minute
+
+// anonymousAuthorName is shown instead of the author of an anonymous post
+const anonymousAuthorName = "Anonymous"
+
 type CreatePostRequest struct {
-	CategoryID  int    `json:"category_id"`
-	Title       string `json:"title"`
+	CategoryID  int    `json:"category_id" binding:"required,number"`
+	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
+	// Anonymous hides the author in every response, the category has to allow it
+	Anonymous bool `json:"anonymous"`
 }
 
 type UpdatePostRequest struct {
-	ID          int    `json:"id"`
-	CategoryID  int    `json:"category_id"`
-	Title       string `json:"title"`
+	ID          int    `json:"id" binding:"required,number"`
+	CategoryID  int    `json:"category_id" binding:"required,number"`
+	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
 }
 
 type CreatePostResponse struct {
 	ID int64 `json:"id"`
 	SuccessPostResponse
+	ModerationStatus string               `json:"moderation_status"`
+	Mentions         []repository.Mention `json:"mentions"`
+	Warnings         []string             `json:"warnings"`
+}
+
+// DuplicatePostResponse has the id of the post that was already created with the same content
+type DuplicatePostResponse struct {
+	ErrorPostResponse
+	ID int64 `json:"id"`
+}
+
+type CreatePostWithImagesResponse struct {
+	DetailPostResponse
+	Warnings []string `json:"warnings"`
+}
+
+type UpdatePostResponse struct {
+	SuccessPostResponse
+	Mentions []repository.Mention `json:"mentions"`
+	Warnings []string             `json:"warnings"`
 }
 
 type DetailPostResponse struct {
 	PostResponse
-	Images []PostImageResponse `json:"images"`
+	Images   []PostImageResponse  `json:"images"`
+	Mentions []repository.Mention `json:"mentions,omitempty"`
 }
 
 type PostResponse struct {
-	ID           int                `json:"id"`
-	IsLike       bool               `json:"is_like"`
-	IsAuthor     bool               `json:"is_author"`
-	Author       AuthorPostResponse `json:"author"`
-	CategoryID   int                `json:"category_id"`
-	Title        string             `json:"title"`
-	Description  string             `json:"description"`
-	CreatedAt    string             `json:"created_at"`
-	CommentCount int                `json:"comment_count"`
-	LikeCount    int                `json:"like_count"`
+	ID                 int                `json:"id"`
+	IsLike             bool               `json:"is_like"`
+	IsAuthor           bool               `json:"is_author"`
+	Author             AuthorPostResponse `json:"author"`
+	CategoryID         int                `json:"category_id"`
+	Title              string             `json:"title"`
+	Description        string             `json:"description"`
+	DescriptionHTML    string             `json:"description_html"`
+	DescriptionSnippet string             `json:"description_snippet,omitempty"`
+	CreatedAt          string             `json:"created_at"`
+	UpdatedAt          string             `json:"updated_at"`
+	IsPinned           bool               `json:"is_pinned"`
+	CommentsLocked     bool               `json:"comments_locked"`
+	CommentCount       int                `json:"comment_count"`
+	LikeCount          int                `json:"like_count"`
+	ModerationStatus   string             `json:"moderation_status"`
+	IsAnonymous        bool               `json:"is_anonymous"`
+	// RealAuthor is the author of an anonymous post, only for admins and moderators
+	RealAuthor *AuthorPostResponse `json:"real_author,omitempty"`
+}
+
+// CompactPostResponse is the shape of fields=compact, without the images and the author details
+type CompactPostResponse struct {
+	ID           int    `json:"id"`
+	AuthorID     int    `json:"author_id"`
+	AuthorName   string `json:"author_name"`
+	CategoryID   int    `json:"category_id"`
+	Title        string `json:"title"`
+	Snippet      string `json:"snippet"`
+	CreatedAt    string `json:"created_at"`
+	IsPinned     bool   `json:"is_pinned"`
+	CommentCount int    `json:"comment_count"`
+	LikeCount    int    `json:"like_count"`
+}
+
+type PostActivityResponse struct {
+	CommentCount int    `json:"comment_count"`
+	LikeCount    int    `json:"like_count"`
+	UpdatedAt    string `json:"updated_at"`
 }
 
 type AuthorPostResponse struct {
@@ -65,6 +133,43 @@ type AuthorPostResponse struct {
 	ProfileImage string `json:"profile_image"`
 }
 
+type CommentsLockResponse struct {
+	CommentsLocked bool `json:"comments_locked"`
+}
+
+type ReorderPostImagesRequest struct {
+	ImageIDs []int `json:"image_ids" binding:"required"`
+}
+
+type BulkDeletePostsRequest struct {
+	IDs []int `json:"ids" binding:"required,min=1,max=100"`
+}
+
+type BulkDeletePostsResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+type ForbiddenPostsResponse struct {
+	ErrorPostResponse
+	IDs []int `json:"ids"`
+}
+
+type PostImageUploadResult struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type UploadPostImagesResponse struct {
+	SuccessPostResponse
+	Results []PostImageUploadResult `json:"results"`
+}
+
+type FailedPostImagesResponse struct {
+	ErrorPostResponse
+	Results []PostImageUploadResult `json:"results"`
+}
+
 type PostImageResponse struct {
 	ID  int    `json:"id"`
 	URL string `json:"url"`
@@ -84,12 +189,30 @@ func (api *API) createPost(ctx *gin.Context) {
 	)
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		}
+		return
+	}
+
+	if errs := validatePostContent(&req.Title, &req.Description); len(errs) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	if !api.validateCategory(ctx, req.CategoryID) {
+		return
+	}
+
+	if req.Anonymous && !api.validateAnonymousPost(ctx, req.CategoryID) {
 		return
 	}
 
-	isTitleOK := service.GetValidationInstance().Validate(req.Title)
-	isDescriptionOK := service.GetValidationInstance().Validate(req.Description)
+	isTitleOK := service.GetValidationInstance().Validate(req.Title, requestLocales(ctx)...)
+	isDescriptionOK := service.GetValidationInstance().Validate(req.Description, requestLocales(ctx)...)
 	if !isTitleOK || !isDescriptionOK {
 		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your post contains bad words"})
 		return
@@ -98,20 +221,196 @@ func (api *API) createPost(ctx *gin.Context) {
 	authorID, err := api.getUserIdFromToken(ctx)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	if !api.beginIdempotentRequest(ctx, authorID, idempotencyScopePost) {
+		return
+	}
+
+	if api.isDuplicatePost(ctx, authorID, req.Title, req.Description) {
+		api.releaseIdempotentRequest(ctx, authorID, idempotencyScopePost)
+		return
 	}
 
-	postID, err := api.postRepo.InsertPost(authorID, req.CategoryID, req.Title, req.Description)
+	postID, err := api.postRepo.InsertPost(authorID, req.CategoryID, req.Title, req.Description, req.Anonymous)
 
 	if err != nil {
+		api.releaseIdempotentRequest(ctx, authorID, idempotencyScopePost)
 		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, CreatePostResponse{
+	mentions, status := api.publishCreatedPost(authorID, int(postID), req.CategoryID, req.Title, req.Description, req.Anonymous)
+
+	api.completeIdempotentRequest(ctx, authorID, idempotencyScopePost, int(postID), CreatePostResponse{
 		ID: postID,
 		SuccessPostResponse: SuccessPostResponse{
 			Message: "Post Created",
 		},
+		ModerationStatus: status,
+		Mentions:         mentions,
+		Warnings:         service.ContentWarnings(req.Title, req.Description),
+	})
+}
+
+// publishCreatedPost notifies the mentioned users and the followers about a new post, a pending post waits for
+// moderatePost to do it. The mentions of an anonymous post aren't saved, their notifications would name the author
+func (api *API) publishCreatedPost(authorID, postID, categoryID int, title, description string, anonymous bool) ([]repository.Mention, string) {
+	if api.preModeration {
+		return []repository.Mention{}, repository.ModerationPending
+	}
+
+	mentions := []repository.Mention{}
+	if !anonymous {
+		mentions = api.saveMentions(authorID, postID, nil, description, nil)
+	}
+	api.publishNewPost(authorID, postID, categoryID, title, anonymous)
+
+	return mentions, repository.ModerationApproved
+}
+
+// validateAnonymousPost responds with 400 when the category doesn't allow anonymous posts
+func (api *API) validateAnonymousPost(ctx *gin.Context, categoryID int) bool {
+	category, err := api.categoryRepo.CategoryByID(categoryID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return false
+	}
+
+	if !category.AllowAnonymous {
+		ctx.JSON(http.StatusBadRequest, gin.H{"errors": []helper.JSONRequestErrorResponse{
+			{Field: "anonymous", Message: "This category doesn't allow anonymous posts"},
+		}})
+		return false
+	}
+
+	return true
+}
+
+// isDuplicatePost responds with 409 and the id of the existing post when the author already posted the same title and
+// description within duplicatePostWindow, which is usually a double submit
+func (api *API) isDuplicatePost(ctx *gin.Context, authorID int, title, description string) bool {
+	postID, err := api.postRepo.FindRecentDuplicatePost(authorID, title, description, duplicatePostWindow)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return true
+	}
+	if postID == 0 {
+		return false
+	}
+
+	ctx.JSON(http.StatusConflict, DuplicatePostResponse{
+		ErrorPostResponse: ErrorPostResponse{Message: "You already created this post"},
+		ID:                postID,
+	})
+	return true
+}
+
+// createPostWithImages writes the images first and then inserts the post along with them in one transaction,
+// the written files are removed when anything fails so no half created post is left behind
+func (api *API) createPostWithImages(ctx *gin.Context) {
+	req := CreatePostRequest{
+		Title:       ctx.PostForm("title"),
+		Description: ctx.PostForm("description"),
+	}
+
+	categoryID, err := strconv.Atoi(ctx.PostForm("category_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Category ID"})
+		return
+	}
+	req.CategoryID = categoryID
+
+	if errs := validatePostContent(&req.Title, &req.Description); len(errs) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	if !api.validateCategory(ctx, req.CategoryID) {
+		return
+	}
+
+	isTitleOK := service.GetValidationInstance().Validate(req.Title, requestLocales(ctx)...)
+	isDescriptionOK := service.GetValidationInstance().Validate(req.Description, requestLocales(ctx)...)
+	if !isTitleOK || !isDescriptionOK {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your post contains bad words"})
+		return
+	}
+
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
+		return
+	}
+
+	files := form.File["images"]
+	if len(files) == 0 {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "At least one image is required"})
+		return
+	}
+
+	authorID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	if api.isDuplicatePost(ctx, authorID, req.Title, req.Description) {
+		return
+	}
+
+	folderPath := filepath.Join(api.mediaDir, "post")
+
+	images := make([]repository.UploadedImage, 0, len(files))
+	removeImages := func() {
+		for _, image := range images {
+			if err := os.Remove(image.Path); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	unixTime := time.Now().UTC().UnixNano()
+	for i, file := range files {
+		fileName, err := api.uploadFileName(fmt.Sprintf("%d-%d", unixTime, i), file.Filename)
+		if err != nil {
+			removeImages()
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+			return
+		}
+		fileLocation := filepath.Join(folderPath, fileName)
+
+		if err := ctx.SaveUploadedFile(file, fileLocation); err != nil {
+			os.Remove(fileLocation)
+			removeImages()
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+			return
+		}
+		images = append(images, repository.UploadedImage{Path: fileLocation, OriginalName: file.Filename})
+	}
+
+	postID, err := api.postRepo.InsertPostWithImages(authorID, req.CategoryID, req.Title, req.Description, images)
+	if err != nil {
+		removeImages()
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	mentions, _ := api.publishCreatedPost(authorID, int(postID), req.CategoryID, req.Title, req.Description, false)
+
+	posts, err := api.postRepo.FetchPostByID(int(postID), authorID)
+	if err != nil || len(posts) == 0 {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	response := buildPostsResponse(posts, authorID, false)[0]
+	response.Mentions = mentions
+
+	ctx.JSON(http.StatusOK, CreatePostWithImagesResponse{
+		DetailPostResponse: response,
+		Warnings:           service.ContentWarnings(req.Title, req.Description),
 	})
 }
 
@@ -121,8 +420,7 @@ func (api *API) uploadPostImages(ctx *gin.Context) {
 		err    error
 	)
 
-	if postID, err = strconv.Atoi(ctx.Param("id")); err != nil {
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Post ID"})
+	if postID, err = helper.ParseID(ctx, "id"); err != nil {
 		return
 	}
 
@@ -132,67 +430,116 @@ func (api *API) uploadPostImages(ctx *gin.Context) {
 		return
 	}
 
-	folderPath := "media/post"
-	err = os.MkdirAll(folderPath, os.ModePerm)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: err.Error()})
-		return
+	folderPath := filepath.Join(api.mediaDir, "post")
+
+	results := api.saveImages(postID, folderPath, form.File["images"], api.postRepo.InsertPostImage)
+
+	for _, result := range results {
+		if result.Error != "" {
+			ctx.JSON(http.StatusInternalServerError, FailedPostImagesResponse{
+				ErrorPostResponse: ErrorPostResponse{Message: "Some images failed to upload"},
+				Results:           results,
+			})
+			return
+		}
 	}
 
-	files := form.File["images"]
+	ctx.JSON(http.StatusOK, UploadPostImagesResponse{
+		SuccessPostResponse: SuccessPostResponse{Message: "Post Images Uploaded"},
+		Results:             results,
+	})
+}
+
+// saveImages stores the uploaded images of a post or questionnaire in folderPath and records each with insert.
+// A fixed number of workers so a form with hundreds of files doesn't open all of them at once
+func (api *API) saveImages(ownerID int, folderPath string, files []*multipart.FileHeader, insert func(ownerID int, path, originalName string) error) []PostImageUploadResult {
+	results := make([]PostImageUploadResult, len(files))
+
+	jobs := make(chan int)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	for _, file := range files {
+	for worker := 0; worker < api.uploadConcurrency && worker < len(files); worker++ {
 		wg.Add(1)
 
-		go func(file *multipart.FileHeader) {
+		go func() {
 			defer wg.Done()
 
-			defer func() {
-				if v := recover(); v != nil {
-					log.Println(v)
-					ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
-					return
-				}
-			}()
-
-			uploadedFile, err := file.Open()
-			if err != nil {
-				ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: err.Error()})
-				return
+			for i := range jobs {
+				results[i] = api.saveImage(ownerID, folderPath, files[i], &mu, insert)
 			}
+		}()
+	}
 
-			defer uploadedFile.Close()
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
 
-			unixTime := time.Now().UTC().UnixNano()
-			fileName := fmt.Sprintf("%d-%d-%s", postID, unixTime, strings.ReplaceAll(file.Filename, " ", ""))
-			fileLocation := filepath.Join(folderPath, fileName)
-			targetFile, err := os.OpenFile(fileLocation, os.O_WRONLY|os.O_CREATE, 0666)
+	wg.Wait()
 
-			if err != nil {
-				ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: err.Error()})
-				return
-			}
+	return results
+}
 
-			defer targetFile.Close()
+// saveImage stores one uploaded file, mu keeps the inserts one at a time
+func (api *API) saveImage(ownerID int, folderPath string, file *multipart.FileHeader, mu *sync.Mutex, insert func(ownerID int, path, originalName string) error) (result PostImageUploadResult) {
+	result.Filename = file.Filename
 
-			if _, err := io.Copy(targetFile, uploadedFile); err != nil {
-				ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: err.Error()})
-				return
-			}
+	defer func() {
+		if v := recover(); v != nil {
+			log.Println(v)
+			result.URL = ""
+			result.Error = "Internal Server Error"
+		}
+	}()
 
-			mu.Lock()
-			if err := api.postRepo.InsertPostImage(postID, fileLocation); err != nil {
-				ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: err.Error()})
-				return
-			}
-			mu.Unlock()
-		}(file)
+	uploadedFile, err := file.Open()
+	if err != nil {
+		result.Error = err.Error()
+		return result
 	}
 
-	wg.Wait()
+	defer uploadedFile.Close()
+
+	fileName, err := api.uploadFileName(fmt.Sprintf("%d-%d", ownerID, time.Now().UTC().UnixNano()), file.Filename)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	fileLocation := filepath.Join(folderPath, fileName)
+	targetFile, err := os.OpenFile(fileLocation, os.O_WRONLY|os.O_CREATE, 0666)
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	defer targetFile.Close()
+
+	if _, err := io.Copy(targetFile, uploadedFile); err != nil {
+		os.Remove(fileLocation)
+		result.Error = err.Error()
+		return result
+	}
 
-	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Images Uploaded"})
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := insert(ownerID, fileLocation, file.Filename); err != nil {
+		os.Remove(fileLocation)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.URL = fileLocation
+	return result
+}
+
+// uploadFileName names a stored upload after the configured strategy, slugPrefix keeps the slug names unique
+func (api *API) uploadFileName(slugPrefix, originalName string) (string, error) {
+	if api.uploadFileNameStrategy == config.FileNameStrategySlug {
+		return slugPrefix + "-" + helper.SafeFileName(originalName), nil
+	}
+	return helper.RandomFileName(originalName)
 }
 
 func (api *API) readPosts(ctx *gin.Context) {
@@ -204,32 +551,38 @@ func (api *API) readPosts(ctx *gin.Context) {
 		return
 	}
 
-	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	limit, err := parseLimit(ctx, api.postsPage)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Limit"})
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
 		return
 	}
 
-	sortBy := ctx.DefaultQuery("sort_by", "newest")
-	switch sortBy {
-	case "newest":
-		sortBy = "created_at DESC"
-	case "oldest":
-		sortBy = "created_at"
-	case "most_liked":
-		sortBy = "like_count DESC"
-	case "most_commented":
-		sortBy = "comment_count DESC"
-	default:
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Sort By"})
+	sortBy, err := postSortOptions.orderBy(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
 		return
 	}
 
-	var filterQuery string
+	// fields is a shape, full or compact, or the list of fields of the full shape to keep
+	fields := ctx.DefaultQuery("fields", "full")
+	var selectedFields []string
+	if fields != "full" && fields != "compact" {
+		if selectedFields, err = parseFields(fields, postListFields); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
+			return
+		}
+	}
+
+	var (
+		filterQuery string
+		filterArgs  []interface{}
+	)
 
+	// The title is bound rather than formatted in, saved searches replay it
 	searchTitle := ctx.DefaultQuery("search_title", "")
 	if searchTitle != "" {
-		filterQuery = fmt.Sprintf("AND title LIKE '%%%s%%' ", searchTitle)
+		filterQuery = `AND p.title LIKE ? ESCAPE '\' `
+		filterArgs = append(filterArgs, "%"+likeEscaper.Replace(searchTitle)+"%")
 	}
 
 	category_id, err := strconv.Atoi(ctx.DefaultQuery("category_id", "0"))
@@ -239,6 +592,9 @@ func (api *API) readPosts(ctx *gin.Context) {
 	}
 	if category_id != 0 {
 		filterQuery = fmt.Sprintf("%sAND category_id = %d ", filterQuery, category_id)
+
+		// Pinned announcements only go first when browsing a single category
+		sortBy = "is_pinned DESC, " + sortBy
 	}
 
 	me, err := strconv.ParseBool(ctx.DefaultQuery("me", "false"))
@@ -251,73 +607,229 @@ func (api *API) readPosts(ctx *gin.Context) {
 		filterQuery = fmt.Sprintf("%sAND author_id = %d", filterQuery, authorID)
 	}
 
-	posts, err := api.postRepo.FetchAllPost(limit, offset, authorID, sortBy, filterQuery)
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
-		return
-	}
+	if hasImages := ctx.Query("has_images"); hasImages != "" {
+		withImages, err := strconv.ParseBool(hasImages)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Filter By Has Images"})
+			return
+		}
 
-	if len(posts) == 0 {
-		ctx.JSON(http.StatusOK, []string{})
-		return
+		imagePredicate := "EXISTS (SELECT 1 FROM post_images WHERE post_id = p.id)"
+		if !withImages {
+			imagePredicate = "NOT " + imagePredicate
+		}
+		filterQuery = fmt.Sprintf("%s AND %s ", filterQuery, imagePredicate)
 	}
 
-	postIDqueue := make([]int, 0)
-	postsDetail := make(map[int]PostResponse)
-
-	for _, post := range posts {
-		if _, ok := postsDetail[post.ID]; !ok {
+	// unanswered=false keeps the posts that have comments instead
+	if unanswered := ctx.Query("unanswered"); unanswered != "" {
+		withoutComments, err := strconv.ParseBool(unanswered)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Filter By Unanswered"})
+			return
+		}
 
-			if len(postIDqueue) == 0 || postIDqueue[len(postIDqueue)-1] != post.ID {
-				postIDqueue = append(postIDqueue, post.ID)
-			}
+		commentPredicate := "p.comment_count = 0"
+		if !withoutComments {
+			commentPredicate = "p.comment_count > 0"
+		}
+		filterQuery = fmt.Sprintf("%s AND %s ", filterQuery, commentPredicate)
+	}
 
-			var (
-				authorMajor, authorInstitute, authorImage string
-				authorBatch                               int
-			)
+	createdAfter, createdBefore := ctx.Query("created_after"), ctx.Query("created_before")
+	var after, before time.Time
 
-			if post.AuthorMajor.Valid {
-				authorMajor = post.AuthorMajor.String
-			}
+	if createdAfter != "" {
+		if after, err = time.Parse(time.RFC3339, createdAfter); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Created After, use RFC3339"})
+			return
+		}
+		filterQuery = fmt.Sprintf("%s AND datetime(p.created_at) >= datetime(?) ", filterQuery)
+		filterArgs = append(filterArgs, after.UTC().Format("2006-01-02 15:04:05"))
+	}
 
-			if post.AuthorInstitution.Valid {
-				authorInstitute = post.AuthorInstitution.String
-			}
+	if createdBefore != "" {
+		if before, err = time.Parse(time.RFC3339, createdBefore); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Created Before, use RFC3339"})
+			return
+		}
+		filterQuery = fmt.Sprintf("%s AND datetime(p.created_at) <= datetime(?) ", filterQuery)
+		filterArgs = append(filterArgs, before.UTC().Format("2006-01-02 15:04:05"))
+	}
 
-			if post.AuthorBatch.Valid {
-				authorBatch = int(post.AuthorBatch.Int32)
-			}
+	if createdAfter != "" && createdBefore != "" && after.After(before) {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Created After must not be later than Created Before"})
+		return
+	}
 
-			if post.AuthorAvatar.Valid {
-				authorImage = post.AuthorAvatar.String
-			}
+	// Anonymous posts are left out, matching them would tell the role of their hidden author
+	authorRole := ctx.Query("author_role")
+	if authorRole != "" {
+		if authorRole, err = repository.NormalizeRole(authorRole); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Filter By Author Role, " + err.Error()})
+			return
+		}
+		filterQuery = fmt.Sprintf("%s AND u.role = ? AND p.is_anonymous = 0 ", filterQuery)
+		filterArgs = append(filterArgs, authorRole)
+	}
 
-			postsDetail[post.ID] = PostResponse{
-				ID:       post.ID,
-				IsLike:   post.IsLike,
-				IsAuthor: authorID == post.AuthorID,
-				Author: AuthorPostResponse{
-					ID:           post.AuthorID,
-					Name:         post.AuthorName,
-					Role:         post.AuthorRole,
-					Major:        authorMajor,
-					Institute:    authorInstitute,
-					Batch:        authorBatch,
-					ProfileImage: authorImage,
-				},
-				CategoryID:   post.CategoryID,
-				Title:        post.Title,
-				Description:  post.Description,
-				CreatedAt:    post.CreatedAt.Format("2006-01-02 15:04:05"),
-				CommentCount: post.CommentCount,
-				LikeCount:    post.LikeCount,
-			}
+	// Anonymous viewers all get the same response (is_like and is_author are always false), so it's cached
+	// by the parsed params. Logged in viewers bypass the cache
+	anonymous := authorID == 0
+	cacheKey := fmt.Sprintf("%s|%d|%d|%s|%d|%t|%s|%s|%s|%s|%s|%s", sortBy, offset, limit, searchTitle, category_id, me,
+		ctx.Query("has_images"), ctx.Query("unanswered"), authorRole, after.Format(time.RFC3339), before.Format(time.RFC3339),
+		fields)
+
+	if anonymous {
+		if body, ok := api.feedCache.Get(cacheKey); ok {
+			ctx.Header("X-Cache", "HIT")
+			ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+			return
 		}
 	}
 
-	images := make(map[int][]PostImageResponse)
+	// The compact shape skips the join with post_images
+	fetch := api.postRepo.FetchAllPost
+	if fields == "compact" {
+		fetch = api.postRepo.FetchAllPostCompact
+	}
+
+	posts, err := fetch(limit, offset, authorID, sortBy, filterQuery, filterArgs...)
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	var response interface{} = []string{}
+	switch {
+	case len(posts) == 0:
+	case fields == "compact":
+		response = buildCompactPostsResponse(posts)
+	case selectedFields != nil:
+		response, err = selectFields(buildPostListResponse(posts, authorID, api.canSeeAnonymousAuthors(ctx)), selectedFields)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+			return
+		}
+	default:
+		response = buildPostListResponse(posts, authorID, api.canSeeAnonymousAuthors(ctx))
+	}
+
+	if !anonymous {
+		ctx.JSON(http.StatusOK, response)
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	api.feedCache.Set(cacheKey, body)
+	ctx.Header("X-Cache", "MISS")
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+func (api *API) readRelatedPosts(ctx *gin.Context) {
+	viewerID := api.getUserIDAvoidPanic(ctx)
+
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	limit, err := parseLimit(ctx, api.relatedPostsPage)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
+		return
+	}
+
+	posts, err := api.postRepo.FetchRelatedPosts(postID, limit, viewerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, buildPostListResponse(posts, viewerID, api.canSeeAnonymousAuthors(ctx)))
+}
+
+// buildPostListResponse is buildPostsResponse with the description_snippet of every post
+func buildPostListResponse(posts []repository.PostDetail, viewerID int, revealAnonymous bool) []DetailPostResponse {
+	response := buildPostsResponse(posts, viewerID, revealAnonymous)
+	for i := range response {
+		response[i].DescriptionSnippet = service.Snippet(response[i].Description, snippetLength)
+	}
+	return response
+}
+
+// buildPostsResponse groups the post rows, one per image, back into posts while keeping their order.
+// revealAnonymous tells whether the viewer may see the authors of anonymous posts
+func buildPostsResponse(posts []repository.PostDetail, authorID int, revealAnonymous bool) []DetailPostResponse {
+	postIDqueue := make([]int, 0)
+	postsDetail := make(map[int]PostResponse)
+
+	for _, post := range posts {
+		if _, ok := postsDetail[post.ID]; !ok {
+
+			if len(postIDqueue) == 0 || postIDqueue[len(postIDqueue)-1] != post.ID {
+				postIDqueue = append(postIDqueue, post.ID)
+			}
+
+			var (
+				authorMajor, authorInstitute, authorImage string
+				authorBatch                               int
+			)
+
+			if post.AuthorMajor.Valid {
+				authorMajor = post.AuthorMajor.String
+			}
+
+			if post.AuthorInstitution.Valid {
+				authorInstitute = post.AuthorInstitution.String
+			}
+
+			if post.AuthorBatch.Valid {
+				authorBatch = int(post.AuthorBatch.Int32)
+			}
+
+			if post.AuthorAvatar.Valid {
+				authorImage = mediaURL("avatar", post.AuthorAvatar.String)
+			}
+
+			postResponse := PostResponse{
+				ID:       post.ID,
+				IsLike:   post.IsLike,
+				IsAuthor: authorID == post.AuthorID,
+				Author: AuthorPostResponse{
+					ID:           post.AuthorID,
+					Name:         post.AuthorName,
+					Role:         post.AuthorRole,
+					Major:        authorMajor,
+					Institute:    authorInstitute,
+					Batch:        authorBatch,
+					ProfileImage: authorImage,
+				},
+				CategoryID:       post.CategoryID,
+				Title:            post.Title,
+				Description:      post.Description,
+				DescriptionHTML:  service.RenderMarkdown(post.Description),
+				CreatedAt:        post.CreatedAt.Format("2006-01-02 15:04:05"),
+				UpdatedAt:        postUpdatedAt(post),
+				IsPinned:         post.IsPinned,
+				CommentsLocked:   post.CommentsLocked,
+				CommentCount:     post.CommentCount,
+				LikeCount:        post.LikeCount,
+				ModerationStatus: post.ModerationStatus,
+				IsAnonymous:      post.IsAnonymous,
+			}
+			maskAnonymousAuthor(&postResponse, revealAnonymous)
+			postsDetail[post.ID] = postResponse
+		}
+	}
+
+	images := make(map[int][]PostImageResponse)
 
 	for _, post := range posts {
 		if _, ok := images[post.ID]; !ok {
@@ -327,7 +839,7 @@ func (api *API) readPosts(ctx *gin.Context) {
 		if post.ImageID.Valid {
 			images[post.ID] = append(images[post.ID], PostImageResponse{
 				ID:  int(post.ImageID.Int32),
-				URL: post.ImagePath.String,
+				URL: mediaURL("post", post.ImagePath.String),
 			})
 		}
 	}
@@ -341,43 +853,98 @@ func (api *API) readPosts(ctx *gin.Context) {
 		})
 	}
 
-	ctx.JSON(http.StatusOK, postsReponse)
+	return postsReponse
 }
 
-func (api *API) readPost(ctx *gin.Context) {
-	var (
-		postID int
-		err    error
-	)
+// buildCompactPostsResponse expects one row per post, as FetchAllPostCompact returns them. The compact shape has no
+// real_author, anonymous posts are masked for everyone
+func buildCompactPostsResponse(posts []repository.PostDetail) []CompactPostResponse {
+	response := make([]CompactPostResponse, 0, len(posts))
+	for _, post := range posts {
+		authorID, authorName := post.AuthorID, post.AuthorName
+		if post.IsAnonymous {
+			authorID, authorName = 0, anonymousAuthorName
+		}
 
-	authorID := api.getUserIDAvoidPanic(ctx)
+		response = append(response, CompactPostResponse{
+			ID:           post.ID,
+			AuthorID:     authorID,
+			AuthorName:   authorName,
+			CategoryID:   post.CategoryID,
+			Title:        post.Title,
+			Snippet:      service.Snippet(post.Description, snippetLength),
+			CreatedAt:    post.CreatedAt.Format("2006-01-02 15:04:05"),
+			IsPinned:     post.IsPinned,
+			CommentCount: post.CommentCount,
+			LikeCount:    post.LikeCount,
+		})
+	}
+
+	return response
+}
 
-	if postID, err = strconv.Atoi(ctx.Param("id")); err != nil {
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Post ID"})
+func (api *API) readPost(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
 		return
 	}
 
-	posts, err := api.postRepo.FetchPostByID(postID, authorID)
+	api.respondWithPost(ctx, postID, api.getUserIDAvoidPanic(ctx))
+}
+
+// readRandomPost responds with a random post in the detail shape, for discovery. category_id narrows it down
+func (api *API) readRandomPost(ctx *gin.Context) {
+	categoryID := 0
+	if query := ctx.Query("category_id"); query != "" {
+		var err error
+		if categoryID, err = strconv.Atoi(query); err != nil || categoryID < 1 {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Category ID"})
+			return
+		}
+		if !api.validateCategory(ctx, categoryID) {
+			return
+		}
+	}
 
+	viewerID := api.getUserIDAvoidPanic(ctx)
+
+	postID, err := api.postRepo.FetchRandomPostID(viewerID, categoryID)
 	if err != nil {
-		fmt.Println(err.Error())
+		if errors.Is(err, repository.ErrPostNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
 		return
 	}
 
-	if len(posts) == 0 {
-		ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
-		return
+	api.respondWithPost(ctx, postID, viewerID)
+}
+
+func (api *API) respondWithPost(ctx *gin.Context, postID, authorID int) {
+	var selectedFields []string
+	if query := ctx.Query("fields"); query != "" {
+		var err error
+		if selectedFields, err = parseFields(query, postDetailFields); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
+			return
+		}
 	}
 
-	commentCount, err := api.commentRepo.CountComment(postID)
+	posts, err := api.postRepo.FetchPostByID(postID, authorID)
 
 	if err != nil {
+		fmt.Println(err.Error())
 		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
 		return
 	}
 
-	likeCount, err := api.likeRepo.CountPostLike(postID)
+	if len(posts) == 0 {
+		ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		return
+	}
+
+	mentions, err := api.mentionRepo.FetchPostMentions(postID)
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
@@ -390,7 +957,7 @@ func (api *API) readPost(ctx *gin.Context) {
 		for _, post := range posts {
 			images = append(images, PostImageResponse{
 				ID:  int(post.ImageID.Int32),
-				URL: post.ImagePath.String,
+				URL: mediaURL("post", post.ImagePath.String),
 			})
 		}
 	}
@@ -413,41 +980,174 @@ func (api *API) readPost(ctx *gin.Context) {
 	}
 
 	if posts[0].AuthorAvatar.Valid {
-		authorImage = posts[0].AuthorAvatar.String
-	}
-
-	ctx.JSON(http.StatusOK, DetailPostResponse{
-		PostResponse: PostResponse{
-			ID:       posts[0].ID,
-			IsLike:   posts[0].IsLike,
-			IsAuthor: posts[0].AuthorID == authorID,
-			Author: AuthorPostResponse{
-				ID:           posts[0].AuthorID,
-				Name:         posts[0].AuthorName,
-				Role:         posts[0].AuthorRole,
-				Major:        authorMajor,
-				Institute:    authorInstitute,
-				Batch:        authorBatch,
-				ProfileImage: authorImage,
-			},
-			CategoryID:   posts[0].CategoryID,
-			Title:        posts[0].Title,
-			Description:  posts[0].Description,
-			CreatedAt:    posts[0].CreatedAt.Format("2006-01-02 15:04:05"),
-			CommentCount: commentCount,
-			LikeCount:    likeCount,
+		authorImage = mediaURL("avatar", posts[0].AuthorAvatar.String)
+	}
+
+	postResponse := PostResponse{
+		ID:       posts[0].ID,
+		IsLike:   posts[0].IsLike,
+		IsAuthor: posts[0].AuthorID == authorID,
+		Author: AuthorPostResponse{
+			ID:           posts[0].AuthorID,
+			Name:         posts[0].AuthorName,
+			Role:         posts[0].AuthorRole,
+			Major:        authorMajor,
+			Institute:    authorInstitute,
+			Batch:        authorBatch,
+			ProfileImage: authorImage,
 		},
-		Images: images,
+		CategoryID:       posts[0].CategoryID,
+		Title:            posts[0].Title,
+		Description:      posts[0].Description,
+		DescriptionHTML:  service.RenderMarkdown(posts[0].Description),
+		CreatedAt:        posts[0].CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:        postUpdatedAt(posts[0]),
+		IsPinned:         posts[0].IsPinned,
+		CommentsLocked:   posts[0].CommentsLocked,
+		CommentCount:     posts[0].CommentCount,
+		LikeCount:        posts[0].LikeCount,
+		ModerationStatus: posts[0].ModerationStatus,
+		IsAnonymous:      posts[0].IsAnonymous,
+	}
+	maskAnonymousAuthor(&postResponse, api.canSeeAnonymousAuthors(ctx))
+
+	var response interface{} = DetailPostResponse{
+		PostResponse: postResponse,
+		Images:       images,
+		Mentions:     mentions,
+	}
+
+	if selectedFields != nil {
+		if response, err = selectFields(response, selectedFields); err != nil {
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+			return
+		}
+	}
+
+	respondWithETag(ctx, response)
+}
+
+// readPostActivity lets polling clients check whether the post changed without downloading it again
+func (api *API) readPostActivity(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	visible, err := api.postVisibleTo(ctx, postID, api.getUserIDAvoidPanic(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+	if !visible {
+		ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		return
+	}
+
+	activity, err := api.postRepo.FetchPostActivity(postID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, PostActivityResponse{
+		CommentCount: activity.CommentCount,
+		LikeCount:    activity.LikeCount,
+		UpdatedAt:    activity.UpdatedAt.Format("2006-01-02 15:04:05"),
 	})
 }
 
+// readPostRevisions lists what the post looked like after every edit, for its author only unless
+// POST_REVISIONS_PUBLIC is set
+func (api *API) readPostRevisions(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	viewerID := api.getUserIDAvoidPanic(ctx)
+	visible, err := api.postVisibleTo(ctx, postID, viewerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+	if !visible {
+		ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		return
+	}
+
+	authorID, err := api.postRepo.FetchAuthorIDByPostID(postID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	// postVisibleTo leaves out the authors the viewer blocked, the ones who blocked the viewer are hidden here
+	blocked, err := api.followRepo.IsBlocked(authorID, viewerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+	if blocked {
+		ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		return
+	}
+
+	if !api.postRevisionsPublic && viewerID != authorID {
+		ctx.JSON(http.StatusForbidden, ErrorPostResponse{Message: "Only the author can see the revisions of this post"})
+		return
+	}
+
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(ctx, api.postRevisionsPage)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: err.Error()})
+		return
+	}
+
+	revisions, err := api.postRepo.FetchPostRevisions(postID, limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	if viewerID != authorID && !api.canSeeAnonymousAuthors(ctx) {
+		for i := range revisions {
+			if revisions[i].IsAnonymous {
+				revisions[i].EditorID, revisions[i].EditorName = 0, anonymousAuthorName
+			}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"revisions": revisions, "limit": limit})
+}
+
 func (api *API) updatePost(ctx *gin.Context) {
 	var (
 		req = UpdatePostRequest{}
 	)
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		}
 		return
 	}
 
@@ -468,26 +1168,66 @@ func (api *API) updatePost(ctx *gin.Context) {
 		return
 	}
 
-	isTitleOK := service.GetValidationInstance().Validate(req.Title)
-	isDescriptionOK := service.GetValidationInstance().Validate(req.Description)
+	posts, err := api.postRepo.FetchPostByID(req.ID, reqAuthorID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+	if len(posts) == 0 {
+		ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		return
+	}
+
+	if errs := validatePostContent(&req.Title, &req.Description); len(errs) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	if !api.validateCategory(ctx, req.CategoryID) {
+		return
+	}
+
+	isTitleOK := service.GetValidationInstance().Validate(req.Title, requestLocales(ctx)...)
+	isDescriptionOK := service.GetValidationInstance().Validate(req.Description, requestLocales(ctx)...)
 	if !isTitleOK || !isDescriptionOK {
 		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your post contains bad words"})
 		return
 	}
 
-	if err := api.postRepo.UpdatePost(req.ID, req.CategoryID, req.Title, req.Description); err != nil {
+	if err := api.postRepo.UpdatePost(req.ID, reqAuthorID, req.CategoryID, req.Title, req.Description); err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Updated"})
+	previousMentions, err := api.mentionRepo.FetchPostMentions(req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	if err := api.mentionRepo.DeletePostMentions(req.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	// The mentions of an anonymous post aren't saved, their notifications would name the author. A post that isn't
+	// approved gets its mentions from moderatePost once it is
+	mentions := []repository.Mention{}
+	if !posts[0].IsAnonymous && posts[0].ModerationStatus == repository.ModerationApproved {
+		mentions = api.saveMentions(reqAuthorID, req.ID, nil, req.Description, previousMentions)
+	}
+
+	ctx.JSON(http.StatusOK, UpdatePostResponse{
+		SuccessPostResponse: SuccessPostResponse{Message: "Post Updated"},
+		Mentions:            mentions,
+		Warnings:            service.ContentWarnings(req.Title, req.Description),
+	})
 
 }
 
 func (api *API) deletePost(ctx *gin.Context) {
-	postID, err := strconv.Atoi(ctx.Param("id"))
+	postID, err := helper.ParseID(ctx, "id")
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Post ID"})
 		return
 	}
 
@@ -516,6 +1256,242 @@ func (api *API) deletePost(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Deleted"})
 }
 
+// undoPost deletes a post its author just created without the usual confirmation. Once postUndoWindow has passed it
+// responds 410 and the post has to be deleted normally
+func (api *API) undoPost(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	reqAuthorID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	imagePaths, err := api.postRepo.UndoPost(postID, reqAuthorID, postUndoWindow)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrPostNotFound):
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		case errors.Is(err, repository.ErrNotPostAuthor):
+			ctx.JSON(http.StatusForbidden, ErrorPostResponse{Message: "Forbidden"})
+		case errors.Is(err, repository.ErrUndoWindowExpired):
+			ctx.JSON(http.StatusGone, ErrorPostResponse{Message: "The post can only be undone right after it's created, delete it instead"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		}
+		return
+	}
+
+	for _, path := range imagePaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Undone"})
+}
+
+func (api *API) reorderPostImages(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	var req ReorderPostImagesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		return
+	}
+
+	reqAuthorID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	if authorID, err := api.postRepo.FetchAuthorIDByPostID(postID); err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	} else if authorID != reqAuthorID {
+		ctx.JSON(http.StatusForbidden, ErrorPostResponse{Message: "Forbidden"})
+		return
+	}
+
+	if err := api.postRepo.ReorderPostImages(postID, req.ImageIDs); err != nil {
+		if errors.Is(err, repository.ErrInvalidImageOrder) {
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "image_ids should contain every image of the post exactly once"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Images Reordered"})
+}
+
+func (api *API) pinPost(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	if err := api.postRepo.PinPost(postID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrPostNotFound):
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		case errors.Is(err, repository.ErrPinLimitReached):
+			ctx.JSON(http.StatusConflict, ErrorPostResponse{
+				Message: fmt.Sprintf("A category can't have more than %d pinned posts", repository.MaxPinnedPostsPerCategory),
+			})
+		default:
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		}
+		return
+	}
+
+	api.recordAudit(ctx, repository.AuditActionPinPost, repository.AuditTargetPost, postID, nil)
+
+	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Pinned"})
+}
+
+func (api *API) unpinPost(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	if err := api.postRepo.UnpinPost(postID); err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	api.recordAudit(ctx, repository.AuditActionUnpinPost, repository.AuditTargetPost, postID, nil)
+
+	ctx.JSON(http.StatusOK, SuccessPostResponse{Message: "Post Unpinned"})
+}
+
+// toggleCommentsLock locks or unlocks the comments of a post, for its author, the moderators of its category and for
+// admins and moderators
+func (api *API) toggleCommentsLock(ctx *gin.Context) {
+	postID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	claims, err := api.getClaimsFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	moderating := claims.Role == repository.RoleAdmin || claims.Role == repository.RoleModerator
+	if !moderating {
+		if authorID, err := api.postRepo.FetchAuthorIDByPostID(postID); err != nil {
+			if errors.Is(err, repository.ErrPostNotFound) {
+				ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+			return
+		} else if authorID != claims.Id {
+			if !api.postCategoryScope(ctx, claims.Id) {
+				if !ctx.IsAborted() {
+					ctx.JSON(http.StatusForbidden, ErrorPostResponse{Message: "Forbidden"})
+				}
+				return
+			}
+			moderating = true
+		}
+	}
+
+	locked, err := api.postRepo.ToggleCommentsLock(postID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	// Authors locking their own posts isn't moderation, only the moderators are audited
+	if moderating {
+		api.recordAudit(ctx, repository.AuditActionLockComments, repository.AuditTargetPost, postID, gin.H{"locked": locked})
+	}
+
+	ctx.JSON(http.StatusOK, CommentsLockResponse{CommentsLocked: locked})
+}
+
+// bulkDeletePosts rejects the whole batch when any of the posts doesn't belong to the caller
+func (api *API) bulkDeletePosts(ctx *gin.Context) {
+	var req BulkDeletePostsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		return
+	}
+
+	authorID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	postIDs := make([]int, 0, len(req.IDs))
+	seen := make(map[int]bool)
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			postIDs = append(postIDs, id)
+		}
+	}
+
+	authorIDs, err := api.postRepo.FetchPostAuthorIDs(postIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	notOwned := make([]int, 0)
+	for _, id := range postIDs {
+		if postAuthorID, ok := authorIDs[id]; !ok || postAuthorID != authorID {
+			notOwned = append(notOwned, id)
+		}
+	}
+
+	if len(notOwned) > 0 {
+		ctx.JSON(http.StatusForbidden, ForbiddenPostsResponse{
+			ErrorPostResponse: ErrorPostResponse{Message: "Some posts don't belong to you"},
+			IDs:               notOwned,
+		})
+		return
+	}
+
+	imagePaths, err := api.postRepo.DeletePostsByID(postIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	for _, path := range imagePaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, BulkDeletePostsResponse{Deleted: len(postIDs)})
+}
+
 func (api *API) getUserIDAvoidPanic(ctx *gin.Context) (authorID int) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -526,3 +1502,51 @@ func (api *API) getUserIDAvoidPanic(ctx *gin.Context) (authorID int) {
 	authorID, _ = api.getUserIdFromToken(ctx)
 	return
 }
+
+// canSeeAnonymousAuthors is for the admins and moderators, the routes reading posts don't require a token.
+// They also see the pending posts, see postVisibleTo
+func (api *API) canSeeAnonymousAuthors(ctx *gin.Context) bool {
+	if !strings.HasPrefix(ctx.GetHeader("Authorization"), "Bearer ") {
+		return false
+	}
+
+	claims, err := api.getClaimsFromToken(ctx)
+	return err == nil && (claims.Role == repository.RoleAdmin || claims.Role == repository.RoleModerator)
+}
+
+// postVisibleTo tells whether the viewer may read the post and its comments, by the rules of the posts list.
+// Admins and moderators also see the posts that aren't approved, they review them
+func (api *API) postVisibleTo(ctx *gin.Context, postID, viewerID int) (bool, error) {
+	return api.postRepo.PostVisibleTo(postID, viewerID, api.canSeeAnonymousAuthors(ctx))
+}
+
+// maskAnonymousAuthor replaces the author of an anonymous post, revealAuthor keeps them as real_author
+func maskAnonymousAuthor(response *PostResponse, revealAuthor bool) {
+	if !response.IsAnonymous {
+		return
+	}
+
+	if revealAuthor {
+		author := response.Author
+		response.RealAuthor = &author
+	}
+	response.Author = AuthorPostResponse{Name: anonymousAuthorName}
+}
+
+// postUpdatedAt falls back to the creation time for posts that were never edited
+func postUpdatedAt(post repository.PostDetail) string {
+	if post.UpdatedAt.Valid {
+		return post.UpdatedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return post.CreatedAt.Format("2006-01-02 15:04:05")
+}
+
+// validatePostContent also strips dangerous HTML from the description, before the length is checked
+func validatePostContent(title, description *string) []helper.JSONRequestErrorResponse {
+	*description = service.SanitizeMarkdown(*description)
+
+	return helper.ValidateTextFields(
+		helper.TextField{Name: "title", Value: title, Max: helper.MaxTitleLength, Required: true},
+		helper.TextField{Name: "description", Value: description, Max: helper.MaxDescriptionLength},
+	)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/althafariq/discusspedia-be/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// importPostsBatchSize is how many posts are inserted per transaction, a failing batch doesn't undo the others
+const importPostsBatchSize = 100
+
+type ImportPostsRequest struct {
+	// SkipBadWords is for trusted imports, the content was already moderated on the other platform
+	SkipBadWords bool              `json:"skip_bad_words"`
+	Posts        []ImportPostInput `json:"posts" binding:"required,min=1,max=1000"`
+}
+
+// ImportPostInput is validated row by row, CreatedAt is a RFC3339 string so one bad timestamp doesn't reject the
+// whole request
+type ImportPostInput struct {
+	AuthorID    int    `json:"author_id"`
+	CategoryID  int    `json:"category_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ImportPostResult struct {
+	Index  int                               `json:"index"`
+	ID     int64                             `json:"id,omitempty"`
+	Error  string                            `json:"error,omitempty"`
+	Errors []helper.JSONRequestErrorResponse `json:"errors,omitempty"`
+}
+
+type ImportPostsResponse struct {
+	Imported int                `json:"imported"`
+	Failed   int                `json:"failed"`
+	Results  []ImportPostResult `json:"results"`
+}
+
+// ImportPosts bulk-creates posts migrated from another platform, keeping their authors and timestamps. Every row
+// gets its own result, the valid ones are inserted in batched transactions. Imported posts are approved right away
+// and don't notify anyone
+func (api *API) ImportPosts(c *gin.Context) {
+	var request ImportPostsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	results := make([]ImportPostResult, len(request.Posts))
+	posts := make([]repository.ImportedPost, 0, len(request.Posts))
+	indexes := make([]int, 0, len(request.Posts))
+
+	authors := make(map[int]bool)
+	categories := make(map[int]bool)
+	for i, input := range request.Posts {
+		results[i].Index = i
+
+		post, errs, err := api.validateImportedPost(c, input, request.SkipBadWords, authors, categories)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(errs) > 0 {
+			results[i].Errors = errs
+			continue
+		}
+
+		posts = append(posts, post)
+		indexes = append(indexes, i)
+	}
+
+	imported := 0
+	for start := 0; start < len(posts); start += importPostsBatchSize {
+		end := start + importPostsBatchSize
+		if end > len(posts) {
+			end = len(posts)
+		}
+
+		ids, err := api.postRepo.InsertImportedPosts(posts[start:end])
+		if err != nil {
+			log.Printf("import posts %d-%d: %v", indexes[start], indexes[end-1], err)
+			for _, index := range indexes[start:end] {
+				results[index].Error = "Could not insert the post"
+			}
+			continue
+		}
+
+		for i, id := range ids {
+			results[indexes[start+i]].ID = id
+		}
+		imported += len(ids)
+	}
+
+	api.recordAudit(c, repository.AuditActionImportPosts, repository.AuditTargetSystem, 0, gin.H{
+		"imported":       imported,
+		"failed":         len(request.Posts) - imported,
+		"skip_bad_words": request.SkipBadWords,
+	})
+
+	c.JSON(http.StatusOK, ImportPostsResponse{
+		Imported: imported,
+		Failed:   len(request.Posts) - imported,
+		Results:  results,
+	})
+}
+
+// validateImportedPost checks one row like createPost would, authors and categories remember the ids already looked up
+func (api *API) validateImportedPost(
+	c *gin.Context, input ImportPostInput, skipBadWords bool, authors, categories map[int]bool,
+) (repository.ImportedPost, []helper.JSONRequestErrorResponse, error) {
+	errs := validatePostContent(&input.Title, &input.Description)
+
+	createdAt, err := time.Parse(time.RFC3339, input.CreatedAt)
+	if err != nil {
+		errs = append(errs, helper.JSONRequestErrorResponse{Field: "created_at", Message: "Must be a RFC3339 time"})
+	} else if createdAt.After(time.Now()) {
+		errs = append(errs, helper.JSONRequestErrorResponse{Field: "created_at", Message: "Can't be in the future"})
+	}
+
+	exists, ok := authors[input.AuthorID]
+	if !ok {
+		if exists, err = api.userRepo.UserExists(input.AuthorID); err != nil {
+			return repository.ImportedPost{}, nil, err
+		}
+		authors[input.AuthorID] = exists
+	}
+	if !exists {
+		errs = append(errs, helper.JSONRequestErrorResponse{Field: "author_id", Message: "User doesn't exist"})
+	}
+
+	exists, ok = categories[input.CategoryID]
+	if !ok {
+		if exists, err = api.categoryRepo.CategoryExists(input.CategoryID); err != nil {
+			return repository.ImportedPost{}, nil, err
+		}
+		categories[input.CategoryID] = exists
+	}
+	if !exists {
+		errs = append(errs, helper.JSONRequestErrorResponse{Field: "category_id", Message: "Category doesn't exist"})
+	}
+
+	if len(errs) == 0 && !skipBadWords {
+		locales := requestLocales(c)
+		if !service.GetValidationInstance().Validate(input.Title, locales...) ||
+			!service.GetValidationInstance().Validate(input.Description, locales...) {
+			errs = append(errs, helper.JSONRequestErrorResponse{Field: "description", Message: "Contains bad words"})
+		}
+	}
+
+	return repository.ImportedPost{
+		AuthorID:    input.AuthorID,
+		CategoryID:  input.CategoryID,
+		Title:       input.Title,
+		Description: input.Description,
+		CreatedAt:   createdAt,
+	}, errs, nil
+}
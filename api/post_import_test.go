@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Post Import Test", func() {
+	var (
+		postRepo  *mockPostRepo
+		userRepo  *mockUserRepo
+		auditRepo *mockAuditRepo
+		handler   http.Handler
+	)
+
+	BeforeEach(func() {
+		postRepo = &mockPostRepo{}
+		userRepo = &mockUserRepo{}
+		auditRepo = &mockAuditRepo{}
+		mainAPI := newTestAPI(mockRepos{post: postRepo, user: userRepo, category: &mockCategoryRepo{}, audit: auditRepo})
+		handler = mainAPI.Handler()
+	})
+
+	importPosts := func(body string, role string) *httptest.ResponseRecorder {
+		userRepo.roles = map[int]string{3: role}
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/posts/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+newToken(3, func(claims *api.Claims) { claims.Role = role }))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	decode := func(w *httptest.ResponseRecorder) api.ImportPostsResponse {
+		var response api.ImportPostsResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		return response
+	}
+
+	It("should insert the valid rows with their timestamps and report the others", func() {
+		w := importPosts(`{"posts":[
+			{"author_id":1,"category_id":1,"title":" Old post ","description":"From the old forum","created_at":"2019-03-04T10:30:00Z"},
+			{"author_id":9,"category_id":1,"title":"Unknown author","created_at":"2019-03-04T10:30:00Z"},
+			{"author_id":2,"category_id":1,"title":"","created_at":"yesterday"},
+			{"author_id":2,"category_id":1,"title":"Dasar anjing","created_at":"2019-03-05T10:30:00Z"}
+		]}`, "admin")
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		response := decode(w)
+		Expect(response.Imported).To(Equal(1))
+		Expect(response.Failed).To(Equal(3))
+		Expect(response.Results[0].ID).To(BeEquivalentTo(1))
+		Expect(response.Results[1].Errors).To(ConsistOf(HaveField("Field", "author_id")))
+		Expect(response.Results[2].Errors).To(ConsistOf(HaveField("Field", "title"), HaveField("Field", "created_at")))
+		Expect(response.Results[3].Errors).To(ConsistOf(HaveField("Field", "description")))
+
+		Expect(postRepo.posts).To(HaveLen(1))
+		Expect(postRepo.posts[0].Title).To(Equal("Old post"))
+		Expect(postRepo.posts[0].CreatedAt.Equal(time.Date(2019, time.March, 4, 10, 30, 0, 0, time.UTC))).To(BeTrue())
+
+		Expect(auditRepo.entries).To(HaveLen(1))
+		Expect(auditRepo.entries[0].Action).To(Equal(repository.AuditActionImportPosts))
+	})
+
+	It("should skip the bad words check when asked to", func() {
+		w := importPosts(`{"skip_bad_words":true,"posts":[
+			{"author_id":2,"category_id":1,"title":"Dasar anjing","created_at":"2019-03-05T10:30:00Z"}
+		]}`, "admin")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(decode(w).Imported).To(Equal(1))
+	})
+
+	It("should reject an empty import", func() {
+		w := importPosts(`{"posts":[]}`, "admin")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should be admin only", func() {
+		w := importPosts(`{"posts":[{"author_id":1,"category_id":1,"title":"Title","created_at":"2019-03-04T10:30:00Z"}]}`, "moderator")
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(postRepo.posts).To(BeEmpty())
+	})
+})
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type MergePostRequest struct {
+	TargetID int `json:"target_id" binding:"required,min=1"`
+}
+
+type MergePostResponse struct {
+	SuccessPostResponse
+	TargetID      int `json:"target_id"`
+	MovedComments int `json:"moved_comments"`
+	MovedLikes    int `json:"moved_likes"`
+}
+
+// MergePost moves the comments and likes of a duplicate post to the target post and removes the duplicate. The audit
+// entry keeps which post it was merged into, and both authors get notified with the target post
+func (api *API) MergePost(ctx *gin.Context) {
+	sourceID, err := helper.ParseID(ctx, "id")
+	if err != nil {
+		return
+	}
+
+	var req MergePostRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Invalid Request Body"})
+		return
+	}
+
+	moderatorID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your ID cann't read"})
+		return
+	}
+
+	merged, err := api.postRepo.MergePost(sourceID, req.TargetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrPostNotFound):
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Post Not Found"})
+		case errors.Is(err, repository.ErrMergeTargetNotFound):
+			ctx.JSON(http.StatusNotFound, ErrorPostResponse{Message: "Target Post Not Found"})
+		case errors.Is(err, repository.ErrMergeSamePost):
+			ctx.JSON(http.StatusBadRequest, ErrorPostResponse{Message: "A post can't be merged into itself"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, ErrorPostResponse{Message: "Internal Server Error"})
+		}
+		return
+	}
+
+	for _, path := range merged.ImagePaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+	}
+
+	api.recordAudit(ctx, repository.AuditActionMergePost, repository.AuditTargetPost, sourceID, gin.H{
+		"merged_into":    req.TargetID,
+		"moved_comments": merged.MovedComments,
+		"moved_likes":    merged.MovedLikes,
+	})
+
+	api.notifRepo.CreateNotification(merged.SourceAuthorID, moderatorID, repository.NotifTypePostMerged, req.TargetID)
+	if merged.TargetAuthorID != merged.SourceAuthorID {
+		api.notifRepo.CreateNotification(merged.TargetAuthorID, moderatorID, repository.NotifTypePostMerged, req.TargetID)
+	}
+
+	ctx.JSON(http.StatusOK, MergePostResponse{
+		SuccessPostResponse: SuccessPostResponse{Message: "Post Merged"},
+		TargetID:            req.TargetID,
+		MovedComments:       merged.MovedComments,
+		MovedLikes:          merged.MovedLikes,
+	})
+}
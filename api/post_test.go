@@ -0,0 +1,755 @@
+package api_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/golang-jwt/jwt/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Post API Test", func() {
+	var (
+		postRepo *mockPostRepo
+		handler  http.Handler
+	)
+
+	BeforeEach(func() {
+		postRepo = &mockPostRepo{}
+		mainAPI := newTestAPI(mockRepos{post: postRepo})
+		handler = mainAPI.Handler()
+	})
+
+	createPost := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(`{"category_id":1,"title":"Title","description":"Description"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	Describe("createPost", func() {
+		When("no token is given", func() {
+			It("should return 401 without inserting the post", func() {
+				w := createPost("")
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+				Expect(postRepo.insertPostCalls).To(Equal(0))
+			})
+		})
+
+		When("token is signed with another key", func() {
+			It("should return 401 without inserting the post", func() {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &api.Claims{
+					Id:   1,
+					Role: "mahasiswa",
+					StandardClaims: jwt.StandardClaims{
+						ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					},
+				}).SignedString([]byte("not the key"))
+				Expect(err).ToNot(HaveOccurred())
+
+				w := createPost("Bearer " + token)
+				Expect(w.Code).To(Equal(http.StatusUnauthorized))
+				Expect(postRepo.insertPostCalls).To(Equal(0))
+			})
+		})
+
+		When("required fields are missing", func() {
+			It("should return 400 with a message per field", func() {
+				mainAPI := newTestAPI(mockRepos{post: postRepo, user: &mockUserRepo{}, category: &mockCategoryRepo{}})
+				handler = mainAPI.Handler()
+
+				req := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(`{"description":"Description"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+				var response struct {
+					Errors []helper.JSONRequestErrorResponse `json:"errors"`
+				}
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Errors).To(ConsistOf(
+					helper.JSONRequestErrorResponse{Field: "category_id", Message: "This field is required"},
+					helper.JSONRequestErrorResponse{Field: "title", Message: "This field is required"},
+				))
+				Expect(postRepo.insertPostCalls).To(Equal(0))
+			})
+		})
+
+		When("pre-moderation is on", func() {
+			It("should create the post as pending without publishing it", func() {
+				cfg := config.Default()
+				cfg.PreModeration = true
+				mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, user: &mockUserRepo{}, category: &mockCategoryRepo{}})
+				handler = mainAPI.Handler()
+
+				w := createPost("Bearer " + newToken(1, nil))
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(postRepo.insertPostCalls).To(Equal(1))
+
+				var response api.CreatePostResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.ModerationStatus).To(Equal(repository.ModerationPending))
+			})
+		})
+
+		When("the author just created the same post", func() {
+			It("should return 409 with the id of that post without inserting it again", func() {
+				postRepo.posts = []repository.Post{{ID: 7, Title: "Title", Description: "Description"}}
+				mainAPI := newTestAPI(mockRepos{post: postRepo, user: &mockUserRepo{}, category: &mockCategoryRepo{}})
+				handler = mainAPI.Handler()
+
+				w := createPost("Bearer " + newToken(1, nil))
+				Expect(w.Code).To(Equal(http.StatusConflict))
+
+				var response api.DuplicatePostResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.ID).To(Equal(int64(7)))
+				Expect(postRepo.insertPostCalls).To(Equal(0))
+			})
+		})
+
+		When("the media dir isn't media", func() {
+			BeforeEach(func() {
+				postRepo.detailPosts = []repository.PostDetail{{
+					ID: 3, AuthorID: 1, AuthorName: "Radit", Title: "Title", Description: "Description",
+					AuthorAvatar: sql.NullString{String: "/srv/uploads/avatar/Radit_1.png", Valid: true},
+					ImageID:      sql.NullInt32{Int32: 7, Valid: true},
+					ImagePath:    sql.NullString{String: "/srv/uploads/post/1 photo.png", Valid: true},
+				}}
+
+				cfg := config.Default()
+				cfg.MediaDir = "/srv/uploads"
+				mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, mention: &mockMentionRepo{}})
+				handler = mainAPI.Handler()
+			})
+
+			It("should build the URLs from the folder the files are served under", func() {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/post/3", nil))
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				var response api.DetailPostResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Images).To(Equal([]api.PostImageResponse{{ID: 7, URL: "media/post/1%20photo.png"}}))
+				Expect(response.Author.ProfileImage).To(Equal("media/avatar/Radit_1.png"))
+			})
+		})
+
+		When("the post is anonymous", func() {
+			createAnonymousPost := func(category *mockCategoryRepo) *httptest.ResponseRecorder {
+				mainAPI := newTestAPI(mockRepos{post: postRepo, user: &mockUserRepo{}, category: category})
+				handler = mainAPI.Handler()
+
+				req := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(`{"category_id":1,"title":"Title","description":"Description","anonymous":true}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				return w
+			}
+
+			It("should store it as anonymous when the category allows it", func() {
+				w := createAnonymousPost(&mockCategoryRepo{allowAnonymous: true})
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(postRepo.anonymousPosts).To(Equal([]int{1}))
+			})
+
+			It("should return 400 when the category doesn't allow it", func() {
+				w := createAnonymousPost(&mockCategoryRepo{})
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(postRepo.insertPostCalls).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("readPosts", func() {
+		readPosts := func(query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/post"+query, nil))
+			return w
+		}
+
+		It("should filter unanswered posts by their comment count", func() {
+			Expect(readPosts("?unanswered=true&category_id=2").Code).To(Equal(http.StatusOK))
+			Expect(readPosts("?unanswered=false").Code).To(Equal(http.StatusOK))
+			Expect(readPosts("?unanswered=maybe").Code).To(Equal(http.StatusBadRequest))
+
+			Expect(postRepo.filters).To(HaveLen(2))
+			Expect(postRepo.filters[0]).To(ContainSubstring("category_id = 2"))
+			Expect(postRepo.filters[0]).To(ContainSubstring("p.comment_count = 0"))
+			Expect(postRepo.filters[1]).To(ContainSubstring("p.comment_count > 0"))
+		})
+
+		It("should filter by the normalized author role", func() {
+			Expect(readPosts("?author_role=%20Mahasiswa&unanswered=true").Code).To(Equal(http.StatusOK))
+			Expect(readPosts("?author_role=teacher").Code).To(Equal(http.StatusBadRequest))
+
+			Expect(postRepo.filters).To(HaveLen(1))
+			Expect(postRepo.filters[0]).To(ContainSubstring("u.role = ? AND p.is_anonymous = 0"))
+			Expect(postRepo.filters[0]).To(ContainSubstring("p.comment_count = 0"))
+			Expect(postRepo.filterArgs[0]).To(Equal([]interface{}{"mahasiswa"}))
+		})
+
+		It("should filter the posts of lecturers", func() {
+			Expect(readPosts("?author_role=Lecturer&sort_by=most_liked").Code).To(Equal(http.StatusOK))
+
+			Expect(postRepo.filters[0]).To(ContainSubstring("u.role = ?"))
+			Expect(postRepo.filterArgs[0]).To(Equal([]interface{}{repository.RoleLecturer}))
+		})
+
+		It("should bind the searched title instead of formatting it into the query", func() {
+			Expect(readPosts("?search_title=" + url.QueryEscape("100%' OR 1=1 --") + "&author_role=siswa").Code).To(Equal(http.StatusOK))
+
+			Expect(postRepo.filters[0]).To(ContainSubstring(`p.title LIKE ? ESCAPE '\'`))
+			Expect(postRepo.filters[0]).ToNot(ContainSubstring("OR 1=1"))
+			Expect(postRepo.filterArgs[0]).To(Equal([]interface{}{`%100\%' OR 1=1 --%`, repository.RoleSiswa}))
+		})
+
+		It("should keep the named presets", func() {
+			Expect(readPosts("").Code).To(Equal(http.StatusOK))
+			Expect(readPosts("?sort_by=most_liked").Code).To(Equal(http.StatusOK))
+			Expect(postRepo.orderBys).To(Equal([]string{"p.created_at DESC", "like_count DESC"}))
+		})
+
+		It("should build the order clause from the direction and tiebreaker", func() {
+			Expect(readPosts("?sort_by=like_count&order=asc&then=created_at").Code).To(Equal(http.StatusOK))
+			Expect(readPosts("?sort_by=newest&order=asc&then=title&then_order=asc").Code).To(Equal(http.StatusOK))
+			Expect(postRepo.orderBys).To(Equal([]string{"like_count, p.created_at DESC", "p.created_at, p.title"}))
+		})
+
+		When("fields=compact is given", func() {
+			It("should return the posts without images and with a plain text snippet", func() {
+				postRepo.compactPosts = []repository.PostDetail{{
+					ID: 3, AuthorID: 2, AuthorName: "Bocil SMA", CategoryID: 1, Title: "Title",
+					Description: "**Bold** start\n\n" + strings.Repeat("kafé ", 60),
+					CreatedAt:   time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC), LikeCount: 4,
+				}}
+
+				w := readPosts("?fields=compact")
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				var response []map[string]interface{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response).To(HaveLen(1))
+				Expect(response[0]).ToNot(HaveKey("images"))
+				Expect(response[0]).ToNot(HaveKey("author"))
+				snippet := response[0]["snippet"].(string)
+				Expect(snippet).To(HavePrefix("Bold start kafé kafé"))
+				Expect(snippet).To(HaveSuffix(" kafé…"))
+				Expect(utf8.RuneCountInString(snippet)).To(BeNumerically("<=", 201))
+				Expect(response[0]["like_count"]).To(BeEquivalentTo(4))
+			})
+
+			It("should reject any other shape", func() {
+				Expect(readPosts("?fields=everything").Code).To(Equal(http.StatusBadRequest))
+				Expect(postRepo.orderBys).To(BeEmpty())
+			})
+		})
+
+		When("a list of fields is given", func() {
+			It("should only return those fields of the full shape", func() {
+				postRepo.detailPosts = []repository.PostDetail{
+					{ID: 3, AuthorID: 2, AuthorName: "Bocil SMA", Title: "First", Description: "Description", LikeCount: 4},
+					{ID: 4, AuthorID: 1, AuthorName: "Radit", Title: "Second", Description: "Description"},
+				}
+
+				w := readPosts("?fields=id,%20title,like_count,images")
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(MatchJSON(`[
+					{"id":3,"title":"First","like_count":4,"images":[]},
+					{"id":4,"title":"Second","like_count":0,"images":[]}
+				]`))
+			})
+
+			It("should return 400 for unknown fields without querying the posts", func() {
+				for _, query := range []string{"?fields=id,password", "?fields=id,,title", "?fields=mentions"} {
+					Expect(readPosts(query).Code).To(Equal(http.StatusBadRequest), query)
+				}
+				Expect(postRepo.orderBys).To(BeEmpty())
+			})
+		})
+
+		When("a sort key isn't in the allowlist", func() {
+			It("should return 400 without querying the posts", func() {
+				for _, query := range []string{
+					"?sort_by=id",
+					"?sort_by=like_count%20DESC",
+					"?sort_by=title&order=sideways",
+					"?then=author_id",
+					"?sort_by=controversial&order=asc",
+				} {
+					Expect(readPosts(query).Code).To(Equal(http.StatusBadRequest), query)
+				}
+				Expect(postRepo.orderBys).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("uploadPostImages", func() {
+		var mediaDir string
+
+		BeforeEach(func() {
+			var err error
+			mediaDir, err = os.MkdirTemp("", "media")
+			Expect(err).ToNot(HaveOccurred())
+
+			cfg := config.Default()
+			cfg.MediaDir = mediaDir
+			mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, user: &mockUserRepo{}})
+			Expect(mainAPI.CreateMediaDirs()).To(Succeed())
+			handler = mainAPI.Handler()
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(mediaDir)
+		})
+
+		When("filename tries to leave the media dir", func() {
+			It("should store the file inside the post folder with a safe name", func() {
+				token := newToken(1, nil)
+
+				body := &bytes.Buffer{}
+				writer := multipart.NewWriter(body)
+				for _, filename := range []string{"../../../evil.png", `..\..\evil.png`, "..", "<script>.png"} {
+					part, err := writer.CreateFormFile("images", filename)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = part.Write([]byte("image"))
+					Expect(err).ToNot(HaveOccurred())
+				}
+				Expect(writer.Close()).To(Succeed())
+
+				req := httptest.NewRequest(http.MethodPost, "/api/post/images/1", body)
+				req.Header.Set("Content-Type", writer.FormDataContentType())
+				req.Header.Set("Authorization", "Bearer "+token)
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				var response api.UploadPostImagesResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Results).To(HaveLen(4))
+				for _, result := range response.Results {
+					Expect(result.Error).To(BeEmpty())
+					Expect(postRepo.imagePaths).To(ContainElement(result.URL))
+				}
+
+				postFolder := filepath.Join(mediaDir, "post")
+				Expect(postRepo.imagePaths).To(HaveLen(4))
+				for _, path := range postRepo.imagePaths {
+					Expect(filepath.Dir(path)).To(Equal(postFolder))
+					Expect(filepath.Base(path)).To(MatchRegexp(`^[A-Za-z0-9._-]+$`))
+					Expect(path).To(BeAnExistingFile())
+				}
+
+				entries, err := os.ReadDir(mediaDir)
+				Expect(err).ToNot(HaveOccurred())
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				Expect(names).To(ConsistOf("avatar", "post", "questionnaire", "resized"))
+			})
+		})
+
+		uploadImage := func(filename string) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, err := writer.CreateFormFile("images", filename)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = part.Write([]byte("image"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/post/images/1", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusOK))
+		}
+
+		It("should store the file under a random name and keep the original name by default", func() {
+			uploadImage("My Holiday.PNG")
+
+			Expect(postRepo.imagePaths).To(HaveLen(1))
+			Expect(filepath.Base(postRepo.imagePaths[0])).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.png$`))
+			Expect(postRepo.originalNames).To(Equal([]string{"My Holiday.PNG"}))
+		})
+
+		When("the slug strategy is configured", func() {
+			BeforeEach(func() {
+				cfg := config.Default()
+				cfg.MediaDir = mediaDir
+				cfg.UploadFileNameStrategy = config.FileNameStrategySlug
+				mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, user: &mockUserRepo{}})
+				handler = mainAPI.Handler()
+			})
+
+			It("should store the file under its sanitized original name", func() {
+				uploadImage("My Holiday.PNG")
+
+				Expect(postRepo.imagePaths).To(HaveLen(1))
+				Expect(filepath.Base(postRepo.imagePaths[0])).To(MatchRegexp(`^1-[0-9]+-MyHoliday\.PNG$`))
+				Expect(postRepo.originalNames).To(Equal([]string{"My Holiday.PNG"}))
+			})
+		})
+	})
+
+	Describe("readPost", func() {
+		It("should reject ids that aren't positive without querying the post", func() {
+			for _, id := range []string{"0", "-3", "abc"} {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/post/"+id, nil))
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(MatchJSON(`{"error":"invalid id"}`))
+			}
+		})
+
+		When("a list of fields is given", func() {
+			BeforeEach(func() {
+				postRepo.detailPosts = []repository.PostDetail{
+					{ID: 3, AuthorID: 2, AuthorName: "Bocil SMA", Title: "Title", Description: "Description", CommentCount: 2},
+				}
+				mainAPI := newTestAPI(mockRepos{post: postRepo, mention: &mockMentionRepo{}})
+				handler = mainAPI.Handler()
+			})
+
+			readPost := func(query string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/post/3"+query, nil))
+				return w
+			}
+
+			It("should only return those fields", func() {
+				w := readPost("?fields=id,author,comment_count,mentions")
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				var response map[string]interface{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				// Mentions are left out when there are none, like in the full response
+				Expect(response).To(HaveLen(3))
+				Expect(response).To(HaveKeyWithValue("id", BeEquivalentTo(3)))
+				Expect(response["author"]).To(HaveKeyWithValue("name", "Bocil SMA"))
+				Expect(response["comment_count"]).To(BeEquivalentTo(2))
+			})
+
+			It("should return 400 for unknown fields", func() {
+				w := readPost("?fields=id,description_snippet")
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(MatchJSON(`{"error":"Unknown Field \"description_snippet\""}`))
+			})
+		})
+
+		When("the post is anonymous", func() {
+			BeforeEach(func() {
+				postRepo.detailPosts = []repository.PostDetail{
+					{ID: 3, AuthorID: 2, AuthorName: "Bocil SMA", Title: "Title", Description: "Description", IsAnonymous: true},
+				}
+				mainAPI := newTestAPI(mockRepos{post: postRepo, mention: &mockMentionRepo{}})
+				handler = mainAPI.Handler()
+			})
+
+			readPost := func(authorization string) api.DetailPostResponse {
+				req := httptest.NewRequest(http.MethodGet, "/api/post/3", nil)
+				if authorization != "" {
+					req.Header.Set("Authorization", authorization)
+				}
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				Expect(w.Code).To(Equal(http.StatusOK))
+
+				var response api.DetailPostResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				return response
+			}
+
+			It("should mask the author", func() {
+				response := readPost("")
+				Expect(response.Author).To(Equal(api.AuthorPostResponse{Name: "Anonymous"}))
+				Expect(response.RealAuthor).To(BeNil())
+			})
+
+			It("should give admins the real author", func() {
+				response := readPost("Bearer " + newToken(1, func(claims *api.Claims) { claims.Role = "admin" }))
+				Expect(response.Author.Name).To(Equal("Anonymous"))
+				Expect(response.RealAuthor).ToNot(BeNil())
+				Expect(response.RealAuthor.ID).To(Equal(2))
+				Expect(response.RealAuthor.Name).To(Equal("Bocil SMA"))
+			})
+		})
+	})
+
+	Describe("readPostRevisions", func() {
+		var followRepo *mockFollowRepo
+
+		BeforeEach(func() {
+			followRepo = &mockFollowRepo{}
+			mainAPI := newTestAPI(mockRepos{post: postRepo, follow: followRepo})
+			handler = mainAPI.Handler()
+			postRepo.revisions = []repository.PostRevision{
+				{ID: 2, PostID: 1, EditorID: 1, EditorName: "Radit", CategoryID: 1, Title: "Edited", Description: "Description"},
+				{ID: 1, PostID: 1, EditorID: 1, EditorName: "Radit", CategoryID: 1, Title: "Title", Description: "Description"},
+			}
+		})
+
+		readRevisions := func(authorization string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/api/post/1/revisions", nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should return the revisions to the author", func() {
+			w := readRevisions("Bearer " + newToken(1, nil))
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response struct {
+				Revisions []repository.PostRevision `json:"revisions"`
+				Limit     int                       `json:"limit"`
+			}
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Revisions).To(HaveLen(2))
+			Expect(response.Revisions[0].Title).To(Equal("Edited"))
+			Expect(response.Revisions[0].EditorName).To(Equal("Radit"))
+			Expect(response.Limit).To(Equal(20))
+		})
+
+		It("should be forbidden for everyone else", func() {
+			Expect(readRevisions("Bearer " + newToken(2, nil)).Code).To(Equal(http.StatusForbidden))
+			Expect(readRevisions("").Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should return 404 to the users the author blocked", func() {
+			followRepo.blockers = []int{1}
+			cfg := config.Default()
+			cfg.PostRevisionsPublic = true
+			mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, follow: followRepo})
+			handler = mainAPI.Handler()
+
+			Expect(readRevisions("Bearer " + newToken(2, nil)).Code).To(Equal(http.StatusNotFound))
+			Expect(readRevisions("Bearer " + newToken(1, nil)).Code).To(Equal(http.StatusOK))
+		})
+
+		It("should return 404 when the post isn't visible, like its activity", func() {
+			postRepo.hidden = []int{1}
+			cfg := config.Default()
+			cfg.PostRevisionsPublic = true
+			mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, follow: followRepo})
+			handler = mainAPI.Handler()
+
+			Expect(readRevisions("Bearer " + newToken(2, nil)).Code).To(Equal(http.StatusNotFound))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/post/1/activity", nil))
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		When("the revisions are public", func() {
+			It("should return them without a token", func() {
+				cfg := config.Default()
+				cfg.PostRevisionsPublic = true
+				mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, follow: followRepo})
+				handler = mainAPI.Handler()
+
+				Expect(readRevisions("").Code).To(Equal(http.StatusOK))
+			})
+
+			It("should hide who edited an anonymous post from everyone but its author and the staff", func() {
+				for i := range postRepo.revisions {
+					postRepo.revisions[i].IsAnonymous = true
+				}
+				cfg := config.Default()
+				cfg.PostRevisionsPublic = true
+				mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: postRepo, follow: followRepo})
+				handler = mainAPI.Handler()
+
+				editors := func(authorization string) []string {
+					w := readRevisions(authorization)
+					Expect(w.Code).To(Equal(http.StatusOK))
+
+					var response struct {
+						Revisions []repository.PostRevision `json:"revisions"`
+					}
+					Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+
+					names := []string{}
+					for _, revision := range response.Revisions {
+						names = append(names, fmt.Sprintf("%d %s", revision.EditorID, revision.EditorName))
+					}
+					return names
+				}
+
+				Expect(editors("")).To(Equal([]string{"0 Anonymous", "0 Anonymous"}))
+				Expect(editors("Bearer " + newToken(2, nil))).To(Equal([]string{"0 Anonymous", "0 Anonymous"}))
+				Expect(editors("Bearer " + newToken(1, nil))).To(Equal([]string{"1 Radit", "1 Radit"}))
+				Expect(editors("Bearer " + newToken(3, func(claims *api.Claims) { claims.Role = "moderator" }))).To(Equal([]string{"1 Radit", "1 Radit"}))
+			})
+		})
+	})
+
+	Describe("updatePost", func() {
+		var (
+			mentionRepo *mockMentionRepo
+			notifRepo   *mockNotifRepo
+		)
+
+		BeforeEach(func() {
+			mentionRepo, notifRepo = &mockMentionRepo{}, &mockNotifRepo{}
+			mainAPI := newTestAPI(mockRepos{
+				post: postRepo, user: &mockUserRepo{}, mention: mentionRepo, notif: notifRepo, category: &mockCategoryRepo{},
+			})
+			handler = mainAPI.Handler()
+		})
+
+		updatePost := func(post repository.PostDetail) *httptest.ResponseRecorder {
+			post.ID = 1
+			postRepo.detailPosts = []repository.PostDetail{post}
+
+			body := `{"id":1,"category_id":1,"title":"Title","description":"Thanks @Bocil"}`
+			req := httptest.NewRequest(http.MethodPut, "/api/post", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should notify the users mentioned in the edit", func() {
+			w := updatePost(repository.PostDetail{ModerationStatus: repository.ModerationApproved})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(mentionRepo.inserted).To(HaveLen(1))
+			Expect(notifRepo.created).To(Equal([]string{repository.NotifTypePostMention}))
+		})
+
+		It("should leave the mentions of a post that isn't approved to the moderation", func() {
+			for _, status := range []string{repository.ModerationPending, repository.ModerationRejected} {
+				w := updatePost(repository.PostDetail{ModerationStatus: status})
+				Expect(w.Code).To(Equal(http.StatusOK))
+			}
+			Expect(postRepo.updated).To(Equal([]int{1, 1}))
+			Expect(mentionRepo.inserted).To(BeEmpty())
+			Expect(notifRepo.created).To(BeEmpty())
+		})
+
+		It("should not save the mentions of an anonymous post", func() {
+			w := updatePost(repository.PostDetail{ModerationStatus: repository.ModerationApproved, IsAnonymous: true})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(postRepo.updated).To(Equal([]int{1}))
+			Expect(mentionRepo.inserted).To(BeEmpty())
+			Expect(notifRepo.created).To(BeEmpty())
+		})
+	})
+
+	Describe("undoPost", func() {
+		undo := func() *httptest.ResponseRecorder {
+			mainAPI := newTestAPI(mockRepos{post: postRepo, user: &mockUserRepo{}})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/post/5/undo", nil)
+			req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+			w := httptest.NewRecorder()
+			mainAPI.Handler().ServeHTTP(w, req)
+			return w
+		}
+
+		It("should undo the post within the window", func() {
+			Expect(undo().Code).To(Equal(http.StatusOK))
+			Expect(postRepo.undone).To(Equal([]int{5}))
+		})
+
+		It("should respond 410 once the window has passed", func() {
+			postRepo.undoErr = repository.ErrUndoWindowExpired
+			Expect(undo().Code).To(Equal(http.StatusGone))
+
+			postRepo.undoErr = repository.ErrNotPostAuthor
+			Expect(undo().Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("saved searches", func() {
+		BeforeEach(func() {
+			mainAPI := newTestAPI(mockRepos{post: postRepo, user: &mockUserRepo{}})
+			handler = mainAPI.Handler()
+		})
+
+		request := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should only keep the params of the posts list", func() {
+			w := request(http.MethodPost, "/api/me/saved-searches", `{"name":"Paged","params":{"offset":"20"}}`)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+
+			w = request(http.MethodPost, "/api/me/saved-searches", `{"name":" ","params":{"category_id":"2"}}`)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(postRepo.savedSearches).To(BeEmpty())
+
+			w = request(http.MethodPost, "/api/me/saved-searches", `{"name":"Oldest of category 2","params":{"category_id":"2","sort_by":"oldest"}}`)
+			Expect(w.Code).To(Equal(http.StatusCreated))
+			Expect(w.Body.String()).To(ContainSubstring(`"params":{"category_id":"2","sort_by":"oldest"}`))
+			Expect(postRepo.savedSearches[0].Query).To(Equal("category_id=2&sort_by=oldest"))
+		})
+
+		It("should run the saved params with the page of the request", func() {
+			postRepo.savedSearches = []repository.SavedSearch{{ID: 1, Name: "Oldest", Query: "category_id=2&sort_by=oldest"}}
+
+			w := request(http.MethodGet, "/api/me/saved-searches/1/posts?limit=5&category_id=3", "")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("X-Page-Limit")).To(Equal("5"))
+			Expect(postRepo.orderBys).To(Equal([]string{"is_pinned DESC, p.created_at"}))
+			Expect(postRepo.filters[0]).To(ContainSubstring("category_id = 2"))
+
+			Expect(request(http.MethodGet, "/api/me/saved-searches/2/posts", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should run the search under the version it was called with", func() {
+			postRepo.savedSearches = []repository.SavedSearch{{ID: 1, Name: "Oldest", Query: "sort_by=oldest"}}
+
+			w := request(http.MethodGet, "/api/v1/me/saved-searches/1/posts", "")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header()).ToNot(HaveKey("Deprecation"))
+
+			w = request(http.MethodGet, "/api/me/saved-searches/1/posts", "")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Deprecation")).To(Equal("true"))
+		})
+	})
+})
@@ -1,8 +1,13 @@
 package api
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/althafariq/discusspedia-be/repository"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,6 +25,22 @@ type UserResponse struct {
 	Institute string `json:"institute"`
 	Major     string `json:"major"`
 	Batch     int    `json:"batch"`
+
+	LastActiveAt *time.Time `json:"last_active_at"`
+	ActiveNow    bool       `json:"active_now"`
+
+	// Privacy is only included for the owner of the profile
+	Privacy *repository.PrivacySettings `json:"privacy,omitempty"`
+}
+
+type MeResponse struct {
+	UserResponse
+	Stats repository.UserStats `json:"stats"`
+}
+
+type UpdatePrivacyRequest struct {
+	LikesPublic     *bool `json:"likes_public"`
+	BookmarksPublic *bool `json:"bookmarks_public"`
 }
 
 type Response struct {
@@ -39,13 +60,53 @@ func (api *API) getProfile(ctx *gin.Context) {
 		return
 	}
 
+	privacy, err := api.userRepo.GetPrivacySettings(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, Response{Message: "Internal Server Error"})
+		return
+	}
+
+	response := newUserResponse(user)
+	response.Privacy = &privacy
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getMe is the profile of the caller together with their cached stats, so clients don't need their own id after login
+func (api *API) getMe(ctx *gin.Context) {
+	userID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, Response{"Unauthorized"})
+		return
+	}
+
+	// A deleted account can't be the caller even if its token hasn't expired yet
+	stats, err := api.userRepo.GetUserStats(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			ctx.JSON(http.StatusUnauthorized, Response{"Unauthorized"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{Message: "Internal Server Error"})
+		return
+	}
+
+	user, err := api.userRepo.GetUserData(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, Response{Message: "Internal Server Error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MeResponse{UserResponse: newUserResponse(user), Stats: stats})
+}
+
+func newUserResponse(user *repository.User) UserResponse {
 	var (
 		userAvatar, userMajor string
 		userBatch             int
 	)
 
 	if user.Avatar != nil {
-		userAvatar = *user.Avatar
+		userAvatar = mediaURL("avatar", *user.Avatar)
 	}
 
 	if user.Major != nil {
@@ -56,7 +117,7 @@ func (api *API) getProfile(ctx *gin.Context) {
 		userBatch = *user.Batch
 	}
 
-	ctx.JSON(http.StatusOK, UserResponse{
+	return UserResponse{
 		ID:        user.Id,
 		Name:      user.Name,
 		Email:     user.Email,
@@ -65,7 +126,45 @@ func (api *API) getProfile(ctx *gin.Context) {
 		Institute: user.Institute,
 		Major:     userMajor,
 		Batch:     userBatch,
-	})
+
+		LastActiveAt: user.LastActiveAt,
+		ActiveNow:    user.LastActiveAt != nil && time.Since(*user.LastActiveAt) < activeNowWindow,
+	}
+}
+
+// updatePrivacy only changes the settings that are given
+func (api *API) updatePrivacy(ctx *gin.Context) {
+	var request UpdatePrivacyRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{Message: "Invalid Request"})
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, Response{"Unauthorized"})
+		return
+	}
+
+	settings, err := api.userRepo.GetPrivacySettings(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, Response{Message: "Internal Server Error"})
+		return
+	}
+
+	if request.LikesPublic != nil {
+		settings.LikesPublic = *request.LikesPublic
+	}
+	if request.BookmarksPublic != nil {
+		settings.BookmarksPublic = *request.BookmarksPublic
+	}
+
+	if err := api.userRepo.UpdatePrivacySettings(userID, settings); err != nil {
+		ctx.JSON(http.StatusInternalServerError, Response{Message: "Internal Server Error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, settings)
 }
 
 func (api *API) updateProfile(ctx *gin.Context) {
@@ -88,3 +187,29 @@ func (api *API) updateProfile(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, Response{Message: "Successfully Updated"})
 }
+
+func (api *API) deleteAccount(ctx *gin.Context) {
+	userID, err := api.getUserIdFromToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, Response{"Unauthorized"})
+		return
+	}
+
+	filePaths, err := api.userRepo.DeleteAccount(userID, api.deleteContentOnAccountDeletion)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{Message: "Internal Server Error"})
+		return
+	}
+
+	for _, filePath := range filePaths {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, Response{Message: "Account Deleted"})
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/althafariq/discusspedia-be/helper"
@@ -32,18 +35,9 @@ type UpdateQuestionnaireRequest struct {
 }
 
 func (api *API) ReadAllQuestionnaires(c *gin.Context) {
-	sortBy := c.DefaultQuery("sort_by", "newest")
-	switch sortBy {
-	case "newest":
-		sortBy = "created_at DESC"
-	case "oldest":
-		sortBy = "created_at"
-	case "most_liked":
-		sortBy = "total_like DESC"
-	case "most_commented":
-		sortBy = "total_comment DESC"
-	default:
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Sort By"})
+	sortBy, err := questionnaireSortOptions.orderBy(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -85,7 +79,7 @@ func (api *API) ReadAllQuestionnaires(c *gin.Context) {
 		}
 	}
 
-	questionnaires, err := api.questionnaireRepo.ReadAllQuestionnaires(userID, filterQuery, sortBy)
+	questionnaires, err := api.questionnaireRepo.ReadAllQuestionnaires(userID, filterQuery, sortBy, -1, 0)
 	if err != nil {
 		c.AbortWithStatusJSON(
 			http.StatusInternalServerError,
@@ -100,13 +94,96 @@ func (api *API) ReadAllQuestionnaires(c *gin.Context) {
 	)
 }
 
+func (api *API) ReadQuestionnairesByAuthor(c *gin.Context) {
+	authorID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	exists, err := api.userRepo.UserExists(authorID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+		return
+	}
+
+	api.respondWithAuthorQuestionnaires(c, api.getUserIDAvoidPanic(c), authorID)
+}
+
+// ReadMyQuestionnaires always lists the caller's own questionnaires, unlike the optional me filter of ReadAllQuestionnaires
+func (api *API) ReadMyQuestionnaires(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.respondWithAuthorQuestionnaires(c, userID, userID)
+}
+
+// ReadQuestionnaireFeed lists the questionnaires of the categories the caller engages with that they haven't answered yet
+func (api *API) ReadQuestionnaireFeed(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.userQuestionnairesPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	questionnaires, err := api.questionnaireRepo.FetchQuestionnaireFeed(userID, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, questionnaires)
+}
+
+func (api *API) respondWithAuthorQuestionnaires(c *gin.Context, viewerID, authorID int) {
+	sortBy, err := questionnaireSortOptions.orderBy(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.userQuestionnairesPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	questionnaires, err := api.questionnaireRepo.ReadAllQuestionnaires(viewerID, fmt.Sprintf("author_id = %d", authorID), sortBy, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, questionnaires)
+}
+
 func (api *API) ReadAllQuestionnaireByID(c *gin.Context) {
-	postID, err := strconv.Atoi(c.Param("id"))
+	postID, err := helper.ParseID(c, "id")
 	if err != nil {
-		c.AbortWithStatusJSON(
-			http.StatusBadRequest,
-			gin.H{"error": "id should be a int"},
-		)
 		return
 	}
 
@@ -127,7 +204,7 @@ func (api *API) ReadAllQuestionnaireByID(c *gin.Context) {
 		)
 		return
 	}
-	if questionnaire == (repository.Questionnaire{}) {
+	if questionnaire.ID == 0 {
 		c.AbortWithStatusJSON(
 			http.StatusBadRequest,
 			gin.H{"error": "No data with given id"},
@@ -166,8 +243,17 @@ func (api *API) CreateQuestionnaire(c *gin.Context) {
 		return
 	}
 
-	isTitleOK := service.GetValidationInstance().Validate(createQuestionnaireRequest.Title)
-	isDescriptionOK := service.GetValidationInstance().Validate(createQuestionnaireRequest.Description)
+	if errs := validateQuestionnaireContent(&createQuestionnaireRequest.Title, &createQuestionnaireRequest.Description); len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	if !api.validateCategory(c, createQuestionnaireRequest.CategoryID) {
+		return
+	}
+
+	isTitleOK := service.GetValidationInstance().Validate(createQuestionnaireRequest.Title, requestLocales(c)...)
+	isDescriptionOK := service.GetValidationInstance().Validate(createQuestionnaireRequest.Description, requestLocales(c)...)
 	if !isTitleOK || !isDescriptionOK {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your post contains bad words"})
 		return
@@ -179,7 +265,35 @@ func (api *API) CreateQuestionnaire(c *gin.Context) {
 		return
 	}
 
-	err = api.questionnaireRepo.InsertQuestionnaire(repository.Questionnaire{
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Force"})
+		return
+	}
+
+	if !api.beginIdempotentRequest(c, userID, idempotencyScopeQuestionnaire) {
+		return
+	}
+
+	// Checked after the idempotency key so a retry replays the created questionnaire instead of conflicting with it
+	if !force {
+		exists, err := api.questionnaireRepo.QuestionnaireTitleExists(userID, createQuestionnaireRequest.Title)
+		if err != nil {
+			api.releaseIdempotentRequest(c, userID, idempotencyScopeQuestionnaire)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if exists {
+			api.releaseIdempotentRequest(c, userID, idempotencyScopeQuestionnaire)
+			c.AbortWithStatusJSON(
+				http.StatusConflict,
+				gin.H{"error": "You already have a questionnaire with this title, use force=true to create it anyway"},
+			)
+			return
+		}
+	}
+
+	postID, err := api.questionnaireRepo.InsertQuestionnaire(repository.Questionnaire{
 		Author: repository.User{
 			Id: userID,
 		},
@@ -192,6 +306,7 @@ func (api *API) CreateQuestionnaire(c *gin.Context) {
 		Reward:      createQuestionnaireRequest.Reward,
 	})
 	if err != nil {
+		api.releaseIdempotentRequest(c, userID, idempotencyScopeQuestionnaire)
 		c.AbortWithStatusJSON(
 			http.StatusInternalServerError,
 			gin.H{"error": err.Error()},
@@ -199,9 +314,13 @@ func (api *API) CreateQuestionnaire(c *gin.Context) {
 		return
 	}
 
-	c.JSON(
-		http.StatusOK,
-		gin.H{"message": "Add Questionnaire Successful"},
+	if err := api.questionnaireRepo.DeleteQuestionnaireDraft(userID); err != nil {
+		log.Println(err)
+	}
+
+	api.completeIdempotentRequest(
+		c, userID, idempotencyScopeQuestionnaire, int(postID),
+		gin.H{"id": postID, "message": "Add Questionnaire Successful"},
 	)
 }
 
@@ -230,8 +349,17 @@ func (api *API) UpdateQuestionnaire(c *gin.Context) {
 		return
 	}
 
-	isTitleOK := service.GetValidationInstance().Validate(updateQuestionnaireRequest.Title)
-	isDescriptionOK := service.GetValidationInstance().Validate(updateQuestionnaireRequest.Description)
+	if errs := validateQuestionnaireContent(&updateQuestionnaireRequest.Title, &updateQuestionnaireRequest.Description); len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	if !api.validateCategory(c, updateQuestionnaireRequest.CategoryID) {
+		return
+	}
+
+	isTitleOK := service.GetValidationInstance().Validate(updateQuestionnaireRequest.Title, requestLocales(c)...)
+	isDescriptionOK := service.GetValidationInstance().Validate(updateQuestionnaireRequest.Description, requestLocales(c)...)
 	if !isTitleOK || !isDescriptionOK {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorPostResponse{Message: "Your post contains bad words"})
 		return
@@ -251,7 +379,7 @@ func (api *API) UpdateQuestionnaire(c *gin.Context) {
 		)
 		return
 	}
-	if questionnaire == (repository.Questionnaire{}) {
+	if questionnaire.ID == 0 {
 		c.AbortWithStatusJSON(
 			http.StatusBadRequest,
 			gin.H{"error": "No data with given id"},
@@ -290,12 +418,8 @@ func (api *API) UpdateQuestionnaire(c *gin.Context) {
 }
 
 func (api *API) DeleteQuestionnaire(c *gin.Context) {
-	postID, err := strconv.Atoi(c.Param("id"))
+	postID, err := helper.ParseID(c, "id")
 	if err != nil {
-		c.AbortWithStatusJSON(
-			http.StatusBadRequest,
-			gin.H{"error": "id should be a int"},
-		)
 		return
 	}
 
@@ -313,7 +437,7 @@ func (api *API) DeleteQuestionnaire(c *gin.Context) {
 		)
 		return
 	}
-	if questionnaire == (repository.Questionnaire{}) {
+	if questionnaire.ID == 0 {
 		c.AbortWithStatusJSON(
 			http.StatusBadRequest,
 			gin.H{"error": "No data with given id"},
@@ -327,7 +451,7 @@ func (api *API) DeleteQuestionnaire(c *gin.Context) {
 		return
 	}
 
-	err = api.questionnaireRepo.DeleteQuestionnaire(postID)
+	imagePaths, err := api.questionnaireRepo.DeleteQuestionnaire(postID)
 	if err != nil {
 		c.AbortWithStatusJSON(
 			http.StatusInternalServerError,
@@ -336,8 +460,75 @@ func (api *API) DeleteQuestionnaire(c *gin.Context) {
 		return
 	}
 
+	for _, path := range imagePaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println(err)
+		}
+	}
+
 	c.JSON(
 		http.StatusOK,
 		gin.H{"message": "Delete Questionnaire Successful"},
 	)
 }
+
+// UploadQuestionnaireImages adds images like a banner or poster to a questionnaire of the caller
+func (api *API) UploadQuestionnaireImages(c *gin.Context) {
+	postID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	questionnaire, err := api.questionnaireRepo.ReadAllQuestionnaireByID(userID, postID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if questionnaire.ID == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+		return
+	} else if questionnaire.Author.Id != userID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not the owner"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files := form.File["images"]
+	if len(files) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "images is required"})
+		return
+	}
+
+	folderPath := filepath.Join(api.mediaDir, "questionnaire")
+
+	results := api.saveImages(postID, folderPath, files, api.questionnaireRepo.InsertQuestionnaireImage)
+	for _, result := range results {
+		if result.Error != "" {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Some images failed to upload", "results": results})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Questionnaire Images Uploaded", "results": results})
+}
+
+// validateQuestionnaireContent also strips dangerous HTML from the description, before the length is checked
+func validateQuestionnaireContent(title, description *string) []helper.JSONRequestErrorResponse {
+	*description = service.SanitizeMarkdown(*description)
+
+	return helper.ValidateTextFields(
+		helper.TextField{Name: "title", Value: title, Max: helper.MaxTitleLength, Required: true},
+		helper.TextField{Name: "description", Value: description, Max: helper.MaxDescriptionLength, Required: true},
+	)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDraftFieldLength matches the varchar(255) of the link and reward columns
+const maxDraftFieldLength = 255
+
+// QuestionnaireDraftRequest has the fields of CreateQuestionnaireRequest without requiring any of them,
+// they're checked when the questionnaire is published
+type QuestionnaireDraftRequest struct {
+	CategoryID  *int   `json:"category_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Reward      string `json:"reward"`
+}
+
+// SaveQuestionnaireDraft replaces the draft of the caller so an interrupted session can be resumed
+func (api *API) SaveQuestionnaireDraft(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request QuestionnaireDraftRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var jsonErr *json.UnmarshalTypeError
+		if errors.As(err, &jsonErr) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s should be a %s", jsonErr.Field, jsonErr.Type)})
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if errs := helper.ValidateTextFields(
+		helper.TextField{Name: "title", Value: &request.Title, Max: helper.MaxTitleLength},
+		helper.TextField{Name: "description", Value: &request.Description, Max: helper.MaxDescriptionLength},
+		helper.TextField{Name: "link", Value: &request.Link, Max: maxDraftFieldLength},
+		helper.TextField{Name: "reward", Value: &request.Reward, Max: maxDraftFieldLength},
+	); len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	if request.CategoryID != nil && *request.CategoryID == 0 {
+		request.CategoryID = nil
+	}
+
+	draft, err := api.questionnaireRepo.SaveQuestionnaireDraft(userID, repository.QuestionnaireDraft{
+		CategoryID:  request.CategoryID,
+		Title:       request.Title,
+		Description: request.Description,
+		Link:        request.Link,
+		Reward:      request.Reward,
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+func (api *API) ReadQuestionnaireDraft(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	draft, err := api.questionnaireRepo.FetchQuestionnaireDraft(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDraftNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "You have no questionnaire draft"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
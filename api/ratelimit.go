@@ -0,0 +1,62 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a sliding window limiter kept in memory, keyed by user id or client ip
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow records the hit when it's allowed, otherwise returns how long until the next one is
+func (r *rateLimiter) Allow(key string) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+
+	hits := r.hits[key][:0]
+	for _, hit := range r.hits[key] {
+		if now.Sub(hit) < r.window {
+			hits = append(hits, hit)
+		}
+	}
+
+	if len(hits) >= r.limit {
+		r.hits[key] = hits
+		return false, r.window - now.Sub(hits[0])
+	}
+
+	r.hits[key] = append(hits, now)
+	return true, 0
+}
+
+// sweep drops the keys whose hits all left the window, once per window so Allow stays cheap. Without it every user
+// or ip ever seen would stay in the map
+func (r *rateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.window {
+		return
+	}
+
+	for key, hits := range r.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) >= r.window {
+			delete(r.hits, key)
+		}
+	}
+	r.lastSweep = now
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type SetReactionRequest struct {
+	Reaction string `json:"reaction" binding:"required"`
+}
+
+// SetPostReaction gives the post the user's reaction, replacing the one they gave before
+func (api API) SetPostReaction(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request SetReactionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if !repository.IsReaction(request.Reaction) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "reaction should be one of " + strings.Join(repository.Reactions, ", "),
+		})
+		return
+	}
+
+	authorID, ok := api.postAuthorOrAbort(c, postID)
+	if !ok {
+		return
+	}
+
+	created, err := api.likeRepo.SetPostReaction(postID, userID, request.Reaction)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Changing the reaction isn't worth another notification
+	if created {
+		api.notifRepo.CreateNotification(authorID, userID, repository.NotifTypePostLike, postID)
+	}
+
+	api.commentHub.Publish(postID, Event{
+		Type: "post_reacted",
+		Data: gin.H{"post_id": postID, "user_id": userID, "reaction": request.Reaction},
+	})
+
+	api.respondWithPostReactions(c, postID, userID)
+}
+
+func (api API) DeletePostReaction(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cleared, err := api.likeRepo.ClearPostReaction(postID, userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !cleared {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No reaction to remove"})
+		return
+	}
+
+	api.respondWithPostReactions(c, postID, userID)
+}
+
+// ReadPostReactions is public, viewer_reaction is only filled in for a logged in viewer
+func (api API) ReadPostReactions(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	if _, ok := api.postAuthorOrAbort(c, postID); !ok {
+		return
+	}
+
+	api.respondWithPostReactions(c, postID, api.getUserIDAvoidPanic(c))
+}
+
+func (api API) postAuthorOrAbort(c *gin.Context, postID int) (int, bool) {
+	authorID, err := api.postRepo.FetchAuthorIDByPostID(postID)
+	if err != nil {
+		if errors.Is(err, repository.ErrPostNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+			return 0, false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return authorID, true
+}
+
+func (api API) respondWithPostReactions(c *gin.Context, postID, viewerID int) {
+	reactions, err := api.likeRepo.FetchPostReactions(postID, viewerID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reactions)
+}
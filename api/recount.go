@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// recountBatchSize is how many posts are checked per transaction, so writers aren't locked out for the whole run
+const recountBatchSize = 500
+
+type RecountResponse struct {
+	PostsChecked int                              `json:"posts_checked"`
+	Corrections  []repository.LikeCountCorrection `json:"corrections"`
+}
+
+// RecountLikes repairs the like counts. Comment and like counts are computed from their rows on every read, so the
+// only way they drift is a like stored twice by the same user, those duplicates are removed
+func (api *API) RecountLikes(c *gin.Context) {
+	response := RecountResponse{Corrections: []repository.LikeCountCorrection{}}
+
+	afterPostID := 0
+	for {
+		batch, err := api.likeRepo.RecountLikes(afterPostID, recountBatchSize)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if batch.Checked == 0 {
+			break
+		}
+
+		response.PostsChecked += batch.Checked
+		response.Corrections = append(response.Corrections, batch.Corrections...)
+		afterPostID = batch.LastPostID
+	}
+
+	api.recordAudit(c, repository.AuditActionRecountLikes, repository.AuditTargetSystem, 0, gin.H{
+		"posts_checked": response.PostsChecked,
+		"corrections":   len(response.Corrections),
+	})
+
+	c.JSON(http.StatusOK, response)
+}
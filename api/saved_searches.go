@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSavedSearchNameLength  = 100
+	maxSavedSearchParamLength = 255
+)
+
+// savedSearchParams are the params of GET /api/post a search can keep, the page is picked when it's run
+var savedSearchParams = map[string]bool{
+	"search_title":   true,
+	"category_id":    true,
+	"me":             true,
+	"has_images":     true,
+	"unanswered":     true,
+	"author_role":    true,
+	"created_after":  true,
+	"created_before": true,
+	"sort_by":        true,
+	"order":          true,
+	"then":           true,
+	"then_order":     true,
+	"fields":         true,
+}
+
+type SavedSearchRequest struct {
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params" binding:"required"`
+}
+
+type SavedSearchResponse struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	Params    map[string]string `json:"params"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func buildSavedSearchResponse(search repository.SavedSearch) SavedSearchResponse {
+	params := map[string]string{}
+	if values, err := url.ParseQuery(search.Query); err == nil {
+		for key := range values {
+			params[key] = values.Get(key)
+		}
+	}
+
+	return SavedSearchResponse{ID: search.ID, Name: search.Name, Params: params, CreatedAt: search.CreatedAt}
+}
+
+// CreateSavedSearch keeps the params of a posts list under a name. Only the keys are checked, a bad value fails
+// when the search is run the same way it would on GET /api/post
+func (api *API) CreateSavedSearch(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request SavedSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if errs := helper.ValidateTextFields(
+		helper.TextField{Name: "name", Value: &request.Name, Required: true, Max: maxSavedSearchNameLength},
+	); len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errs})
+		return
+	}
+
+	query := url.Values{}
+	for key, value := range request.Params {
+		if !savedSearchParams[key] {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s can't be saved in a search", key)})
+			return
+		}
+		if len(value) > maxSavedSearchParamLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is too long", key)})
+			return
+		}
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	search, err := api.postRepo.InsertSavedSearch(userID, request.Name, query.Encode())
+	if err != nil {
+		if errors.Is(err, repository.ErrTooManySavedSearches) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("You can keep at most %d saved searches", repository.MaxSavedSearches),
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, buildSavedSearchResponse(search))
+}
+
+func (api *API) ReadSavedSearches(c *gin.Context) {
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	searches, err := api.postRepo.FetchSavedSearches(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]SavedSearchResponse, len(searches))
+	for i, search := range searches {
+		response[i] = buildSavedSearchResponse(search)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RunSavedSearch serves GET /api/post with the saved params, only offset and limit are taken from the request
+func (api *API) RunSavedSearch(c *gin.Context) {
+	searchID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	search, err := api.postRepo.FetchSavedSearch(searchID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSavedSearchNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := url.ParseQuery(search.Query)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := c.Request.URL.Query()
+	for _, key := range []string{"offset", "limit"} {
+		if value := page.Get(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	// HandleContext resets the context, so the posts list parses the new query instead of a cached one. The list is
+	// served under the same version as the saved search
+	c.Request.URL.Path = strings.TrimSuffix(c.FullPath(), "/me/saved-searches/:id/posts") + "/post"
+	c.Request.URL.RawQuery = query.Encode()
+	api.router.HandleContext(c)
+}
+
+func (api *API) DeleteSavedSearch(c *gin.Context) {
+	searchID, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := api.postRepo.DeleteSavedSearch(searchID, userID); err != nil {
+		if errors.Is(err, repository.ErrSavedSearchNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted"})
+}
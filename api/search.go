@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	searchTypeAll           = "all"
+	searchTypePost          = "post"
+	searchTypeQuestionnaire = "questionnaire"
+)
+
+// The results keep the shape of their native responses with a type next to it
+type PostSearchResult struct {
+	Type string `json:"type"`
+	DetailPostResponse
+}
+
+type QuestionnaireSearchResult struct {
+	Type string `json:"type"`
+	repository.Questionnaire
+}
+
+type searchResult struct {
+	relevance int
+	createdAt time.Time
+	item      interface{}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Search matches q against the title and description of posts and questionnaires. Title matches rank above
+// description only matches, then the newest come first
+func (api *API) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	searchType := c.DefaultQuery("type", searchTypeAll)
+	if searchType != searchTypeAll && searchType != searchTypePost && searchType != searchTypeQuestionnaire {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Type"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.searchPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	viewerID := api.getUserIDAvoidPanic(c)
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	// The page of the merged list can only contain the first offset+limit results of each type
+	fetchLimit := offset + limit
+	results := make([]searchResult, 0)
+
+	if searchType != searchTypeQuestionnaire {
+		posts, err := api.postRepo.FetchAllPost(fetchLimit, 0, viewerID,
+			`p.title LIKE ? ESCAPE '\' DESC, p.created_at DESC`,
+			`AND (p.title LIKE ? ESCAPE '\' OR p.desc LIKE ? ESCAPE '\')`,
+			pattern, pattern, pattern)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		createdAt := make(map[int]time.Time)
+		for _, post := range posts {
+			createdAt[post.ID] = post.CreatedAt
+		}
+
+		for _, post := range buildPostListResponse(posts, viewerID, api.canSeeAnonymousAuthors(c)) {
+			results = append(results, searchResult{
+				relevance: searchRelevance(post.Title, query),
+				createdAt: createdAt[post.ID],
+				item:      PostSearchResult{Type: searchTypePost, DetailPostResponse: post},
+			})
+		}
+	}
+
+	if searchType != searchTypePost {
+		questionnaires, err := api.questionnaireRepo.ReadAllQuestionnaires(viewerID,
+			`(p.title LIKE ? ESCAPE '\' OR p.desc LIKE ? ESCAPE '\')`,
+			`p.title LIKE ? ESCAPE '\' DESC, p.created_at DESC`,
+			fetchLimit, 0, pattern, pattern, pattern)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, questionnaire := range questionnaires {
+			result := searchResult{
+				relevance: searchRelevance(questionnaire.Title, query),
+				item:      QuestionnaireSearchResult{Type: searchTypeQuestionnaire, Questionnaire: questionnaire},
+			}
+			if questionnaire.CreatedAt != nil {
+				result.createdAt = *questionnaire.CreatedAt
+			}
+			results = append(results, result)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].relevance != results[j].relevance {
+			return results[i].relevance > results[j].relevance
+		}
+		return results[i].createdAt.After(results[j].createdAt)
+	})
+
+	items := make([]interface{}, 0, limit)
+	for i := offset; i < len(results) && i < offset+limit; i++ {
+		items = append(items, results[i].item)
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func searchRelevance(title, query string) int {
+	if strings.Contains(strings.ToLower(title), strings.ToLower(query)) {
+		return 2
+	}
+	return 1
+}
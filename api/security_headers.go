@@ -0,0 +1,31 @@
+package api
+
+import (
+	"github.com/althafariq/discusspedia-be/config"
+	"github.com/gin-gonic/gin"
+)
+
+// securityHeaders are set on every response, the config leaves out the ones that are off
+func securityHeaders(cfg config.Config) gin.HandlerFunc {
+	headers := map[string]string{
+		"X-Frame-Options":         cfg.FrameOptions,
+		"Content-Security-Policy": cfg.ContentSecurityPolicy,
+		"Referrer-Policy":         cfg.ReferrerPolicy,
+	}
+	if cfg.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+
+	for name, value := range headers {
+		if value == "" {
+			delete(headers, name)
+		}
+	}
+
+	return func(c *gin.Context) {
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Next()
+	}
+}
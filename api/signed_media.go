@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type SignMediaRequest struct {
+	Folder   string `json:"folder" binding:"required"`
+	Filename string `json:"filename" binding:"required"`
+}
+
+type SignMediaResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// mediaSignature is the HMAC of the file and the unix time the URL expires at, so neither can be changed
+func (api *API) mediaSignature(folder, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, api.mediaSigningKey)
+	fmt.Fprintf(mac, "%s/%s\n%d", folder, filename, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (api *API) signedMediaURL(folder, filename string, expiresAt time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", api.mediaSignature(folder, filename, expiresAt.Unix()))
+
+	return "/media/signed/" + url.PathEscape(folder) + "/" + url.PathEscape(filename) + "?" + query.Encode()
+}
+
+// SignMediaURL gives moderators a URL to a media file that stops working after SIGNED_MEDIA_TTL
+func (api *API) SignMediaURL(c *gin.Context) {
+	var request SignMediaRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	exists, ok := api.mediaFolders()[request.Folder]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Folder"})
+		return
+	}
+	if strings.Contains(request.Filename, "..") || strings.ContainsAny(request.Filename, `/\`) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Filename"})
+		return
+	}
+
+	found, err := exists(filepath.Join(api.mediaDir, request.Folder, request.Filename))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File Not Found"})
+		return
+	}
+
+	expiresAt := time.Now().Add(api.signedMediaTTL).Truncate(time.Second)
+	c.JSON(http.StatusOK, SignMediaResponse{
+		URL:       api.signedMediaURL(request.Folder, request.Filename, expiresAt),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// serveSignedMedia serves the file only while its URL hasn't expired and the signature matches, a wrong signature
+// and an expired one are both 403 so a URL can't be probed for files
+func (api *API) serveSignedMedia(c *gin.Context) {
+	folder, filename := c.Param("folder"), c.Param("filename")
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	expected := api.mediaSignature(folder, filename, expires)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("signature"))) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	remaining := time.Until(time.Unix(expires, 0))
+	if remaining <= 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Signed URL expired"})
+		return
+	}
+
+	exists, ok := api.mediaFolders()[folder]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File Not Found"})
+		return
+	}
+
+	api.serveMedia(c, folder, exists, fmt.Sprintf("private, max-age=%d", int(remaining.Seconds())))
+}
@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signed Media Test", func() {
+	var (
+		cfg     config.Config
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		cfg = config.Default()
+		cfg.MediaDir = GinkgoT().TempDir()
+
+		path := filepath.Join(cfg.MediaDir, "post", "1-photo.png")
+		Expect(os.Mkdir(filepath.Join(cfg.MediaDir, "post"), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"), 0644)).To(Succeed())
+
+		mainAPI := newTestAPIWithConfig(cfg, mockRepos{post: &mockPostRepo{imagePaths: []string{path}}, user: &mockUserRepo{roles: map[int]string{3: "moderator"}}})
+		handler = mainAPI.Handler()
+	})
+
+	serve := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	moderator := func() string {
+		return newToken(3, func(claims *api.Claims) { claims.Role = "moderator" })
+	}
+
+	sign := func() api.SignMediaResponse {
+		w := serve(http.MethodPost, "/api/media/sign", moderator(), `{"folder":"post","filename":"1-photo.png"}`)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var response api.SignMediaResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		return response
+	}
+
+	It("should serve the file through the signed URL until it expires", func() {
+		response := sign()
+		Expect(response.ExpiresAt).To(BeTemporally("~", time.Now().Add(cfg.SignedMediaTTL), time.Second))
+
+		w := serve(http.MethodGet, response.URL, "", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal("image/png"))
+		Expect(w.Header().Get("Cache-Control")).To(HavePrefix("private"))
+	})
+
+	It("should return 403 for a tampered or expired URL", func() {
+		response := sign()
+
+		tampered := "0"
+		if strings.HasSuffix(response.URL, "0") {
+			tampered = "1"
+		}
+		Expect(serve(http.MethodGet, response.URL[:len(response.URL)-1]+tampered, "", "").Code).To(Equal(http.StatusForbidden))
+		Expect(serve(http.MethodGet, strings.Replace(response.URL, "1-photo.png", "2-photo.png", 1), "", "").Code).To(Equal(http.StatusForbidden))
+
+		expires := time.Now().Add(-time.Minute).Unix()
+		mac := hmac.New(sha256.New, []byte(cfg.MediaSigningKey))
+		fmt.Fprintf(mac, "post/1-photo.png\n%d", expires)
+		expired := fmt.Sprintf("/media/signed/post/1-photo.png?expires=%d&signature=%s", expires, hex.EncodeToString(mac.Sum(nil)))
+
+		w := serve(http.MethodGet, expired, "", "")
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(w.Body.String()).To(ContainSubstring("expired"))
+	})
+
+	It("should only sign files with a record for moderators", func() {
+		Expect(serve(http.MethodPost, "/api/media/sign", newToken(1, nil), `{"folder":"post","filename":"1-photo.png"}`).Code).To(Equal(http.StatusForbidden))
+		Expect(serve(http.MethodPost, "/api/media/sign", moderator(), `{"folder":"post","filename":"9-missing.png"}`).Code).To(Equal(http.StatusNotFound))
+		Expect(serve(http.MethodPost, "/api/media/sign", moderator(), `{"folder":"../post","filename":"1-photo.png"}`).Code).To(Equal(http.StatusBadRequest))
+	})
+})
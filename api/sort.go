@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errInvalidSortBy     = errors.New("Invalid Sort By")
+	errInvalidOrder      = errors.New("Invalid Order")
+	errInvalidTiebreaker = errors.New("Invalid Then")
+)
+
+type sortPreset struct {
+	column string
+	desc   bool
+	// clause replaces column for presets ordering by several expressions, their direction can't be overridden
+	clause string
+}
+
+// sortOptions maps the sort keys clients may send to SQL. Only these strings ever reach the ORDER BY clause
+type sortOptions struct {
+	columns map[string]string
+	presets map[string]sortPreset
+}
+
+var postSortOptions = sortOptions{
+	columns: map[string]string{
+		"created_at":    "p.created_at",
+		"updated_at":    "p.updated_at",
+		"title":         "p.title",
+		"like_count":    "like_count",
+		"comment_count": "comment_count",
+	},
+	presets: map[string]sortPreset{
+		"newest":         {column: "created_at", desc: true},
+		"oldest":         {column: "created_at"},
+		"most_liked":     {column: "like_count", desc: true},
+		"most_commented": {column: "comment_count", desc: true},
+		"controversial":  {clause: repository.OrderControversial},
+	},
+}
+
+var questionnaireSortOptions = sortOptions{
+	columns: map[string]string{
+		"created_at":    "p.created_at",
+		"title":         "p.title",
+		"like_count":    "total_like",
+		"comment_count": "total_comment",
+	},
+	presets: map[string]sortPreset{
+		"newest":         {column: "created_at", desc: true},
+		"oldest":         {column: "created_at"},
+		"most_liked":     {column: "like_count", desc: true},
+		"most_commented": {column: "comment_count", desc: true},
+	},
+}
+
+// orderBy builds the order clause from sort_by, a preset or a column, order (asc or desc) and an optional then
+// column with its then_order as tiebreaker. Columns sort descending unless order says otherwise
+func (s sortOptions) orderBy(c *gin.Context) (string, error) {
+	sortBy := c.DefaultQuery("sort_by", "newest")
+
+	var clause string
+	if preset, ok := s.presets[sortBy]; ok && preset.clause != "" {
+		if c.Query("order") != "" {
+			return "", errInvalidOrder
+		}
+		clause = preset.clause
+	} else {
+		column, desc := sortBy, true
+		if ok {
+			column, desc = preset.column, preset.desc
+		} else if _, ok := s.columns[column]; !ok {
+			return "", errInvalidSortBy
+		}
+
+		desc, err := parseOrder(c.Query("order"), desc)
+		if err != nil {
+			return "", err
+		}
+		clause = orderTerm(s.columns[column], desc)
+	}
+
+	then := c.Query("then")
+	if then == "" {
+		if c.Query("then_order") != "" {
+			return "", errInvalidTiebreaker
+		}
+		return clause, nil
+	}
+
+	expression, ok := s.columns[then]
+	if !ok {
+		return "", errInvalidTiebreaker
+	}
+
+	desc, err := parseOrder(c.Query("then_order"), true)
+	if err != nil {
+		return "", err
+	}
+
+	return clause + ", " + orderTerm(expression, desc), nil
+}
+
+func parseOrder(order string, fallback bool) (bool, error) {
+	switch order {
+	case "":
+		return fallback, nil
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	}
+	return false, errInvalidOrder
+}
+
+func orderTerm(expression string, desc bool) string {
+	if desc {
+		return expression + " DESC"
+	}
+	return expression
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+)
+
+func (api API) GetUserStats(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	stats, err := api.userRepo.GetUserStats(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No data with given id"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetPlatformStats is the admin dashboard summary, it can be up to a minute old
+func (api API) GetPlatformStats(c *gin.Context) {
+	stats, err := api.userRepo.GetPlatformStats()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetLeaderboard ranks the top contributors of the period, a week by default. Boards are cached for a few minutes
+func (api API) GetLeaderboard(c *gin.Context) {
+	period := c.DefaultQuery("period", repository.LeaderboardWeek)
+
+	limit, err := parseLimit(c, api.leaderboardPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := api.userRepo.FetchLeaderboard(period)
+	if err != nil {
+		if errors.Is(err, repository.ErrUnknownLeaderboardPeriod) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "period should be week, month or all"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"period": period, "users": entries})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type UsersBatchRequest struct {
+	IDs []int `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// ReadUsersBatch returns the name, role and avatar of every requested user by id, so a client can fill all the author
+// cards of a page in one call. Unknown ids are left out
+func (api API) ReadUsersBatch(c *gin.Context) {
+	var request UsersBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ids := make([]int, 0, len(request.IDs))
+	seen := make(map[int]bool)
+	for _, id := range request.IDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	users, err := api.userRepo.FetchUsersByIDs(ids)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
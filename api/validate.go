@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/althafariq/discusspedia-be/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ValidateContentRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+type ValidateContentResponse struct {
+	service.CheckResult
+	Warnings []string `json:"warnings"`
+}
+
+// ValidateContent runs the checks of the create handlers on a text without saving anything, for live feedback
+// in the editor. It's public, so it's limited per client ip
+func (api *API) ValidateContent(c *gin.Context) {
+	if allowed, retryAfter := api.validateLimiter.Allow(c.ClientIP()); !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+		return
+	}
+
+	var req ValidateContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "text is required"})
+		return
+	}
+
+	if utf8.RuneCountInString(req.Text) > helper.MaxDescriptionLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []helper.JSONRequestErrorResponse{
+			{Field: "text", Message: "Must be at most " + strconv.Itoa(helper.MaxDescriptionLength) + " characters"},
+		}})
+		return
+	}
+
+	c.JSON(http.StatusOK, ValidateContentResponse{
+		CheckResult: service.GetValidationInstance().Check(req.Text, requestLocales(c)...),
+		Warnings:    service.ContentWarnings(req.Text),
+	})
+}
+
+type SetLocaleRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// requestLocales returns the languages of the Accept-Language header, without their region and skipping the ones
+// with q=0, e.g. ["en", "id"] for "en-US,en;q=0.9,id;q=0.8"
+func requestLocales(c *gin.Context) []string {
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return nil
+	}
+
+	locales := []string{}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		excluded := false
+		for _, param := range params[1:] {
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(param), "q="), 64); err == nil && q == 0 {
+				excluded = true
+			}
+		}
+
+		locale := strings.ToLower(strings.TrimSpace(strings.SplitN(params[0], "-", 2)[0]))
+		if excluded || locale == "" || locale == "*" || seen[locale] {
+			continue
+		}
+		seen[locale] = true
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+// ReadProfanityLocales lists the bad words lists and whether each one applies to every request
+func (api *API) ReadProfanityLocales(c *gin.Context) {
+	c.JSON(http.StatusOK, service.GetValidationInstance().Locales())
+}
+
+// SetProfanityLocale turns a list on or off until the next restart, PROFANITY_LOCALES sets them at startup.
+// A turned off list still applies to requests whose Accept-Language asks for it
+func (api *API) SetProfanityLocale(c *gin.Context) {
+	var req SetLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+
+	if err := service.GetValidationInstance().SetLocaleEnabled(c.Param("locale"), *req.Enabled); err != nil {
+		if errors.Is(err, service.ErrUnknownLocale) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionProfanityLocale, repository.AuditTargetSystem, 0, gin.H{
+		"locale":  c.Param("locale"),
+		"enabled": *req.Enabled,
+	})
+
+	c.JSON(http.StatusOK, service.GetValidationInstance().Locales())
+}
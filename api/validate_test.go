@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/service"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate API Test", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		mainAPI := newTestAPI(mockRepos{user: &mockUserRepo{roles: map[int]string{3: "admin"}}, audit: &mockAuditRepo{}})
+		handler = mainAPI.Handler()
+	})
+
+	AfterEach(func() {
+		// The lists are shared by every API in the process
+		Expect(service.GetValidationInstance().SetLocaleEnabled(service.LocaleEnglish, true)).To(Succeed())
+	})
+
+	serve := func(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	validate := func(text string, headers ...string) api.ValidateContentResponse {
+		w := serve(http.MethodPost, "/api/validate", `{"text":"`+text+`"}`, headers...)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var response api.ValidateContentResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		return response
+	}
+
+	admin := func() string {
+		return "Bearer " + newToken(3, func(claims *api.Claims) { claims.Role = "admin" })
+	}
+
+	It("should match the words of every active locale", func() {
+		response := validate("dasar anjing, what the fuck")
+		Expect(response.OK).To(BeFalse())
+		Expect(response.Matches).To(HaveLen(2))
+		Expect(response.Matches[0].Locale).To(Equal(service.LocaleIndonesian))
+		Expect(response.Matches[1].Locale).To(Equal(service.LocaleEnglish))
+	})
+
+	It("should apply a disabled list only to requests in its language", func() {
+		w := serve(http.MethodPut, "/api/admin/profanity/locales/en", `{"enabled":false}`, "Authorization", admin())
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var locales []service.LocaleStatus
+		Expect(json.Unmarshal(w.Body.Bytes(), &locales)).To(Succeed())
+		Expect(locales).To(ContainElement(HaveField("Locale", service.LocaleEnglish)))
+		for _, locale := range locales {
+			Expect(locale.Enabled).To(Equal(locale.Locale != service.LocaleEnglish))
+		}
+
+		Expect(validate("what the fuck").OK).To(BeTrue())
+		Expect(validate("what the fuck", "Accept-Language", "en-US,en;q=0.9").OK).To(BeFalse())
+		Expect(validate("what the fuck", "Accept-Language", "id, en;q=0").OK).To(BeTrue())
+	})
+
+	It("should return 404 for a locale without a list", func() {
+		w := serve(http.MethodPut, "/api/admin/profanity/locales/fr", `{"enabled":true}`, "Authorization", admin())
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+})
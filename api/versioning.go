@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiV1Prefix is where the current routes live, the unversioned /api routes are deprecated aliases of them
+const apiV1Prefix = "/api/v1"
+
+// deprecated marks the responses of the routes it's used on with the Deprecation header, the Sunset header when the
+// routes have a date they'll be removed on and a Link to the route replacing them
+func deprecated(sunset time.Time, successor func(path string) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor != nil {
+			c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor(c.Request.URL.Path)))
+		}
+		c.Next()
+	}
+}
+
+// unversionedSuccessor is the v1 route of an unversioned one, /api/post/1 is /api/v1/post/1
+func unversionedSuccessor(path string) string {
+	return apiV1Prefix + strings.TrimPrefix(path, "/api")
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/helper"
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	webhookMaxAttempts  = 3
+	webhookRetryBackoff = 500 * time.Millisecond
+)
+
+type CreateWebhookRequest struct {
+	URL        string `json:"url" binding:"required,url,max=2048"`
+	CategoryID *int   `json:"category_id"`
+	Secret     string `json:"secret" binding:"omitempty,min=16,max=255"`
+}
+
+// CreateWebhookResponse is the only response that shows the secret
+type CreateWebhookResponse struct {
+	repository.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookPayload is the JSON body POSTed to the webhooks, signed in the X-Discusspedia-Signature header
+type WebhookPayload struct {
+	Event string              `json:"event"`
+	Post  repository.FeedPost `json:"post"`
+}
+
+func (api *API) ReadWebhooks(c *gin.Context) {
+	webhooks, err := api.webhookRepo.FetchWebhooks()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// CreateWebhook subscribes a URL to the new posts of a category, or of every category without category_id. A secret is
+// generated when none is given
+func (api *API) CreateWebhook(c *gin.Context) {
+	var request CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": helper.GetErrorMessage(ve)})
+		} else {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if target, err := url.Parse(request.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Webhook URL should be http or https"})
+		return
+	}
+
+	if request.CategoryID != nil {
+		exists, err := api.categoryRepo.CategoryExists(*request.CategoryID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": repository.ErrCategoryNotFound.Error()})
+			return
+		}
+	}
+
+	if request.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		request.Secret = hex.EncodeToString(secret)
+	}
+
+	userID, err := api.getUserIdFromToken(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook := repository.Webhook{
+		URL:        request.URL,
+		CategoryID: request.CategoryID,
+		Secret:     request.Secret,
+		CreatedBy:  userID,
+		CreatedAt:  time.Now(),
+	}
+	id, err := api.webhookRepo.InsertWebhook(webhook)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	webhook.ID = int(id)
+
+	api.recordAudit(c, repository.AuditActionCreateWebhook, repository.AuditTargetWebhook, webhook.ID, gin.H{
+		"url":         webhook.URL,
+		"category_id": webhook.CategoryID,
+	})
+
+	c.JSON(http.StatusCreated, CreateWebhookResponse{Webhook: webhook, Secret: webhook.Secret})
+}
+
+func (api *API) DeleteWebhook(c *gin.Context) {
+	id, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	if err := api.webhookRepo.DeleteWebhook(id); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	api.recordAudit(c, repository.AuditActionDeleteWebhook, repository.AuditTargetWebhook, id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook Deleted"})
+}
+
+// ReadWebhookDeliveries is the delivery log of a webhook, newest attempt first
+func (api *API) ReadWebhookDeliveries(c *gin.Context) {
+	id, err := helper.ParseID(c, "id")
+	if err != nil {
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Offset"})
+		return
+	}
+
+	limit, err := parseLimit(c, api.webhookDeliveriesPage)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveries, err := api.webhookRepo.FetchWebhookDeliveries(id, limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "limit": limit})
+}
+
+// webhookSignature is the hex HMAC-SHA256 of the body with the secret of the webhook
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhooks POSTs the post to every webhook of its category. It's meant to run in its own goroutine, so a slow
+// or failing webhook never holds up the request that created the post
+func (api API) deliverWebhooks(event string, post repository.FeedPost) {
+	webhooks, err := api.webhookRepo.FetchWebhooksForCategory(post.CategoryID)
+	if err != nil {
+		log.Printf("webhooks for post %d: %v", post.ID, err)
+		return
+	}
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(WebhookPayload{Event: event, Post: post})
+	if err != nil {
+		log.Printf("webhook payload for post %d: %v", post.ID, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		go api.deliverWebhook(webhook, event, post.ID, body)
+	}
+}
+
+// deliverWebhook retries network errors, 429 and 5xx responses with a doubling backoff, logging every attempt
+func (api API) deliverWebhook(webhook repository.Webhook, event string, postID int, body []byte) {
+	backoff := webhookRetryBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		start := time.Now()
+		statusCode, err := api.postWebhook(webhook, event, body)
+
+		delivery := repository.WebhookDelivery{
+			WebhookID:  webhook.ID,
+			Event:      event,
+			PostID:     postID,
+			Attempt:    attempt,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if statusCode != 0 {
+			delivery.StatusCode = &statusCode
+		}
+		if err != nil {
+			message := err.Error()
+			delivery.Error = &message
+		}
+		if err := api.webhookRepo.InsertWebhookDelivery(delivery); err != nil {
+			log.Printf("webhook %d delivery log: %v", webhook.ID, err)
+		}
+
+		// Other 4xx responses won't change by sending the same payload again
+		retryable := statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+		if err == nil || !retryable {
+			return
+		}
+
+		if attempt == webhookMaxAttempts {
+			log.Printf("webhook %d gave up on post %d after %d attempts", webhook.ID, postID, attempt)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (api API) postWebhook(webhook repository.Webhook, event string, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Discusspedia-Webhook")
+	request.Header.Set("X-Discusspedia-Event", event)
+	request.Header.Set("X-Discusspedia-Signature", webhookSignature(webhook.Secret, body))
+
+	response, err := api.webhookClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
@@ -0,0 +1,180 @@
+package api_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type receivedWebhook struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+var _ = Describe("Webhook Test", func() {
+	var (
+		webhookRepo *mockWebhookRepo
+		handler     http.Handler
+		server      *httptest.Server
+		mu          sync.Mutex
+		received    []receivedWebhook
+		statuses    []int
+	)
+
+	BeforeEach(func() {
+		received = nil
+		statuses = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, receivedWebhook{
+				event:     r.Header.Get("X-Discusspedia-Event"),
+				signature: r.Header.Get("X-Discusspedia-Signature"),
+				body:      body,
+			})
+
+			status := http.StatusNoContent
+			if len(statuses) > 0 {
+				status, statuses = statuses[0], statuses[1:]
+			}
+			w.WriteHeader(status)
+		}))
+		DeferCleanup(server.Close)
+
+		webhookRepo = &mockWebhookRepo{}
+		mainAPI := newTestAPI(mockRepos{
+			follow:   &mockFollowRepo{},
+			post:     &mockPostRepo{},
+			user:     &mockUserRepo{roles: map[int]string{3: "admin"}},
+			category: &mockCategoryRepo{},
+			audit:    &mockAuditRepo{},
+			webhook:  webhookRepo,
+		})
+		handler = mainAPI.Handler()
+	})
+
+	receivedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+
+	createWebhook := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+newToken(3, func(claims *api.Claims) { claims.Role = "admin" }))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	createPost := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/post", strings.NewReader(`{"category_id":1,"title":"Title","description":"Description"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	Describe("CreateWebhook", func() {
+		It("should return the generated secret once", func() {
+			w := createWebhook(`{"url":"` + server.URL + `","category_id":1}`)
+			Expect(w.Code).To(Equal(http.StatusCreated))
+
+			var response map[string]interface{}
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response["secret"]).To(HaveLen(64))
+			Expect(webhookRepo.webhooks).To(HaveLen(1))
+			Expect(webhookRepo.webhooks[0].Secret).To(Equal(response["secret"]))
+		})
+
+		It("should reject URLs that aren't http or https", func() {
+			w := createWebhook(`{"url":"ftp://example.com/hook"}`)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(webhookRepo.webhooks).To(BeEmpty())
+		})
+
+		It("should be admin only", func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/webhooks", strings.NewReader(`{"url":"`+server.URL+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+newToken(1, nil))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("delivery", func() {
+		It("should POST the new post signed with the secret of the webhook", func() {
+			Expect(createWebhook(`{"url":"` + server.URL + `","secret":"0123456789abcdef"}`).Code).To(Equal(http.StatusCreated))
+			Expect(createPost().Code).To(Equal(http.StatusOK))
+
+			Eventually(receivedCount).Should(Equal(1))
+			webhook := received[0]
+			Expect(webhook.event).To(Equal(repository.WebhookEventPostCreated))
+
+			mac := hmac.New(sha256.New, []byte("0123456789abcdef"))
+			mac.Write(webhook.body)
+			Expect(webhook.signature).To(Equal("sha256=" + hex.EncodeToString(mac.Sum(nil))))
+
+			var payload api.WebhookPayload
+			Expect(json.Unmarshal(webhook.body, &payload)).To(Succeed())
+			Expect(payload.Event).To(Equal(repository.WebhookEventPostCreated))
+			Expect(payload.Post.AuthorID).To(Equal(1))
+			Expect(payload.Post.CategoryID).To(Equal(1))
+			Expect(payload.Post.Title).To(Equal("Title"))
+
+			Eventually(webhookRepo.Deliveries).Should(HaveLen(1))
+			Expect(*webhookRepo.Deliveries()[0].StatusCode).To(Equal(http.StatusNoContent))
+			Expect(webhookRepo.Deliveries()[0].Error).To(BeNil())
+		})
+
+		It("should skip webhooks of other categories", func() {
+			Expect(createWebhook(`{"url":"` + server.URL + `","category_id":2}`).Code).To(Equal(http.StatusCreated))
+			Expect(createPost().Code).To(Equal(http.StatusOK))
+
+			Consistently(receivedCount, "200ms").Should(BeZero())
+		})
+
+		It("should retry server errors and log every attempt", func() {
+			statuses = []int{http.StatusInternalServerError, http.StatusServiceUnavailable}
+			Expect(createWebhook(`{"url":"` + server.URL + `"}`).Code).To(Equal(http.StatusCreated))
+			Expect(createPost().Code).To(Equal(http.StatusOK))
+
+			Eventually(webhookRepo.Deliveries, "3s").Should(HaveLen(3))
+			deliveries := webhookRepo.Deliveries()
+			Expect(*deliveries[0].StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(deliveries[0].Error).NotTo(BeNil())
+			Expect(deliveries[2].Attempt).To(Equal(3))
+			Expect(*deliveries[2].StatusCode).To(Equal(http.StatusNoContent))
+		})
+
+		It("should not retry other client errors", func() {
+			statuses = []int{http.StatusGone}
+			Expect(createWebhook(`{"url":"` + server.URL + `"}`).Code).To(Equal(http.StatusCreated))
+			Expect(createPost().Code).To(Equal(http.StatusOK))
+
+			Eventually(webhookRepo.Deliveries).Should(HaveLen(1))
+			Consistently(webhookRepo.Deliveries, "700ms").Should(HaveLen(1))
+			Expect(*webhookRepo.Deliveries()[0].StatusCode).To(Equal(http.StatusGone))
+		})
+	})
+})
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Origins are already open through the CORS config
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// CommentsWebSocket authenticates through the token query param since browsers can't set headers on websocket requests
+func (api API) CommentsWebSocket(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "id should be a int"})
+		return
+	}
+
+	token, err := api.ValidateToken(c.Query("token"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, AuthErrorResponse{Error: "Invalid token"})
+		return
+	}
+
+	claims := token.Claims.(*Claims)
+	userID := claims.Id
+	if !api.checkAccountActive(c, userID) {
+		return
+	}
+
+	// Posts the user can't read, pending or by someone they blocked, look like they don't exist. Admins and moderators
+	// see the pending ones
+	moderating := claims.Role == repository.RoleAdmin || claims.Role == repository.RoleModerator
+	visible, err := api.postRepo.PostVisibleTo(postID, userID, moderating)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !visible {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Post Not Found"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub := api.commentHub.Subscribe(postID)
+
+	// Clients never send anything meaningful, reading only detects disconnects and handles pongs
+	go func() {
+		defer api.commentHub.Unsubscribe(postID, sub)
+
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.send:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := conn.WriteJSON(event); err != nil {
+				api.commentHub.Unsubscribe(postID, sub)
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				api.commentHub.Unsubscribe(postID, sub)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Upload file name strategies, uuid stores uploads under a random name and slug under their sanitized original name
+const (
+	FileNameStrategyUUID = "uuid"
+	FileNameStrategySlug = "slug"
+)
+
+// What happens to a reply deeper than COMMENT_MAX_DEPTH, flatten attaches it to the deepest allowed ancestor
+// and reject refuses it
+const (
+	CommentDepthFlatten = "flatten"
+	CommentDepthReject  = "reject"
+)
+
+type Config struct {
+	// APP_ENV, either development or production
+	Env string
+	// PORT the server listens on
+	Port string
+	// DB_PATH of the sqlite database file
+	DBPath string
+	// DB_BUSY_TIMEOUT is how long a write waits for another one to release the database before failing as locked
+	DBBusyTimeout time.Duration
+	// JWT_SECRET signs the auth tokens, it has a default outside production only
+	JWTSecret string
+	// JWT_ALGORITHM signs the auth tokens, HS256, HS384 or HS512
+	JWTAlgorithm string
+	// JWT_ISSUER and JWT_AUDIENCE are set on new tokens and required on the ones that are validated
+	JWTIssuer   string
+	JWTAudience string
+	// JWT_EXPIRY is how long a new token is valid, as a Go duration like 60m
+	JWTExpiry time.Duration
+	// JWT_LEEWAY is how far the exp, iat and nbf claims may be off, to allow for clock skew
+	JWTLeeway time.Duration
+	// MEDIA_DIR stores the uploaded post images and avatars, it's also served under /media
+	MediaDir string
+	// MEDIA_CLEANUP_INTERVAL is how often media files without a record are removed, 0 turns the job off
+	MediaCleanupInterval time.Duration
+	// MEDIA_CLEANUP_GRACE is how old a file has to be before the cleanup may remove it, so uploads still in progress are kept
+	MediaCleanupGrace time.Duration
+	// MEDIA_SIGNING_KEY signs the short-lived media URLs, it defaults to JWT_SECRET
+	MediaSigningKey string
+	// SIGNED_MEDIA_TTL is how long a signed media URL is valid
+	SignedMediaTTL time.Duration
+	// UPLOAD_CONCURRENCY is how many images of one upload request are saved at the same time
+	UploadConcurrency int
+	// UPLOAD_FILENAME_STRATEGY names the stored uploads, uuid or slug. The original name is kept in the database either way
+	UploadFileNameStrategy string
+	// EXPORT_LIMIT_PER_DAY is how many data exports a user can request per day
+	ExportLimitPerDay int
+	// PROFANITY_THRESHOLD is the lowest bad word severity that blocks a post
+	ProfanityThreshold service.Severity
+	// PROFANITY_LOCALES are the bad words lists every text is checked against, comma separated
+	ProfanityLocales []string
+	// ACCOUNT_DELETION_MODE=delete removes the posts and comments of deleted accounts instead of anonymizing them
+	DeleteContentOnAccountDeletion bool
+	// PASSWORD_HASH_COST is the bcrypt cost of new password hashes, older hashes with a lower cost are upgraded on login
+	PasswordHashCost int
+	// PRE_MODERATION=true keeps new posts pending until an admin or moderator approves them
+	PreModeration bool
+	// WEBHOOK_TIMEOUT is how long one webhook delivery attempt may take before it's retried
+	WebhookTimeout time.Duration
+	// COMMENT_MAX_DEPTH is how deep replies can be nested, top level comments are at depth 1
+	CommentMaxDepth int
+	// COMMENT_DEPTH_MODE is flatten or reject, for the replies that would be nested deeper
+	CommentDepthMode string
+	// POST_REVISIONS_PUBLIC=true lets everyone read the revision history of a post, not only its author
+	PostRevisionsPublic bool
+	// CORS_ALLOWED_ORIGINS are the origins browsers may call the API from, comma separated, * allows every origin
+	CORSAllowedOrigins []string
+	// CONTENT_TYPE_NOSNIFF=false stops sending X-Content-Type-Options: nosniff, media files always send it
+	ContentTypeNosniff bool
+	// FRAME_OPTIONS, CONTENT_SECURITY_POLICY and REFERRER_POLICY are the values of their headers, off leaves a header out
+	FrameOptions          string
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+	// UNVERSIONED_API_SUNSET is the date the /api routes without a version stop working, announced in the Sunset header
+	UnversionedAPISunset time.Time
+	// ADMIN_EMAIL and ADMIN_PASSWORD create an admin account when migrating, outside production only
+	AdminEmail    string
+	AdminPassword string
+}
+
+// Default is the development config, without reading the environment
+func Default() Config {
+	return Config{
+		Env:                    EnvDevelopment,
+		Port:                   "8080",
+		DBPath:                 "discusspedia.db",
+		DBBusyTimeout:          5 * time.Second,
+		JWTSecret:              "key",
+		JWTAlgorithm:           "HS256",
+		JWTIssuer:              "discusspedia",
+		JWTAudience:            "discusspedia",
+		JWTExpiry:              60 * time.Minute,
+		JWTLeeway:              30 * time.Second,
+		MediaDir:               "media",
+		MediaCleanupInterval:   24 * time.Hour,
+		MediaCleanupGrace:      time.Hour,
+		MediaSigningKey:        "key",
+		SignedMediaTTL:         5 * time.Minute,
+		UploadConcurrency:      4,
+		UploadFileNameStrategy: FileNameStrategyUUID,
+		ExportLimitPerDay:      2,
+		PasswordHashCost:       bcrypt.DefaultCost,
+		ProfanityThreshold:     service.SeverityMild,
+		ProfanityLocales:       []string{service.LocaleIndonesian, service.LocaleEnglish},
+		WebhookTimeout:         5 * time.Second,
+		CommentMaxDepth:        5,
+		CommentDepthMode:       CommentDepthFlatten,
+		CORSAllowedOrigins:     []string{"*"},
+		ContentTypeNosniff:     true,
+		FrameOptions:           "DENY",
+		ContentSecurityPolicy:  "default-src 'none'; frame-ancestors 'none'",
+		ReferrerPolicy:         "no-referrer",
+	}
+}
+
+// Load overrides the defaults with the environment variables that are set and validates the result
+func Load() (Config, error) {
+	config := Default()
+
+	config.Env = getEnv("APP_ENV", config.Env)
+	config.Port = getEnv("PORT", config.Port)
+	config.DBPath = getEnv("DB_PATH", config.DBPath)
+	config.MediaDir = getEnv("MEDIA_DIR", config.MediaDir)
+	config.UploadFileNameStrategy = strings.ToLower(getEnv("UPLOAD_FILENAME_STRATEGY", config.UploadFileNameStrategy))
+	config.CommentDepthMode = strings.ToLower(getEnv("COMMENT_DEPTH_MODE", config.CommentDepthMode))
+	config.DeleteContentOnAccountDeletion = os.Getenv("ACCOUNT_DELETION_MODE") == "delete"
+	config.JWTAlgorithm = getEnv("JWT_ALGORITHM", config.JWTAlgorithm)
+	config.JWTIssuer = getEnv("JWT_ISSUER", config.JWTIssuer)
+	config.JWTAudience = getEnv("JWT_AUDIENCE", config.JWTAudience)
+	config.FrameOptions = strings.ToUpper(headerEnv("FRAME_OPTIONS", config.FrameOptions))
+	config.ContentSecurityPolicy = headerEnv("CONTENT_SECURITY_POLICY", config.ContentSecurityPolicy)
+	config.ReferrerPolicy = headerEnv("REFERRER_POLICY", config.ReferrerPolicy)
+	config.AdminEmail = os.Getenv("ADMIN_EMAIL")
+	config.AdminPassword = os.Getenv("ADMIN_PASSWORD")
+
+	if config.Env == EnvProduction {
+		config.JWTSecret = os.Getenv("JWT_SECRET")
+	} else {
+		config.JWTSecret = getEnv("JWT_SECRET", config.JWTSecret)
+	}
+	config.MediaSigningKey = getEnv("MEDIA_SIGNING_KEY", config.JWTSecret)
+
+	if env := os.Getenv("DB_BUSY_TIMEOUT"); env != "" {
+		timeout, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("DB_BUSY_TIMEOUT should be a duration: %w", err)
+		}
+		config.DBBusyTimeout = timeout
+	}
+
+	if env := os.Getenv("JWT_EXPIRY"); env != "" {
+		expiry, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("JWT_EXPIRY should be a duration: %w", err)
+		}
+		config.JWTExpiry = expiry
+	}
+
+	if env := os.Getenv("JWT_LEEWAY"); env != "" {
+		leeway, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("JWT_LEEWAY should be a duration: %w", err)
+		}
+		config.JWTLeeway = leeway
+	}
+
+	if env := os.Getenv("MEDIA_CLEANUP_INTERVAL"); env != "" {
+		interval, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("MEDIA_CLEANUP_INTERVAL should be a duration: %w", err)
+		}
+		config.MediaCleanupInterval = interval
+	}
+
+	if env := os.Getenv("MEDIA_CLEANUP_GRACE"); env != "" {
+		grace, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("MEDIA_CLEANUP_GRACE should be a duration: %w", err)
+		}
+		config.MediaCleanupGrace = grace
+	}
+
+	if env := os.Getenv("SIGNED_MEDIA_TTL"); env != "" {
+		ttl, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("SIGNED_MEDIA_TTL should be a duration: %w", err)
+		}
+		config.SignedMediaTTL = ttl
+	}
+
+	if env := os.Getenv("WEBHOOK_TIMEOUT"); env != "" {
+		timeout, err := time.ParseDuration(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("WEBHOOK_TIMEOUT should be a duration: %w", err)
+		}
+		config.WebhookTimeout = timeout
+	}
+
+	if env := os.Getenv("EXPORT_LIMIT_PER_DAY"); env != "" {
+		limit, err := strconv.Atoi(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("EXPORT_LIMIT_PER_DAY should be a int: %w", err)
+		}
+		config.ExportLimitPerDay = limit
+	}
+
+	if env := os.Getenv("UPLOAD_CONCURRENCY"); env != "" {
+		concurrency, err := strconv.Atoi(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("UPLOAD_CONCURRENCY should be a int: %w", err)
+		}
+		config.UploadConcurrency = concurrency
+	}
+
+	if env := os.Getenv("PASSWORD_HASH_COST"); env != "" {
+		cost, err := strconv.Atoi(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("PASSWORD_HASH_COST should be a int: %w", err)
+		}
+		config.PasswordHashCost = cost
+	}
+
+	if env := os.Getenv("COMMENT_MAX_DEPTH"); env != "" {
+		depth, err := strconv.Atoi(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("COMMENT_MAX_DEPTH should be a int: %w", err)
+		}
+		config.CommentMaxDepth = depth
+	}
+
+	if env := os.Getenv("PRE_MODERATION"); env != "" {
+		preModeration, err := strconv.ParseBool(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("PRE_MODERATION should be a bool: %w", err)
+		}
+		config.PreModeration = preModeration
+	}
+
+	if env := os.Getenv("POST_REVISIONS_PUBLIC"); env != "" {
+		public, err := strconv.ParseBool(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("POST_REVISIONS_PUBLIC should be a bool: %w", err)
+		}
+		config.PostRevisionsPublic = public
+	}
+
+	if env := os.Getenv("CONTENT_TYPE_NOSNIFF"); env != "" {
+		nosniff, err := strconv.ParseBool(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("CONTENT_TYPE_NOSNIFF should be a bool: %w", err)
+		}
+		config.ContentTypeNosniff = nosniff
+	}
+
+	if env := os.Getenv("CORS_ALLOWED_ORIGINS"); env != "" {
+		config.CORSAllowedOrigins = nil
+		for _, origin := range strings.Split(env, ",") {
+			config.CORSAllowedOrigins = append(config.CORSAllowedOrigins, strings.TrimSpace(origin))
+		}
+	}
+
+	if env := os.Getenv("UNVERSIONED_API_SUNSET"); env != "" {
+		sunset, err := time.Parse("2006-01-02", env)
+		if err != nil {
+			return Config{}, fmt.Errorf("UNVERSIONED_API_SUNSET should be a date like 2006-01-02: %w", err)
+		}
+		config.UnversionedAPISunset = sunset
+	}
+
+	if env := os.Getenv("PROFANITY_THRESHOLD"); env != "" {
+		threshold, err := service.ParseSeverity(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("PROFANITY_THRESHOLD: %w", err)
+		}
+		config.ProfanityThreshold = threshold
+	}
+
+	if env := os.Getenv("PROFANITY_LOCALES"); env != "" {
+		config.ProfanityLocales = nil
+		for _, locale := range strings.Split(env, ",") {
+			config.ProfanityLocales = append(config.ProfanityLocales, strings.ToLower(strings.TrimSpace(locale)))
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		return fmt.Errorf("APP_ENV should be %s or %s", EnvDevelopment, EnvProduction)
+	}
+
+	if c.JWTSecret == "" {
+		return errors.New("JWT_SECRET is required")
+	}
+
+	if c.JWTAlgorithm != "HS256" && c.JWTAlgorithm != "HS384" && c.JWTAlgorithm != "HS512" {
+		return errors.New("JWT_ALGORITHM should be HS256, HS384 or HS512")
+	}
+
+	if c.JWTIssuer == "" || c.JWTAudience == "" {
+		return errors.New("JWT_ISSUER and JWT_AUDIENCE are required")
+	}
+
+	if c.JWTExpiry <= 0 {
+		return errors.New("JWT_EXPIRY should be positive")
+	}
+
+	if c.JWTLeeway < 0 {
+		return errors.New("JWT_LEEWAY can't be negative")
+	}
+
+	if c.DBPath == "" {
+		return errors.New("DB_PATH is required")
+	}
+
+	if c.DBBusyTimeout < 0 {
+		return errors.New("DB_BUSY_TIMEOUT can't be negative")
+	}
+
+	if c.MediaDir == "" {
+		return errors.New("MEDIA_DIR is required")
+	}
+
+	if c.MediaCleanupInterval < 0 {
+		return errors.New("MEDIA_CLEANUP_INTERVAL can't be negative")
+	}
+
+	if c.MediaCleanupGrace < time.Minute {
+		return errors.New("MEDIA_CLEANUP_GRACE should be at least 1m")
+	}
+
+	if c.MediaSigningKey == "" {
+		return errors.New("MEDIA_SIGNING_KEY is required")
+	}
+
+	if c.SignedMediaTTL <= 0 || c.SignedMediaTTL > 24*time.Hour {
+		return errors.New("SIGNED_MEDIA_TTL should be positive and at most 24h")
+	}
+
+	if c.UploadConcurrency < 1 {
+		return errors.New("UPLOAD_CONCURRENCY should be at least 1")
+	}
+
+	if c.UploadFileNameStrategy != FileNameStrategyUUID && c.UploadFileNameStrategy != FileNameStrategySlug {
+		return fmt.Errorf("UPLOAD_FILENAME_STRATEGY should be %s or %s", FileNameStrategyUUID, FileNameStrategySlug)
+	}
+
+	if c.CommentMaxDepth < 1 {
+		return errors.New("COMMENT_MAX_DEPTH should be at least 1")
+	}
+
+	if c.CommentDepthMode != CommentDepthFlatten && c.CommentDepthMode != CommentDepthReject {
+		return fmt.Errorf("COMMENT_DEPTH_MODE should be %s or %s", CommentDepthFlatten, CommentDepthReject)
+	}
+
+	if c.WebhookTimeout <= 0 {
+		return errors.New("WEBHOOK_TIMEOUT should be positive")
+	}
+
+	if c.ExportLimitPerDay < 1 {
+		return errors.New("EXPORT_LIMIT_PER_DAY should be at least 1")
+	}
+
+	if c.PasswordHashCost < bcrypt.DefaultCost || c.PasswordHashCost > bcrypt.MaxCost {
+		return fmt.Errorf("PASSWORD_HASH_COST should be between %d and %d", bcrypt.DefaultCost, bcrypt.MaxCost)
+	}
+
+	if len(c.CORSAllowedOrigins) == 0 {
+		return errors.New("CORS_ALLOWED_ORIGINS is required")
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" && len(c.CORSAllowedOrigins) > 1 {
+			return errors.New("CORS_ALLOWED_ORIGINS can't mix * with other origins")
+		}
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: %q should start with http:// or https://", origin)
+		}
+	}
+
+	if c.FrameOptions != "" && c.FrameOptions != "DENY" && c.FrameOptions != "SAMEORIGIN" {
+		return errors.New("FRAME_OPTIONS should be DENY, SAMEORIGIN or off")
+	}
+
+	if c.AdminEmail != "" {
+		if c.Env == EnvProduction {
+			return errors.New("ADMIN_EMAIL can't be used in production")
+		}
+		if len(c.AdminPassword) < 8 || c.AdminPassword == "password" {
+			return errors.New("ADMIN_PASSWORD is required with ADMIN_EMAIL, with at least 8 characters and not \"password\"")
+		}
+	}
+
+	for _, locale := range c.ProfanityLocales {
+		if !service.IsWordListLocale(locale) {
+			return fmt.Errorf("PROFANITY_LOCALES: no bad words list for %q", locale)
+		}
+	}
+
+	return nil
+}
+
+// headerEnv reads the value of a response header, off turns the header off
+func headerEnv(key, fallback string) string {
+	value := getEnv(key, fallback)
+	if strings.EqualFold(value, "off") {
+		return ""
+	}
+	return value
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -2,14 +2,22 @@ package main
 
 import (
 	"database/sql"
+	"log"
 
+	"github.com/althafariq/discusspedia-be/config"
 	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/db/seeder"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
-	db, err := sql.Open("sqlite3", "discusspedia.db")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", cfg.DBPath)
 	if err != nil {
 		panic(err)
 	}
@@ -17,4 +25,10 @@ func main() {
 	defer db.Close()
 
 	migration.Migrate(db)
+
+	if cfg.AdminEmail != "" {
+		if err := seeder.SeedAdmin(db, cfg.AdminEmail, cfg.AdminPassword, cfg.PasswordHashCost); err != nil {
+			log.Fatalf("can't create the admin account: %v", err)
+		}
+	}
 }
@@ -2,8 +2,11 @@ package migration
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/althafariq/discusspedia-be/db/seeder"
+	"github.com/althafariq/discusspedia-be/repository"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -11,6 +14,10 @@ import (
 // Run This Script for migration db
 func Migrate(db *sql.DB) {
 
+	if err := renameLegacyNotifications(db); err != nil {
+		panic(err)
+	}
+
 	_, err := db.Exec(`
 	CREATE TABLE IF NOT EXISTS users (
     id integer not null primary key AUTOINCREMENT,
@@ -18,7 +25,14 @@ func Migrate(db *sql.DB) {
     email varchar(255) not null UNIQUE,
     password varchar(255) not null,
 	role varchar(255) not null,
-	avatar varchar(255) null
+	avatar varchar(255) null,
+	banned_until datetime null,
+	ban_reason varchar(255) null,
+	deleted_at datetime null,
+	likes_public boolean not null default 1,
+	bookmarks_public boolean not null default 0,
+	last_active_at datetime null,
+	registered_at datetime null
 );
 
 CREATE TABLE IF NOT EXISTS user_details (
@@ -31,7 +45,8 @@ CREATE TABLE IF NOT EXISTS user_details (
 
 CREATE TABLE IF NOT EXISTS categories(
 	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
-	name varchar(255) not null
+	name varchar(255) not null,
+	allow_anonymous boolean NOT NULL DEFAULT 0
 );
 
 CREATE TABLE IF NOT EXISTS posts(
@@ -41,6 +56,11 @@ CREATE TABLE IF NOT EXISTS posts(
 	title varchar(255) NOT NULL,
 	desc text NOT NULL,
 	created_at datetime NOT NULL,
+	updated_at datetime NULL,
+	is_pinned boolean NOT NULL DEFAULT 0,
+	comments_locked boolean NOT NULL DEFAULT 0,
+	moderation_status varchar(16) NOT NULL DEFAULT 'approved',
+	is_anonymous boolean NOT NULL DEFAULT 0,
 	FOREIGN KEY (author_id) REFERENCES users(id),
 	FOREIGN KEY (category_id) REFERENCES categories(id)
 );
@@ -52,17 +72,41 @@ CREATE TABLE IF NOT EXISTS questionnaires(
 	FOREIGN KEY (post_id) REFERENCES posts(id)
 );
 
+CREATE TABLE IF NOT EXISTS questionnaire_drafts(
+	author_id integer NOT NULL PRIMARY KEY,
+	category_id integer NULL,
+	title varchar(255) NOT NULL DEFAULT '',
+	description text NOT NULL DEFAULT '',
+	link varchar(255) NOT NULL DEFAULT '',
+	reward varchar(255) NOT NULL DEFAULT '',
+	updated_at datetime NOT NULL,
+	FOREIGN KEY (author_id) REFERENCES users(id)
+);
+
 CREATE TABLE IF NOT EXISTS post_images(
     id integer not null primary key AUTOINCREMENT,
 	post_id integer NOT NULL,
 	path varchar(255) NOT NULL,
+	original_name varchar(255) NULL,
+	display_order integer NOT NULL DEFAULT 0,
 	FOREIGN KEY (post_id) REFERENCES posts(id)
 );
 
-CREATE TABLE IF NOT EXISTS post_likes(
+CREATE TABLE IF NOT EXISTS questionnaire_images(
+    id integer not null primary key AUTOINCREMENT,
+	questionnaire_id integer NOT NULL,
+	path varchar(255) NOT NULL,
+	original_name varchar(255) NULL,
+	display_order integer NOT NULL DEFAULT 0,
+	FOREIGN KEY (questionnaire_id) REFERENCES questionnaires(post_id)
+);
+
+CREATE TABLE IF NOT EXISTS post_reactions(
     id integer not null primary key AUTOINCREMENT,
 	post_id integer NOT NULL,
 	user_id integer NOT NULL,
+	reaction varchar(16) NOT NULL DEFAULT 'like',
+	reacted_at datetime NULL,
 	FOREIGN KEY (post_id) REFERENCES posts(id),
 	FOREIGN KEY (user_id) REFERENCES users(id)
 );
@@ -74,6 +118,7 @@ CREATE TABLE IF NOT EXISTS comments(
 	comment_id integer NULL,
 	comment text NOT NULL,
 	created_at datetime NOT NULL,
+	is_highlighted boolean NOT NULL DEFAULT 0,
 	FOREIGN KEY (post_id) REFERENCES posts(id),
 	FOREIGN KEY (author_id) REFERENCES users(id),
 	FOREIGN KEY (comment_id) REFERENCES comments(id)
@@ -87,20 +132,366 @@ CREATE TABLE IF NOT EXISTS comment_likes(
 	FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
+CREATE TABLE IF NOT EXISTS follows(
+    id integer not null primary key AUTOINCREMENT,
+	follower_id integer NOT NULL,
+	following_id integer NOT NULL,
+	created_at datetime NOT NULL,
+	UNIQUE (follower_id, following_id),
+	FOREIGN KEY (follower_id) REFERENCES users(id),
+	FOREIGN KEY (following_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS mentions(
+    id integer not null primary key AUTOINCREMENT,
+	user_id integer NOT NULL,
+	author_id integer NOT NULL,
+	post_id integer NOT NULL,
+	comment_id integer NULL,
+	start integer NOT NULL,
+	end integer NOT NULL,
+	created_at datetime NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (author_id) REFERENCES users(id),
+	FOREIGN KEY (post_id) REFERENCES posts(id),
+	FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
+
 CREATE TABLE IF NOT EXISTS notifications(
     id integer not null primary key AUTOINCREMENT,
-	comment_id integer NOT NULL,
 	user_id integer NOT NULL,
-	already_read tinyint(1) NOT NULL DEFAULT 0,
+	type varchar(50) NOT NULL,
+	actor_id integer NOT NULL,
+	target_id integer NOT NULL,
+	read_at datetime NULL,
 	created_at datetime NOT NULL,
-	FOREIGN KEY (comment_id) REFERENCES comments(id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences(
+	user_id integer NOT NULL,
+	type varchar(50) NOT NULL,
+	enabled boolean NOT NULL DEFAULT 1,
+	PRIMARY KEY (user_id, type),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS saved_searches(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	user_id integer NOT NULL,
+	name varchar(100) NOT NULL,
+	query text NOT NULL,
+	created_at datetime NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS category_moderators(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	category_id integer NOT NULL,
+	user_id integer NOT NULL,
+	created_at datetime NOT NULL,
+	UNIQUE (category_id, user_id),
+	FOREIGN KEY (category_id) REFERENCES categories(id),
 	FOREIGN KEY (user_id) REFERENCES users(id)
 );
+
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+    id integer not null primary key AUTOINCREMENT,
+	user_id integer NOT NULL,
+	scope varchar(50) NOT NULL,
+	idempotency_key varchar(255) NOT NULL,
+	resource_id integer NULL,
+	response text NULL,
+	created_at datetime NOT NULL,
+	UNIQUE (user_id, scope, idempotency_key),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS blocks(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	blocker_id integer NOT NULL,
+	blocked_id integer NOT NULL,
+	created_at datetime NOT NULL,
+	UNIQUE (blocker_id, blocked_id),
+	FOREIGN KEY (blocker_id) REFERENCES users(id),
+	FOREIGN KEY (blocked_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	actor_id integer NOT NULL,
+	action varchar(50) NOT NULL,
+	target_type varchar(20) NOT NULL,
+	target_id integer NOT NULL,
+	metadata text NULL,
+	created_at datetime NOT NULL,
+	FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS webhooks(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	url varchar(2048) NOT NULL,
+	category_id integer NULL,
+	secret varchar(255) NOT NULL,
+	created_by integer NOT NULL,
+	created_at datetime NOT NULL,
+	FOREIGN KEY (category_id) REFERENCES categories(id),
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	webhook_id integer NOT NULL,
+	event varchar(50) NOT NULL,
+	post_id integer NOT NULL,
+	attempt integer NOT NULL,
+	status_code integer NULL,
+	error text NULL,
+	duration_ms integer NOT NULL,
+	created_at datetime NOT NULL,
+	FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+);
+
+CREATE TABLE IF NOT EXISTS post_revisions(
+	id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+	post_id integer NOT NULL,
+	editor_id integer NOT NULL,
+	category_id integer NOT NULL,
+	title varchar(255) NOT NULL,
+	desc text NOT NULL,
+	created_at datetime NOT NULL,
+	FOREIGN KEY (post_id) REFERENCES posts(id),
+	FOREIGN KEY (editor_id) REFERENCES users(id)
+);
 `)
 
 	if err != nil {
 		panic(err)
 	}
 
-	seeder.Seed(db)
+	if err := migratePostLikes(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateNotifications(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateColumns(db); err != nil {
+		panic(err)
+	}
+
+	if err := migrateRoles(db); err != nil {
+		panic(err)
+	}
+
+	// The indexes come after the columns, some of them are on columns older databases only just got
+	_, err = db.Exec(`
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_id ON post_reactions(post_id);
+CREATE INDEX IF NOT EXISTS idx_follows_following_id ON follows(following_id);
+CREATE INDEX IF NOT EXISTS idx_users_last_active_at ON users(last_active_at);
+CREATE INDEX IF NOT EXISTS idx_posts_moderation_status ON posts(moderation_status);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_category_moderators_user_id ON category_moderators(user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id ON post_revisions(post_id);
+CREATE INDEX IF NOT EXISTS idx_saved_searches_user_id ON saved_searches(user_id);
+`)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// The seed users have unique emails, a database that already has users isn't seeded again
+	var hasUsers bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users)`).Scan(&hasUsers); err != nil {
+		panic(err)
+	}
+	if !hasUsers {
+		seeder.Seed(db)
+	}
+}
+
+// migratePostLikes moves the likes of databases created before reactions into post_reactions, each one as a like
+func migratePostLikes(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'post_likes')`).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO post_reactions (post_id, user_id, reaction) SELECT post_id, user_id, 'like' FROM post_likes ORDER BY id;
+		DROP TABLE post_likes;`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// renameLegacyNotifications moves the notifications table of databases from before notification types out of the way,
+// so the new table is created in its place and migrateNotifications copies the old rows into it
+func renameLegacyNotifications(db *sql.DB) error {
+	legacy, err := columnExists(db, "notifications", "comment_id")
+	if err != nil || !legacy {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TABLE notifications RENAME TO legacy_notifications;`)
+	return err
+}
+
+// migrateNotifications copies the legacy notifications, which were all about comments, as comment notifications or
+// replies when they went to the author of the parent comment. They had no read time, the creation time stands in for
+// it. Notifications of deleted comments were never listed and are dropped
+func migrateNotifications(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'legacy_notifications')`).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO notifications (user_id, type, actor_id, target_id, read_at, created_at)
+		SELECT
+			n.user_id,
+			CASE WHEN parent.author_id = n.user_id THEN 'reply' ELSE 'comment' END,
+			c.author_id,
+			n.comment_id,
+			CASE WHEN n.already_read THEN n.created_at END,
+			n.created_at
+		FROM legacy_notifications n
+		INNER JOIN comments c ON c.id = n.comment_id
+		LEFT JOIN comments parent ON parent.id = c.comment_id
+		ORDER BY n.id;
+		DROP TABLE legacy_notifications;`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addedColumns are the columns added to tables after they were first created. CREATE TABLE IF NOT EXISTS leaves the
+// tables of existing databases as they are, so these are added to them one by one
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"users", "banned_until", "datetime null"},
+	{"users", "ban_reason", "varchar(255) null"},
+	{"users", "deleted_at", "datetime null"},
+	{"users", "likes_public", "boolean not null default 1"},
+	{"users", "bookmarks_public", "boolean not null default 0"},
+	{"users", "last_active_at", "datetime null"},
+	{"users", "registered_at", "datetime null"},
+	{"categories", "allow_anonymous", "boolean NOT NULL DEFAULT 0"},
+	{"posts", "updated_at", "datetime NULL"},
+	{"posts", "is_pinned", "boolean NOT NULL DEFAULT 0"},
+	{"posts", "comments_locked", "boolean NOT NULL DEFAULT 0"},
+	{"posts", "moderation_status", "varchar(16) NOT NULL DEFAULT 'approved'"},
+	{"posts", "is_anonymous", "boolean NOT NULL DEFAULT 0"},
+	{"post_images", "original_name", "varchar(255) NULL"},
+	{"post_images", "display_order", "integer NOT NULL DEFAULT 0"},
+	{"comments", "is_highlighted", "boolean NOT NULL DEFAULT 0"},
+}
+
+// migrateColumns adds the addedColumns that the tables don't have yet
+func migrateColumns(db *sql.DB) error {
+	for _, added := range addedColumns {
+		exists, err := columnExists(db, added.table, added.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, added.table, added.column, added.definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateRoles normalizes the roles stored before they were checked, and makes the users table refuse the roles that
+// aren't one of repository.Roles. SQLite can't add a CHECK to an existing table, so triggers do it, recreated on every
+// migration to follow the current roles. Rows with a role that can't be normalized are left for an admin to fix
+func migrateRoles(db *sql.DB) error {
+	rows, err := db.Query(`SELECT DISTINCT role FROM users;`)
+	if err != nil {
+		return err
+	}
+
+	var stored []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			rows.Close()
+			return err
+		}
+		stored = append(stored, role)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DROP TRIGGER IF EXISTS users_role_insert; DROP TRIGGER IF EXISTS users_role_update;`); err != nil {
+		return err
+	}
+
+	for _, role := range stored {
+		normalized, err := repository.NormalizeRole(role)
+		if err != nil || normalized == role {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE users SET role = ? WHERE role = ?;`, normalized, role); err != nil {
+			return err
+		}
+	}
+
+	roles := make([]string, len(repository.Roles))
+	for i, role := range repository.Roles {
+		roles[i] = "'" + role + "'"
+	}
+	known := strings.Join(roles, ", ")
+
+	if _, err := tx.Exec(fmt.Sprintf(`
+		CREATE TRIGGER users_role_insert BEFORE INSERT ON users WHEN NEW.role NOT IN (%[1]s)
+		BEGIN SELECT RAISE(ABORT, 'unknown role'); END;
+		CREATE TRIGGER users_role_update BEFORE UPDATE OF role ON users WHEN NEW.role NOT IN (%[1]s)
+		BEGIN SELECT RAISE(ABORT, 'unknown role'); END;`, known)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, table, column).Scan(&exists)
+	return exists, err
 }
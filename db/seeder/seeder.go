@@ -69,3 +69,16 @@ func Seed(db *sql.DB) {
 	(7, $1, $2, "Comment 7", 6, "2022-06-11 19:33:02.3861157+07:00");`, postId, userMahasiswaId)
 
 }
+
+// SeedAdmin creates the admin account with the given credentials, unless a user already has the email. It's for
+// setting up development databases, the credentials come from ADMIN_EMAIL and ADMIN_PASSWORD
+func SeedAdmin(db *sql.DB, email, password string, cost int) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO users (name, email, password, role)
+		SELECT 'Admin', ?, ?, 'admin' WHERE NOT EXISTS (SELECT 1 FROM users WHERE email = ?)`, email, hashedPassword, email)
+	return err
+}
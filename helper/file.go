@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"crypto/rand"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const MaxFileNameLength = 100
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SafeFileName turns an uploaded file name into a base name that can't leave the upload folder: only letters,
+// digits, dots, dashes and underscores are kept, leading dots are removed and the name is capped with its extension kept
+func SafeFileName(name string) string {
+	// Backslashes aren't separators on Linux but a Windows client may still send them
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = unsafeFileNameChars.ReplaceAllString(name, "")
+	name = strings.TrimLeft(name, ".")
+
+	if len(name) > MaxFileNameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 10 {
+			ext = ""
+		}
+		name = name[:MaxFileNameLength-len(ext)] + ext
+	}
+
+	if name == "" {
+		return "file"
+	}
+
+	return name
+}
+
+// RandomFileName is a random UUID (version 4) with the lowercased extension of the uploaded file name, so nothing the
+// user chose ends up in the stored name but the extension
+func RandomFileName(name string) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	ext := strings.ToLower(filepath.Ext(SafeFileName(name)))
+	if len(ext) > 10 {
+		ext = ""
+	}
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x%s", id[0:4], id[4:6], id[6:8], id[8:10], id[10:], ext), nil
+}
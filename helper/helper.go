@@ -3,6 +3,7 @@ package helper
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -48,3 +49,33 @@ func GetErrorMessage(ve validator.ValidationErrors) []JSONRequestErrorResponse {
 
 	return out
 }
+
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 10000
+	MaxCommentLength     = 5000
+)
+
+type TextField struct {
+	Name     string
+	Value    *string
+	Max      int
+	Required bool
+}
+
+// ValidateTextFields trims every value in place, then checks it isn't empty (when required) and doesn't exceed
+// the max length counted in characters
+func ValidateTextFields(fields ...TextField) []JSONRequestErrorResponse {
+	out := make([]JSONRequestErrorResponse, 0)
+	for _, field := range fields {
+		*field.Value = strings.TrimSpace(*field.Value)
+
+		if field.Required && *field.Value == "" {
+			out = append(out, JSONRequestErrorResponse{field.Name, "This field is required"})
+		} else if utf8.RuneCountInString(*field.Value) > field.Max {
+			out = append(out, JSONRequestErrorResponse{field.Name, fmt.Sprintf("Must be at most %d characters", field.Max)})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,22 @@
+package helper
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidID = errors.New("invalid id")
+
+// ParseID reads the path param as an id. Anything but a positive int is rejected with 400 and ErrInvalidID is returned,
+// so the handler only has to return
+func ParseID(c *gin.Context, param string) (int, error) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil || id <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidID.Error()})
+		return 0, ErrInvalidID
+	}
+	return id, nil
+}
@@ -1,28 +1,50 @@
 package main
 
 import (
-	"database/sql"
+	"log"
 
 	"github.com/althafariq/discusspedia-be/api"
+	"github.com/althafariq/discusspedia-be/config"
 	"github.com/althafariq/discusspedia-be/repository"
+	"github.com/althafariq/discusspedia-be/service"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
-	db, err := sql.Open("sqlite3", "discusspedia.db")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
+	service.GetValidationInstance().SetThreshold(cfg.ProfanityThreshold)
+	if err := service.GetValidationInstance().SetActiveLocales(cfg.ProfanityLocales); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
+	db, err := repository.OpenDB(cfg.DBPath, cfg.DBBusyTimeout)
 	if err != nil {
 		panic(err)
 	}
 
 	commentRepo := repository.NewCommentRepository(db)
+	followRepo := repository.NewFollowRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
 	likeRepo := repository.NewLikeRepository(db)
+	mentionRepo := repository.NewMentionRepository(db)
 	notifRepo := repository.NewNotificationRepository(db)
 	postsRepo := repository.NewPostRepository(db)
+	postsRepo.SetPreModeration(cfg.PreModeration)
 	userRepo := repository.NewUserRepository(db)
+	userRepo.SetPasswordCost(cfg.PasswordHashCost)
 	categoryRepo := repository.NewCategoryRepository(db)
 	questionnaireRepo := repository.NewQuestionnaireRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
 
-	mainAPI := api.NewAPI(*commentRepo, *likeRepo, *notifRepo, *postsRepo, *userRepo, *categoryRepo, *questionnaireRepo)
+	mainAPI := api.NewAPI(cfg, commentRepo, followRepo, idempotencyRepo, likeRepo, mentionRepo, notifRepo, postsRepo, userRepo, categoryRepo, questionnaireRepo, auditRepo, webhookRepo)
+	if err := mainAPI.CreateMediaDirs(); err != nil {
+		log.Fatalf("can't create the media dir: %v", err)
+	}
 	mainAPI.Start()
 }
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	AuditActionBanUser                 = "user.ban"
+	AuditActionUnbanUser               = "user.unban"
+	AuditActionChangeRole              = "user.role"
+	AuditActionApprovePost             = "post.approve"
+	AuditActionRejectPost              = "post.reject"
+	AuditActionPinPost                 = "post.pin"
+	AuditActionUnpinPost               = "post.unpin"
+	AuditActionLockComments            = "post.lock_comments"
+	AuditActionCleanupMedia            = "media.cleanup"
+	AuditActionRecountLikes            = "likes.recount"
+	AuditActionProfanityLocale         = "profanity.locale"
+	AuditActionAssignCategoryModerator = "category.assign_moderator"
+	AuditActionRevokeCategoryModerator = "category.revoke_moderator"
+	AuditActionCategoryAnonymous       = "category.allow_anonymous"
+	AuditActionCreateWebhook           = "webhook.create"
+	AuditActionDeleteWebhook           = "webhook.delete"
+	AuditActionImportPosts             = "post.import"
+	AuditActionMergePost               = "post.merge"
+)
+
+const (
+	AuditTargetUser     = "user"
+	AuditTargetPost     = "post"
+	AuditTargetCategory = "category"
+	AuditTargetWebhook  = "webhook"
+	AuditTargetSystem   = "system"
+)
+
+// AuditEntry is one moderation action, Metadata holds the details of the action as JSON, like the reason of a ban
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	ActorID    int             `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int             `json:"target_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows down the audit log, zero values are not filtered on
+type AuditFilter struct {
+	ActorID int
+	Action  string
+	From    time.Time
+	To      time.Time
+}
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+func (a *AuditRepository) InsertAuditEntry(actorID int, action, targetType string, targetID int, metadata json.RawMessage) error {
+	var storedMetadata *string
+	if len(metadata) > 0 {
+		value := string(metadata)
+		storedMetadata = &value
+	}
+
+	_, err := a.db.Exec(`
+		INSERT INTO audit_log (actor_id, action, target_type, target_id, metadata, created_at) VALUES (?, ?, ?, ?, ?, ?);
+	`, actorID, action, targetType, targetID, storedMetadata, time.Now())
+	return err
+}
+
+// FetchAuditEntries returns the newest entries first
+func (a *AuditRepository) FetchAuditEntries(filter AuditFilter, limit, offset int) ([]AuditEntry, error) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	if filter.ActorID > 0 {
+		conditions = append(conditions, "l.actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "l.action = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "datetime(l.created_at) >= datetime(?)")
+		args = append(args, filter.From.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "datetime(l.created_at) <= datetime(?)")
+		args = append(args, filter.To.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	rows, err := a.db.Query(`
+		SELECT l.id, l.actor_id, COALESCE(u.name, ''), l.action, l.target_type, l.target_id, l.metadata, l.created_at
+		FROM audit_log l
+		LEFT JOIN users u ON u.id = l.actor_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT ? OFFSET ?;
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var metadata sql.NullString
+		err := rows.Scan(
+			&entry.ID, &entry.ActorID, &entry.ActorName, &entry.Action, &entry.TargetType, &entry.TargetID,
+			&metadata, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if metadata.Valid {
+			entry.Metadata = json.RawMessage(metadata.String)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit Repository Test", func() {
+	var (
+		db        *sql.DB
+		auditRepo *repository.AuditRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		auditRepo = repository.NewAuditRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	It("should return the entries newest first and filter them", func() {
+		Expect(auditRepo.InsertAuditEntry(3, repository.AuditActionBanUser, repository.AuditTargetUser, 2,
+			json.RawMessage(`{"reason":"spam"}`))).To(Succeed())
+		Expect(auditRepo.InsertAuditEntry(3, repository.AuditActionPinPost, repository.AuditTargetPost, 1, nil)).To(Succeed())
+		Expect(auditRepo.InsertAuditEntry(1, repository.AuditActionPinPost, repository.AuditTargetPost, 1, nil)).To(Succeed())
+
+		entries, err := auditRepo.FetchAuditEntries(repository.AuditFilter{}, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].ActorName).To(Equal("Radit"))
+		Expect(entries[2].Action).To(Equal(repository.AuditActionBanUser))
+		Expect(entries[2].Metadata).To(MatchJSON(`{"reason":"spam"}`))
+		Expect(entries[1].Metadata).To(BeNil())
+
+		entries, err = auditRepo.FetchAuditEntries(repository.AuditFilter{ActorID: 3, Action: repository.AuditActionPinPost}, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].TargetID).To(Equal(1))
+
+		entries, err = auditRepo.FetchAuditEntries(repository.AuditFilter{From: time.Now().Add(time.Hour)}, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+
+		entries, err = auditRepo.FetchAuditEntries(repository.AuditFilter{To: time.Now().Add(time.Hour)}, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+	})
+})
@@ -0,0 +1,89 @@
+package repository
+
+import "time"
+
+// BlockedUser is a user in the block list of the viewer
+type BlockedUser struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Avatar    *string   `json:"avatar"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockUser also removes the follows between both users, created is false when the user was already blocked
+func (f *FollowRepository) BlockUser(blockerID, blockedID int) (bool, error) {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO blocks (blocker_id, blocked_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+	`, blockerID, blockedID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM follows WHERE (follower_id = ?1 AND following_id = ?2) OR (follower_id = ?2 AND following_id = ?1);
+	`, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+
+	return created > 0, tx.Commit()
+}
+
+// UnblockUser returns false when the user wasn't blocked
+func (f *FollowRepository) UnblockUser(blockerID, blockedID int) (bool, error) {
+	result, err := f.db.Exec(`DELETE FROM blocks WHERE blocker_id = ? AND blocked_id = ?;`, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	return removed > 0, err
+}
+
+func (f *FollowRepository) IsBlocked(blockerID, blockedID int) (bool, error) {
+	var blocked bool
+	err := f.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = ? AND blocked_id = ?);`, blockerID, blockedID,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+// FetchBlockedUsers returns the users blocked by the user, most recently blocked first
+func (f *FollowRepository) FetchBlockedUsers(blockerID, limit, offset int) ([]BlockedUser, error) {
+	rows, err := f.db.Query(`
+		SELECT u.id, u.name, u.avatar, b.created_at
+		FROM blocks b
+		INNER JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC, b.id DESC
+		LIMIT ? OFFSET ?;
+	`, blockerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []BlockedUser{}
+	for rows.Next() {
+		var user BlockedUser
+		if err := rows.Scan(&user.ID, &user.Name, &user.Avatar, &user.BlockedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
@@ -2,10 +2,13 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var ErrCategoryNotFound = errors.New("category not found")
+
 type CategoryRepository struct {
 	db *sql.DB
 }
@@ -18,7 +21,7 @@ func NewCategoryRepository(db *sql.DB) *CategoryRepository {
 
 func (c CategoryRepository) GetAllCategories() ([]Category, error) {
 	categories := make([]Category, 0)
-	rows, err := c.db.Query("SELECT * FROM categories")
+	rows, err := c.db.Query("SELECT id, name, allow_anonymous FROM categories")
 	if err != nil {
 		return categories, err
 	}
@@ -26,9 +29,87 @@ func (c CategoryRepository) GetAllCategories() ([]Category, error) {
 
 	for rows.Next() {
 		category := Category{}
-		rows.Scan(&category.ID, &category.Name)
+		rows.Scan(&category.ID, &category.Name, &category.AllowAnonymous)
 		categories = append(categories, category)
 	}
 
 	return categories, nil
 }
+
+func (c CategoryRepository) CategoryExists(id int) (bool, error) {
+	var exists bool
+	err := c.db.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?)", id).Scan(&exists)
+	return exists, err
+}
+
+func (c CategoryRepository) CategoryByID(id int) (Category, error) {
+	var category Category
+	err := c.db.QueryRow("SELECT id, name, allow_anonymous FROM categories WHERE id = ?", id).
+		Scan(&category.ID, &category.Name, &category.AllowAnonymous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return category, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// SetCategoryAllowAnonymous only affects new posts, the anonymous posts already in the category stay anonymous
+func (c CategoryRepository) SetCategoryAllowAnonymous(id int, allow bool) error {
+	result, err := c.db.Exec("UPDATE categories SET allow_anonymous = ? WHERE id = ?", allow, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+// CountCategoryPosts counts the published posts of the category, questionnaires aren't included
+func (c CategoryRepository) CountCategoryPosts(id int) (int, error) {
+	var count int
+	err := c.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM posts p
+		LEFT JOIN questionnaires q ON q.post_id = p.id
+		WHERE p.category_id = ? AND q.post_id IS NULL AND p.moderation_status = ?`,
+		id, ModerationApproved,
+	).Scan(&count)
+	return count, err
+}
+
+// CountPostsByCategory counts the published posts of every category like CountCategoryPosts, in one query. The
+// categories without posts are left out unless includeEmpty is set
+func (c CategoryRepository) CountPostsByCategory(includeEmpty bool) ([]CategoryPostCount, error) {
+	rows, err := c.db.Query(`
+		SELECT c.id, c.name, COUNT(p.id)
+		FROM categories c
+		LEFT JOIN posts p ON p.category_id = c.id AND p.moderation_status = ?
+			AND NOT EXISTS (SELECT 1 FROM questionnaires q WHERE q.post_id = p.id)
+		GROUP BY c.id, c.name
+		HAVING ? OR COUNT(p.id) > 0
+		ORDER BY c.id`,
+		ModerationApproved, includeEmpty,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []CategoryPostCount{}
+	for rows.Next() {
+		var count CategoryPostCount
+		if err := rows.Scan(&count.CategoryID, &count.Name, &count.PostCount); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Category Repository Test", func() {
+	var (
+		db           *sql.DB
+		categoryRepo *repository.CategoryRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		categoryRepo = repository.NewCategoryRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	It("should count the published posts of every category without the questionnaires", func() {
+		postRepo := repository.NewPostRepository(db)
+		questionnaireRepo := repository.NewQuestionnaireRepository(db)
+
+		_, err := postRepo.InsertPost(2, 3, "Published", "Description", false)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = questionnaireRepo.InsertQuestionnaire(repository.Questionnaire{
+			Author: repository.User{Id: 2}, Category: repository.Category{ID: 3}, Title: "Survey", Description: "Description",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		postRepo.SetPreModeration(true)
+		_, err = postRepo.InsertPost(2, 3, "Pending", "Description", false)
+		Expect(err).ToNot(HaveOccurred())
+
+		counts, err := categoryRepo.CountPostsByCategory(false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(counts).To(HaveLen(2))
+		Expect(counts[0].CategoryID).To(Equal(1))
+		Expect(counts[1]).To(Equal(repository.CategoryPostCount{CategoryID: 3, Name: "Psikologi", PostCount: 1}))
+
+		postCount, err := categoryRepo.CountCategoryPosts(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(counts[0].PostCount).To(Equal(postCount))
+
+		counts, err = categoryRepo.CountPostsByCategory(true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(counts)).To(BeNumerically(">", 3))
+		Expect(counts[1].CategoryID).To(Equal(2))
+		Expect(counts[1].PostCount).To(BeZero())
+	})
+})
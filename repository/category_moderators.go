@@ -0,0 +1,128 @@
+package repository
+
+import "time"
+
+// CategoryModerator is a user allowed to moderate the posts of a category
+type CategoryModerator struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Avatar     *string   `json:"avatar"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+// AddCategoryModerator returns false when the user already moderates the category
+func (c CategoryRepository) AddCategoryModerator(categoryID, userID int) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	var categoryExists, userExists bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?), EXISTS (SELECT 1 FROM users WHERE id = ?);
+	`, categoryID, userID).Scan(&categoryExists, &userExists)
+	if err != nil {
+		return false, err
+	}
+	if !categoryExists {
+		return false, ErrCategoryNotFound
+	}
+	if !userExists {
+		return false, ErrUserNotFound
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO category_moderators (category_id, user_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (category_id, user_id) DO NOTHING;
+	`, categoryID, userID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return created > 0, tx.Commit()
+}
+
+// RemoveCategoryModerator returns false when the user wasn't a moderator of the category
+func (c CategoryRepository) RemoveCategoryModerator(categoryID, userID int) (bool, error) {
+	result, err := c.db.Exec(
+		`DELETE FROM category_moderators WHERE category_id = ? AND user_id = ?;`, categoryID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	return removed > 0, err
+}
+
+func (c CategoryRepository) IsCategoryModerator(userID, categoryID int) (bool, error) {
+	var moderator bool
+	err := c.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM category_moderators WHERE user_id = ? AND category_id = ?);`, userID, categoryID,
+	).Scan(&moderator)
+	return moderator, err
+}
+
+// FetchModeratedCategoryIDs returns the categories the user moderates, empty when they moderate none
+func (c CategoryRepository) FetchModeratedCategoryIDs(userID int) ([]int, error) {
+	rows, err := c.db.Query(
+		`SELECT category_id FROM category_moderators WHERE user_id = ? ORDER BY category_id;`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categoryIDs := []int{}
+	for rows.Next() {
+		var categoryID int
+		if err := rows.Scan(&categoryID); err != nil {
+			return nil, err
+		}
+		categoryIDs = append(categoryIDs, categoryID)
+	}
+
+	return categoryIDs, rows.Err()
+}
+
+// FetchCategoryModerators returns the moderators of the category, longest serving first
+func (c CategoryRepository) FetchCategoryModerators(categoryID int) ([]CategoryModerator, error) {
+	var exists bool
+	err := c.db.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?)", categoryID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCategoryNotFound
+	}
+
+	rows, err := c.db.Query(`
+		SELECT u.id, u.name, u.avatar, cm.created_at
+		FROM category_moderators cm
+		INNER JOIN users u ON u.id = cm.user_id
+		WHERE cm.category_id = ?
+		ORDER BY cm.created_at, cm.id;
+	`, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderators := []CategoryModerator{}
+	for rows.Next() {
+		var moderator CategoryModerator
+		if err := rows.Scan(&moderator.ID, &moderator.Name, &moderator.Avatar, &moderator.AssignedAt); err != nil {
+			return nil, err
+		}
+		moderators = append(moderators, moderator)
+	}
+
+	return moderators, rows.Err()
+}
@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Category Moderator Repository Test", func() {
+	var (
+		db           *sql.DB
+		categoryRepo *repository.CategoryRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		categoryRepo = repository.NewCategoryRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	It("should assign and revoke the moderators of a category", func() {
+		created, err := categoryRepo.AddCategoryModerator(1, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(BeTrue())
+
+		created, err = categoryRepo.AddCategoryModerator(1, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(BeFalse())
+
+		_, err = categoryRepo.AddCategoryModerator(3, 2)
+		Expect(err).ToNot(HaveOccurred())
+
+		moderator, err := categoryRepo.IsCategoryModerator(2, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(moderator).To(BeTrue())
+
+		moderator, err = categoryRepo.IsCategoryModerator(2, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(moderator).To(BeFalse())
+
+		categoryIDs, err := categoryRepo.FetchModeratedCategoryIDs(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(categoryIDs).To(Equal([]int{1, 3}))
+
+		moderators, err := categoryRepo.FetchCategoryModerators(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(moderators).To(HaveLen(1))
+		Expect(moderators[0].Name).To(Equal("Bocil SMA"))
+
+		removed, err := categoryRepo.RemoveCategoryModerator(1, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed).To(BeTrue())
+
+		removed, err = categoryRepo.RemoveCategoryModerator(1, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed).To(BeFalse())
+
+		categoryIDs, err = categoryRepo.FetchModeratedCategoryIDs(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(categoryIDs).To(Equal([]int{3}))
+	})
+
+	It("should not assign moderators to unknown categories or users", func() {
+		_, err := categoryRepo.AddCategoryModerator(99, 2)
+		Expect(err).To(MatchError(repository.ErrCategoryNotFound))
+
+		_, err = categoryRepo.AddCategoryModerator(1, 99)
+		Expect(err).To(MatchError(repository.ErrUserNotFound))
+
+		_, err = categoryRepo.FetchCategoryModerators(99)
+		Expect(err).To(MatchError(repository.ErrCategoryNotFound))
+	})
+})
@@ -2,11 +2,18 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrCommentIsReply  = errors.New("only top level comments can be highlighted")
+)
+
 type CommentRepository struct {
 	db *sql.DB
 }
@@ -20,17 +27,23 @@ func NewCommentRepository(db *sql.DB) *CommentRepository {
 func (c *CommentRepository) SelectAllCommentsByParentCommentID(out chan<- []Comment, errOut chan<- error, userID, parentCommentID int) {
 	sqlStmt := `
 	SELECT
-		c.*,
+		c.id,
+		c.post_id,
+		c.author_id,
+		c.comment_id,
+		c.comment,
+		c.created_at,
+		c.is_highlighted,
 		u.name as author_name,
 		u.avatar as author_avatar,
 		(SELECT COUNT(*) FROM comment_likes WHERE comment_id = c.id) AS total_like,
 		(SELECT EXISTS (SELECT 1 FROM comment_likes WHERE comment_id = c.id AND user_id = ?)) AS is_like
 	FROM comments c
 	LEFT JOIN users u ON c.author_id = u.id
-	WHERE c.comment_id = ?
+	WHERE c.comment_id = ? AND c.author_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)
 	ORDER BY c.created_at;`
 
-	rows, err := c.db.Query(sqlStmt, userID, parentCommentID)
+	rows, err := c.db.Query(sqlStmt, userID, parentCommentID, userID)
 	if err != nil {
 		errOut <- err
 		return
@@ -51,6 +64,7 @@ func (c *CommentRepository) SelectAllCommentsByParentCommentID(out chan<- []Comm
 			&comment.ParentCommentID,
 			&comment.Comment,
 			&comment.CreatedAt,
+			&comment.IsHighlighted,
 			&comment.AuthorName,
 			&comment.AuthorAvatar,
 			&comment.TotalLike,
@@ -85,20 +99,175 @@ func (c *CommentRepository) SelectAllCommentsByParentCommentID(out chan<- []Comm
 	close(ch)
 }
 
+// FetchCommentsOfPost returns up to limit comments of the post at every depth in a single query, with the number of
+// comments the post has. They're ordered by id so a reply always comes after the comment it replies to. Comments of
+// users the viewer blocked are left out
+func (c *CommentRepository) FetchCommentsOfPost(userID, postID, limit int) ([]Comment, int, error) {
+	sqlStmt := `
+	SELECT
+		c.id,
+		c.post_id,
+		c.author_id,
+		c.comment_id,
+		c.comment,
+		c.created_at,
+		c.is_highlighted,
+		u.name as author_name,
+		u.avatar as author_avatar,
+		(SELECT COUNT(*) FROM comment_likes WHERE comment_id = c.id) AS total_like,
+		(SELECT EXISTS (SELECT 1 FROM comment_likes WHERE comment_id = c.id AND user_id = ?)) AS is_like,
+		COUNT(*) OVER () AS total
+	FROM comments c
+	LEFT JOIN users u ON c.author_id = u.id
+	WHERE c.post_id = ? AND c.author_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)
+	ORDER BY c.id
+	LIMIT ?;`
+
+	rows, err := c.db.Query(sqlStmt, userID, postID, userID, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var total int
+	comments := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		err = rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.AuthorID,
+			&comment.ParentCommentID,
+			&comment.Comment,
+			&comment.CreatedAt,
+			&comment.IsHighlighted,
+			&comment.AuthorName,
+			&comment.AuthorAvatar,
+			&comment.TotalLike,
+			&comment.IsLike,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		comment.IsAuthor = comment.AuthorID == userID
+		comments = append(comments, comment)
+	}
+
+	return comments, total, rows.Err()
+}
+
+// FetchCommentsBeforeCursor returns up to limit comments of the post at every depth older than the cursor comment,
+// newest first. A cursor of 0 starts from the newest comment
+func (c *CommentRepository) FetchCommentsBeforeCursor(postID, cursor, limit, viewerID int) ([]Comment, error) {
+	return c.fetchCommentsFromCursor(postID, cursor, limit, viewerID, true)
+}
+
+// FetchCommentsAfterCursor returns up to limit comments of the post at every depth newer than the cursor comment,
+// oldest first
+func (c *CommentRepository) FetchCommentsAfterCursor(postID, cursor, limit, viewerID int) ([]Comment, error) {
+	return c.fetchCommentsFromCursor(postID, cursor, limit, viewerID, false)
+}
+
+// fetchCommentsFromCursor pages on (created_at, id) so comments inserted meanwhile don't shift the pages. When the
+// cursor comment was deleted the ids are compared alone, they're given in insertion order too
+func (c *CommentRepository) fetchCommentsFromCursor(postID, cursor, limit, viewerID int, before bool) ([]Comment, error) {
+	comparison, order := ">", "ASC"
+	if before {
+		comparison, order = "<", "DESC"
+	}
+
+	condition := ""
+	args := []interface{}{viewerID, postID, viewerID}
+
+	if cursor > 0 {
+		var createdAt string
+		err := c.db.QueryRow(`SELECT CAST(created_at AS TEXT) FROM comments WHERE id = ?;`, cursor).Scan(&createdAt)
+		switch {
+		case err == nil:
+			condition = fmt.Sprintf("AND (c.created_at %[1]s ? OR (c.created_at = ? AND c.id %[1]s ?))", comparison)
+			args = append(args, createdAt, createdAt, cursor)
+		case errors.Is(err, sql.ErrNoRows):
+			condition = fmt.Sprintf("AND c.id %s ?", comparison)
+			args = append(args, cursor)
+		default:
+			return nil, err
+		}
+	}
+
+	sqlStmt := fmt.Sprintf(`
+	SELECT
+		c.id,
+		c.post_id,
+		c.author_id,
+		c.comment_id,
+		c.comment,
+		c.created_at,
+		c.is_highlighted,
+		u.name as author_name,
+		u.avatar as author_avatar,
+		(SELECT COUNT(*) FROM comment_likes WHERE comment_id = c.id) AS total_like,
+		(SELECT EXISTS (SELECT 1 FROM comment_likes WHERE comment_id = c.id AND user_id = ?)) AS is_like
+	FROM comments c
+	LEFT JOIN users u ON c.author_id = u.id
+	WHERE c.post_id = ? AND c.author_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?) %s
+	ORDER BY c.created_at %s, c.id %s
+	LIMIT ?;`, condition, order, order)
+
+	rows, err := c.db.Query(sqlStmt, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		err = rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.AuthorID,
+			&comment.ParentCommentID,
+			&comment.Comment,
+			&comment.CreatedAt,
+			&comment.IsHighlighted,
+			&comment.AuthorName,
+			&comment.AuthorAvatar,
+			&comment.TotalLike,
+			&comment.IsLike,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		comment.IsAuthor = comment.AuthorID == viewerID
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
 func (c *CommentRepository) SelectAllCommentsByPostID(userID, postID int) ([]Comment, error) {
 	sqlStmt := `
 	SELECT
-		c.*,
+		c.id,
+		c.post_id,
+		c.author_id,
+		c.comment_id,
+		c.comment,
+		c.created_at,
+		c.is_highlighted,
 		u.name as author_name,
 		u.avatar as author_avatar,
 		(SELECT COUNT(*) FROM comment_likes WHERE comment_id = c.id) AS total_like,
 		(SELECT EXISTS (SELECT 1 FROM comment_likes WHERE comment_id = c.id AND user_id = ?)) AS is_like
 	FROM comments c
 	LEFT JOIN users u ON c.author_id = u.id
-	WHERE c.post_id = ? AND c.comment_id ISNULL
-	ORDER BY c.created_at;`
+	WHERE c.post_id = ? AND c.comment_id ISNULL AND c.author_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)
+	ORDER BY c.is_highlighted DESC, c.created_at;`
 
-	rows, err := c.db.Query(sqlStmt, userID, postID)
+	rows, err := c.db.Query(sqlStmt, userID, postID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +287,7 @@ func (c *CommentRepository) SelectAllCommentsByPostID(userID, postID int) ([]Com
 			&comment.ParentCommentID,
 			&comment.Comment,
 			&comment.CreatedAt,
+			&comment.IsHighlighted,
 			&comment.AuthorName,
 			&comment.AuthorAvatar,
 			&comment.TotalLike,
@@ -165,6 +335,53 @@ func (c *CommentRepository) FetchCommentAuthorId(commentID int) (int, error) {
 	}
 }
 
+func (c *CommentRepository) FetchCommentPostId(commentID int) (int, error) {
+	sqlStmt := `
+	SELECT post_id FROM comments WHERE id = ?;`
+
+	var postID int
+	err := c.db.QueryRow(sqlStmt, commentID).Scan(&postID)
+	return postID, err
+}
+
+// FetchCommentPath returns the post of the comment and the ids of the thread leading to it, the top level comment
+// first and the comment itself last
+func (c *CommentRepository) FetchCommentPath(commentID int) (int, []int, error) {
+	rows, err := c.db.Query(`
+		WITH RECURSIVE ancestors(id, post_id, parent_id, level) AS (
+			SELECT id, post_id, comment_id, 0 FROM comments WHERE id = ?
+			UNION ALL
+			SELECT c.id, c.post_id, c.comment_id, a.level + 1
+			FROM comments c
+			INNER JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id, post_id FROM ancestors ORDER BY level DESC;`, commentID)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		postID int
+		path   []int
+	)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id, &postID); err != nil {
+			return 0, nil, err
+		}
+		path = append(path, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	if len(path) == 0 {
+		return 0, nil, ErrCommentNotFound
+	}
+	return postID, path, nil
+}
+
 func (c *CommentRepository) InsertComment(comment Comment) (int64, error) {
 	sqlStmt := `INSERT INTO comments (post_id, author_id, comment, comment_id, created_at) VALUES (?, ?, ?, ?, ?);`
 	res, err := c.db.Exec(sqlStmt, comment.PostID, comment.AuthorID, comment.Comment, comment.ParentCommentID, time.Now())
@@ -181,6 +398,45 @@ func (c *CommentRepository) UpdateComment(comment Comment) error {
 	return err
 }
 
+// SetCommentHighlight marks the comment as the best answer of its post or clears it. The previous highlight is cleared
+// in the same transaction, so a post never has two
+func (c *CommentRepository) SetCommentHighlight(commentID int, highlighted bool) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var (
+		postID          int
+		parentCommentID *int
+	)
+	err = tx.QueryRow(`SELECT post_id, comment_id FROM comments WHERE id = ?;`, commentID).Scan(&postID, &parentCommentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if highlighted {
+		if parentCommentID != nil {
+			return ErrCommentIsReply
+		}
+
+		if _, err := tx.Exec(`UPDATE comments SET is_highlighted = 0 WHERE post_id = ? AND is_highlighted = 1;`, postID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE comments SET is_highlighted = ? WHERE id = ?;`, highlighted, commentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (c *CommentRepository) DeleteComment(commentID int) error {
 	sqlStmt := `DELETE FROM comments WHERE id = ? OR comment_id = ?`
 	_, err := c.db.Exec(sqlStmt, commentID, commentID)
@@ -199,3 +455,84 @@ func (c CommentRepository) CountComment(postID int) (int, error) {
 
 	return totalLike, nil
 }
+
+// FetchAllCommentsByAuthor returns the author's comments without author info, replies or likes
+func (c *CommentRepository) FetchAllCommentsByAuthor(authorID int) ([]Comment, error) {
+	sqlStmt := `
+	SELECT id, post_id, author_id, comment_id, comment, created_at
+	FROM comments
+	WHERE author_id = ?
+	ORDER BY created_at;`
+
+	rows, err := c.db.Query(sqlStmt, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		err = rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.AuthorID,
+			&comment.ParentCommentID,
+			&comment.Comment,
+			&comment.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		comment.IsAuthor = true
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
+
+// FetchCommentsByAuthor returns the author's comments newest first along with the post they belong to.
+// Comments of deleted accounts are anonymized so they're left out, and so are the comments on posts that aren't
+// approved unless the viewer wrote the post or withPending is set for the admins and moderators
+func (c *CommentRepository) FetchCommentsByAuthor(authorID, viewerID, limit, offset int, withPending bool) ([]UserComment, error) {
+	visibility := pendingVisibility(viewerID)
+	if withPending {
+		visibility = ""
+	}
+
+	sqlStmt := `
+	SELECT c.id, c.post_id, p.title, c.comment_id, c.comment, c.created_at
+	FROM comments c
+	INNER JOIN posts p ON p.id = c.post_id
+	INNER JOIN users u ON u.id = c.author_id AND u.deleted_at IS NULL
+	WHERE c.author_id = ? ` + visibility + `
+	ORDER BY c.created_at DESC, c.id DESC
+	LIMIT ? OFFSET ?;`
+
+	rows, err := c.db.Query(sqlStmt, authorID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []UserComment{}
+	for rows.Next() {
+		var comment UserComment
+		err = rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.PostTitle,
+			&comment.ParentCommentID,
+			&comment.Comment,
+			&comment.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
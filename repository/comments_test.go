@@ -0,0 +1,97 @@
+package repository_test
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Comment Cursor Repository Test", func() {
+	var (
+		db          *sql.DB
+		commentRepo *repository.CommentRepository
+	)
+
+	ids := func(comments []repository.Comment) []int {
+		result := []int{}
+		for _, comment := range comments {
+			result = append(result, comment.ID)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		commentRepo = repository.NewCommentRepository(db)
+
+		// Post 2 gets comments 8 to 12, 9 and 10 at the same time so the id breaks the tie
+		createdAt := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+		for i, offset := range []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+			_, err := db.Exec(`INSERT INTO comments (id, post_id, author_id, comment, created_at) VALUES (?, 2, 1, 'comment', ?);`,
+				8+i, createdAt.Add(offset))
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	It("should page back without skipping or repeating when comments are added", func() {
+		page, err := commentRepo.FetchCommentsBeforeCursor(2, 0, 2, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(page)).To(Equal([]int{12, 11}))
+		Expect(page[0].IsAuthor).To(BeTrue())
+
+		_, err = commentRepo.InsertComment(repository.Comment{PostID: 2, AuthorID: 2, Comment: "new"})
+		Expect(err).ToNot(HaveOccurred())
+
+		page, err = commentRepo.FetchCommentsBeforeCursor(2, 11, 2, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(page)).To(Equal([]int{10, 9}))
+
+		page, err = commentRepo.FetchCommentsBeforeCursor(2, 9, 2, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(page)).To(Equal([]int{8}))
+
+		page, err = commentRepo.FetchCommentsAfterCursor(2, 12, 2, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(page)).To(Equal([]int{13}))
+	})
+
+	It("should fall back to the id when the cursor comment was deleted", func() {
+		Expect(commentRepo.DeleteComment(10)).To(Succeed())
+
+		page, err := commentRepo.FetchCommentsBeforeCursor(2, 10, 5, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(page)).To(Equal([]int{9, 8}))
+	})
+
+	It("should return the thread leading to a comment, top level first", func() {
+		// Seeded on post 1: 4 <- 6 <- 7
+		postID, path, err := commentRepo.FetchCommentPath(7)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(postID).To(Equal(1))
+		Expect(path).To(Equal([]int{4, 6, 7}))
+
+		_, path, err = commentRepo.FetchCommentPath(8)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(path).To(Equal([]int{8}))
+
+		_, _, err = commentRepo.FetchCommentPath(99)
+		Expect(err).To(MatchError(repository.ErrCommentNotFound))
+	})
+})
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// busyRetries is how many times a write is retried when SQLite reports the database as locked, waiting busyBackoff
+// before the first retry and twice as long before every next one
+const (
+	busyRetries = 4
+	busyBackoff = 20 * time.Millisecond
+)
+
+// OpenDB opens the sqlite database at path with busy_timeout set on every connection, so a write waits up to
+// busyTimeout for another one to finish instead of failing right away with "database is locked"
+func OpenDB(path string, busyTimeout time.Duration) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	return sql.Open("sqlite3", fmt.Sprintf("%s%s_busy_timeout=%d", path, separator, busyTimeout.Milliseconds()))
+}
+
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// retryOnBusy calls write again with exponential backoff as long as it fails because the database is locked, up to
+// busyRetries times
+func retryOnBusy(write func() error) error {
+	backoff := busyBackoff
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if err == nil || !isBusy(err) || attempt == busyRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// withTx runs fn in a transaction and commits it, the whole transaction is retried when the database is locked
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return retryOnBusy(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
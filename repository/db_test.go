@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locked Database Test", func() {
+	var (
+		db     *sql.DB
+		locker *sql.DB
+	)
+
+	BeforeEach(func() {
+		path := filepath.Join(GinkgoT().TempDir(), "locked.db")
+
+		var err error
+		// Without a busy timeout a locked database fails right away, so only the retries can get the write through
+		db, err = repository.OpenDB(path, 0)
+		Expect(err).ToNot(HaveOccurred())
+		migration.Migrate(db)
+
+		locker, err = repository.OpenDB(path, 0)
+		Expect(err).ToNot(HaveOccurred())
+		locker.SetMaxOpenConns(1)
+	})
+
+	AfterEach(func() {
+		locker.Close()
+		db.Close()
+	})
+
+	It("should retry inserting a post image until the database is released", func() {
+		postRepo := repository.NewPostRepository(db)
+
+		_, err := locker.Exec("BEGIN EXCLUSIVE")
+		Expect(err).ToNot(HaveOccurred())
+
+		released := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(50 * time.Millisecond)
+			_, err := locker.Exec("COMMIT")
+			Expect(err).ToNot(HaveOccurred())
+			close(released)
+		}()
+
+		Expect(postRepo.InsertPostImage(1, "media/post/locked.png", "photo.png")).To(Succeed())
+		Eventually(released).Should(BeClosed())
+
+		exists, err := postRepo.PostImageExists("media/post/locked.png")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+	})
+
+	It("should give up when the database stays locked", func() {
+		postRepo := repository.NewPostRepository(db)
+
+		_, err := locker.Exec("BEGIN EXCLUSIVE")
+		Expect(err).ToNot(HaveOccurred())
+		defer locker.Exec("ROLLBACK")
+
+		Expect(postRepo.InsertPostImage(1, "media/post/locked.png", "photo.png")).To(MatchError(ContainSubstring("database is locked")))
+	})
+})
@@ -15,9 +15,19 @@ type Comment struct {
 	TotalReply      int        `json:"total_reply"`
 	IsLike          bool       `json:"is_like"`
 	IsAuthor        bool       `json:"is_author"`
+	IsHighlighted   bool       `json:"is_highlighted"`
 	Reply           []Comment  `json:"reply"`
 }
 
+type UserComment struct {
+	ID              int        `json:"id"`
+	PostID          int        `json:"post_id"`
+	PostTitle       string     `json:"post_title"`
+	ParentCommentID *int       `json:"parent_comment_id"`
+	Comment         string     `json:"comment"`
+	CreatedAt       *time.Time `json:"created_at"`
+}
+
 type PostLike struct {
 	ID     int
 	PostID int
@@ -43,21 +53,44 @@ type Questionnaire struct {
 	TotalComment int        `json:"total_comment"`
 	IsLike       bool       `json:"is_like"`
 	IsAuthor     bool       `json:"is_author"`
+
+	Images []QuestionnaireImage `json:"images"`
+}
+
+// UploadedImage is an image file stored for a post, OriginalName is the file name it was uploaded with
+type UploadedImage struct {
+	Path         string
+	OriginalName string
+}
+
+type QuestionnaireImage struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
 }
 
 type Notification struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	CommentID   int       `json:"comment_id"`
-	PostID      int       `json:"post_id"`
-	PostTitle   string    `json:"post_title"`
-	AlreadyRead bool      `json:"already_read"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID        int        `json:"id"`
+	Type      string     `json:"type"`
+	ActorID   int        `json:"actor_id"`
+	ActorName string     `json:"actor_name"`
+	TargetID  int        `json:"target_id"`
+	PostID    *int       `json:"post_id"`
+	PostTitle *string    `json:"post_title"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 type Category struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	// AllowAnonymous lets the posts of the category hide their author
+	AllowAnonymous bool `json:"allow_anonymous"`
+}
+
+type CategoryPostCount struct {
+	CategoryID int    `json:"category_id"`
+	Name       string `json:"name"`
+	PostCount  int    `json:"post_count"`
 }
 
 type User struct {
@@ -69,4 +102,112 @@ type User struct {
 	Major     *string `json:"major"`
 	Batch     *int    `json:"batch"`
 	Avatar    *string `json:"avatar"`
+	// LastActiveAt is nil until the user makes an authenticated request
+	LastActiveAt *time.Time `json:"last_active_at"`
+}
+
+// UserCard is a user in a list of users, IsFollowing is whether the viewer follows them
+type UserCard struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	Avatar      *string   `json:"avatar"`
+	Institute   *string   `json:"institute"`
+	IsFollowing bool      `json:"is_following"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+// UserSummary is what an author card needs of a user
+type UserSummary struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Role   string  `json:"role"`
+	Avatar *string `json:"avatar"`
+}
+
+// ActiveUser is a user in the list of recently active users
+type ActiveUser struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Role         string    `json:"role"`
+	Avatar       *string   `json:"avatar"`
+	Institute    *string   `json:"institute"`
+	IsFollowing  bool      `json:"is_following"`
+	LastActiveAt time.Time `json:"last_active_at"`
+}
+
+type UserStats struct {
+	PostCount          int `json:"post_count"`
+	QuestionnaireCount int `json:"questionnaire_count"`
+	CommentCount       int `json:"comment_count"`
+	TotalLikesReceived int `json:"total_likes_received"`
+	FollowerCount      int `json:"follower_count"`
+	FollowingCount     int `json:"following_count"`
+}
+
+type IdempotencyKey struct {
+	ResourceID *int
+	Response   []byte
+	CreatedAt  time.Time
+}
+
+type PrivacySettings struct {
+	LikesPublic     bool `json:"likes_public"`
+	BookmarksPublic bool `json:"bookmarks_public"`
+}
+
+type UserBan struct {
+	BannedUntil time.Time `json:"banned_until"`
+	Reason      string    `json:"reason"`
+}
+
+type Mention struct {
+	ID        int    `json:"-"`
+	UserID    int    `json:"user_id"`
+	UserName  string `json:"name"`
+	AuthorID  int    `json:"-"`
+	PostID    int    `json:"-"`
+	CommentID *int   `json:"-"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+}
+
+// UserMention is a post or a comment that mentioned the user, NotificationID and ReadAt come from the notification the
+// mention sent, which is how mentions are marked as read
+type UserMention struct {
+	PostID         int        `json:"post_id"`
+	PostTitle      string     `json:"post_title"`
+	CommentID      *int       `json:"comment_id"`
+	AuthorID       int        `json:"author_id"`
+	AuthorName     string     `json:"author_name"`
+	Content        string     `json:"-"`
+	Start          int        `json:"-"`
+	End            int        `json:"-"`
+	NotificationID *int       `json:"notification_id"`
+	ReadAt         *time.Time `json:"read_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type FeedPost struct {
+	ID         int       `json:"id"`
+	AuthorID   int       `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	CategoryID int       `json:"category_id"`
+	Title      string    `json:"title"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PostActivity struct {
+	CommentCount int
+	LikeCount    int
+	UpdatedAt    time.Time
+}
+
+type Post struct {
+	ID          int        `json:"id"`
+	CategoryID  int        `json:"category_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
 }
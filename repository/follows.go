@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type FollowRepository struct {
+	db *sql.DB
+}
+
+func NewFollowRepository(db *sql.DB) *FollowRepository {
+	return &FollowRepository{
+		db: db,
+	}
+}
+
+func (f *FollowRepository) InsertFollow(followerID, followingID int) error {
+	sqlStmt := `INSERT INTO follows (follower_id, following_id, created_at) VALUES (?, ?, ?);`
+	_, err := f.db.Exec(sqlStmt, followerID, followingID, time.Now())
+	return err
+}
+
+func (f *FollowRepository) DeleteFollow(followerID, followingID int) error {
+	sqlStmt := `DELETE FROM follows WHERE follower_id = ? AND following_id = ?;`
+	_, err := f.db.Exec(sqlStmt, followerID, followingID)
+	return err
+}
+
+func (f *FollowRepository) CheckFollowIsExist(followerID, followingID int) (bool, error) {
+	sqlStmt := `SELECT EXISTS (SELECT 1 FROM follows WHERE follower_id = ? AND following_id = ?);`
+
+	var isExist bool
+	err := f.db.QueryRow(sqlStmt, followerID, followingID).Scan(&isExist)
+	return isExist, err
+}
+
+func (f *FollowRepository) FetchFollowerIDs(userID int) ([]int, error) {
+	rows, err := f.db.Query(`SELECT follower_id FROM follows WHERE following_id = ?;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followerIDs := []int{}
+	for rows.Next() {
+		var followerID int
+		if err := rows.Scan(&followerID); err != nil {
+			return nil, err
+		}
+		followerIDs = append(followerIDs, followerID)
+	}
+
+	return followerIDs, nil
+}
+
+// FetchFollowers returns the users following userID, latest follow first. namePattern is a LIKE pattern on their
+// name with \ as escape character
+func (f *FollowRepository) FetchFollowers(userID, viewerID int, namePattern string, limit, offset int) ([]UserCard, error) {
+	return f.fetchFollowUsers("follower_id", "following_id", userID, viewerID, namePattern, limit, offset)
+}
+
+// FetchFollowing returns the users userID follows, like FetchFollowers
+func (f *FollowRepository) FetchFollowing(userID, viewerID int, namePattern string, limit, offset int) ([]UserCard, error) {
+	return f.fetchFollowUsers("following_id", "follower_id", userID, viewerID, namePattern, limit, offset)
+}
+
+// fetchFollowUsers lists the users in userColumn of the follows where ownerColumn is userID
+func (f *FollowRepository) fetchFollowUsers(userColumn, ownerColumn string, userID, viewerID int, namePattern string, limit, offset int) ([]UserCard, error) {
+	sqlStmt := fmt.Sprintf(`
+		SELECT
+			u.id,
+			u.name,
+			u.role,
+			u.avatar,
+			ud.institute,
+			(SELECT EXISTS (SELECT 1 FROM follows WHERE follower_id = ? AND following_id = u.id)) AS is_following,
+			f.created_at
+		FROM follows f
+		INNER JOIN users u ON u.id = f.%s AND u.deleted_at IS NULL
+		LEFT JOIN user_details ud ON ud.user_id = u.id
+		WHERE f.%s = ? AND u.name LIKE ? ESCAPE '\'
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT ? OFFSET ?;`, userColumn, ownerColumn)
+
+	rows, err := f.db.Query(sqlStmt, viewerID, userID, namePattern, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserCard{}
+	for rows.Next() {
+		var user UserCard
+		if err := rows.Scan(&user.ID, &user.Name, &user.Role, &user.Avatar, &user.Institute, &user.IsFollowing, &user.FollowedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,127 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Follow Repository Test", func() {
+	var (
+		db         *sql.DB
+		followRepo *repository.FollowRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+
+		// Seeds Radit (1) and Bocil SMA (2)
+		migration.Migrate(db)
+		seedAdmin(db)
+
+		followRepo = repository.NewFollowRepository(db)
+
+		Expect(followRepo.InsertFollow(2, 1)).To(Succeed())
+		Expect(followRepo.InsertFollow(3, 1)).To(Succeed())
+		Expect(followRepo.InsertFollow(1, 2)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	ids := func(users []repository.UserCard) []int {
+		out := make([]int, 0)
+		for _, user := range users {
+			out = append(out, user.ID)
+		}
+		return out
+	}
+
+	Describe("FetchFollowers", func() {
+		It("should return a page of followers, latest first, with is_following of the viewer", func() {
+			users, err := followRepo.FetchFollowers(1, 1, "%", 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ids(users)).To(Equal([]int{3, 2}))
+			Expect(users[0].IsFollowing).To(BeFalse())
+			Expect(users[1].IsFollowing).To(BeTrue())
+
+			users, err = followRepo.FetchFollowers(1, 1, "%", 1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ids(users)).To(Equal([]int{2}))
+		})
+
+		It("should filter by name", func() {
+			users, err := followRepo.FetchFollowers(1, 1, "%bocil%", 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ids(users)).To(Equal([]int{2}))
+		})
+	})
+
+	Describe("FetchFollowing", func() {
+		It("should return the users the user follows", func() {
+			users, err := followRepo.FetchFollowing(1, 2, "%", 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ids(users)).To(Equal([]int{2}))
+			Expect(users[0].Name).To(Equal("Bocil SMA"))
+		})
+	})
+
+	Describe("BlockUser", func() {
+		It("should remove the follows between both users and hide the posts and comments of the blocked user", func() {
+			created, err := followRepo.BlockUser(2, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(BeTrue())
+
+			created, err = followRepo.BlockUser(2, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(BeFalse())
+
+			Expect(followRepo.CheckFollowIsExist(2, 1)).To(BeFalse())
+			Expect(followRepo.CheckFollowIsExist(1, 2)).To(BeFalse())
+			Expect(followRepo.CheckFollowIsExist(3, 1)).To(BeTrue())
+			Expect(followRepo.IsBlocked(2, 1)).To(BeTrue())
+			Expect(followRepo.IsBlocked(1, 2)).To(BeFalse())
+
+			postRepo := repository.NewPostRepository(db)
+			commentRepo := repository.NewCommentRepository(db)
+
+			// Post 1 and its comments are Radit's
+			posts, err := postRepo.FetchAllPost(10, 0, 2, "p.id", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(BeEmpty())
+			comments, _, err := commentRepo.FetchCommentsOfPost(2, 1, 100)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comments).To(BeEmpty())
+
+			posts, err = postRepo.FetchAllPost(10, 0, 3, "p.id", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			comments, _, err = commentRepo.FetchCommentsOfPost(3, 1, 100)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comments).To(HaveLen(7))
+
+			blocked, err := followRepo.FetchBlockedUsers(2, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blocked).To(HaveLen(1))
+			Expect(blocked[0].Name).To(Equal("Radit"))
+
+			Expect(followRepo.UnblockUser(2, 1)).To(BeTrue())
+			Expect(followRepo.UnblockUser(2, 1)).To(BeFalse())
+
+			posts, err = postRepo.FetchAllPost(10, 0, 2, "p.id", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+		})
+	})
+})
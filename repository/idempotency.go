@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// IdempotencyKeyTTL is how long a retried request replays the original response instead of inserting again
+const IdempotencyKeyTTL = 24 * time.Hour
+
+var (
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+// ReserveIdempotencyKey claims the key before the request is processed, it returns false when the key is already taken
+// by a previous or in-flight request. Expired keys are cleaned up on the way
+func (i *IdempotencyRepository) ReserveIdempotencyKey(userID int, scope, key string) (bool, error) {
+	tx, err := i.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?;`, time.Now().Add(-IdempotencyKeyTTL))
+	if err != nil {
+		return false, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO idempotency_keys (user_id, scope, idempotency_key, created_at) VALUES (?, ?, ?, ?);
+	`, userID, scope, key, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// FetchIdempotencyKey returns a nil Response while the first request is still being processed
+func (i *IdempotencyRepository) FetchIdempotencyKey(userID int, scope, key string) (IdempotencyKey, error) {
+	sqlStatement := `
+		SELECT resource_id, response, created_at FROM idempotency_keys
+		WHERE user_id = ? AND scope = ? AND idempotency_key = ?;
+	`
+
+	var (
+		record   IdempotencyKey
+		response sql.NullString
+	)
+	err := i.db.QueryRow(sqlStatement, userID, scope, key).Scan(&record.ResourceID, &response, &record.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return IdempotencyKey{}, ErrIdempotencyKeyNotFound
+		}
+		return IdempotencyKey{}, err
+	}
+
+	if response.Valid {
+		record.Response = []byte(response.String)
+	}
+
+	return record, nil
+}
+
+func (i *IdempotencyRepository) CompleteIdempotencyKey(userID int, scope, key string, resourceID int, response []byte) error {
+	sqlStatement := `
+		UPDATE idempotency_keys SET resource_id = ?, response = ?
+		WHERE user_id = ? AND scope = ? AND idempotency_key = ?;
+	`
+
+	_, err := i.db.Exec(sqlStatement, resourceID, string(response), userID, scope, key)
+	return err
+}
+
+// ReleaseIdempotencyKey frees the key of a failed request so the client can retry it
+func (i *IdempotencyRepository) ReleaseIdempotencyKey(userID int, scope, key string) error {
+	sqlStatement := `DELETE FROM idempotency_keys WHERE user_id = ? AND scope = ? AND idempotency_key = ? AND response IS NULL;`
+
+	_, err := i.db.Exec(sqlStatement, userID, scope, key)
+	return err
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The API depends on these interfaces instead of the concrete repositories so handlers can be tested with mocks
+
+type PostRepo interface {
+	InsertPost(authorID, categoryID int, title, description string, anonymous bool) (int64, error)
+	InsertPostWithImages(authorID, categoryID int, title, description string, images []UploadedImage) (int64, error)
+	InsertImportedPosts(posts []ImportedPost) ([]int64, error)
+	InsertPostImage(postID int, path, originalName string) error
+	FindRecentDuplicatePost(authorID int, title, desc string, within time.Duration) (int64, error)
+	ReorderPostImages(postID int, imageIDs []int) error
+	PostImageExists(path string) (bool, error)
+	FetchAllPost(limit, offset, authorID int, orderBy, filter string, args ...interface{}) ([]PostDetail, error)
+	FetchAllPostCompact(limit, offset, authorID int, orderBy, filter string, args ...interface{}) ([]PostDetail, error)
+	FetchRelatedPosts(postID, limit, viewerID int) ([]PostDetail, error)
+	FetchRandomPostID(viewerID, categoryID int) (int, error)
+	FetchPostByID(postID, authorID int) ([]PostDetail, error)
+	PostVisibleTo(postID, viewerID int, withPending bool) (bool, error)
+	FetchAuthorIDByPostID(postID int) (int, error)
+	FetchPostCategoryID(postID int) (int, error)
+	FetchPostActivity(postID int) (PostActivity, error)
+	UpdatePost(postID, editorID, categoryID int, title, description string) error
+	FetchPostRevisions(postID, limit, offset int) ([]PostRevision, error)
+	DeletePostByID(postID int) error
+	FetchPostAuthorIDs(postIDs []int) (map[int]int, error)
+	DeletePostsByID(postIDs []int) ([]string, error)
+	MergePost(sourceID, targetID int) (MergedPost, error)
+	UndoPost(postID, authorID int, within time.Duration) ([]string, error)
+	PinPost(postID int) error
+	UnpinPost(postID int) error
+	ToggleCommentsLock(postID int) (bool, error)
+	CommentsLocked(postID int) (bool, error)
+	FetchFollowingPostsAfter(userID, afterPostID, limit int) ([]FeedPost, error)
+	FetchPostsByAuthor(authorID int) ([]Post, error)
+	FetchPendingPosts(limit, offset int, categoryIDs []int) ([]PostDetail, error)
+	SetModerationStatus(postID int, status string) (ModeratedPost, error)
+	InsertSavedSearch(userID int, name, query string) (SavedSearch, error)
+	FetchSavedSearches(userID int) ([]SavedSearch, error)
+	FetchSavedSearch(searchID, userID int) (SavedSearch, error)
+	DeleteSavedSearch(searchID, userID int) error
+}
+
+type CommentRepo interface {
+	SelectAllCommentsByPostID(userID, postID int) ([]Comment, error)
+	FetchCommentsOfPost(userID, postID, limit int) ([]Comment, int, error)
+	FetchCommentsBeforeCursor(postID, cursor, limit, viewerID int) ([]Comment, error)
+	FetchCommentsAfterCursor(postID, cursor, limit, viewerID int) ([]Comment, error)
+	FetchCommentAuthorId(commentID int) (int, error)
+	FetchCommentPostId(commentID int) (int, error)
+	FetchCommentPath(commentID int) (int, []int, error)
+	InsertComment(comment Comment) (int64, error)
+	UpdateComment(comment Comment) error
+	DeleteComment(commentID int) error
+	SetCommentHighlight(commentID int, highlighted bool) error
+	CountComment(postID int) (int, error)
+	FetchAllCommentsByAuthor(authorID int) ([]Comment, error)
+	FetchCommentsByAuthor(authorID, viewerID, limit, offset int, withPending bool) ([]UserComment, error)
+}
+
+type LikeRepo interface {
+	InsertPostLike(postLike PostLike) error
+	LikePost(postID, userID int) (bool, error)
+	DeletePostLike(postLike PostLike) error
+	CountPostLike(postID int) (int, error)
+	CheckPostLikeIsExist(postLike PostLike) (bool, error)
+	InsertCommentLike(commentLike CommentLike) error
+	DeleteCommentLike(commentLike CommentLike) error
+	CheckCommentLikeIsExist(commentLike CommentLike) (bool, error)
+	FetchLikedPostIDs(userID int) ([]int, error)
+	FetchLikedCommentIDs(userID int) ([]int, error)
+	RecountLikes(afterPostID, limit int) (LikeRecountBatch, error)
+	SetPostReaction(postID, userID int, reaction string) (bool, error)
+	ClearPostReaction(postID, userID int) (bool, error)
+	FetchPostReactions(postID, viewerID int) (PostReactions, error)
+}
+
+type QuestionnaireRepo interface {
+	ReadAllQuestionnaires(userID int, filter, sortBy string, limit, offset int, args ...interface{}) ([]Questionnaire, error)
+	FetchQuestionnaireFeed(userID, limit, offset int) ([]Questionnaire, error)
+	InsertQuestionnaireImage(questionnaireID int, path, originalName string) error
+	QuestionnaireImageExists(path string) (bool, error)
+	ReadAllQuestionnaireByID(userID, postID int) (Questionnaire, error)
+	QuestionnaireTitleExists(authorID int, title string) (bool, error)
+	InsertQuestionnaire(questionnaire Questionnaire) (int64, error)
+	UpdateQuestionnaire(questionnaire Questionnaire) error
+	DeleteQuestionnaire(postID int) ([]string, error)
+	SaveQuestionnaireDraft(authorID int, draft QuestionnaireDraft) (QuestionnaireDraft, error)
+	FetchQuestionnaireDraft(authorID int) (QuestionnaireDraft, error)
+	DeleteQuestionnaireDraft(authorID int) error
+}
+
+type UserRepo interface {
+	Login(email string, password string) (*int, error)
+	GetUserData(id int) (*User, error)
+	UserExists(id int) (bool, error)
+	FetchUsersByIDs(ids []int) (map[int]UserSummary, error)
+	UpdateUserData(id int, name, email string) error
+	GetUserRole(id int) (*string, error)
+	InsertNewUser(name string, email string, password string, role string, institute string, major *string, batch *int) (userId int, responseCode int, err error)
+	UpdateAvatar(userId int, filepath string) error
+	AvatarExists(filepath string) (bool, error)
+	GetUserStats(userID int) (UserStats, error)
+	GetPlatformStats() (PlatformStats, error)
+	UpdateUserRole(adminID, userID int, role string) (string, error)
+	FetchLeaderboard(period string) ([]LeaderboardEntry, error)
+	BanUser(userID int, until time.Time, reason string) error
+	UnbanUser(userID int) error
+	GetActiveBan(userID int) (*UserBan, error)
+	TouchLastActive(userID int) error
+	FetchActiveUsers(since time.Time, viewerID, limit, offset int) ([]ActiveUser, error)
+	GetPrivacySettings(userID int) (PrivacySettings, error)
+	UpdatePrivacySettings(userID int, settings PrivacySettings) error
+	DeleteAccount(userID int, deleteContent bool) ([]string, error)
+}
+
+type CategoryRepo interface {
+	GetAllCategories() ([]Category, error)
+	CategoryExists(id int) (bool, error)
+	CategoryByID(id int) (Category, error)
+	SetCategoryAllowAnonymous(id int, allow bool) error
+	CountCategoryPosts(id int) (int, error)
+	CountPostsByCategory(includeEmpty bool) ([]CategoryPostCount, error)
+	AddCategoryModerator(categoryID, userID int) (bool, error)
+	RemoveCategoryModerator(categoryID, userID int) (bool, error)
+	IsCategoryModerator(userID, categoryID int) (bool, error)
+	FetchModeratedCategoryIDs(userID int) ([]int, error)
+	FetchCategoryModerators(categoryID int) ([]CategoryModerator, error)
+}
+
+type FollowRepo interface {
+	InsertFollow(followerID, followingID int) error
+	DeleteFollow(followerID, followingID int) error
+	CheckFollowIsExist(followerID, followingID int) (bool, error)
+	FetchFollowerIDs(userID int) ([]int, error)
+	FetchFollowers(userID, viewerID int, namePattern string, limit, offset int) ([]UserCard, error)
+	FetchFollowing(userID, viewerID int, namePattern string, limit, offset int) ([]UserCard, error)
+	BlockUser(blockerID, blockedID int) (bool, error)
+	UnblockUser(blockerID, blockedID int) (bool, error)
+	IsBlocked(blockerID, blockedID int) (bool, error)
+	FetchBlockedUsers(blockerID, limit, offset int) ([]BlockedUser, error)
+}
+
+type MentionRepo interface {
+	FetchUserByName(name string) (int, string, error)
+	InsertMentions(mentions []Mention) error
+	FetchPostMentions(postID int) ([]Mention, error)
+	FetchCommentMentions(commentID int) ([]Mention, error)
+	DeletePostMentions(postID int) error
+	DeleteCommentMentions(commentID int) error
+	FetchUserMentions(userID, limit, offset int) ([]UserMention, error)
+}
+
+type NotificationRepo interface {
+	CreateNotification(userId, actorId int, notifType string, targetId int) error
+	CreateNotifications(userIds []int, actorId int, notifType string, targetId int) error
+	GetAllNotifications(userId, page, limit int) ([]Notification, error)
+	CountUnreadNotifications(userId int) (int, error)
+	SetReadNotification(userId int, notifId int) error
+	SetReadAllNotification(userId int) error
+	SetReadNotificationsOfTypes(userId int, types ...string) error
+	FetchNotificationPreferences(userId int) (map[string]bool, error)
+	UpdateNotificationPreferences(userId int, preferences map[string]bool) error
+}
+
+type IdempotencyRepo interface {
+	ReserveIdempotencyKey(userID int, scope, key string) (bool, error)
+	FetchIdempotencyKey(userID int, scope, key string) (IdempotencyKey, error)
+	CompleteIdempotencyKey(userID int, scope, key string, resourceID int, response []byte) error
+	ReleaseIdempotencyKey(userID int, scope, key string) error
+}
+
+type AuditRepo interface {
+	InsertAuditEntry(actorID int, action, targetType string, targetID int, metadata json.RawMessage) error
+	FetchAuditEntries(filter AuditFilter, limit, offset int) ([]AuditEntry, error)
+}
+
+type WebhookRepo interface {
+	InsertWebhook(webhook Webhook) (int64, error)
+	DeleteWebhook(id int) error
+	FetchWebhooks() ([]Webhook, error)
+	FetchWebhooksForCategory(categoryID int) ([]Webhook, error)
+	InsertWebhookDelivery(delivery WebhookDelivery) error
+	FetchWebhookDeliveries(webhookID, limit, offset int) ([]WebhookDelivery, error)
+}
+
+var (
+	_ PostRepo          = (*PostRepository)(nil)
+	_ CommentRepo       = (*CommentRepository)(nil)
+	_ LikeRepo          = (*LikeRepository)(nil)
+	_ QuestionnaireRepo = (*QuestionnaireRepository)(nil)
+	_ UserRepo          = (*UserRepository)(nil)
+	_ CategoryRepo      = (*CategoryRepository)(nil)
+	_ WebhookRepo       = (*WebhookRepository)(nil)
+	_ FollowRepo        = (*FollowRepository)(nil)
+	_ MentionRepo       = (*MentionRepository)(nil)
+	_ NotificationRepo  = (*NotificationRepository)(nil)
+	_ IdempotencyRepo   = (*IdempotencyRepository)(nil)
+	_ AuditRepo         = (*AuditRepository)(nil)
+)
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	LeaderboardWeek  = "week"
+	LeaderboardMonth = "month"
+	LeaderboardAll   = "all"
+)
+
+// The weights of the leaderboard score, writing a post is worth more than a comment and both more than a like
+const (
+	leaderboardPostWeight    = 5
+	leaderboardCommentWeight = 2
+	leaderboardLikeWeight    = 1
+)
+
+// LeaderboardSize is how many users are ranked and cached per period, callers take the top of it
+const LeaderboardSize = 100
+
+// leaderboardTTL keeps the board from aggregating every post, comment and like on each request
+const leaderboardTTL = 5 * time.Minute
+
+var ErrUnknownLeaderboardPeriod = errors.New("unknown leaderboard period")
+
+type LeaderboardEntry struct {
+	Rank          int     `json:"rank"`
+	UserID        int     `json:"user_id"`
+	Name          string  `json:"name"`
+	Role          string  `json:"role"`
+	Avatar        *string `json:"avatar"`
+	PostCount     int     `json:"post_count"`
+	CommentCount  int     `json:"comment_count"`
+	LikesReceived int     `json:"likes_received"`
+	Score         int     `json:"score"`
+}
+
+type cachedLeaderboard struct {
+	entries   []LeaderboardEntry
+	expiresAt time.Time
+}
+
+type leaderboardCache struct {
+	mu      sync.Mutex
+	periods map[string]cachedLeaderboard
+}
+
+// leaderboardSince is the start of the period, nil for all time
+func leaderboardSince(period string, now time.Time) (*time.Time, error) {
+	var since time.Time
+	switch period {
+	case LeaderboardWeek:
+		since = now.AddDate(0, 0, -7)
+	case LeaderboardMonth:
+		since = now.AddDate(0, -1, 0)
+	case LeaderboardAll:
+		return nil, nil
+	default:
+		return nil, ErrUnknownLeaderboardPeriod
+	}
+	return &since, nil
+}
+
+// FetchLeaderboard ranks the top LeaderboardSize users by the posts, comments and post likes they got in the period,
+// each board is cached for leaderboardTTL. Questionnaires, anonymous posts and posts waiting for moderation don't
+// count, and neither do banned or deleted users. Likes given before they had a date only count for all time
+func (u *UserRepository) FetchLeaderboard(period string) ([]LeaderboardEntry, error) {
+	now := time.Now()
+	since, err := leaderboardSince(period, now)
+	if err != nil {
+		return nil, err
+	}
+
+	u.leaderboard.mu.Lock()
+	defer u.leaderboard.mu.Unlock()
+
+	if cached, ok := u.leaderboard.periods[period]; ok && now.Before(cached.expiresAt) {
+		return cached.entries, nil
+	}
+
+	sqlStatement := `
+		WITH activity AS (
+			SELECT p.author_id AS user_id, 1 AS posts, 0 AS comments, 0 AS likes
+			FROM posts p
+			LEFT JOIN questionnaires q ON q.post_id = p.id
+			WHERE q.post_id IS NULL AND p.is_anonymous = 0 AND p.moderation_status = 'approved'
+				AND (?1 IS NULL OR p.created_at >= ?1)
+			UNION ALL
+			SELECT c.author_id, 0, 1, 0
+			FROM comments c
+			WHERE ?1 IS NULL OR c.created_at >= ?1
+			UNION ALL
+			SELECT p.author_id, 0, 0, 1
+			FROM post_reactions r
+			INNER JOIN posts p ON p.id = r.post_id
+			LEFT JOIN questionnaires q ON q.post_id = p.id
+			WHERE q.post_id IS NULL AND p.is_anonymous = 0 AND (?1 IS NULL OR r.reacted_at >= ?1)
+		)
+		SELECT
+			u.id, u.name, u.role, u.avatar,
+			SUM(a.posts), SUM(a.comments), SUM(a.likes),
+			SUM(a.posts) * ?2 + SUM(a.comments) * ?3 + SUM(a.likes) * ?4 AS score
+		FROM activity a
+		INNER JOIN users u ON u.id = a.user_id
+		WHERE u.deleted_at IS NULL AND (u.banned_until IS NULL OR u.banned_until <= ?5)
+		GROUP BY u.id
+		ORDER BY score DESC, u.id ASC
+		LIMIT ?6;
+	`
+
+	rows, err := u.db.Query(sqlStatement, since, leaderboardPostWeight, leaderboardCommentWeight, leaderboardLikeWeight,
+		now, LeaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		entry := LeaderboardEntry{Rank: len(entries) + 1}
+		err := rows.Scan(&entry.UserID, &entry.Name, &entry.Role, &entry.Avatar,
+			&entry.PostCount, &entry.CommentCount, &entry.LikesReceived, &entry.Score)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	u.leaderboard.periods[period] = cachedLeaderboard{entries: entries, expiresAt: now.Add(leaderboardTTL)}
+	return entries, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -18,19 +19,35 @@ func NewLikeRepository(db *sql.DB) *LikeRepository {
 }
 
 func (l *LikeRepository) InsertPostLike(postLike PostLike) error {
-	sqlStmt := `INSERT INTO post_likes (post_id, user_id) VALUES (?, ?);`
-	_, err := l.db.Exec(sqlStmt, postLike.PostID, postLike.UserID)
+	sqlStmt := `INSERT INTO post_reactions (post_id, user_id, reacted_at) VALUES (?, ?, ?);`
+	_, err := l.db.Exec(sqlStmt, postLike.PostID, postLike.UserID, time.Now())
 	return err
 }
 
+// LikePost likes the post in one statement, so two requests racing can't both insert. It reports false without
+// changing anything when the user already reacted to the post
+func (l *LikeRepository) LikePost(postID, userID int) (bool, error) {
+	res, err := l.db.Exec(`
+		INSERT INTO post_reactions (post_id, user_id, reacted_at)
+		SELECT ?1, ?2, ?3
+		WHERE NOT EXISTS (SELECT 1 FROM post_reactions WHERE post_id = ?1 AND user_id = ?2);
+	`, postID, userID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
 func (l *LikeRepository) DeletePostLike(postLike PostLike) error {
-	sqlStmt := `DELETE FROM post_likes WHERE post_id = ? AND user_id = ?;`
+	sqlStmt := `DELETE FROM post_reactions WHERE post_id = ? AND user_id = ?;`
 	_, err := l.db.Exec(sqlStmt, postLike.PostID, postLike.UserID)
 	return err
 }
 
 func (l *LikeRepository) CountPostLike(postID int) (int, error) {
-	sqlStmt := `SELECT COUNT(*) FROM post_likes WHERE post_id = ?;`
+	sqlStmt := `SELECT COUNT(*) FROM post_reactions WHERE post_id = ?;`
 	result := l.db.QueryRow(sqlStmt, postID)
 
 	var totalLike int
@@ -46,7 +63,7 @@ func (l *LikeRepository) CheckPostLikeIsExist(postLike PostLike) (bool, error) {
 	sqlStmt := `
 	SELECT  
 		COUNT(*)
-	FROM post_likes
+	FROM post_reactions
 	WHERE post_id = ? AND user_id = ?;`
 	result := l.db.QueryRow(sqlStmt, postLike.PostID, postLike.UserID)
 
@@ -109,3 +126,154 @@ func (l *LikeRepository) CheckCommentLikeIsExist(commentLike CommentLike) (bool,
 		return false, nil
 	}
 }
+
+func (l *LikeRepository) FetchLikedPostIDs(userID int) ([]int, error) {
+	return l.fetchLikedIDs(`SELECT post_id FROM post_reactions WHERE user_id = ? ORDER BY id;`, userID)
+}
+
+func (l *LikeRepository) FetchLikedCommentIDs(userID int) ([]int, error) {
+	return l.fetchLikedIDs(`SELECT comment_id FROM comment_likes WHERE user_id = ? ORDER BY id;`, userID)
+}
+
+func (l *LikeRepository) fetchLikedIDs(sqlStmt string, userID int) ([]int, error) {
+	rows, err := l.db.Query(sqlStmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// LikeCountCorrection is a post, or a comment of it when CommentID is set, whose likes had duplicate rows
+type LikeCountCorrection struct {
+	PostID    int  `json:"post_id"`
+	CommentID *int `json:"comment_id,omitempty"`
+	Before    int  `json:"before"`
+	After     int  `json:"after"`
+}
+
+type LikeRecountBatch struct {
+	Checked     int
+	LastPostID  int
+	Corrections []LikeCountCorrection
+}
+
+// RecountLikes checks the likes of up to limit posts after afterPostID, and of their comments. A user who liked the same
+// post or comment more than once is counted once for each row, so the extra rows are removed keeping the oldest.
+// Callers continue from LastPostID until Checked is 0, each batch runs in its own transaction
+func (l *LikeRepository) RecountLikes(afterPostID, limit int) (LikeRecountBatch, error) {
+	batch := LikeRecountBatch{Corrections: []LikeCountCorrection{}}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return batch, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM posts WHERE id > ? ORDER BY id LIMIT ?;`, afterPostID, limit)
+	if err != nil {
+		return batch, err
+	}
+
+	postIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return batch, err
+		}
+		postIDs = append(postIDs, id)
+	}
+	rows.Close()
+
+	if len(postIDs) == 0 {
+		return batch, nil
+	}
+
+	placeholders, args := inClause(postIDs)
+	batch.Checked = len(postIDs)
+	batch.LastPostID = postIDs[len(postIDs)-1]
+
+	postLikes, err := scanLikeCorrections(tx, fmt.Sprintf(`
+		SELECT post_id, NULL, COUNT(*), COUNT(DISTINCT user_id)
+		FROM post_reactions
+		WHERE post_id IN (%s)
+		GROUP BY post_id
+		HAVING COUNT(*) > COUNT(DISTINCT user_id);`, placeholders), args...)
+	if err != nil {
+		return batch, err
+	}
+
+	commentLikes, err := scanLikeCorrections(tx, fmt.Sprintf(`
+		SELECT c.post_id, cl.comment_id, COUNT(*), COUNT(DISTINCT cl.user_id)
+		FROM comment_likes cl
+		INNER JOIN comments c ON c.id = cl.comment_id
+		WHERE c.post_id IN (%s)
+		GROUP BY cl.comment_id
+		HAVING COUNT(*) > COUNT(DISTINCT cl.user_id);`, placeholders), args...)
+	if err != nil {
+		return batch, err
+	}
+
+	twice := append(append([]interface{}{}, args...), args...)
+
+	if len(postLikes) > 0 {
+		if _, err := tx.Exec(fmt.Sprintf(`
+			DELETE FROM post_reactions
+			WHERE post_id IN (%[1]s)
+			AND id NOT IN (SELECT MIN(id) FROM post_reactions WHERE post_id IN (%[1]s) GROUP BY post_id, user_id);`, placeholders), twice...); err != nil {
+			return batch, err
+		}
+	}
+
+	if len(commentLikes) > 0 {
+		if _, err := tx.Exec(fmt.Sprintf(`
+			DELETE FROM comment_likes
+			WHERE comment_id IN (SELECT id FROM comments WHERE post_id IN (%[1]s))
+			AND id NOT IN (
+				SELECT MIN(id) FROM comment_likes
+				WHERE comment_id IN (SELECT id FROM comments WHERE post_id IN (%[1]s))
+				GROUP BY comment_id, user_id
+			);`, placeholders), twice...); err != nil {
+			return batch, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return batch, err
+	}
+
+	batch.Corrections = append(postLikes, commentLikes...)
+	return batch, nil
+}
+
+func scanLikeCorrections(tx *sql.Tx, query string, args ...interface{}) ([]LikeCountCorrection, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	corrections := []LikeCountCorrection{}
+	for rows.Next() {
+		var correction LikeCountCorrection
+		if err := rows.Scan(&correction.PostID, &correction.CommentID, &correction.Before, &correction.After); err != nil {
+			return nil, err
+		}
+		corrections = append(corrections, correction)
+	}
+
+	return corrections, rows.Err()
+}
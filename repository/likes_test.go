@@ -0,0 +1,131 @@
+package repository_test
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Like Repository Test", func() {
+	var (
+		db       *sql.DB
+		likeRepo *repository.LikeRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+
+		migration.Migrate(db)
+
+		likeRepo = repository.NewLikeRepository(db)
+
+		_, err = db.Exec(`
+			INSERT INTO posts (id, author_id, category_id, title, desc, created_at) VALUES
+			(101, 1, 1, 'First', 'First post', datetime('now')),
+			(102, 1, 1, 'Second', 'Second post', datetime('now')),
+			(103, 2, 1, 'Third', 'Third post', datetime('now'));
+			INSERT INTO comments (id, post_id, author_id, comment, created_at) VALUES (101, 103, 1, 'Comment', datetime('now'));
+			INSERT INTO post_reactions (post_id, user_id) VALUES (101, 2), (101, 2), (101, 3), (102, 2), (103, 1);
+			INSERT INTO comment_likes (comment_id, user_id) VALUES (101, 2), (101, 2), (101, 2);`)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Describe("LikePost", func() {
+		It("should keep one like per user when the same like comes twice", func() {
+			liked, err := likeRepo.LikePost(102, 3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(liked).To(BeTrue())
+
+			liked, err = likeRepo.LikePost(102, 3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(liked).To(BeFalse())
+
+			count, err := likeRepo.CountPostLike(102)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
+
+		It("should count every like of concurrent requests once", func() {
+			var wg sync.WaitGroup
+			results := make(chan bool, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+
+					liked, err := likeRepo.LikePost(102, 1)
+					Expect(err).ToNot(HaveOccurred())
+					results <- liked
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			inserted := 0
+			for liked := range results {
+				if liked {
+					inserted++
+				}
+			}
+			Expect(inserted).To(Equal(1))
+
+			count, err := likeRepo.CountPostLike(102)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
+	})
+
+	Describe("RecountLikes", func() {
+		It("should remove duplicate likes in batches and report what it corrected", func() {
+			batch, err := likeRepo.RecountLikes(100, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Checked).To(Equal(2))
+			Expect(batch.LastPostID).To(Equal(102))
+			Expect(batch.Corrections).To(Equal([]repository.LikeCountCorrection{{PostID: 101, Before: 3, After: 2}}))
+
+			batch, err = likeRepo.RecountLikes(batch.LastPostID, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Checked).To(Equal(1))
+			commentID := 101
+			Expect(batch.Corrections).To(Equal([]repository.LikeCountCorrection{{PostID: 103, CommentID: &commentID, Before: 3, After: 1}}))
+
+			batch, err = likeRepo.RecountLikes(batch.LastPostID, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Checked).To(Equal(0))
+
+			count, err := likeRepo.CountPostLike(101)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(2))
+
+			count, err = likeRepo.CountCommentLike(101)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(1))
+		})
+
+		It("should find nothing to correct once repaired", func() {
+			_, err := likeRepo.RecountLikes(100, 10)
+			Expect(err).ToNot(HaveOccurred())
+
+			batch, err := likeRepo.RecountLikes(100, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Checked).To(Equal(3))
+			Expect(batch.Corrections).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type MentionRepository struct {
+	db *sql.DB
+}
+
+func NewMentionRepository(db *sql.DB) *MentionRepository {
+	return &MentionRepository{
+		db: db,
+	}
+}
+
+// FetchUserByName matches the display name case-insensitively, the oldest account wins when names collide
+func (m *MentionRepository) FetchUserByName(name string) (int, string, error) {
+	sqlStmt := `SELECT id, name FROM users WHERE LOWER(name) = LOWER(?) ORDER BY id LIMIT 1;`
+
+	var (
+		userID   int
+		userName string
+	)
+	err := m.db.QueryRow(sqlStmt, name).Scan(&userID, &userName)
+	return userID, userName, err
+}
+
+func (m *MentionRepository) InsertMentions(mentions []Mention) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, mention := range mentions {
+		_, err = tx.Exec(
+			"INSERT INTO mentions (user_id, author_id, post_id, comment_id, start, end, created_at) VALUES (?, ?, ?, ?, ?, ?, ?);",
+			mention.UserID,
+			mention.AuthorID,
+			mention.PostID,
+			mention.CommentID,
+			mention.Start,
+			mention.End,
+			time.Now(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *MentionRepository) FetchPostMentions(postID int) ([]Mention, error) {
+	return m.fetchMentions("m.post_id = ? AND m.comment_id IS NULL", postID)
+}
+
+func (m *MentionRepository) FetchCommentMentions(commentID int) ([]Mention, error) {
+	return m.fetchMentions("m.comment_id = ?", commentID)
+}
+
+func (m *MentionRepository) fetchMentions(filter string, arg int) ([]Mention, error) {
+	sqlStmt := fmt.Sprintf(`
+	SELECT m.id, m.user_id, u.name, m.author_id, m.post_id, m.comment_id, m.start, m.end
+	FROM mentions m
+	JOIN users u ON u.id = m.user_id
+	WHERE %s
+	ORDER BY m.start;`, filter)
+
+	rows, err := m.db.Query(sqlStmt, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var mention Mention
+		err = rows.Scan(
+			&mention.ID,
+			&mention.UserID,
+			&mention.UserName,
+			&mention.AuthorID,
+			&mention.PostID,
+			&mention.CommentID,
+			&mention.Start,
+			&mention.End,
+		)
+		if err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, nil
+}
+
+// FetchUserMentions returns the posts and comments mentioning the user newest first, one entry for each even when they
+// mention the user more than once. Start and End are those of the first mention
+func (m *MentionRepository) FetchUserMentions(userID, limit, offset int) ([]UserMention, error) {
+	rows, err := m.db.Query(`
+	SELECT um.*, n.read_at
+	FROM (
+		SELECT
+			m.post_id,
+			p.title,
+			m.comment_id,
+			m.author_id,
+			u.name,
+			COALESCE(c.comment, p.desc),
+			MIN(m.start),
+			m.end,
+			(SELECT n.id FROM notifications n
+				WHERE n.user_id = m.user_id AND (
+					(n.type = ?1 AND n.target_id = m.comment_id) OR
+					(m.comment_id IS NULL AND n.type = ?2 AND n.target_id = m.post_id))
+				ORDER BY n.id DESC LIMIT 1) AS notification_id,
+			m.created_at,
+			MAX(m.id) AS last_id
+		FROM mentions m
+		JOIN posts p ON p.id = m.post_id
+		JOIN users u ON u.id = m.author_id
+		LEFT JOIN comments c ON c.id = m.comment_id
+		WHERE m.user_id = ?3 AND (m.comment_id IS NULL OR c.id IS NOT NULL)
+		GROUP BY m.post_id, m.comment_id
+	) um
+	LEFT JOIN notifications n ON n.id = um.notification_id
+	ORDER BY um.created_at DESC, um.last_id DESC
+	LIMIT ?4 OFFSET ?5;`, NotifTypeCommentMention, NotifTypePostMention, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []UserMention{}
+	for rows.Next() {
+		var (
+			mention UserMention
+			lastID  int
+		)
+		err = rows.Scan(
+			&mention.PostID,
+			&mention.PostTitle,
+			&mention.CommentID,
+			&mention.AuthorID,
+			&mention.AuthorName,
+			&mention.Content,
+			&mention.Start,
+			&mention.End,
+			&mention.NotificationID,
+			&mention.CreatedAt,
+			&lastID,
+			&mention.ReadAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+func (m *MentionRepository) DeletePostMentions(postID int) error {
+	_, err := m.db.Exec("DELETE FROM mentions WHERE post_id = ? AND comment_id IS NULL;", postID)
+	return err
+}
+
+func (m *MentionRepository) DeleteCommentMentions(commentID int) error {
+	_, err := m.db.Exec("DELETE FROM mentions WHERE comment_id = ?;", commentID)
+	return err
+}
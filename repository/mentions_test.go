@@ -0,0 +1,81 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mention Repository Test", func() {
+	var (
+		db          *sql.DB
+		mentionRepo *repository.MentionRepository
+		notifRepo   *repository.NotificationRepository
+		commentRepo *repository.CommentRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+		seedAdmin(db)
+
+		mentionRepo = repository.NewMentionRepository(db)
+		notifRepo = repository.NewNotificationRepository(db)
+		commentRepo = repository.NewCommentRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	It("should list the posts and comments mentioning the user once each, newest first", func() {
+		Expect(mentionRepo.InsertMentions([]repository.Mention{
+			{UserID: 2, AuthorID: 1, PostID: 1, Start: 0, End: 5},
+		})).To(Succeed())
+		Expect(notifRepo.CreateNotification(2, 1, repository.NotifTypePostMention, 1)).To(Succeed())
+
+		commentID, err := commentRepo.InsertComment(repository.Comment{PostID: 1, AuthorID: 3, Comment: "hi @Bocil_SMA and @Bocil_SMA"})
+		Expect(err).ToNot(HaveOccurred())
+		id := int(commentID)
+		Expect(mentionRepo.InsertMentions([]repository.Mention{
+			{UserID: 2, AuthorID: 3, PostID: 1, CommentID: &id, Start: 18, End: 27},
+			{UserID: 2, AuthorID: 3, PostID: 1, CommentID: &id, Start: 3, End: 12},
+		})).To(Succeed())
+
+		mentions, err := mentionRepo.FetchUserMentions(2, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mentions).To(HaveLen(2))
+		Expect(*mentions[0].CommentID).To(Equal(id))
+		Expect(mentions[0].AuthorName).To(Equal("Admin"))
+		Expect(mentions[0].Content).To(Equal("hi @Bocil_SMA and @Bocil_SMA"))
+		Expect(mentions[0].Start).To(Equal(3))
+		Expect(mentions[0].End).To(Equal(12))
+		Expect(mentions[0].NotificationID).To(BeNil())
+		Expect(mentions[1].CommentID).To(BeNil())
+		Expect(mentions[1].NotificationID).ToNot(BeNil())
+		Expect(mentions[1].ReadAt).To(BeNil())
+
+		Expect(notifRepo.SetReadNotificationsOfTypes(2, repository.NotifTypePostMention, repository.NotifTypeCommentMention)).To(Succeed())
+
+		mentions, err = mentionRepo.FetchUserMentions(2, 1, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mentions).To(HaveLen(1))
+		Expect(mentions[0].ReadAt).ToNot(BeNil())
+
+		Expect(commentRepo.DeleteComment(id)).To(Succeed())
+
+		mentions, err = mentionRepo.FetchUserMentions(2, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mentions).To(HaveLen(1))
+	})
+})
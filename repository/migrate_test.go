@@ -0,0 +1,121 @@
+package repository_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate Test", func() {
+	var db *sql.DB
+
+	// The shipped database has the schema of before the migrations, with its users, posts and comments
+	BeforeEach(func() {
+		shipped, err := os.ReadFile(filepath.Join("..", "discusspedia.db"))
+		Expect(err).ToNot(HaveOccurred())
+
+		path := filepath.Join(GinkgoT().TempDir(), "discusspedia.db")
+		Expect(os.WriteFile(path, shipped, 0644)).To(Succeed())
+
+		db, err = sql.Open("sqlite3", path)
+		Expect(err).ToNot(HaveOccurred())
+		db.SetMaxOpenConns(1)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	countUsers := func() int {
+		var count int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)).To(Succeed())
+		return count
+	}
+
+	It("should add the new columns to the existing tables without seeding them again", func() {
+		users := countUsers()
+
+		Expect(func() { migration.Migrate(db) }).ToNot(Panic())
+		Expect(countUsers()).To(Equal(users))
+
+		Expect(repository.NewUserRepository(db).TouchLastActive(1)).To(Succeed())
+
+		posts, err := repository.NewPostRepository(db).FetchAllPost(10, 0, 1, "created_at DESC", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(posts).ToNot(BeEmpty())
+		Expect(posts[0].ModerationStatus).To(Equal(repository.ModerationApproved))
+	})
+
+	It("should let the existing users be banned", func() {
+		migration.Migrate(db)
+		userRepo := repository.NewUserRepository(db)
+
+		ban, err := userRepo.GetActiveBan(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ban).To(BeNil())
+
+		Expect(userRepo.BanUser(1, time.Now().Add(time.Hour), "spam")).To(Succeed())
+		ban, err = userRepo.GetActiveBan(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ban.Reason).To(Equal("spam"))
+
+		Expect(userRepo.TouchLastActive(2)).To(Succeed())
+		active, err := userRepo.FetchActiveUsers(time.Now().Add(-time.Hour), 0, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(active).To(HaveLen(1))
+	})
+
+	It("should keep the notifications of the existing comments", func() {
+		migration.Migrate(db)
+		notifRepo := repository.NewNotificationRepository(db)
+
+		notifications, err := notifRepo.GetAllNotifications(4, 1, 10)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(notifications).To(HaveLen(2))
+		Expect(notifications[0].Type).To(Equal(repository.NotifTypeComment))
+
+		unread, err := notifRepo.CountUnreadNotifications(4)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unread).To(Equal(1))
+
+		Expect(notifRepo.CreateNotification(4, 1, repository.NotifTypeFollow, 1)).To(Succeed())
+
+		var legacy int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'legacy_notifications'`).Scan(&legacy)).To(Succeed())
+		Expect(legacy).To(Equal(0))
+	})
+
+	It("should refuse unknown roles in the existing users table", func() {
+		_, err := db.Exec(`UPDATE users SET role = ' Siswa' WHERE id = 2`)
+		Expect(err).ToNot(HaveOccurred())
+
+		migration.Migrate(db)
+		userRepo := repository.NewUserRepository(db)
+
+		role, err := userRepo.GetUserRole(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*role).To(Equal(repository.RoleSiswa))
+
+		_, err = db.Exec(`UPDATE users SET role = 'teacher' WHERE id = 2`)
+		Expect(err).To(MatchError(ContainSubstring("unknown role")))
+		_, err = db.Exec(`INSERT INTO users (name, email, password, role) VALUES ('Dosen', 'dosen@gmail.com', 'x', 'dosen')`)
+		Expect(err).To(MatchError(ContainSubstring("unknown role")))
+
+		_, err = userRepo.UpdateUserRole(3, 2, repository.RoleLecturer)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should leave a migrated database as it is when it's migrated again", func() {
+		migration.Migrate(db)
+		users := countUsers()
+
+		Expect(func() { migration.Migrate(db) }).ToNot(Panic())
+		Expect(countUsers()).To(Equal(users))
+	})
+})
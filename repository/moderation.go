@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrPostApproved is returned when rejecting a published post, taking it down is done by deleting it
+var ErrPostApproved = errors.New("post is already approved")
+
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// ModeratedPost is the post a moderation decision was made on, PreviousStatus tells whether it was published before
+type ModeratedPost struct {
+	AuthorID       int
+	CategoryID     int
+	Title          string
+	Description    string
+	IsAnonymous    bool
+	PreviousStatus string
+}
+
+// SetPreModeration makes new posts wait as pending until a moderator approves them, it's meant to be called once at
+// startup. Questionnaires are not moderated
+func (p *PostRepository) SetPreModeration(enabled bool) {
+	p.preModeration = enabled
+}
+
+func (p *PostRepository) newPostStatus() string {
+	if p.preModeration {
+		return ModerationPending
+	}
+	return ModerationApproved
+}
+
+// FetchPendingPosts returns the posts waiting for moderation, oldest first so none is left behind.
+// A nil categoryIDs doesn't filter by category, it's for the global moderators
+func (p *PostRepository) FetchPendingPosts(limit, offset int, categoryIDs []int) ([]PostDetail, error) {
+	filter := fmt.Sprintf("AND p.moderation_status = '%s'", ModerationPending)
+	if categoryIDs != nil {
+		if len(categoryIDs) == 0 {
+			return []PostDetail{}, nil
+		}
+
+		ids := make([]string, len(categoryIDs))
+		for i, id := range categoryIDs {
+			ids[i] = strconv.Itoa(id)
+		}
+		filter += fmt.Sprintf(" AND p.category_id IN (%s)", strings.Join(ids, ", "))
+	}
+
+	return p.fetchPosts(limit, offset, 0, "p.created_at ASC, p.id ASC", filter, true)
+}
+
+// SetModerationStatus stores the decision on the post and returns the post as it was before.
+// Rejected posts can still be approved later, approved ones stay approved
+func (p *PostRepository) SetModerationStatus(postID int, status string) (ModeratedPost, error) {
+	var post ModeratedPost
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return post, err
+	}
+
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		SELECT p.author_id, p.category_id, p.title, p.desc, p.is_anonymous, p.moderation_status
+		FROM posts p
+		LEFT JOIN questionnaires q ON q.post_id = p.id
+		WHERE p.id = ? AND q.post_id IS NULL;
+	`, postID).Scan(&post.AuthorID, &post.CategoryID, &post.Title, &post.Description, &post.IsAnonymous, &post.PreviousStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, ErrPostNotFound
+	}
+	if err != nil {
+		return post, err
+	}
+
+	if post.PreviousStatus == ModerationApproved && status != ModerationApproved {
+		return post, ErrPostApproved
+	}
+
+	if _, err := tx.Exec(`UPDATE posts SET moderation_status = ? WHERE id = ?;`, status, postID); err != nil {
+		return post, err
+	}
+
+	return post, tx.Commit()
+}
@@ -3,37 +3,215 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+const (
+	NotifTypePostLike       = "post_like"
+	NotifTypeCommentLike    = "comment_like"
+	NotifTypeComment        = "comment"
+	NotifTypeReply          = "reply"
+	NotifTypeFollow         = "follow"
+	NotifTypePostMention    = "post_mention"
+	NotifTypeCommentMention = "comment_mention"
+	NotifTypePostApproved   = "post_approved"
+	NotifTypePostRejected   = "post_rejected"
+	NotifTypePostMerged     = "post_merged"
+	NotifTypeNewPost        = "new_post"
+)
+
+// NotificationTypes are every type of notification, users can turn each of them off
+var NotificationTypes = []string{
+	NotifTypePostLike, NotifTypeCommentLike, NotifTypeComment, NotifTypeReply, NotifTypeFollow,
+	NotifTypePostMention, NotifTypeCommentMention, NotifTypePostApproved, NotifTypePostRejected,
+	NotifTypePostMerged, NotifTypeNewPost,
+}
+
+// notificationBatchSize is how many notifications CreateNotifications inserts per statement, the user id of each row
+// is one of SQLite's 999 variables
+const notificationBatchSize = 500
+
+// notificationEnabled is the condition of inserting a notification for user_id, users who turned the type off are
+// skipped. Its ? is bound to the type
+const notificationEnabled = `NOT EXISTS (
+	SELECT 1 FROM notification_preferences np WHERE np.user_id = recipient.user_id AND np.type = ? AND np.enabled = 0
+)`
+
+// unreadCountTTL keeps badge polling from hitting the database on every request
+const unreadCountTTL = 5 * time.Second
+
+type unreadCount struct {
+	count     int
+	expiresAt time.Time
+}
+
+type unreadCountCache struct {
+	mu        sync.RWMutex
+	counts    map[int]unreadCount
+	lastSweep time.Time
+}
+
 type NotificationRepository struct {
-	db *sql.DB
+	db          *sql.DB
+	unreadCache *unreadCountCache
 }
 
 func NewNotificationRepository(db *sql.DB) *NotificationRepository {
 	return &NotificationRepository{
 		db: db,
+		unreadCache: &unreadCountCache{
+			counts: make(map[int]unreadCount),
+		},
 	}
 }
 
-func (n NotificationRepository) CreateNotification(userId int, commentId int) error {
-	_, err := n.db.Exec("INSERT INTO notifications (user_id, comment_id, created_at) VALUES (?, ?, ?)", userId, commentId, time.Now())
-	return err
+func (c *unreadCountCache) get(userId int) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.counts[userId]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return 0, false
+	}
+	return cached.count, true
+}
+
+func (c *unreadCountCache) set(userId, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	// Users who stop polling would stay in the map forever, so the expired counts are dropped once per TTL
+	if now.Sub(c.lastSweep) >= unreadCountTTL {
+		for id, cached := range c.counts {
+			if now.After(cached.expiresAt) {
+				delete(c.counts, id)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	c.counts[userId] = unreadCount{count: count, expiresAt: now.Add(unreadCountTTL)}
+}
+
+func (c *unreadCountCache) invalidate(userId int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.counts, userId)
+}
+
+// CreateNotification skips notifying users about their own actions and about the types they turned off
+func (n NotificationRepository) CreateNotification(userId, actorId int, notifType string, targetId int) error {
+	if userId == actorId {
+		return nil
+	}
+
+	_, err := n.db.Exec(`
+		INSERT INTO notifications (user_id, type, actor_id, target_id, created_at)
+		SELECT recipient.user_id, ?, ?, ?, ? FROM (SELECT ? AS user_id) recipient
+		WHERE `+notificationEnabled,
+		notifType, actorId, targetId, time.Now(), userId, notifType)
+	if err != nil {
+		return err
+	}
+
+	n.unreadCache.invalidate(userId)
+	return nil
+}
+
+// CreateNotifications notifies every user of userIds about the same thing in one transaction, in batches of
+// notificationBatchSize rows. Like CreateNotification the actor and the users who turned the type off are skipped
+func (n NotificationRepository) CreateNotifications(userIds []int, actorId int, notifType string, targetId int) error {
+	recipients := make([]int, 0, len(userIds))
+	for _, userId := range userIds {
+		if userId != actorId {
+			recipients = append(recipients, userId)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	err := withTx(n.db, func(tx *sql.Tx) error {
+		for start := 0; start < len(recipients); start += notificationBatchSize {
+			end := start + notificationBatchSize
+			if end > len(recipients) {
+				end = len(recipients)
+			}
+
+			args := []interface{}{notifType, actorId, targetId, now}
+			for _, userId := range recipients[start:end] {
+				args = append(args, userId)
+			}
+			args = append(args, notifType)
+
+			placeholders := strings.TrimSuffix(strings.Repeat("(?), ", end-start), ", ")
+			_, err := tx.Exec(`
+				INSERT INTO notifications (user_id, type, actor_id, target_id, created_at)
+				SELECT recipient.user_id, ?, ?, ?, ? FROM (SELECT column1 AS user_id FROM (VALUES `+placeholders+`)) recipient
+				WHERE `+notificationEnabled, args...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, userId := range recipients {
+		n.unreadCache.invalidate(userId)
+	}
+	return nil
 }
 
 func (n NotificationRepository) GetAllNotifications(userId, page, limit int) ([]Notification, error) {
-	rows, err := n.db.Query("SELECT notifications.id, users.name, notifications.comment_id, comments.post_id, posts.title, notifications.already_read, notifications.created_at FROM notifications JOIN comments ON notifications.comment_id = comments.id JOIN users ON users.id = comments.author_id JOIN posts ON posts.id = comments.post_id WHERE user_id = ? ORDER BY notifications.created_at DESC LIMIT ? OFFSET ?", userId, limit, (page-1)*limit)
+	sqlStmt := `
+	SELECT
+		n.id,
+		n.type,
+		n.actor_id,
+		u.name,
+		n.target_id,
+		p.id,
+		p.title,
+		n.read_at,
+		n.created_at
+	FROM notifications n
+	JOIN users u ON u.id = n.actor_id
+	LEFT JOIN comments c ON n.type IN (?, ?, ?, ?) AND c.id = n.target_id
+	LEFT JOIN posts p ON p.id = CASE WHEN n.type IN (?, ?, ?, ?, ?, ?) THEN n.target_id ELSE c.post_id END
+	WHERE n.user_id = ?
+	ORDER BY n.created_at DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := n.db.Query(sqlStmt, NotifTypeComment, NotifTypeReply, NotifTypeCommentLike, NotifTypeCommentMention, NotifTypePostLike, NotifTypePostMention, NotifTypePostApproved, NotifTypePostRejected, NotifTypePostMerged, NotifTypeNewPost, userId, limit, (page-1)*limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var notifications []Notification
+	notifications := []Notification{}
 	for rows.Next() {
 		var notification Notification
-		if err := rows.Scan(&notification.ID, &notification.Name, &notification.CommentID, &notification.PostID, &notification.PostTitle, &notification.AlreadyRead, &notification.CreatedAt); err != nil {
+		if err := rows.Scan(
+			&notification.ID,
+			&notification.Type,
+			&notification.ActorID,
+			&notification.ActorName,
+			&notification.TargetID,
+			&notification.PostID,
+			&notification.PostTitle,
+			&notification.ReadAt,
+			&notification.CreatedAt,
+		); err != nil {
 			return nil, err
 		}
 		notifications = append(notifications, notification)
@@ -42,18 +220,110 @@ func (n NotificationRepository) GetAllNotifications(userId, page, limit int) ([]
 	return notifications, nil
 }
 
+func (n NotificationRepository) CountUnreadNotifications(userId int) (int, error) {
+	if count, ok := n.unreadCache.get(userId); ok {
+		return count, nil
+	}
+
+	var count int
+	err := n.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userId).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	n.unreadCache.set(userId, count)
+	return count, nil
+}
+
 func (n NotificationRepository) SetReadNotification(userId int, notifId int) error {
-	affected, err := n.db.Exec("UPDATE notifications SET already_read = 1 WHERE id = ? and user_id=?", notifId, userId)
+	affected, err := n.db.Exec("UPDATE notifications SET read_at = COALESCE(read_at, ?) WHERE id = ? AND user_id = ?", time.Now(), notifId, userId)
+	if err != nil {
+		return err
+	}
+
 	if rows, _ := affected.RowsAffected(); rows < 1 {
 		return errors.New("no notification found")
 	}
-	return err
+
+	n.unreadCache.invalidate(userId)
+	return nil
 }
 
 func (n NotificationRepository) SetReadAllNotification(userId int) error {
-	affected, err := n.db.Exec("UPDATE notifications SET already_read = 1 WHERE user_id = ?", userId)
-	if rows, _ := affected.RowsAffected(); rows < 1 {
-		return errors.New("no notification found")
+	_, err := n.db.Exec("UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL", time.Now(), userId)
+	if err != nil {
+		return err
+	}
+
+	n.unreadCache.invalidate(userId)
+	return nil
+}
+
+// SetReadNotificationsOfTypes marks the unread notifications of the given types as read, like all the mentions
+func (n NotificationRepository) SetReadNotificationsOfTypes(userId int, types ...string) error {
+	if len(types) == 0 {
+		return nil
+	}
+
+	args := []interface{}{time.Now(), userId}
+	for _, notifType := range types {
+		args = append(args, notifType)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(types)), ", ")
+	_, err := n.db.Exec(
+		"UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL AND type IN ("+placeholders+")", args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	n.unreadCache.invalidate(userId)
+	return nil
+}
+
+// FetchNotificationPreferences maps every notification type to whether the user gets it, the types they never
+// changed are on
+func (n NotificationRepository) FetchNotificationPreferences(userId int) (map[string]bool, error) {
+	preferences := make(map[string]bool, len(NotificationTypes))
+	for _, notifType := range NotificationTypes {
+		preferences[notifType] = true
 	}
-	return err
+
+	rows, err := n.db.Query("SELECT type, enabled FROM notification_preferences WHERE user_id = ?", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			notifType string
+			enabled   bool
+		)
+		if err := rows.Scan(&notifType, &enabled); err != nil {
+			return nil, err
+		}
+		if _, ok := preferences[notifType]; ok {
+			preferences[notifType] = enabled
+		}
+	}
+
+	return preferences, rows.Err()
+}
+
+// UpdateNotificationPreferences stores the given types only, the others keep their setting
+func (n NotificationRepository) UpdateNotificationPreferences(userId int, preferences map[string]bool) error {
+	return withTx(n.db, func(tx *sql.Tx) error {
+		for notifType, enabled := range preferences {
+			_, err := tx.Exec(`
+				INSERT INTO notification_preferences (user_id, type, enabled) VALUES (?, ?, ?)
+				ON CONFLICT (user_id, type) DO UPDATE SET enabled = excluded.enabled;`,
+				userId, notifType, enabled)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,101 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Notification Repository Test", func() {
+	var (
+		db        *sql.DB
+		notifRepo *repository.NotificationRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+
+		// Seeds Radit (1), Bocil SMA (2) and Post 1
+		migration.Migrate(db)
+
+		notifRepo = repository.NewNotificationRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Describe("CreateNotifications", func() {
+		It("should notify every user but the actor", func() {
+			Expect(notifRepo.CreateNotifications([]int{2, 1, 3}, 1, repository.NotifTypeNewPost, 1)).To(Succeed())
+
+			for _, userID := range []int{2, 3} {
+				notifications, err := notifRepo.GetAllNotifications(userID, 1, 10)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(notifications).To(HaveLen(1))
+				Expect(notifications[0].Type).To(Equal(repository.NotifTypeNewPost))
+				Expect(*notifications[0].PostTitle).To(Equal("Post 1"))
+			}
+
+			count, err := notifRepo.CountUnreadNotifications(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(BeZero())
+		})
+
+		It("should insert more users than fit in one statement", func() {
+			userIDs := make([]int, 250)
+			for i := range userIDs {
+				userIDs[i] = 2
+			}
+
+			Expect(notifRepo.CreateNotifications(userIDs, 1, repository.NotifTypeNewPost, 1)).To(Succeed())
+
+			count, err := notifRepo.CountUnreadNotifications(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(250))
+		})
+	})
+
+	Describe("NotificationPreferences", func() {
+		It("should have every type on until the user turns it off", func() {
+			preferences, err := notifRepo.FetchNotificationPreferences(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(preferences).To(HaveLen(len(repository.NotificationTypes)))
+			Expect(preferences).To(HaveKeyWithValue(repository.NotifTypeFollow, true))
+
+			Expect(notifRepo.UpdateNotificationPreferences(2, map[string]bool{repository.NotifTypeFollow: false})).To(Succeed())
+
+			preferences, err = notifRepo.FetchNotificationPreferences(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(preferences).To(HaveKeyWithValue(repository.NotifTypeFollow, false))
+			Expect(preferences).To(HaveKeyWithValue(repository.NotifTypePostLike, true))
+		})
+
+		It("should skip the types the user turned off", func() {
+			Expect(notifRepo.UpdateNotificationPreferences(2, map[string]bool{repository.NotifTypeFollow: false})).To(Succeed())
+
+			Expect(notifRepo.CreateNotification(2, 1, repository.NotifTypeFollow, 1)).To(Succeed())
+			Expect(notifRepo.CreateNotifications([]int{2, 3}, 1, repository.NotifTypeFollow, 1)).To(Succeed())
+			Expect(notifRepo.CreateNotification(2, 1, repository.NotifTypePostLike, 1)).To(Succeed())
+
+			notifications, err := notifRepo.GetAllNotifications(2, 1, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(notifications).To(HaveLen(1))
+			Expect(notifications[0].Type).To(Equal(repository.NotifTypePostLike))
+
+			count, err := notifRepo.CountUnreadNotifications(3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// platformStatsTTL keeps the admin dashboard from running every count on each refresh, the numbers change slowly
+const platformStatsTTL = time.Minute
+
+// PlatformStats are the totals of the whole platform. Users registered before registered_at existed don't count as new
+type PlatformStats struct {
+	Users            int       `json:"users"`
+	Posts            int       `json:"posts"`
+	Questionnaires   int       `json:"questionnaires"`
+	Comments         int       `json:"comments"`
+	Likes            int       `json:"likes"`
+	NewUsers7Days    int       `json:"new_users_7_days"`
+	NewUsers30Days   int       `json:"new_users_30_days"`
+	DailyActiveUsers int       `json:"daily_active_users"`
+	GeneratedAt      time.Time `json:"generated_at"`
+}
+
+type platformStatsCache struct {
+	mu        sync.Mutex
+	stats     PlatformStats
+	expiresAt time.Time
+}
+
+// GetPlatformStats counts everything in a single query and caches the result for platformStatsTTL. Likes are the
+// likes of posts and comments together, the daily active users were active in the last 24 hours
+func (u *UserRepository) GetPlatformStats() (PlatformStats, error) {
+	u.platformStats.mu.Lock()
+	defer u.platformStats.mu.Unlock()
+
+	now := time.Now().UTC()
+	if now.Before(u.platformStats.expiresAt) {
+		return u.platformStats.stats, nil
+	}
+
+	sqlStatement := `
+		SELECT
+			(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL),
+			(SELECT COUNT(*) FROM posts p LEFT JOIN questionnaires q ON q.post_id = p.id WHERE q.post_id IS NULL),
+			(SELECT COUNT(*) FROM questionnaires),
+			(SELECT COUNT(*) FROM comments),
+			(SELECT COUNT(*) FROM post_reactions) + (SELECT COUNT(*) FROM comment_likes),
+			(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND registered_at >= ?1),
+			(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND registered_at >= ?2),
+			(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND last_active_at >= ?3);
+	`
+
+	stats := PlatformStats{GeneratedAt: now}
+	err := u.db.QueryRow(sqlStatement, now.AddDate(0, 0, -7), now.AddDate(0, 0, -30), now.Add(-24*time.Hour)).Scan(
+		&stats.Users, &stats.Posts, &stats.Questionnaires, &stats.Comments, &stats.Likes,
+		&stats.NewUsers7Days, &stats.NewUsers30Days, &stats.DailyActiveUsers)
+	if err != nil {
+		return PlatformStats{}, err
+	}
+
+	u.platformStats.stats = stats
+	u.platformStats.expiresAt = now.Add(platformStatsTTL)
+	return stats, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrMergeTargetNotFound = errors.New("target post not found")
+	ErrMergeSamePost       = errors.New("a post can't be merged into itself")
+)
+
+// MergedPost tells what MergePost moved, ImagePaths are the files of the source that should be removed once the merge
+// is committed
+type MergedPost struct {
+	SourceAuthorID int
+	TargetAuthorID int
+	MovedComments  int
+	MovedLikes     int
+	ImagePaths     []string
+}
+
+// MergePost moves the comments and likes of the source post to the target and deletes the source, in one
+// transaction. A user who liked both keeps their reaction on the target. Posts aren't soft-deleted, so the source
+// is removed along with its images, revisions and the mentions of its description. Moved comments lose their
+// highlight, it was the best answer of another question
+func (p *PostRepository) MergePost(sourceID, targetID int) (MergedPost, error) {
+	var merged MergedPost
+	if sourceID == targetID {
+		return merged, ErrMergeSamePost
+	}
+
+	err := withTx(p.db, func(tx *sql.Tx) error {
+		merged = MergedPost{ImagePaths: []string{}}
+
+		authorOf := `
+			SELECT p.author_id FROM posts p
+			LEFT JOIN questionnaires q ON q.post_id = p.id
+			WHERE p.id = ? AND q.post_id IS NULL;`
+
+		err := tx.QueryRow(authorOf, sourceID).Scan(&merged.SourceAuthorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(authorOf, targetID).Scan(&merged.TargetAuthorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMergeTargetNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`UPDATE comments SET post_id = ?, is_highlighted = 0 WHERE post_id = ?;`, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		moved, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		merged.MovedComments = int(moved)
+
+		_, err = tx.Exec(`
+			DELETE FROM post_reactions
+			WHERE post_id = ?1 AND user_id IN (SELECT user_id FROM post_reactions WHERE post_id = ?2);`, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+
+		result, err = tx.Exec(`UPDATE post_reactions SET post_id = ? WHERE post_id = ?;`, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		moved, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		merged.MovedLikes = int(moved)
+
+		// The mentions in the moved comments go along with them, the ones in the description are deleted with it
+		_, err = tx.Exec(`UPDATE mentions SET post_id = ? WHERE post_id = ? AND comment_id IS NOT NULL;`, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM mentions WHERE post_id = ?;`, sourceID); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(`SELECT path FROM post_images WHERE post_id = ?;`, sourceID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return err
+			}
+			merged.ImagePaths = append(merged.ImagePaths, path)
+		}
+		rows.Close()
+
+		for _, statement := range []string{
+			`DELETE FROM post_images WHERE post_id = ?;`,
+			`DELETE FROM post_revisions WHERE post_id = ?;`,
+			`DELETE FROM posts WHERE id = ?;`,
+		} {
+			if _, err := tx.Exec(statement, sourceID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return MergedPost{}, err
+	}
+
+	return merged, nil
+}
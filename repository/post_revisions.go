@@ -0,0 +1,51 @@
+package repository
+
+import "time"
+
+// PostRevision is one version of a post's title, description and category, along with who saved it and when
+type PostRevision struct {
+	ID          int       `json:"id"`
+	PostID      int       `json:"post_id"`
+	EditorID    int       `json:"editor_id"`
+	EditorName  string    `json:"editor_name"`
+	CategoryID  int       `json:"category_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	// IsAnonymous is the post's, the editor of an anonymous post is masked like its author
+	IsAnonymous bool `json:"-"`
+}
+
+// FetchPostRevisions returns the versions of the post, newest first. Posts that were never edited have none.
+// Editors whose account was deleted along with its content are shown as a "Deleted User" with the id 0
+func (p *PostRepository) FetchPostRevisions(postID, limit, offset int) ([]PostRevision, error) {
+	rows, err := p.db.Query(`
+		SELECT r.id, r.post_id, COALESCE(u.id, 0), COALESCE(u.name, 'Deleted User'), r.category_id, r.title, r.desc,
+			r.created_at, p.is_anonymous
+		FROM post_revisions r
+		LEFT JOIN users u ON u.id = r.editor_id
+		JOIN posts p ON p.id = r.post_id
+		WHERE r.post_id = ?
+		ORDER BY r.id DESC
+		LIMIT ? OFFSET ?;`, postID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var revision PostRevision
+		err := rows.Scan(
+			&revision.ID, &revision.PostID, &revision.EditorID, &revision.EditorName,
+			&revision.CategoryID, &revision.Title, &revision.Description, &revision.CreatedAt,
+			&revision.IsAnonymous,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
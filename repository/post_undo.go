@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotPostAuthor     = errors.New("not the author of the post")
+	ErrUndoWindowExpired = errors.New("the post can't be undone anymore")
+)
+
+// UndoPost deletes a post its author created less than within ago, and returns the paths of its images to remove.
+// Unlike DeletePostByID it also takes back what publishing it set off: the likes, the mentions of the description
+// and the notifications pointing at the post
+func (p *PostRepository) UndoPost(postID, authorID int, within time.Duration) ([]string, error) {
+	imagePaths := []string{}
+
+	err := withTx(p.db, func(tx *sql.Tx) error {
+		imagePaths = imagePaths[:0]
+
+		var (
+			postAuthorID int
+			createdAt    time.Time
+		)
+		err := tx.QueryRow(`
+			SELECT p.author_id, p.created_at FROM posts p
+			LEFT JOIN questionnaires q ON q.post_id = p.id
+			WHERE p.id = ? AND q.post_id IS NULL;`, postID).Scan(&postAuthorID, &createdAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if postAuthorID != authorID {
+			return ErrNotPostAuthor
+		}
+		if time.Since(createdAt) > within {
+			return ErrUndoWindowExpired
+		}
+
+		rows, err := tx.Query(`SELECT path FROM post_images WHERE post_id = ?;`, postID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return err
+			}
+			imagePaths = append(imagePaths, path)
+		}
+		rows.Close()
+
+		_, err = tx.Exec(`DELETE FROM notifications WHERE target_id = ? AND type IN (?, ?, ?, ?);`,
+			postID, NotifTypePostLike, NotifTypePostMention, NotifTypePostApproved, NotifTypeNewPost)
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range []string{
+			`DELETE FROM post_reactions WHERE post_id = ?;`,
+			`DELETE FROM mentions WHERE post_id = ? AND comment_id IS NULL;`,
+			`DELETE FROM post_images WHERE post_id = ?;`,
+			`DELETE FROM post_revisions WHERE post_id = ?;`,
+			`DELETE FROM posts WHERE id = ?;`,
+		} {
+			if _, err := tx.Exec(statement, postID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imagePaths, nil
+}
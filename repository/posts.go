@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -23,30 +25,53 @@ type PostDetail struct {
 	Title             string         `db:"title"`
 	Description       string         `db:"desc"`
 	CreatedAt         time.Time      `db:"created_at"`
+	UpdatedAt         sql.NullTime   `db:"updated_at"`
+	IsPinned          bool           `db:"is_pinned"`
+	CommentsLocked    bool           `db:"comments_locked"`
 	CommentCount      int            `db:"comment_count"`
 	LikeCount         int            `db:"like_count"`
 	ImageID           sql.NullInt32  `db:"image_id"`
 	ImagePath         sql.NullString `db:"image_path"`
+	ImageOrder        sql.NullInt32  `db:"image_order"`
+	ModerationStatus  string         `db:"moderation_status"`
+	IsAnonymous       bool           `db:"is_anonymous"`
 }
 
 type PostRepository struct {
-	db *sql.DB
+	db            *sql.DB
+	preModeration bool
 }
 
 var (
-	ErrPostNotFound = errors.New("post not found")
+	ErrPostNotFound      = errors.New("post not found")
+	ErrPinLimitReached   = errors.New("pin limit reached")
+	ErrInvalidImageOrder = errors.New("image ids should contain every image of the post exactly once")
 )
 
+// MaxPinnedPostsPerCategory keeps announcements from pushing every other post down
+const MaxPinnedPostsPerCategory = 3
+
+// OrderControversial ranks posts with many comments relative to their likes first. Posts below the
+// minimum of 3 comments come after the rest so a brand-new post with one comment can't dominate
+const OrderControversial = "comment_count >= 3 DESC, CAST(comment_count AS REAL) / (like_count + 1) DESC, created_at DESC"
+
+// New images are shown after the existing ones, even when those were reordered
+const insertPostImageStatement = `
+	INSERT INTO post_images (post_id, path, original_name, display_order)
+	VALUES (?1, ?2, ?3, (SELECT COALESCE(MAX(display_order) + 1, 0) FROM post_images WHERE post_id = ?1));
+`
+
 func NewPostRepository(db *sql.DB) *PostRepository {
 	return &PostRepository{
 		db: db,
 	}
 }
 
-func (p *PostRepository) InsertPost(authorID, categoryID int, title, description string) (int64, error) {
+// InsertPost keeps the author of an anonymous post, only the responses hide it
+func (p *PostRepository) InsertPost(authorID, categoryID int, title, description string, anonymous bool) (int64, error) {
 	sqlStatement := `
-    INSERT INTO posts (author_id, category_id, title, desc, created_at) VALUES
-    (?, ?, ?, ?, ?);
+    INSERT INTO posts (author_id, category_id, title, desc, created_at, moderation_status, is_anonymous) VALUES
+    (?, ?, ?, ?, ?, ?, ?);
   `
 
 	tx, err := p.db.Begin()
@@ -57,7 +82,7 @@ func (p *PostRepository) InsertPost(authorID, categoryID int, title, description
 
 	defer tx.Rollback()
 
-	result, err := tx.Exec(sqlStatement, authorID, categoryID, title, description, time.Now())
+	result, err := tx.Exec(sqlStatement, authorID, categoryID, title, description, time.Now(), p.newPostStatus(), anonymous)
 
 	if err != nil {
 		return 0, err
@@ -76,37 +101,150 @@ func (p *PostRepository) InsertPost(authorID, categoryID int, title, description
 	return id, nil
 }
 
-func (p *PostRepository) InsertPostImage(postID int, path string) error {
-	sqlStatement := `
-		INSERT INTO post_images (post_id, path) VALUES (?, ?);
-	`
-	tx, err := p.db.Begin()
+// InsertPostWithImages inserts the post and all of its images in a single transaction
+func (p *PostRepository) InsertPostWithImages(authorID, categoryID int, title, description string, images []UploadedImage) (int64, error) {
+	var id int64
+	err := withTx(p.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			INSERT INTO posts (author_id, category_id, title, desc, created_at, moderation_status) VALUES (?, ?, ?, ?, ?, ?);
+		`, authorID, categoryID, title, description, time.Now(), p.newPostStatus())
+		if err != nil {
+			return err
+		}
 
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, image := range images {
+			if _, err := tx.Exec(insertPostImageStatement, id, image.Path, image.OriginalName); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	defer tx.Rollback()
+	return id, nil
+}
 
-	_, error := tx.Exec(sqlStatement, postID, path)
+// ImportedPost is a post brought over from another platform, it keeps its original creation time
+type ImportedPost struct {
+	AuthorID    int
+	CategoryID  int
+	Title       string
+	Description string
+	CreatedAt   time.Time
+}
 
-	if error != nil {
-		return err
+// InsertImportedPosts inserts the posts in a single transaction and returns their ids in the same order. They were
+// already published elsewhere, so they skip pre-moderation
+func (p *PostRepository) InsertImportedPosts(posts []ImportedPost) ([]int64, error) {
+	var ids []int64
+	err := withTx(p.db, func(tx *sql.Tx) error {
+		ids = make([]int64, 0, len(posts))
+
+		statement, err := tx.Prepare(`
+			INSERT INTO posts (author_id, category_id, title, desc, created_at, moderation_status) VALUES (?, ?, ?, ?, ?, ?);
+		`)
+		if err != nil {
+			return err
+		}
+		defer statement.Close()
+
+		for _, post := range posts {
+			result, err := statement.Exec(
+				post.AuthorID, post.CategoryID, post.Title, post.Description, post.CreatedAt, ModerationApproved,
+			)
+			if err != nil {
+				return err
+			}
+
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if error := tx.Commit(); error != nil {
+	return ids, nil
+}
+
+// InsertPostImage is retried when the database is locked, the images of an upload are inserted concurrently
+func (p *PostRepository) InsertPostImage(postID int, path, originalName string) error {
+	return withTx(p.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(insertPostImageStatement, postID, path, originalName)
 		return err
+	})
+}
+
+func (p *PostRepository) PostImageExists(path string) (bool, error) {
+	var exists bool
+	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM post_images WHERE path = ?);`, path).Scan(&exists)
+	return exists, err
+}
+
+// FetchAllPost filter is appended to the WHERE clause, its ? placeholders are bound to args.
+// Only posts the viewer may see are listed, see postVisibility
+func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filter string, args ...interface{}) ([]PostDetail, error) {
+	return p.fetchPosts(limit, offset, authorID, orderBy, postVisibility(authorID)+" "+filter, true, args...)
+}
+
+// FetchAllPostCompact is FetchAllPost without the images, one row per post and no join with post_images
+func (p *PostRepository) FetchAllPostCompact(limit, offset, authorID int, orderBy, filter string, args ...interface{}) ([]PostDetail, error) {
+	return p.fetchPosts(limit, offset, authorID, orderBy, postVisibility(authorID)+" "+filter, false, args...)
+}
+
+// postVisibility hides posts that aren't approved from everyone but their author, and posts of users the viewer blocked
+func postVisibility(viewerID int) string {
+	return pendingVisibility(viewerID) + " " + blockVisibility(viewerID)
+}
+
+// pendingVisibility hides posts that aren't approved from everyone but their author
+func pendingVisibility(viewerID int) string {
+	return fmt.Sprintf("AND (p.moderation_status = '%s' OR p.author_id = %d)", ModerationApproved, viewerID)
+}
+
+// blockVisibility hides the posts of users the viewer blocked
+func blockVisibility(viewerID int) string {
+	return fmt.Sprintf("AND p.author_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = %d)", viewerID)
+}
+
+// PostVisibleTo tells whether the post exists and the viewer may see it, by the same rules as the posts list.
+// withPending also shows the posts that aren't approved, for the admins and moderators reviewing them
+func (p *PostRepository) PostVisibleTo(postID, viewerID int, withPending bool) (bool, error) {
+	visibility := postVisibility(viewerID)
+	if withPending {
+		visibility = blockVisibility(viewerID)
 	}
 
-	return nil
+	var visible bool
+	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts p WHERE p.id = ? `+visibility+`);`, postID).Scan(&visible)
+	return visible, err
 }
 
-func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filter string) ([]PostDetail, error) {
+func (p *PostRepository) fetchPosts(limit, offset, authorID int, orderBy, filter string, withImages bool, args ...interface{}) ([]PostDetail, error) {
+	imageColumns, imageJoin := "NULL, NULL, NULL", ""
+	if withImages {
+		imageColumns = "pi.id as image_id, pi.path as image_path, pi.display_order as image_order"
+		imageJoin = "LEFT JOIN post_images pi ON up.id = pi.post_id"
+	}
+
 	sqlStatement := fmt.Sprintf(
 		`
 		SELECT 
 		up.id,
-		(SELECT EXISTS (SELECT 1 FROM post_likes WHERE post_id = up.id AND user_id = %d)) AS is_like,
+		(SELECT EXISTS (SELECT 1 FROM post_reactions WHERE post_id = up.id AND user_id = %d)) AS is_like,
 		up.author_id,
 		up.author_name,
 		up.author_role,
@@ -118,10 +256,14 @@ func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filt
 		up.title,
 		up.desc,
 		up.created_at,
+		up.updated_at,
+		up.is_pinned,
+		up.comments_locked,
 		up.comment_count,
 		up.like_count,
-		pi.id as image_id,
-		pi.path as image_path
+		up.moderation_status,
+		up.is_anonymous,
+		%s
 		FROM (
 			SELECT
 			p.id,
@@ -136,26 +278,31 @@ func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filt
 			p.title,
 			p.desc,
 			p.created_at,
+			p.updated_at,
+			p.is_pinned,
+			p.comments_locked,
 			p.comment_count,
-			COUNT(pl.id) as like_count
+			COUNT(pl.id) as like_count,
+			p.moderation_status,
+			p.is_anonymous
 			FROM (
 				SELECT 
-				p.id, p.author_id, p.category_id, p.title, p.desc, p.created_at, COUNT(c.id) as comment_count 
+				p.id, p.author_id, p.category_id, p.title, p.desc, p.created_at, p.updated_at, p.is_pinned, p.comments_locked, p.moderation_status, p.is_anonymous, COUNT(c.id) as comment_count 
 				FROM posts p
 				LEFT JOIN comments c ON c.post_id  = p.id 
 				GROUP BY p.id
 			) p
 			INNER JOIN users u ON p.author_id = u.id
 			LEFT JOIN user_details ud ON u.id = ud.user_id	
-			LEFT JOIN post_likes pl ON pl.post_id = p.id
+			LEFT JOIN post_reactions pl ON pl.post_id = p.id
 			LEFT JOIN questionnaires q ON q.post_id = p.id
 			WHERE q.link IS NULL %s
 			GROUP BY p.id
 			ORDER BY %s
 			LIMIT %d OFFSET %d
 		) up
-		LEFT JOIN post_images pi ON up.id = pi.post_id;`,
-		authorID, filter, orderBy, limit, offset)
+		%s;`,
+		authorID, imageColumns, filter, orderBy, limit, offset, imageJoin)
 
 	tx, err := p.db.Begin()
 
@@ -165,7 +312,7 @@ func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filt
 
 	defer tx.Rollback()
 
-	rows, err := tx.Query(sqlStatement)
+	rows, err := tx.Query(sqlStatement, args...)
 
 	if err != nil {
 		return nil, err
@@ -180,8 +327,9 @@ func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filt
 			&post.ID, &post.IsLike,
 			&post.AuthorID, &post.AuthorName, &post.AuthorRole, &post.AuthorAvatar,
 			&post.AuthorInstitution, &post.AuthorMajor, &post.AuthorBatch,
-			&post.CategoryID, &post.Title, &post.Description, &post.CreatedAt, &post.CommentCount, &post.LikeCount,
-			&post.ImageID, &post.ImagePath)
+			&post.CategoryID, &post.Title, &post.Description, &post.CreatedAt, &post.UpdatedAt, &post.IsPinned, &post.CommentsLocked,
+			&post.CommentCount, &post.LikeCount, &post.ModerationStatus, &post.IsAnonymous,
+			&post.ImageID, &post.ImagePath, &post.ImageOrder)
 
 		if err != nil {
 			return nil, err
@@ -194,9 +342,81 @@ func (p *PostRepository) FetchAllPost(limit, offset, authorID int, orderBy, filt
 		return nil, err
 	}
 
+	sortPostImages(posts)
+
 	return posts, nil
 }
 
+// sortPostImages orders the image rows of every post by display_order while keeping the posts in their order,
+// the join with post_images can't be ordered in SQL without losing the ORDER BY of the posts
+func sortPostImages(posts []PostDetail) {
+	position := make(map[int]int)
+	for _, post := range posts {
+		if _, ok := position[post.ID]; !ok {
+			position[post.ID] = len(position)
+		}
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].ID != posts[j].ID {
+			return position[posts[i].ID] < position[posts[j].ID]
+		}
+		return posts[i].ImageOrder.Int32 < posts[j].ImageOrder.Int32
+	})
+}
+
+// FetchRelatedPosts returns other posts of the same category, most liked and then newest first.
+// Posts have no tags yet so the category is the only thing matched
+func (p *PostRepository) FetchRelatedPosts(postID, limit, viewerID int) ([]PostDetail, error) {
+	filter := fmt.Sprintf("AND p.category_id = (SELECT category_id FROM posts WHERE id = %d) AND p.id != %d", postID, postID)
+
+	return p.FetchAllPost(limit, 0, viewerID, "like_count DESC, p.created_at DESC", filter)
+}
+
+// FetchRandomPostID picks a random published post that isn't a questionnaire, from the category when categoryID isn't
+// 0. It draws a random id between the lowest and highest one and takes the first eligible post from there, wrapping
+// around to the lowest eligible id, so both lookups walk the primary key instead of sorting the whole table. Posts
+// after a gap of deleted or ineligible ids are a bit more likely to be picked. The viewer's own posts and the ones of
+// users they blocked are left out
+func (p *PostRepository) FetchRandomPostID(viewerID, categoryID int) (int, error) {
+	var pivot sql.NullInt64
+	err := p.db.QueryRow(`SELECT MIN(id) + ABS(RANDOM()) % (MAX(id) - MIN(id) + 1) FROM posts;`).Scan(&pivot)
+	if err != nil {
+		return 0, err
+	}
+	if !pivot.Valid {
+		return 0, ErrPostNotFound
+	}
+
+	filter := `
+		p.moderation_status = ? AND p.author_id != ?
+		AND p.author_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)
+		AND NOT EXISTS (SELECT 1 FROM questionnaires q WHERE q.post_id = p.id)`
+	args := []interface{}{ModerationApproved, viewerID, viewerID}
+	if categoryID != 0 {
+		filter += " AND p.category_id = ?"
+		args = append(args, categoryID)
+	}
+
+	var postID int
+	err = p.db.QueryRow(
+		`SELECT p.id FROM posts p WHERE p.id >= ? AND `+filter+` ORDER BY p.id LIMIT 1;`,
+		append([]interface{}{pivot.Int64}, args...)...,
+	).Scan(&postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = p.db.QueryRow(
+			`SELECT p.id FROM posts p WHERE p.id < ? AND `+filter+` ORDER BY p.id LIMIT 1;`,
+			append([]interface{}{pivot.Int64}, args...)...,
+		).Scan(&postID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPostNotFound
+	}
+
+	return postID, err
+}
+
+// FetchPostByID returns one row per image of the post, each with the comment and like counts
 func (p *PostRepository) FetchPostByID(postID, authorID int) ([]PostDetail, error) {
 	var (
 		posts        []PostDetail
@@ -206,7 +426,7 @@ func (p *PostRepository) FetchPostByID(postID, authorID int) ([]PostDetail, erro
 	sqlStatement = `
 		SELECT 
 			p.id as id,
-			(SELECT EXISTS (SELECT 1 FROM post_likes WHERE post_id = p.id AND user_id = ?)) AS is_like,
+			(SELECT EXISTS (SELECT 1 FROM post_reactions WHERE post_id = p.id AND user_id = ?)) AS is_like,
 			u.id as author_id,
 			u.name as author_name,
 			u.role as author_role,
@@ -218,13 +438,22 @@ func (p *PostRepository) FetchPostByID(postID, authorID int) ([]PostDetail, erro
 			p.title as title,
 			p.desc as desc,
 			p.created_at as created_at,
+			p.updated_at as updated_at,
+			p.is_pinned as is_pinned,
+			p.comments_locked as comments_locked,
+			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS comment_count,
+			(SELECT COUNT(*) FROM post_reactions WHERE post_id = p.id) AS like_count,
+			p.moderation_status as moderation_status,
+			p.is_anonymous as is_anonymous,
 			pi.id as image_id,
-			pi.path as image_path
+			pi.path as image_path,
+			pi.display_order as image_order
 		FROM posts p
 		INNER JOIN users u ON p.author_id = u.id
 		LEFT JOIN user_details ud ON u.id = ud.user_id
 		LEFT JOIN post_images pi ON p.id = pi.post_id
-		WHERE p.id = ?;
+		WHERE p.id = ? AND (p.moderation_status = ? OR p.author_id = ?)
+		ORDER BY pi.display_order, pi.id;
 	`
 
 	tx, err := p.db.Begin()
@@ -235,7 +464,7 @@ func (p *PostRepository) FetchPostByID(postID, authorID int) ([]PostDetail, erro
 
 	defer tx.Rollback()
 
-	rows, err := tx.Query(sqlStatement, authorID, postID)
+	rows, err := tx.Query(sqlStatement, authorID, postID, ModerationApproved, authorID)
 
 	if err != nil {
 		return nil, err
@@ -249,8 +478,9 @@ func (p *PostRepository) FetchPostByID(postID, authorID int) ([]PostDetail, erro
 			&post.ID, &post.IsLike,
 			&post.AuthorID, &post.AuthorName, &post.AuthorRole, &post.AuthorAvatar,
 			&post.AuthorInstitution, &post.AuthorMajor, &post.AuthorBatch,
-			&post.CategoryID, &post.Title, &post.Description, &post.CreatedAt,
-			&post.ImageID, &post.ImagePath)
+			&post.CategoryID, &post.Title, &post.Description, &post.CreatedAt, &post.UpdatedAt, &post.IsPinned,
+			&post.CommentsLocked, &post.CommentCount, &post.LikeCount, &post.ModerationStatus, &post.IsAnonymous,
+			&post.ImageID, &post.ImagePath, &post.ImageOrder)
 
 		if err != nil {
 			return nil, err
@@ -297,9 +527,100 @@ func (p *PostRepository) FetchAuthorIDByPostID(postID int) (int, error) {
 	return authorID, nil
 }
 
-func (p *PostRepository) UpdatePost(postID, categoryID int, title, description string) error {
+// FetchPostCategoryID is used to scope the category moderators to the posts of their categories
+func (p *PostRepository) FetchPostCategoryID(postID int) (int, error) {
+	var categoryID int
+	err := p.db.QueryRow(`SELECT category_id FROM posts WHERE id = ?;`, postID).Scan(&categoryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrPostNotFound
+	}
+	return categoryID, err
+}
+
+// FetchPostActivity only counts the comments and likes of a post, for clients polling for changes
+func (p *PostRepository) FetchPostActivity(postID int) (PostActivity, error) {
+	sqlStatement := `
+		SELECT
+			(SELECT COUNT(*) FROM comments WHERE post_id = p.id),
+			(SELECT COUNT(*) FROM post_reactions WHERE post_id = p.id),
+			p.created_at,
+			p.updated_at
+		FROM posts p
+		WHERE p.id = ?;
+	`
+
+	var (
+		activity  PostActivity
+		updatedAt sql.NullTime
+	)
+
+	err := p.db.QueryRow(sqlStatement, postID).Scan(&activity.CommentCount, &activity.LikeCount, &activity.UpdatedAt, &updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return PostActivity{}, ErrPostNotFound
+		}
+
+		return PostActivity{}, err
+	}
+
+	if updatedAt.Valid {
+		activity.UpdatedAt = updatedAt.Time
+	}
+
+	return activity, nil
+}
+
+// ReorderPostImages sets the display_order of the post images to their position in imageIDs
+func (p *PostRepository) ReorderPostImages(postID int, imageIDs []int) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM post_images WHERE post_id = ?;`, postID)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = true
+	}
+	rows.Close()
+
+	if len(imageIDs) != len(existing) {
+		return ErrInvalidImageOrder
+	}
+
+	seen := make(map[int]bool)
+	for _, id := range imageIDs {
+		if !existing[id] || seen[id] {
+			return ErrInvalidImageOrder
+		}
+		seen[id] = true
+	}
+
+	for order, id := range imageIDs {
+		if _, err := tx.Exec(`UPDATE post_images SET display_order = ? WHERE id = ?;`, order, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdatePost records the edit in post_revisions within the same transaction. The first edit of a post also records
+// the original version, attributed to its author, so the history always starts with what was first published
+func (p *PostRepository) UpdatePost(postID, editorID, categoryID int, title, description string) error {
 	sqlStatement := `
-		UPDATE posts SET category_id = ?, title = ?, desc = ? WHERE id = ?;
+		UPDATE posts SET category_id = ?, title = ?, desc = ?, updated_at = ? WHERE id = ?;
 	`
 
 	tx, err := p.db.Begin()
@@ -310,7 +631,26 @@ func (p *PostRepository) UpdatePost(postID, categoryID int, title, description s
 
 	defer tx.Rollback()
 
-	_, err = tx.Exec(sqlStatement, categoryID, title, description, postID)
+	now := time.Now()
+
+	_, err = tx.Exec(`
+		INSERT INTO post_revisions (post_id, editor_id, category_id, title, desc, created_at)
+		SELECT id, author_id, category_id, title, desc, created_at FROM posts
+		WHERE id = ?1 AND NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_id = ?1);`, postID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlStatement, categoryID, title, description, now, postID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO post_revisions (post_id, editor_id, category_id, title, desc, created_at)
+		SELECT id, ?, category_id, title, desc, ? FROM posts WHERE id = ?;`, editorID, now, postID)
 
 	if err != nil {
 		return err
@@ -346,9 +686,289 @@ func (p *PostRepository) DeletePostByID(postID int) error {
 		return err
 	}
 
+	_, err = tx.Exec(`DELETE FROM post_revisions WHERE post_id = ?;`, postID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FetchPostAuthorIDs maps each existing post id to its author in a single query, unknown ids are left out
+func (p *PostRepository) FetchPostAuthorIDs(postIDs []int) (map[int]int, error) {
+	authorIDs := make(map[int]int)
+	if len(postIDs) == 0 {
+		return authorIDs, nil
+	}
+
+	placeholders, args := inClause(postIDs)
+	rows, err := p.db.Query(fmt.Sprintf(`SELECT id, author_id FROM posts WHERE id IN (%s);`, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, authorID int
+		if err := rows.Scan(&postID, &authorID); err != nil {
+			return nil, err
+		}
+		authorIDs[postID] = authorID
+	}
+
+	return authorIDs, rows.Err()
+}
+
+// DeletePostsByID deletes the posts and their images in a single transaction, it returns the image files
+// that should be removed once the deletion is committed
+func (p *PostRepository) DeletePostsByID(postIDs []int) ([]string, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
+	tx, err := p.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	placeholders, args := inClause(postIDs)
+
+	rows, err := tx.Query(fmt.Sprintf(`SELECT path FROM post_images WHERE post_id IN (%s);`, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	imagePaths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		imagePaths = append(imagePaths, path)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM post_images WHERE post_id IN (%s);`, placeholders), args...); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM post_revisions WHERE post_id IN (%s);`, placeholders), args...); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM posts WHERE id IN (%s);`, placeholders), args...); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return imagePaths, nil
+}
+
+// FindRecentDuplicatePost returns the id of the latest post of the author created within the given time with the same
+// title and description, ignoring surrounding whitespace, or 0 when there isn't one
+func (p *PostRepository) FindRecentDuplicatePost(authorID int, title, desc string, within time.Duration) (int64, error) {
+	sqlStatement := `
+		SELECT id FROM posts
+		WHERE author_id = ? AND TRIM(title) = ? AND TRIM(desc) = ? AND created_at >= ?
+		ORDER BY id DESC
+		LIMIT 1;`
+
+	var id int64
+	err := p.db.QueryRow(sqlStatement, authorID, strings.TrimSpace(title), strings.TrimSpace(desc), time.Now().Add(-within)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// inClause returns the "?, ?, ?" placeholders and args for an IN (...) condition
+func inClause(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// PinPost fails with ErrPinLimitReached when the post's category already has MaxPinnedPostsPerCategory pinned posts.
+// Pinning an already pinned post is a no-op
+func (p *PostRepository) PinPost(postID int) error {
+	tx, err := p.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var (
+		categoryID int
+		isPinned   bool
+	)
+	err = tx.QueryRow(`SELECT category_id, is_pinned FROM posts WHERE id = ?;`, postID).Scan(&categoryID, &isPinned)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPostNotFound
+		}
+		return err
+	}
+
+	if isPinned {
+		return nil
+	}
+
+	var pinnedCount int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM posts WHERE category_id = ? AND is_pinned = 1;`, categoryID).Scan(&pinnedCount)
+	if err != nil {
+		return err
+	}
+
+	if pinnedCount >= MaxPinnedPostsPerCategory {
+		return ErrPinLimitReached
+	}
+
+	if _, err := tx.Exec(`UPDATE posts SET is_pinned = 1 WHERE id = ?;`, postID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *PostRepository) UnpinPost(postID int) error {
+	result, err := p.db.Exec(`UPDATE posts SET is_pinned = 0 WHERE id = ?;`, postID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
 		return err
 	}
 
+	if affected == 0 {
+		return ErrPostNotFound
+	}
+
 	return nil
 }
+
+// ToggleCommentsLock flips comments_locked of the post and returns the new value
+func (p *PostRepository) ToggleCommentsLock(postID int) (bool, error) {
+	tx, err := p.db.Begin()
+
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE posts SET comments_locked = NOT comments_locked WHERE id = ?;`, postID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected == 0 {
+		return false, ErrPostNotFound
+	}
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT comments_locked FROM posts WHERE id = ?;`, postID).Scan(&locked); err != nil {
+		return false, err
+	}
+
+	return locked, tx.Commit()
+}
+
+func (p *PostRepository) CommentsLocked(postID int) (bool, error) {
+	var locked bool
+	err := p.db.QueryRow(`SELECT comments_locked FROM posts WHERE id = ?;`, postID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, ErrPostNotFound
+	}
+	return locked, err
+}
+
+// FetchFollowingPostsAfter returns posts of followed users with an id greater than afterPostID, oldest first.
+// Anonymous posts are left out, they would tell the followers who wrote them
+func (p *PostRepository) FetchFollowingPostsAfter(userID, afterPostID, limit int) ([]FeedPost, error) {
+	sqlStatement := `
+		SELECT p.id, p.author_id, u.name, p.category_id, p.title, p.created_at
+		FROM posts p
+		INNER JOIN follows f ON f.following_id = p.author_id AND f.follower_id = ?
+		INNER JOIN users u ON u.id = p.author_id
+		LEFT JOIN questionnaires q ON q.post_id = p.id
+		WHERE q.post_id IS NULL AND p.id > ? AND p.moderation_status = ? AND p.is_anonymous = 0
+		ORDER BY p.id
+		LIMIT ?;
+	`
+
+	rows, err := p.db.Query(sqlStatement, userID, afterPostID, ModerationApproved, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []FeedPost{}
+	for rows.Next() {
+		var post FeedPost
+		err := rows.Scan(&post.ID, &post.AuthorID, &post.AuthorName, &post.CategoryID, &post.Title, &post.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+// FetchPostsByAuthor returns every post of the author without questionnaires, oldest first
+func (p *PostRepository) FetchPostsByAuthor(authorID int) ([]Post, error) {
+	sqlStatement := `
+		SELECT p.id, p.category_id, p.title, p.desc, p.created_at, p.updated_at
+		FROM posts p
+		LEFT JOIN questionnaires q ON q.post_id = p.id
+		WHERE q.post_id IS NULL AND p.author_id = ?
+		ORDER BY p.created_at;
+	`
+
+	rows, err := p.db.Query(sqlStatement, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(&post.ID, &post.CategoryID, &post.Title, &post.Description, &post.CreatedAt, &post.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,662 @@
+package repository_test
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Post Repository Test", func() {
+	var (
+		db       *sql.DB
+		postRepo *repository.PostRepository
+		likeRepo *repository.LikeRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+
+		// Seeds Radit (1), Bocil SMA (2), the categories and Post 1 with its comments
+		migration.Migrate(db)
+
+		postRepo = repository.NewPostRepository(db)
+		likeRepo = repository.NewLikeRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Describe("InsertPost", func() {
+		It("should return the id of the new post", func() {
+			postID, err := postRepo.InsertPost(2, 3, "Title", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(postID).To(BeEquivalentTo(2))
+
+			posts, err := postRepo.FetchPostByID(int(postID), 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].AuthorID).To(Equal(2))
+			Expect(posts[0].CategoryID).To(Equal(3))
+			Expect(posts[0].Title).To(Equal("Title"))
+			Expect(posts[0].Description).To(Equal("Description"))
+			Expect(posts[0].UpdatedAt.Valid).To(BeFalse())
+		})
+
+		When("the post is anonymous", func() {
+			It("should keep the author and leave the post out of the followers feed", func() {
+				_, err := db.Exec(`INSERT INTO follows (follower_id, following_id, created_at) VALUES (1, 2, datetime('now'));`)
+				Expect(err).ToNot(HaveOccurred())
+
+				postID, err := postRepo.InsertPost(2, 3, "Title", "Description", true)
+				Expect(err).ToNot(HaveOccurred())
+
+				posts, err := postRepo.FetchAllPost(10, 0, 1, "p.id", "AND p.id = ?", postID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(posts).To(HaveLen(1))
+				Expect(posts[0].AuthorID).To(Equal(2))
+				Expect(posts[0].IsAnonymous).To(BeTrue())
+
+				feed, err := postRepo.FetchFollowingPostsAfter(1, 0, 10)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(feed).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("InsertImportedPosts", func() {
+		It("should keep the original creation time and skip pre-moderation", func() {
+			postRepo.SetPreModeration(true)
+			createdAt := time.Date(2019, time.March, 4, 10, 30, 0, 0, time.UTC)
+
+			ids, err := postRepo.InsertImportedPosts([]repository.ImportedPost{
+				{AuthorID: 2, CategoryID: 3, Title: "First", Description: "Description", CreatedAt: createdAt},
+				{AuthorID: 1, CategoryID: 1, Title: "Second", Description: "Description", CreatedAt: createdAt.Add(time.Hour)},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ids).To(Equal([]int64{2, 3}))
+
+			posts, err := postRepo.FetchPostByID(2, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].Title).To(Equal("First"))
+			Expect(posts[0].CreatedAt.Equal(createdAt)).To(BeTrue())
+		})
+	})
+
+	Describe("FetchAllPostCompact", func() {
+		It("should return one row per post without images", func() {
+			postID, err := postRepo.InsertPostWithImages(2, 3, "Title", "Description", []repository.UploadedImage{
+				{Path: "media/post/a.png", OriginalName: "Beach A.png"},
+				{Path: "media/post/b.png", OriginalName: "b.png"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			var originalName string
+			Expect(db.QueryRow("SELECT original_name FROM post_images WHERE path = 'media/post/a.png'").Scan(&originalName)).To(Succeed())
+			Expect(originalName).To(Equal("Beach A.png"))
+
+			posts, err := postRepo.FetchAllPost(10, 0, 0, "p.id", "AND p.id = ?", postID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(2))
+
+			posts, err = postRepo.FetchAllPostCompact(10, 0, 0, "p.id", "AND p.id = ?", postID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].Title).To(Equal("Title"))
+			Expect(posts[0].ImageID.Valid).To(BeFalse())
+		})
+	})
+
+	Describe("Moderation", func() {
+		It("should only show pending posts to their author until they are approved", func() {
+			postRepo.SetPreModeration(true)
+
+			postID, err := postRepo.InsertPost(2, 3, "Pending", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "p.id", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+
+			posts, err = postRepo.FetchAllPost(10, 0, 2, "p.id", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(2))
+			Expect(posts[1].ModerationStatus).To(Equal(repository.ModerationPending))
+
+			posts, err = postRepo.FetchPostByID(int(postID), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(BeEmpty())
+
+			pending, err := postRepo.FetchPendingPosts(10, 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(HaveLen(1))
+			Expect(pending[0].ID).To(BeEquivalentTo(postID))
+
+			pending, err = postRepo.FetchPendingPosts(10, 0, []int{1, 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(BeEmpty())
+
+			pending, err = postRepo.FetchPendingPosts(10, 0, []int{3})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(HaveLen(1))
+
+			moderated, err := postRepo.SetModerationStatus(int(postID), repository.ModerationApproved)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(moderated.AuthorID).To(Equal(2))
+			Expect(moderated.PreviousStatus).To(Equal(repository.ModerationPending))
+
+			posts, err = postRepo.FetchPostByID(int(postID), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].ModerationStatus).To(Equal(repository.ModerationApproved))
+
+			pending, err = postRepo.FetchPendingPosts(10, 0, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending).To(BeEmpty())
+		})
+
+		It("should not reject an approved post", func() {
+			postID, err := postRepo.InsertPost(2, 3, "Title", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = postRepo.SetModerationStatus(int(postID), repository.ModerationRejected)
+			Expect(err).To(MatchError(repository.ErrPostApproved))
+
+			_, err = postRepo.SetModerationStatus(99, repository.ModerationApproved)
+			Expect(err).To(MatchError(repository.ErrPostNotFound))
+		})
+	})
+
+	Describe("FindRecentDuplicatePost", func() {
+		It("should find the latest post of the author with the same content within the time", func() {
+			postID, err := postRepo.InsertPost(2, 3, "Title", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			id, err := postRepo.FindRecentDuplicatePost(2, "  Title ", "Description\n", time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(Equal(postID))
+
+			for _, other := range []struct {
+				authorID    int
+				description string
+				within      time.Duration
+			}{
+				{1, "Description", time.Minute},
+				{2, "Other", time.Minute},
+				{2, "Description", -time.Minute},
+			} {
+				id, err := postRepo.FindRecentDuplicatePost(other.authorID, "Title", other.description, other.within)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id).To(BeZero())
+			}
+		})
+	})
+
+	Describe("FetchAllPost", func() {
+		It("should return the posts with their comment and like counts", func() {
+			postID, err := postRepo.InsertPost(2, 2, "Second", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: int(postID), UserID: 1})).To(Succeed())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: int(postID), UserID: 2})).To(Succeed())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "like_count DESC", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(2))
+
+			Expect(posts[0].ID).To(BeEquivalentTo(postID))
+			Expect(posts[0].LikeCount).To(Equal(2))
+			Expect(posts[0].CommentCount).To(Equal(0))
+			Expect(posts[0].IsLike).To(BeTrue())
+
+			Expect(posts[1].ID).To(Equal(1))
+			Expect(posts[1].LikeCount).To(Equal(0))
+			Expect(posts[1].CommentCount).To(Equal(7))
+			Expect(posts[1].IsLike).To(BeFalse())
+		})
+
+		It("should apply the filter with its args", func() {
+			_, err := postRepo.InsertPost(2, 2, "Second", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "AND p.category_id = ?", 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].Title).To(Equal("Second"))
+		})
+
+		It("should filter on the role of the author", func() {
+			_, err := postRepo.InsertPost(2, 1, "By a siswa", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = postRepo.InsertPost(2, 1, "Anonymous siswa", "Description", true)
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "AND u.role = ? AND p.is_anonymous = 0", "siswa")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].Title).To(Equal("By a siswa"))
+		})
+
+		It("should filter on the lecturer role", func() {
+			_, err := repository.NewUserRepository(db).UpdateUserRole(3, 2, repository.RoleLecturer)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = postRepo.InsertPost(2, 1, "By a lecturer", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "AND u.role = ? AND p.is_anonymous = 0", repository.RoleLecturer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].Title).To(Equal("By a lecturer"))
+		})
+
+		It("should filter on the comment count", func() {
+			_, err := postRepo.InsertPost(2, 1, "Unanswered", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "AND p.comment_count = 0")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].Title).To(Equal("Unanswered"))
+			Expect(posts[0].CommentCount).To(BeZero())
+		})
+
+		It("should leave out questionnaires", func() {
+			_, err := repository.NewQuestionnaireRepository(db).InsertQuestionnaire(repository.Questionnaire{
+				Author:      repository.User{Id: 1},
+				Category:    repository.Category{ID: 1},
+				Title:       "Questionnaire",
+				Description: "Description",
+				Link:        "https://forms.gle/abc",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].ID).To(Equal(1))
+		})
+	})
+
+	Describe("FetchRandomPostID", func() {
+		It("should only pick published posts of others in the category", func() {
+			_, err := repository.NewQuestionnaireRepository(db).InsertQuestionnaire(repository.Questionnaire{
+				Author:      repository.User{Id: 2},
+				Category:    repository.Category{ID: 1},
+				Title:       "Questionnaire",
+				Description: "Description",
+				Link:        "https://forms.gle/abc",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			postID, err := postRepo.InsertPost(2, 3, "Title", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				randomID, err := postRepo.FetchRandomPostID(1, 0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(randomID).To(BeEquivalentTo(postID))
+			}
+
+			_, err = postRepo.FetchRandomPostID(1, 1)
+			Expect(err).To(MatchError(repository.ErrPostNotFound))
+
+			randomID, err := postRepo.FetchRandomPostID(0, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(randomID).To(Equal(1))
+		})
+
+		It("should wrap around to the lowest eligible post", func() {
+			// Post 1 and the next one are Radit's, followed by a long run of the viewer's own posts
+			lastEligibleID, err := postRepo.InsertPost(1, 1, "Title", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 20; i++ {
+				_, err := postRepo.InsertPost(2, 1, "Own", "Description", false)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			picks := map[int]int{}
+			for i := 0; i < 50; i++ {
+				randomID, err := postRepo.FetchRandomPostID(2, 0)
+				Expect(err).ToNot(HaveOccurred())
+				picks[randomID]++
+			}
+			Expect(picks[1]).To(BeNumerically(">", picks[int(lastEligibleID)]))
+		})
+	})
+
+	Describe("FetchPostByID", func() {
+		When("post has images", func() {
+			It("should return one row per image", func() {
+				Expect(postRepo.InsertPostImage(1, "media/post/a.png", "photo.png")).To(Succeed())
+				Expect(postRepo.InsertPostImage(1, "media/post/b.png", "photo.png")).To(Succeed())
+
+				posts, err := postRepo.FetchPostByID(1, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(posts).To(HaveLen(2))
+				Expect(posts[0].ImagePath.String).To(Equal("media/post/a.png"))
+				Expect(posts[1].ImagePath.String).To(Equal("media/post/b.png"))
+			})
+		})
+
+		It("should return the same counts as FetchAllPost", func() {
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: 1, UserID: 2})).To(Succeed())
+			Expect(postRepo.InsertPostImage(1, "media/post/a.png", "photo.png")).To(Succeed())
+			Expect(postRepo.InsertPostImage(1, "media/post/b.png", "photo.png")).To(Succeed())
+
+			listed, err := postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed).ToNot(BeEmpty())
+
+			posts, err := postRepo.FetchPostByID(1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(2))
+			for _, post := range posts {
+				Expect(post.CommentCount).To(Equal(listed[0].CommentCount))
+				Expect(post.LikeCount).To(Equal(listed[0].LikeCount))
+			}
+			Expect(posts[0].CommentCount).To(Equal(7))
+			Expect(posts[0].LikeCount).To(Equal(1))
+		})
+
+		When("post doesn't exist", func() {
+			It("should return no rows", func() {
+				posts, err := postRepo.FetchPostByID(99, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(posts).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("UpdatePost", func() {
+		It("should update the post and set updated_at", func() {
+			Expect(postRepo.UpdatePost(1, 1, 2, "Updated", "Updated Description")).To(Succeed())
+
+			posts, err := postRepo.FetchPostByID(1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].CategoryID).To(Equal(2))
+			Expect(posts[0].Title).To(Equal("Updated"))
+			Expect(posts[0].Description).To(Equal("Updated Description"))
+			Expect(posts[0].UpdatedAt.Valid).To(BeTrue())
+		})
+
+		It("should record the original post and every edit as revisions", func() {
+			revisions, err := postRepo.FetchPostRevisions(1, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revisions).To(BeEmpty())
+
+			original, err := postRepo.FetchPostByID(1, 1)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(postRepo.UpdatePost(1, 1, 2, "Updated", "Updated Description")).To(Succeed())
+			Expect(postRepo.UpdatePost(1, 1, 2, "Updated Again", "Updated Description")).To(Succeed())
+
+			revisions, err = postRepo.FetchPostRevisions(1, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revisions).To(HaveLen(3))
+			Expect(revisions[0].Title).To(Equal("Updated Again"))
+			Expect(revisions[1].Title).To(Equal("Updated"))
+			Expect(revisions[1].CategoryID).To(Equal(2))
+			Expect(revisions[2].Title).To(Equal(original[0].Title))
+			Expect(revisions[2].Description).To(Equal(original[0].Description))
+			Expect(revisions[2].CreatedAt).To(BeTemporally("==", original[0].CreatedAt))
+			for _, revision := range revisions {
+				Expect(revision.EditorID).To(Equal(1))
+				Expect(revision.EditorName).To(Equal("Radit"))
+			}
+
+			revisions, err = postRepo.FetchPostRevisions(1, 1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revisions).To(HaveLen(1))
+			Expect(revisions[0].Title).To(Equal("Updated"))
+		})
+	})
+
+	Describe("DeletePostByID", func() {
+		It("should delete the post along with its images", func() {
+			postID, err := postRepo.InsertPostWithImages(1, 1, "Title", "Description", []repository.UploadedImage{{Path: "media/post/a.png", OriginalName: "a.png"}, {Path: "media/post/b.png", OriginalName: "b.png"}})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(postRepo.DeletePostByID(int(postID))).To(Succeed())
+
+			posts, err := postRepo.FetchPostByID(int(postID), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(BeEmpty())
+
+			var imageCount int
+			Expect(db.QueryRow("SELECT COUNT(*) FROM post_images WHERE post_id = ?", postID).Scan(&imageCount)).To(Succeed())
+			Expect(imageCount).To(Equal(0))
+
+			_, err = postRepo.FetchAuthorIDByPostID(int(postID))
+			Expect(err).To(MatchError(repository.ErrPostNotFound))
+		})
+	})
+
+	Describe("MergePost", func() {
+		It("should move the comments and likes to the target and delete the source", func() {
+			targetID, err := postRepo.InsertPost(2, 1, "Target", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: 1, UserID: 2})).To(Succeed())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: 1, UserID: 3})).To(Succeed())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: int(targetID), UserID: 2})).To(Succeed())
+
+			merged, err := postRepo.MergePost(1, int(targetID))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(merged.SourceAuthorID).To(Equal(1))
+			Expect(merged.TargetAuthorID).To(Equal(2))
+			Expect(merged.MovedComments).To(Equal(7))
+			Expect(merged.MovedLikes).To(Equal(1))
+
+			posts, err := postRepo.FetchPostByID(int(targetID), 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts[0].CommentCount).To(Equal(7))
+			// User 2 liked both posts, the like is kept once
+			Expect(posts[0].LikeCount).To(Equal(2))
+
+			_, err = postRepo.FetchAuthorIDByPostID(1)
+			Expect(err).To(MatchError(repository.ErrPostNotFound))
+		})
+
+		It("should not merge a post into itself or into a missing post", func() {
+			_, err := postRepo.MergePost(1, 1)
+			Expect(err).To(MatchError(repository.ErrMergeSamePost))
+
+			_, err = postRepo.MergePost(1, 99)
+			Expect(err).To(MatchError(repository.ErrMergeTargetNotFound))
+
+			comments, err := repository.NewCommentRepository(db).CountComment(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comments).To(Equal(7))
+		})
+	})
+
+	Describe("ReorderPostImages", func() {
+		BeforeEach(func() {
+			Expect(postRepo.InsertPostImage(1, "media/post/a.png", "photo.png")).To(Succeed())
+			Expect(postRepo.InsertPostImage(1, "media/post/b.png", "photo.png")).To(Succeed())
+			Expect(postRepo.InsertPostImage(1, "media/post/c.png", "photo.png")).To(Succeed())
+		})
+
+		imagePaths := func(posts []repository.PostDetail) []string {
+			paths := make([]string, 0)
+			for _, post := range posts {
+				if post.ID == 1 {
+					paths = append(paths, post.ImagePath.String)
+				}
+			}
+			return paths
+		}
+
+		It("should order the images of FetchPostByID and FetchAllPost", func() {
+			Expect(postRepo.ReorderPostImages(1, []int{3, 1, 2})).To(Succeed())
+
+			posts, err := postRepo.FetchPostByID(1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(imagePaths(posts)).To(Equal([]string{"media/post/c.png", "media/post/a.png", "media/post/b.png"}))
+
+			posts, err = postRepo.FetchAllPost(10, 0, 1, "created_at DESC", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(imagePaths(posts)).To(Equal([]string{"media/post/c.png", "media/post/a.png", "media/post/b.png"}))
+		})
+
+		It("should show new images after the reordered ones", func() {
+			Expect(postRepo.ReorderPostImages(1, []int{3, 2, 1})).To(Succeed())
+			Expect(postRepo.InsertPostImage(1, "media/post/d.png", "photo.png")).To(Succeed())
+
+			posts, err := postRepo.FetchPostByID(1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(imagePaths(posts)).To(Equal([]string{"media/post/c.png", "media/post/b.png", "media/post/a.png", "media/post/d.png"}))
+		})
+
+		When("image ids aren't every image of the post exactly once", func() {
+			It("should return ErrInvalidImageOrder", func() {
+				Expect(postRepo.ReorderPostImages(1, []int{3, 1})).To(MatchError(repository.ErrInvalidImageOrder))
+				Expect(postRepo.ReorderPostImages(1, []int{3, 1, 1})).To(MatchError(repository.ErrInvalidImageOrder))
+				Expect(postRepo.ReorderPostImages(1, []int{3, 1, 99})).To(MatchError(repository.ErrInvalidImageOrder))
+			})
+		})
+	})
+
+	Describe("FetchPostActivity", func() {
+		It("should return the comment and like counts", func() {
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: 1, UserID: 2})).To(Succeed())
+
+			activity, err := postRepo.FetchPostActivity(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(activity.CommentCount).To(Equal(7))
+			Expect(activity.LikeCount).To(Equal(1))
+			Expect(activity.UpdatedAt.IsZero()).To(BeFalse())
+		})
+
+		When("post doesn't exist", func() {
+			It("should return ErrPostNotFound", func() {
+				_, err := postRepo.FetchPostActivity(99)
+				Expect(err).To(MatchError(repository.ErrPostNotFound))
+			})
+		})
+	})
+
+	Describe("ToggleCommentsLock", func() {
+		It("should flip comments_locked and return the new value", func() {
+			locked, err := postRepo.ToggleCommentsLock(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeTrue())
+
+			posts, err := postRepo.FetchPostByID(1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts[0].CommentsLocked).To(BeTrue())
+
+			locked, err = postRepo.ToggleCommentsLock(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeFalse())
+
+			locked, err = postRepo.CommentsLocked(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(locked).To(BeFalse())
+		})
+
+		When("post doesn't exist", func() {
+			It("should return ErrPostNotFound", func() {
+				_, err := postRepo.ToggleCommentsLock(99)
+				Expect(err).To(MatchError(repository.ErrPostNotFound))
+
+				_, err = postRepo.CommentsLocked(99)
+				Expect(err).To(MatchError(repository.ErrPostNotFound))
+			})
+		})
+	})
+
+	Describe("FetchRelatedPosts", func() {
+		It("should return other posts of the same category", func() {
+			sameID, err := postRepo.InsertPost(2, 1, "Same Category", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = postRepo.InsertPost(2, 2, "Other Category", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			posts, err := postRepo.FetchRelatedPosts(1, 5, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(HaveLen(1))
+			Expect(posts[0].ID).To(BeEquivalentTo(sameID))
+		})
+	})
+
+	Describe("SavedSearches", func() {
+		It("should keep the searches of each user apart", func() {
+			search, err := postRepo.InsertSavedSearch(1, "Go", "search_title=go")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = postRepo.InsertSavedSearch(2, "Mine", "me=true")
+			Expect(err).ToNot(HaveOccurred())
+
+			searches, err := postRepo.FetchSavedSearches(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(searches).To(HaveLen(1))
+			Expect(searches[0].Query).To(Equal("search_title=go"))
+
+			_, err = postRepo.FetchSavedSearch(search.ID, 2)
+			Expect(err).To(MatchError(repository.ErrSavedSearchNotFound))
+			Expect(postRepo.DeleteSavedSearch(search.ID, 2)).To(MatchError(repository.ErrSavedSearchNotFound))
+
+			Expect(postRepo.DeleteSavedSearch(search.ID, 1)).To(Succeed())
+			searches, err = postRepo.FetchSavedSearches(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(searches).To(BeEmpty())
+		})
+
+		It("should refuse more than MaxSavedSearches", func() {
+			for i := 0; i < repository.MaxSavedSearches; i++ {
+				_, err := postRepo.InsertSavedSearch(1, "Search", "category_id=1")
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			_, err := postRepo.InsertSavedSearch(1, "One too many", "category_id=1")
+			Expect(err).To(MatchError(repository.ErrTooManySavedSearches))
+		})
+	})
+
+	Describe("UndoPost", func() {
+		It("should delete a new post with its likes and notifications", func() {
+			postID, err := postRepo.InsertPost(2, 1, "Oops", "Description", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: int(postID), UserID: 1})).To(Succeed())
+
+			notifRepo := repository.NewNotificationRepository(db)
+			Expect(notifRepo.CreateNotification(1, 2, repository.NotifTypeNewPost, int(postID))).To(Succeed())
+
+			_, err = postRepo.UndoPost(int(postID), 1, time.Minute)
+			Expect(err).To(MatchError(repository.ErrNotPostAuthor))
+
+			paths, err := postRepo.UndoPost(int(postID), 2, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(paths).To(BeEmpty())
+
+			_, err = postRepo.FetchAuthorIDByPostID(int(postID))
+			Expect(err).To(MatchError(repository.ErrPostNotFound))
+
+			var remaining int
+			Expect(db.QueryRow(`SELECT (SELECT COUNT(*) FROM post_reactions WHERE post_id = ?1) + (SELECT COUNT(*) FROM notifications WHERE target_id = ?1)`, postID).Scan(&remaining)).To(Succeed())
+			Expect(remaining).To(BeZero())
+		})
+
+		It("should refuse once the window has passed", func() {
+			_, err := db.Exec(`UPDATE posts SET created_at = ? WHERE id = 1;`, time.Now().Add(-2*time.Minute))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = postRepo.UndoPost(1, 1, time.Minute)
+			Expect(err).To(MatchError(repository.ErrUndoWindowExpired))
+
+			_, err = postRepo.UndoPost(99, 1, time.Minute)
+			Expect(err).To(MatchError(repository.ErrPostNotFound))
+		})
+	})
+})
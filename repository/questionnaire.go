@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -18,7 +19,9 @@ func NewQuestionnaireRepository(db *sql.DB) *QuestionnaireRepository {
 	}
 }
 
-func (q *QuestionnaireRepository) ReadAllQuestionnaires(userID int, filter, sortBy string) ([]Questionnaire, error) {
+// ReadAllQuestionnaires returns every questionnaire matching the filter when limit is -1. The ? placeholders of
+// the filter and then of sortBy are bound to args
+func (q *QuestionnaireRepository) ReadAllQuestionnaires(userID int, filter, sortBy string, limit, offset int, args ...interface{}) ([]Questionnaire, error) {
 	sqlStmt := fmt.Sprintf(
 		`
 	SELECT
@@ -31,27 +34,29 @@ func (q *QuestionnaireRepository) ReadAllQuestionnaires(userID int, filter, sort
 		ud.institute,
 		ud.major,
 		ud.batch,
-		c.*,
+		c.id,
+		c.name,
 		p.title,
 		p.desc,
 		p.created_at,
 		q.link,
 		q.reward,
-		(SELECT COUNT(*) FROM post_likes WHERE post_id = p.id) AS total_like,
+		(SELECT COUNT(*) FROM post_reactions WHERE post_id = p.id) AS total_like,
 		(SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS total_comment,
-		(SELECT EXISTS (SELECT 1 FROM post_likes WHERE post_id = p.id AND user_id = %d)) AS is_like
+		(SELECT EXISTS (SELECT 1 FROM post_reactions WHERE post_id = p.id AND user_id = %d)) AS is_like
 	FROM posts p
 	LEFT JOIN users u ON p.author_id = u.id
 	LEFT JOIN user_details ud ON u.id = ud.user_id
 	LEFT JOIN categories c ON p.category_id = c.id
 	INNER JOIN questionnaires q ON p.id = q.post_id
 	WHERE %s
-	ORDER BY %s;`,
+	ORDER BY %s
+	LIMIT ? OFFSET ?;`,
 		userID,
 		filter,
 		sortBy)
 
-	rows, err := q.db.Query(sqlStmt)
+	rows, err := q.db.Query(sqlStmt, append(args, limit, offset)...)
 	if err != nil {
 		return nil, err
 	}
@@ -93,9 +98,40 @@ func (q *QuestionnaireRepository) ReadAllQuestionnaires(userID int, filter, sort
 		questionnaires = append(questionnaires, questionnaire)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := q.attachImages(questionnaires); err != nil {
+		return nil, err
+	}
+
 	return questionnaires, nil
 }
 
+// questionnaireRewardBoostDays is how much newer a questionnaire offering a reward ranks in the questionnaire feed
+const questionnaireRewardBoostDays = 3
+
+// affinityCategories are the categories the user posted in or reacted to a post of
+const affinityCategories = `
+	SELECT category_id FROM posts WHERE author_id = ?
+	UNION
+	SELECT ap.category_id FROM post_reactions r INNER JOIN posts ap ON ap.id = r.post_id WHERE r.user_id = ?`
+
+// FetchQuestionnaireFeed returns the questionnaires of the categories the user engages with, or of every category
+// while they haven't engaged with any. Their own questionnaires, the ones they already answered in the comments and
+// those of users they blocked are left out. The newest come first, a reward counts as questionnaireRewardBoostDays newer
+func (q *QuestionnaireRepository) FetchQuestionnaireFeed(userID, limit, offset int) ([]Questionnaire, error) {
+	filter := fmt.Sprintf(`p.author_id != ?
+		AND NOT EXISTS (SELECT 1 FROM comments cm WHERE cm.post_id = p.id AND cm.author_id = ?)
+		AND NOT EXISTS (SELECT 1 FROM blocks b WHERE b.blocker_id = ? AND b.blocked_id = p.author_id)
+		AND (p.category_id IN (%[1]s) OR NOT EXISTS (%[1]s))`, affinityCategories)
+	sortBy := "julianday(p.created_at) + (CASE WHEN TRIM(COALESCE(q.reward, '')) != '' THEN ? ELSE 0 END) DESC, p.id DESC"
+
+	return q.ReadAllQuestionnaires(userID, filter, sortBy, limit, offset,
+		userID, userID, userID, userID, userID, userID, userID, questionnaireRewardBoostDays)
+}
+
 func (q *QuestionnaireRepository) ReadAllQuestionnaireByID(userID, postID int) (Questionnaire, error) {
 	sqlStmt := `
 	SELECT
@@ -108,15 +144,16 @@ func (q *QuestionnaireRepository) ReadAllQuestionnaireByID(userID, postID int) (
 		ud.institute,
 		ud.major,
 		ud.batch,
-		c.*,
+		c.id,
+		c.name,
 		p.title,
 		p.desc,
 		p.created_at,
 		q.link,
 		q.reward,
-		(SELECT COUNT(*) FROM post_likes WHERE post_id = p.id) AS total_like,
+		(SELECT COUNT(*) FROM post_reactions WHERE post_id = p.id) AS total_like,
 		(SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS total_comment,
-		(SELECT EXISTS (SELECT 1 FROM post_likes WHERE post_id = p.id AND user_id = ?)) AS is_like
+		(SELECT EXISTS (SELECT 1 FROM post_reactions WHERE post_id = p.id AND user_id = ?)) AS is_like
 	FROM posts p
 	LEFT JOIN users u ON p.author_id = u.id
 	LEFT JOIN user_details ud ON u.id = ud.user_id
@@ -155,16 +192,47 @@ func (q *QuestionnaireRepository) ReadAllQuestionnaireByID(userID, postID int) (
 		if questionnaire.Author.Id == userID {
 			questionnaire.IsAuthor = true
 		}
-		return questionnaire, nil
+
+		questionnaires := []Questionnaire{questionnaire}
+		if err := q.attachImages(questionnaires); err != nil {
+			return Questionnaire{}, err
+		}
+		return questionnaires[0], nil
 	default:
 		return Questionnaire{}, err
 	}
 }
 
-func (q QuestionnaireRepository) InsertQuestionnaire(questionnaire Questionnaire) error {
+// QuestionnaireTitleExists compares the titles trimmed and case-insensitively, in Go since SQLite's LOWER only knows ASCII
+func (q *QuestionnaireRepository) QuestionnaireTitleExists(authorID int, title string) (bool, error) {
+	rows, err := q.db.Query(
+		"SELECT p.title FROM posts p INNER JOIN questionnaires q ON p.id = q.post_id WHERE p.author_id = ?;",
+		authorID,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	title = strings.TrimSpace(title)
+	for rows.Next() {
+		var existing string
+		if err := rows.Scan(&existing); err != nil {
+			return false, err
+		}
+
+		if strings.EqualFold(strings.TrimSpace(existing), title) {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+func (q QuestionnaireRepository) InsertQuestionnaire(questionnaire Questionnaire) (int64, error) {
 	tx, err := q.db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	defer tx.Rollback()
@@ -178,12 +246,12 @@ func (q QuestionnaireRepository) InsertQuestionnaire(questionnaire Questionnaire
 		time.Now(),
 	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	_, err = tx.Exec(
@@ -193,14 +261,14 @@ func (q QuestionnaireRepository) InsertQuestionnaire(questionnaire Questionnaire
 		questionnaire.Reward,
 	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if err = tx.Commit(); err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return id, nil
 }
 
 func (q QuestionnaireRepository) UpdateQuestionnaire(questionnaire Questionnaire) error {
@@ -239,20 +307,53 @@ func (q QuestionnaireRepository) UpdateQuestionnaire(questionnaire Questionnaire
 	return nil
 }
 
-func (q QuestionnaireRepository) DeleteQuestionnaire(postID int) error {
+// DeleteQuestionnaire returns the paths of the images that were attached, for the caller to remove the files
+func (q QuestionnaireRepository) DeleteQuestionnaire(postID int) ([]string, error) {
 	tx, err := q.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer tx.Rollback()
 
+	rows, err := tx.Query("SELECT path FROM questionnaire_images WHERE questionnaire_id = ?;", postID)
+	if err != nil {
+		return nil, err
+	}
+
+	imagePaths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		imagePaths = append(imagePaths, path)
+	}
+	rows.Close()
+
+	_, err = tx.Exec(
+		"DELETE FROM questionnaire_images WHERE questionnaire_id = ?;",
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM post_revisions WHERE post_id = ?;",
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = tx.Exec(
 		"DELETE FROM posts WHERE id = ?;",
 		postID,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	_, err = tx.Exec(
@@ -260,12 +361,77 @@ func (q QuestionnaireRepository) DeleteQuestionnaire(postID int) error {
 		postID,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return imagePaths, nil
+}
+
+// New images are shown after the existing ones, like the images of posts
+// InsertQuestionnaireImage is retried when the database is locked, the images of an upload are inserted concurrently
+func (q QuestionnaireRepository) InsertQuestionnaireImage(questionnaireID int, path, originalName string) error {
+	return retryOnBusy(func() error {
+		_, err := q.db.Exec(`
+			INSERT INTO questionnaire_images (questionnaire_id, path, original_name, display_order)
+			VALUES (?1, ?2, ?3, (SELECT COALESCE(MAX(display_order) + 1, 0) FROM questionnaire_images WHERE questionnaire_id = ?1));`,
+			questionnaireID, path, originalName)
+		return err
+	})
+}
+
+func (q QuestionnaireRepository) QuestionnaireImageExists(path string) (bool, error) {
+	var exists bool
+	err := q.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM questionnaire_images WHERE path = ?);`, path).Scan(&exists)
+	return exists, err
+}
+
+// attachImages loads the images of every questionnaire in one query, the ones without images get an empty list
+func (q QuestionnaireRepository) attachImages(questionnaires []Questionnaire) error {
+	if len(questionnaires) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(questionnaires))
+	for i := range questionnaires {
+		ids[i] = questionnaires[i].ID
+	}
+	placeholders, args := inClause(ids)
+
+	rows, err := q.db.Query(fmt.Sprintf(`
+		SELECT questionnaire_id, id, path FROM questionnaire_images
+		WHERE questionnaire_id IN (%s)
+		ORDER BY display_order, id;`, placeholders), args...)
+	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	images := make(map[int][]QuestionnaireImage)
+	for rows.Next() {
+		var (
+			questionnaireID int
+			image           QuestionnaireImage
+		)
+		if err := rows.Scan(&questionnaireID, &image.ID, &image.URL); err != nil {
+			return err
+		}
+		images[questionnaireID] = append(images[questionnaireID], image)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range questionnaires {
+		questionnaires[i].Images = images[questionnaires[i].ID]
+		if questionnaires[i].Images == nil {
+			questionnaires[i].Images = []QuestionnaireImage{}
+		}
+	}
 
 	return nil
 }
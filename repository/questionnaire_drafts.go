@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrDraftNotFound = errors.New("draft not found")
+
+// QuestionnaireDraft holds the fields of a questionnaire that isn't published yet, any of them may still be empty
+type QuestionnaireDraft struct {
+	CategoryID  *int      `json:"category_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Link        string    `json:"link"`
+	Reward      string    `json:"reward"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SaveQuestionnaireDraft replaces the draft of the author, only the latest one is kept
+func (q *QuestionnaireRepository) SaveQuestionnaireDraft(authorID int, draft QuestionnaireDraft) (QuestionnaireDraft, error) {
+	draft.UpdatedAt = time.Now().UTC()
+
+	_, err := q.db.Exec(`
+		INSERT INTO questionnaire_drafts (author_id, category_id, title, description, link, reward, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (author_id) DO UPDATE SET
+			category_id = excluded.category_id,
+			title = excluded.title,
+			description = excluded.description,
+			link = excluded.link,
+			reward = excluded.reward,
+			updated_at = excluded.updated_at;
+	`, authorID, draft.CategoryID, draft.Title, draft.Description, draft.Link, draft.Reward, draft.UpdatedAt)
+
+	return draft, err
+}
+
+func (q *QuestionnaireRepository) FetchQuestionnaireDraft(authorID int) (QuestionnaireDraft, error) {
+	var (
+		draft      QuestionnaireDraft
+		categoryID sql.NullInt64
+	)
+
+	err := q.db.QueryRow(`
+		SELECT category_id, title, description, link, reward, updated_at
+		FROM questionnaire_drafts
+		WHERE author_id = ?;
+	`, authorID).Scan(&categoryID, &draft.Title, &draft.Description, &draft.Link, &draft.Reward, &draft.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return draft, ErrDraftNotFound
+	}
+	if err != nil {
+		return draft, err
+	}
+
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		draft.CategoryID = &id
+	}
+
+	return draft, nil
+}
+
+func (q *QuestionnaireRepository) DeleteQuestionnaireDraft(authorID int) error {
+	_, err := q.db.Exec(`DELETE FROM questionnaire_drafts WHERE author_id = ?;`, authorID)
+	return err
+}
@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Questionnaire Draft Repository Test", func() {
+	var (
+		db                *sql.DB
+		questionnaireRepo *repository.QuestionnaireRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		questionnaireRepo = repository.NewQuestionnaireRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	It("should keep only the latest draft of the author", func() {
+		_, err := questionnaireRepo.FetchQuestionnaireDraft(2)
+		Expect(err).To(MatchError(repository.ErrDraftNotFound))
+
+		categoryID := 3
+		_, err = questionnaireRepo.SaveQuestionnaireDraft(2, repository.QuestionnaireDraft{CategoryID: &categoryID, Title: "First"})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = questionnaireRepo.SaveQuestionnaireDraft(2, repository.QuestionnaireDraft{Title: "Second", Link: "https://example.com"})
+		Expect(err).ToNot(HaveOccurred())
+
+		draft, err := questionnaireRepo.FetchQuestionnaireDraft(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(draft.CategoryID).To(BeNil())
+		Expect(draft.Title).To(Equal("Second"))
+		Expect(draft.Link).To(Equal("https://example.com"))
+
+		Expect(questionnaireRepo.DeleteQuestionnaireDraft(2)).To(Succeed())
+
+		_, err = questionnaireRepo.FetchQuestionnaireDraft(2)
+		Expect(err).To(MatchError(repository.ErrDraftNotFound))
+	})
+})
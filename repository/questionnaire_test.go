@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Questionnaire Repository Test", func() {
+	var (
+		db                *sql.DB
+		questionnaireRepo *repository.QuestionnaireRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		questionnaireRepo = repository.NewQuestionnaireRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Describe("FetchQuestionnaireFeed", func() {
+		insertQuestionnaire := func(authorID, categoryID int, title, reward string) int {
+			id, err := questionnaireRepo.InsertQuestionnaire(repository.Questionnaire{
+				Author:   repository.User{Id: authorID},
+				Category: repository.Category{ID: categoryID},
+				Title:    title,
+				Link:     "https://forms.example.com/" + title,
+				Reward:   reward,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			return int(id)
+		}
+
+		feedTitles := func(userID int) []string {
+			questionnaires, err := questionnaireRepo.FetchQuestionnaireFeed(userID, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			titles := []string{}
+			for _, questionnaire := range questionnaires {
+				titles = append(titles, questionnaire.Title)
+			}
+			return titles
+		}
+
+		It("should only keep the categories the user engages with and leave out the answered ones", func() {
+			insertQuestionnaire(1, 2, "Economy", "")
+			sports := insertQuestionnaire(1, 3, "Sports", "")
+			rewarded := insertQuestionnaire(1, 3, "Rewarded", "Gopay 10k")
+			insertQuestionnaire(1, 2, "Newest", "")
+
+			// Without engagement every category is relevant, the reward outranks the newer questionnaires
+			Expect(feedTitles(2)).To(Equal([]string{"Rewarded", "Newest", "Sports", "Economy"}))
+
+			_, err := db.Exec("INSERT INTO post_reactions (post_id, user_id, reaction) VALUES (?, 2, 'like')", sports)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(feedTitles(2)).To(Equal([]string{"Rewarded", "Sports"}))
+
+			// Posting in a category is engaging with it too, but the user's own questionnaires aren't in their feed
+			insertQuestionnaire(2, 3, "Own", "")
+			Expect(feedTitles(2)).To(Equal([]string{"Rewarded", "Sports"}))
+
+			_, err = db.Exec("INSERT INTO comments (post_id, author_id, comment, created_at) VALUES (?, 2, 'Done!', datetime('now'))", rewarded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(feedTitles(2)).To(Equal([]string{"Sports"}))
+
+			_, err = db.Exec("INSERT INTO blocks (blocker_id, blocked_id, created_at) VALUES (2, 1, datetime('now'))")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(feedTitles(2)).To(BeEmpty())
+
+			Expect(feedTitles(3)).To(Equal([]string{"Rewarded", "Own", "Newest", "Sports", "Economy"}))
+		})
+	})
+})
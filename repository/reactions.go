@@ -0,0 +1,109 @@
+package repository
+
+import "time"
+
+const (
+	ReactionLike  = "like"
+	ReactionLove  = "love"
+	ReactionHaha  = "haha"
+	ReactionWow   = "wow"
+	ReactionSad   = "sad"
+	ReactionAngry = "angry"
+)
+
+// Reactions are the reactions a post accepts, in the order clients show them: 👍 ❤️ 😂 😮 😢 😡.
+// A like from the likes endpoints is stored as ReactionLike
+var Reactions = []string{ReactionLike, ReactionLove, ReactionHaha, ReactionWow, ReactionSad, ReactionAngry}
+
+func IsReaction(reaction string) bool {
+	for _, r := range Reactions {
+		if r == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// PostReactions has a count for every reaction, LikeCount is their total so it matches like_count of the posts
+type PostReactions struct {
+	Counts         map[string]int `json:"counts"`
+	LikeCount      int            `json:"like_count"`
+	ViewerReaction *string        `json:"viewer_reaction"`
+}
+
+// SetPostReaction replaces the reaction the user gave the post, created is false when only the type changed
+func (l *LikeRepository) SetPostReaction(postID, userID int, reaction string) (bool, error) {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE post_reactions SET reaction = ? WHERE post_id = ? AND user_id = ?;`, reaction, postID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		if _, err := tx.Exec(`INSERT INTO post_reactions (post_id, user_id, reaction, reacted_at) VALUES (?, ?, ?, ?);`, postID, userID, reaction, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	return rows == 0, tx.Commit()
+}
+
+// ClearPostReaction reports false when the user hadn't reacted to the post
+func (l *LikeRepository) ClearPostReaction(postID, userID int) (bool, error) {
+	res, err := l.db.Exec(`DELETE FROM post_reactions WHERE post_id = ? AND user_id = ?;`, postID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// FetchPostReactions counts the reactions of the post, ViewerReaction is nil when the viewer hasn't reacted
+func (l *LikeRepository) FetchPostReactions(postID, viewerID int) (PostReactions, error) {
+	reactions := PostReactions{Counts: make(map[string]int, len(Reactions))}
+	for _, reaction := range Reactions {
+		reactions.Counts[reaction] = 0
+	}
+
+	rows, err := l.db.Query(`
+		SELECT reaction, COUNT(*), MAX(user_id = ?)
+		FROM post_reactions
+		WHERE post_id = ?
+		GROUP BY reaction;`, viewerID, postID)
+	if err != nil {
+		return reactions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			reaction string
+			count    int
+			viewer   bool
+		)
+		if err := rows.Scan(&reaction, &count, &viewer); err != nil {
+			return reactions, err
+		}
+
+		reactions.Counts[reaction] = count
+		reactions.LikeCount += count
+		if viewer {
+			reactions.ViewerReaction = &reaction
+		}
+	}
+
+	return reactions, rows.Err()
+}
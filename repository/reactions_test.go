@@ -0,0 +1,115 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reaction Repository Test", func() {
+	var (
+		db       *sql.DB
+		likeRepo *repository.LikeRepository
+		postID   int
+	)
+
+	open := func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+	}
+
+	BeforeEach(func() {
+		open()
+		migration.Migrate(db)
+
+		likeRepo = repository.NewLikeRepository(db)
+
+		id, err := repository.NewPostRepository(db).InsertPost(1, 1, "Title", "Description", false)
+		Expect(err).ToNot(HaveOccurred())
+		postID = int(id)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	Describe("SetPostReaction", func() {
+		It("should keep one reaction per user and count every type", func() {
+			created, err := likeRepo.SetPostReaction(postID, 1, repository.ReactionLove)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(BeTrue())
+
+			created, err = likeRepo.SetPostReaction(postID, 1, repository.ReactionWow)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(created).To(BeFalse())
+
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: postID, UserID: 2})).To(Succeed())
+
+			reactions, err := likeRepo.FetchPostReactions(postID, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reactions.Counts).To(HaveLen(len(repository.Reactions)))
+			Expect(reactions.Counts[repository.ReactionWow]).To(Equal(1))
+			Expect(reactions.Counts[repository.ReactionLike]).To(Equal(1))
+			Expect(reactions.Counts[repository.ReactionLove]).To(Equal(0))
+			Expect(reactions.LikeCount).To(Equal(2))
+			Expect(*reactions.ViewerReaction).To(Equal(repository.ReactionWow))
+
+			count, err := likeRepo.CountPostLike(postID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
+	})
+
+	Describe("ClearPostReaction", func() {
+		It("should remove the reaction and report whether there was one", func() {
+			_, err := likeRepo.SetPostReaction(postID, 1, repository.ReactionSad)
+			Expect(err).ToNot(HaveOccurred())
+
+			cleared, err := likeRepo.ClearPostReaction(postID, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cleared).To(BeTrue())
+
+			cleared, err = likeRepo.ClearPostReaction(postID, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cleared).To(BeFalse())
+
+			reactions, err := likeRepo.FetchPostReactions(postID, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reactions.LikeCount).To(Equal(0))
+			Expect(reactions.ViewerReaction).To(BeNil())
+		})
+	})
+
+	Describe("Migrate", func() {
+		It("should move the likes of a database from before reactions", func() {
+			db.Close()
+			open()
+
+			_, err := db.Exec(`
+				CREATE TABLE post_likes(id integer not null primary key AUTOINCREMENT, post_id integer NOT NULL, user_id integer NOT NULL);
+				INSERT INTO post_likes (post_id, user_id) VALUES (7, 1), (7, 2);`)
+			Expect(err).ToNot(HaveOccurred())
+
+			migration.Migrate(db)
+			likeRepo = repository.NewLikeRepository(db)
+
+			reactions, err := likeRepo.FetchPostReactions(7, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reactions.Counts[repository.ReactionLike]).To(Equal(2))
+			Expect(*reactions.ViewerReaction).To(Equal(repository.ReactionLike))
+
+			var legacy int
+			Expect(db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'post_likes'`).Scan(&legacy)).To(Succeed())
+			Expect(legacy).To(Equal(0))
+		})
+	})
+})
@@ -1,13 +1,23 @@
 package repository_test
 
 import (
+	"database/sql"
 	"testing"
 
+	"github.com/althafariq/discusspedia-be/db/seeder"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestRepository(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Repository Suite")
 }
+
+// seedAdmin adds the admin (3) after the seeded users, migrations don't create one
+func seedAdmin(db *sql.DB) {
+	if err := seeder.SeedAdmin(db, "admin@discusspedia.com", "password", bcrypt.MinCost); err != nil {
+		panic(err)
+	}
+}
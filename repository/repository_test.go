@@ -2,15 +2,22 @@ package repository_test
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/althafariq/discusspedia-be/db/migration"
 	"github.com/althafariq/discusspedia-be/repository"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var _ = Describe("Login Register Test", func() {
-	var userRepo *repository.UserRepository
+	var (
+		userRepo    *repository.UserRepository
+		postRepo    *repository.PostRepository
+		commentRepo *repository.CommentRepository
+		likeRepo    *repository.LikeRepository
+	)
 
 	BeforeEach(func() {
 		db, err := sql.Open("sqlite3", "basis-app.db")
@@ -19,8 +26,12 @@ var _ = Describe("Login Register Test", func() {
 		}
 
 		userRepo = repository.NewUserRepository(db)
+		postRepo = repository.NewPostRepository(db)
+		commentRepo = repository.NewCommentRepository(db)
+		likeRepo = repository.NewLikeRepository(db)
 
 		migration.Migrate(db)
+		seedAdmin(db)
 	})
 
 	AfterEach(func() {
@@ -29,10 +40,20 @@ var _ = Describe("Login Register Test", func() {
 			panic(err)
 		}
 
-		db.Exec(`DROP TABLE notifications;
+		db.Exec(`DROP TABLE post_revisions;
+		DROP TABLE webhook_deliveries;
+		DROP TABLE webhooks;
+		DROP TABLE category_moderators;
+		DROP TABLE audit_log;
+		DROP TABLE blocks;
+		DROP TABLE questionnaire_drafts;
+		DROP TABLE idempotency_keys;
+		DROP TABLE notifications;
+		DROP TABLE follows;
+		DROP TABLE mentions;
 		DROP TABLE comment_likes;
 		DROP TABLE comments;
-		DROP TABLE post_likes;
+		DROP TABLE post_reactions;
 		DROP TABLE questionnaires;
 		DROP TABLE post_images;
 		DROP TABLE posts;
@@ -51,6 +72,30 @@ var _ = Describe("Login Register Test", func() {
 				})
 			}
 		})
+		When("the stored hash has a lower cost than the configured one", func() {
+			It("should upgrade the hash after a successful login", func() {
+				db, err := sql.Open("sqlite3", "basis-app.db")
+				Expect(err).ToNot(HaveOccurred())
+				defer db.Close()
+
+				weakHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = db.Exec("UPDATE users SET password = ? WHERE id = 1", weakHash)
+				Expect(err).ToNot(HaveOccurred())
+
+				userRepo.SetPasswordCost(bcrypt.DefaultCost)
+				userId, err := userRepo.Login("resradit@gmail.com", "password")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(*userId).To(Equal(1))
+
+				var storedHash []byte
+				Expect(db.QueryRow("SELECT password FROM users WHERE id = 1").Scan(&storedHash)).To(Succeed())
+				Expect(bcrypt.Cost(storedHash)).To(Equal(bcrypt.DefaultCost))
+
+				_, err = userRepo.Login("resradit@gmail.com", "password")
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
 		When("email is correct but password is incorrect", func() {
 			It("should return Login Failed error", func() {
 				_, err := userRepo.Login("resradit@gmail.com", "pass")
@@ -84,6 +129,31 @@ var _ = Describe("Login Register Test", func() {
 						})
 					})
 
+					When("User role isn't lowercase", func() {
+						It("should store the normalized role", func() {
+							userId, _, err := userRepo.InsertNewUser("user 3", "user3@gmail.com", "password", " Siswa ", "institute 1", nil, nil)
+							Expect(err).ToNot(HaveOccurred())
+
+							role, err := userRepo.GetUserRole(userId)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(*role).To(Equal(repository.RoleSiswa))
+						})
+					})
+
+					When("User role is an alias", func() {
+						It("should store the role it stands for", func() {
+							major := "Informatika"
+							batch := 6
+
+							userId, _, err := userRepo.InsertNewUser("user 4", "user4@gmail.com", "password", "Student", "institute 1", &major, &batch)
+							Expect(err).ToNot(HaveOccurred())
+
+							role, err := userRepo.GetUserRole(userId)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(*role).To(Equal(repository.RoleMahasiswa))
+						})
+					})
+
 					When("User role is 'siswa'", func() {
 						It("should return user id", func() {
 
@@ -126,6 +196,13 @@ var _ = Describe("Login Register Test", func() {
 					Expect(err.Error()).To(Equal("role must be either 'mahasiswa' or 'siswa'"))
 				})
 			})
+
+			When("User's role is 'lecturer'", func() {
+				It("should leave it for an admin to give", func() {
+					_, _, err := userRepo.InsertNewUser("user 2", "user2@gmail.com", "password", "lecturer", "institute 1", nil, nil)
+					Expect(err).To(MatchError(repository.ErrRoleNotAllowed))
+				})
+			})
 		})
 
 		When("Some data are incomplete", func() {
@@ -135,4 +212,409 @@ var _ = Describe("Login Register Test", func() {
 			})
 		})
 	})
+
+	Describe("DeleteAccount", func() {
+		It("should stop the user from moderating categories", func() {
+			db, err := sql.Open("sqlite3", "basis-app.db")
+			Expect(err).ToNot(HaveOccurred())
+			defer db.Close()
+			categoryRepo := repository.NewCategoryRepository(db)
+
+			for _, deleteContent := range []bool{false, true} {
+				userID := 1
+				if deleteContent {
+					userID = 2
+				}
+
+				_, err := categoryRepo.AddCategoryModerator(1, userID)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = userRepo.DeleteAccount(userID, deleteContent)
+				Expect(err).ToNot(HaveOccurred())
+
+				moderates, err := categoryRepo.IsCategoryModerator(userID, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(moderates).To(BeFalse())
+			}
+		})
+
+		It("should remove the stored responses of the user's requests", func() {
+			db, err := sql.Open("sqlite3", "basis-app.db")
+			Expect(err).ToNot(HaveOccurred())
+			defer db.Close()
+			idempotencyRepo := repository.NewIdempotencyRepository(db)
+
+			_, err = idempotencyRepo.ReserveIdempotencyKey(1, "post", "key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(idempotencyRepo.CompleteIdempotencyKey(1, "post", "key", 1, []byte(`{"id":1}`))).To(Succeed())
+
+			_, err = userRepo.DeleteAccount(1, false)
+			Expect(err).ToNot(HaveOccurred())
+
+			var count int
+			Expect(db.QueryRow(`SELECT COUNT(*) FROM idempotency_keys WHERE user_id = 1`).Scan(&count)).To(Succeed())
+			Expect(count).To(BeZero())
+		})
+
+		It("should keep the revisions the user made to other posts", func() {
+			Expect(postRepo.UpdatePost(1, 1, 1, "Edited", "Description")).To(Succeed())
+			Expect(postRepo.UpdatePost(1, 2, 1, "Moderated", "Description")).To(Succeed())
+
+			_, err := userRepo.DeleteAccount(2, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			revisions, err := postRepo.FetchPostRevisions(1, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(revisions).To(HaveLen(3))
+			Expect(revisions[0].Title).To(Equal("Moderated"))
+			Expect(revisions[0].EditorID).To(Equal(0))
+			Expect(revisions[0].EditorName).To(Equal("Deleted User"))
+			Expect(revisions[1].EditorName).To(Equal("Radit"))
+		})
+	})
+
+	Describe("UpdateUserRole", func() {
+		It("should store the normalized role and return the previous one", func() {
+			previous, err := userRepo.UpdateUserRole(3, 2, "Moderator")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(previous).To(Equal(repository.RoleSiswa))
+
+			role, err := userRepo.GetUserRole(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*role).To(Equal(repository.RoleModerator))
+		})
+
+		It("should give the lecturer role", func() {
+			_, err := userRepo.UpdateUserRole(3, 1, " Lecturer")
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err := userRepo.GetUserRole(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*role).To(Equal(repository.RoleLecturer))
+		})
+
+		It("should refuse unknown roles, missing users and the admin's own role", func() {
+			_, err := userRepo.UpdateUserRole(3, 2, "teacher")
+			Expect(err).To(MatchError(repository.ErrUnknownRole))
+
+			_, err = userRepo.UpdateUserRole(3, 99, "siswa")
+			Expect(err).To(MatchError(repository.ErrUserNotFound))
+
+			_, err = userRepo.UpdateUserRole(3, 3, "siswa")
+			Expect(err).To(MatchError(repository.ErrCannotChangeSelf))
+		})
+	})
+
+	Describe("Ban", func() {
+		When("ban is still active", func() {
+			It("should return the ban reason", func() {
+				err := userRepo.BanUser(1, time.Now().Add(time.Hour), "spam")
+				Expect(err).ToNot(HaveOccurred())
+
+				ban, err := userRepo.GetActiveBan(1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ban).ToNot(BeNil())
+				Expect(ban.Reason).To(Equal("spam"))
+			})
+		})
+
+		When("ban has expired", func() {
+			It("should not return any ban", func() {
+				err := userRepo.BanUser(1, time.Now().Add(-time.Minute), "spam")
+				Expect(err).ToNot(HaveOccurred())
+
+				ban, err := userRepo.GetActiveBan(1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ban).To(BeNil())
+			})
+		})
+
+		When("user is unbanned", func() {
+			It("should not return any ban", func() {
+				err := userRepo.BanUser(1, time.Now().Add(time.Hour), "spam")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(userRepo.UnbanUser(1)).To(Succeed())
+
+				ban, err := userRepo.GetActiveBan(1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ban).To(BeNil())
+			})
+		})
+	})
+
+	Describe("LastActive", func() {
+		It("should list the users active since the given time, most recent first", func() {
+			user, err := userRepo.GetUserData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.LastActiveAt).To(BeNil())
+
+			Expect(userRepo.TouchLastActive(1)).To(Succeed())
+			Expect(userRepo.TouchLastActive(2)).To(Succeed())
+
+			user, err = userRepo.GetUserData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.LastActiveAt).ToNot(BeNil())
+			Expect(*user.LastActiveAt).To(BeTemporally("~", time.Now(), time.Minute))
+
+			users, err := userRepo.FetchActiveUsers(time.Now().Add(-time.Minute), 0, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(users).To(HaveLen(2))
+			Expect(users[0].ID).To(Equal(2))
+			Expect(users[1].ID).To(Equal(1))
+
+			users, err = userRepo.FetchActiveUsers(time.Now().Add(time.Minute), 0, 10, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(users).To(BeEmpty())
+		})
+	})
+
+	Describe("GetUserStats", func() {
+		It("should leave out anonymous posts and their likes", func() {
+			postID, err := postRepo.InsertPost(1, 1, "Anonymous", "desc", true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: int(postID), UserID: 2})).To(Succeed())
+
+			stats, err := userRepo.GetUserStats(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats.PostCount).To(Equal(1))
+			Expect(stats.TotalLikesReceived).To(Equal(0))
+		})
+	})
+
+	Describe("GetPlatformStats", func() {
+		It("should count new and active users and keep the result for a minute", func() {
+			_, _, err := userRepo.InsertNewUser("user 1", "user1@gmail.com", "password", "siswa", "institute 1", nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(userRepo.TouchLastActive(1)).To(Succeed())
+
+			stats, err := userRepo.GetPlatformStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats.Users).To(Equal(4))
+			Expect(stats.Posts).To(Equal(1))
+			Expect(stats.Comments).To(Equal(7))
+			// The seeded users have no registered_at
+			Expect(stats.NewUsers7Days).To(Equal(1))
+			Expect(stats.NewUsers30Days).To(Equal(1))
+			Expect(stats.DailyActiveUsers).To(Equal(1))
+
+			_, err = postRepo.InsertPost(1, 1, "Question", "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			cached, err := userRepo.GetPlatformStats()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached).To(Equal(stats))
+		})
+	})
+
+	Describe("FetchLeaderboard", func() {
+		It("should rank users by the weighted activity of the period", func() {
+			_, err := commentRepo.InsertComment(repository.Comment{PostID: 1, AuthorID: 2, Comment: "answer"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: 1, UserID: 2})).To(Succeed())
+			_, err = postRepo.InsertPost(2, 1, "Anonymous question", "desc", true)
+			Expect(err).ToNot(HaveOccurred())
+
+			// The seeded comments of Radit are from 2022
+			week, err := userRepo.FetchLeaderboard(repository.LeaderboardWeek)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(week).To(HaveLen(2))
+			Expect(week[0].UserID).To(Equal(1))
+			Expect(week[0].Rank).To(Equal(1))
+			Expect(week[0].PostCount).To(Equal(1))
+			Expect(week[0].CommentCount).To(BeZero())
+			Expect(week[0].LikesReceived).To(Equal(1))
+			Expect(week[0].Score).To(Equal(6))
+			Expect(week[1].UserID).To(Equal(2))
+			Expect(week[1].PostCount).To(BeZero())
+			Expect(week[1].Score).To(Equal(2))
+
+			all, err := userRepo.FetchLeaderboard(repository.LeaderboardAll)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(all[0].CommentCount).To(Equal(7))
+			Expect(all[0].Score).To(Equal(20))
+
+			_, err = userRepo.FetchLeaderboard("year")
+			Expect(err).To(MatchError(repository.ErrUnknownLeaderboardPeriod))
+		})
+
+		It("should leave out banned users once the cached board expires", func() {
+			_, err := commentRepo.InsertComment(repository.Comment{PostID: 1, AuthorID: 2, Comment: "answer"})
+			Expect(err).ToNot(HaveOccurred())
+
+			board, err := userRepo.FetchLeaderboard(repository.LeaderboardMonth)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(board).To(HaveLen(2))
+
+			Expect(userRepo.BanUser(2, time.Now().Add(time.Hour), "spam")).To(Succeed())
+
+			cached, err := userRepo.FetchLeaderboard(repository.LeaderboardMonth)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached).To(Equal(board))
+
+			db, err := sql.Open("sqlite3", "basis-app.db")
+			Expect(err).ToNot(HaveOccurred())
+			defer db.Close()
+
+			board, err = repository.NewUserRepository(db).FetchLeaderboard(repository.LeaderboardMonth)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(board).To(HaveLen(1))
+			Expect(board[0].UserID).To(Equal(1))
+		})
+	})
+
+	Describe("FetchUsersByIDs", func() {
+		It("should return the known users by id", func() {
+			users, err := userRepo.FetchUsersByIDs([]int{2, 1, 99})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(users).To(HaveLen(2))
+			Expect(users[1].Name).To(Equal("Radit"))
+			Expect(users[2].Name).To(Equal("Bocil SMA"))
+			Expect(users).ToNot(HaveKey(99))
+		})
+	})
+
+	Describe("SetCommentHighlight", func() {
+		It("should keep one highlighted comment per post and list it first", func() {
+			postID, err := postRepo.InsertPost(1, 1, "Question", "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			commentIDs := []int{}
+			for i := 0; i < 3; i++ {
+				commentID, err := commentRepo.InsertComment(repository.Comment{PostID: int(postID), AuthorID: 2, Comment: "answer"})
+				Expect(err).ToNot(HaveOccurred())
+				commentIDs = append(commentIDs, int(commentID))
+			}
+			parentID := commentIDs[0]
+			replyID, err := commentRepo.InsertComment(repository.Comment{PostID: int(postID), AuthorID: 1, ParentCommentID: &parentID, Comment: "reply"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(commentRepo.SetCommentHighlight(commentIDs[2], true)).To(Succeed())
+			Expect(commentRepo.SetCommentHighlight(commentIDs[1], true)).To(Succeed())
+			Expect(commentRepo.SetCommentHighlight(int(replyID), true)).To(MatchError(repository.ErrCommentIsReply))
+			Expect(commentRepo.SetCommentHighlight(9999, true)).To(MatchError(repository.ErrCommentNotFound))
+
+			comments, err := commentRepo.SelectAllCommentsByPostID(1, int(postID))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comments).To(HaveLen(3))
+			Expect(comments[0].ID).To(Equal(commentIDs[1]))
+			Expect(comments[0].IsHighlighted).To(BeTrue())
+			Expect(comments[1].ID).To(Equal(commentIDs[0]))
+			Expect(comments[2].IsHighlighted).To(BeFalse())
+
+			Expect(commentRepo.SetCommentHighlight(commentIDs[1], false)).To(Succeed())
+			comments, err = commentRepo.SelectAllCommentsByPostID(1, int(postID))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comments[0].ID).To(Equal(commentIDs[0]))
+			Expect(comments[1].IsHighlighted).To(BeFalse())
+		})
+	})
+
+	Describe("PostVisibleTo", func() {
+		It("should hide pending posts from everyone but their author", func() {
+			postRepo.SetPreModeration(true)
+			postID, err := postRepo.InsertPost(1, 1, "Pending", "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			visible, err := postRepo.PostVisibleTo(int(postID), 2, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visible).To(BeFalse())
+
+			visible, err = postRepo.PostVisibleTo(int(postID), 1, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visible).To(BeTrue())
+		})
+
+		It("should show pending posts to the staff reviewing them", func() {
+			postRepo.SetPreModeration(true)
+			postID, err := postRepo.InsertPost(1, 1, "Pending", "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			visible, err := postRepo.PostVisibleTo(int(postID), 3, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visible).To(BeTrue())
+		})
+
+		It("should leave comments on pending posts out of the commenter's list", func() {
+			postRepo.SetPreModeration(true)
+			postID, err := postRepo.InsertPost(1, 1, "Pending", "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = commentRepo.InsertComment(repository.Comment{PostID: int(postID), AuthorID: 2, Comment: "early"})
+			Expect(err).ToNot(HaveOccurred())
+
+			titles := func(viewerID int, withPending bool) []string {
+				comments, err := commentRepo.FetchCommentsByAuthor(2, viewerID, 100, 0, withPending)
+				Expect(err).ToNot(HaveOccurred())
+
+				titles := []string{}
+				for _, comment := range comments {
+					titles = append(titles, comment.PostTitle)
+				}
+				return titles
+			}
+
+			Expect(titles(2, false)).ToNot(ContainElement("Pending"))
+			Expect(titles(1, false)).To(ContainElement("Pending"))
+			Expect(titles(3, true)).To(ContainElement("Pending"))
+		})
+
+		It("should hide the posts of blocked users and posts that don't exist", func() {
+			db, err := sql.Open("sqlite3", "basis-app.db")
+			Expect(err).ToNot(HaveOccurred())
+			defer db.Close()
+
+			postID, err := postRepo.InsertPost(1, 1, "Blocked", "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repository.NewFollowRepository(db).BlockUser(2, 1)
+			Expect(err).ToNot(HaveOccurred())
+
+			visible, err := postRepo.PostVisibleTo(int(postID), 2, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visible).To(BeFalse())
+
+			visible, err = postRepo.PostVisibleTo(int(postID)+100, 1, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visible).To(BeFalse())
+		})
+	})
+
+	Describe("FetchAllPost", func() {
+		insertPost := func(title string, comments, likes int) int {
+			postID, err := postRepo.InsertPost(1, 1, title, "desc", false)
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < comments; i++ {
+				_, err := commentRepo.InsertComment(repository.Comment{PostID: int(postID), AuthorID: 2, Comment: "comment"})
+				Expect(err).ToNot(HaveOccurred())
+			}
+			for i := 0; i < likes; i++ {
+				Expect(likeRepo.InsertPostLike(repository.PostLike{PostID: int(postID), UserID: i + 1})).To(Succeed())
+			}
+
+			return int(postID)
+		}
+
+		indexOf := func(posts []repository.PostDetail, postID int) int {
+			for i, post := range posts {
+				if post.ID == postID {
+					return i
+				}
+			}
+			return -1
+		}
+
+		When("sorted by controversial", func() {
+			It("should rank a heavily debated post above a universally liked one", func() {
+				likedID := insertPost("Liked", 3, 5)
+				debatedID := insertPost("Debated", 4, 0)
+				newID := insertPost("New", 1, 0)
+
+				posts, err := postRepo.FetchAllPost(10, 0, 1, repository.OrderControversial, "")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(indexOf(posts, debatedID)).To(BeNumerically(">=", 0))
+				Expect(indexOf(posts, debatedID)).To(BeNumerically("<", indexOf(posts, likedID)))
+				Expect(indexOf(posts, likedID)).To(BeNumerically("<", indexOf(posts, newID)))
+			})
+		})
+	})
 })
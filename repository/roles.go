@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The roles a user can have. Mahasiswa are university students and siswa high school students, they're the roles
+// users register with. Lecturers, admins and moderators are only given by an admin, a lecturer's posts are meant to
+// be authoritative so nobody can claim the role themselves
+const (
+	RoleMahasiswa = "mahasiswa"
+	RoleSiswa     = "siswa"
+	RoleLecturer  = "lecturer"
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Roles is every role in the order they're listed, the users table refuses anything else
+var Roles = []string{RoleMahasiswa, RoleSiswa, RoleLecturer, RoleAdmin, RoleModerator}
+
+// roleAliases are other names accepted for a role, a student is a university student unless they say siswa
+var roleAliases = map[string]string{
+	"student": RoleMahasiswa,
+}
+
+var (
+	ErrUnknownRole      = fmt.Errorf("role should be one of %s", strings.Join(Roles, ", "))
+	ErrRoleNotAllowed   = errors.New("role must be either 'mahasiswa' or 'siswa'")
+	ErrCannotChangeSelf = errors.New("admins can't change their own role")
+)
+
+// NormalizeRole trims and lowercases the role so "Mahasiswa " is stored as mahasiswa and resolves the roleAliases,
+// it fails with ErrUnknownRole for anything that isn't one of Roles
+func NormalizeRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if alias, ok := roleAliases[role]; ok {
+		role = alias
+	}
+	for _, known := range Roles {
+		if role == known {
+			return role, nil
+		}
+	}
+	return "", ErrUnknownRole
+}
+
+// IsRegistrationRole tells whether users can pick the role themselves when they register
+func IsRegistrationRole(role string) bool {
+	return role == RoleMahasiswa || role == RoleSiswa
+}
+
+// UpdateUserRole gives the user another role, it's normalized first. An admin can't change their own role so there's
+// always someone left to undo a mistake
+func (u *UserRepository) UpdateUserRole(adminID, userID int, role string) (string, error) {
+	role, err := NormalizeRole(role)
+	if err != nil {
+		return "", err
+	}
+
+	if adminID == userID {
+		return "", ErrCannotChangeSelf
+	}
+
+	var previous string
+	err = u.db.QueryRow(`SELECT role FROM users WHERE id = ? AND deleted_at IS NULL;`, userID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := u.db.Exec(`UPDATE users SET role = ? WHERE id = ?;`, role, userID); err != nil {
+		return "", err
+	}
+
+	return previous, nil
+}
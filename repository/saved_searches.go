@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MaxSavedSearches is how many searches a user can keep
+const MaxSavedSearches = 50
+
+var (
+	ErrSavedSearchNotFound  = errors.New("saved search not found")
+	ErrTooManySavedSearches = errors.New("too many saved searches")
+)
+
+// SavedSearch keeps the query string of a posts list, it's applied again as is when the search is run
+type SavedSearch struct {
+	ID        int
+	Name      string
+	Query     string
+	CreatedAt time.Time
+}
+
+// InsertSavedSearch fails with ErrTooManySavedSearches once the user has MaxSavedSearches
+func (p *PostRepository) InsertSavedSearch(userID int, name, query string) (SavedSearch, error) {
+	search := SavedSearch{Name: name, Query: query, CreatedAt: time.Now().UTC()}
+
+	err := withTx(p.db, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM saved_searches WHERE user_id = ?;`, userID).Scan(&count); err != nil {
+			return err
+		}
+		if count >= MaxSavedSearches {
+			return ErrTooManySavedSearches
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO saved_searches (user_id, name, query, created_at) VALUES (?, ?, ?, ?);
+		`, userID, search.Name, search.Query, search.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		search.ID = int(id)
+		return err
+	})
+	if err != nil {
+		return SavedSearch{}, err
+	}
+
+	return search, nil
+}
+
+// FetchSavedSearches lists the searches of the user, newest first
+func (p *PostRepository) FetchSavedSearches(userID int) ([]SavedSearch, error) {
+	rows, err := p.db.Query(`
+		SELECT id, name, query, created_at FROM saved_searches WHERE user_id = ? ORDER BY id DESC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []SavedSearch{}
+	for rows.Next() {
+		var search SavedSearch
+		if err := rows.Scan(&search.ID, &search.Name, &search.Query, &search.CreatedAt); err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+
+	return searches, rows.Err()
+}
+
+// FetchSavedSearch reports ErrSavedSearchNotFound for the searches of other users too
+func (p *PostRepository) FetchSavedSearch(searchID, userID int) (SavedSearch, error) {
+	var search SavedSearch
+	err := p.db.QueryRow(`
+		SELECT id, name, query, created_at FROM saved_searches WHERE id = ? AND user_id = ?;
+	`, searchID, userID).Scan(&search.ID, &search.Name, &search.Query, &search.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SavedSearch{}, ErrSavedSearchNotFound
+	}
+
+	return search, err
+}
+
+func (p *PostRepository) DeleteSavedSearch(searchID, userID int) error {
+	res, err := p.db.Exec(`DELETE FROM saved_searches WHERE id = ? AND user_id = ?;`, searchID, userID)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := res.RowsAffected(); rows < 1 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
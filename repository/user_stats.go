@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// userStatsTTL is short enough that profile headers don't look stale after the user posts something
+const userStatsTTL = 30 * time.Second
+
+type cachedUserStats struct {
+	stats     UserStats
+	expiresAt time.Time
+}
+
+type userStatsCache struct {
+	mu    sync.RWMutex
+	stats map[int]cachedUserStats
+}
+
+func (c *userStatsCache) get(userID int) (UserStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.stats[userID]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return UserStats{}, false
+	}
+	return cached.stats, true
+}
+
+func (c *userStatsCache) set(userID int, stats UserStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats[userID] = cachedUserStats{stats: stats, expiresAt: time.Now().Add(userStatsTTL)}
+}
+
+// GetUserStats counts everything in a single query, questionnaires are posts too so they're excluded from post_count.
+// Anonymous posts and their likes aren't counted, a number going up next to one would give the author away
+func (u *UserRepository) GetUserStats(userID int) (UserStats, error) {
+	if stats, ok := u.statsCache.get(userID); ok {
+		return stats, nil
+	}
+
+	sqlStatement := `
+		SELECT
+			(SELECT COUNT(*) FROM posts p LEFT JOIN questionnaires q ON q.post_id = p.id WHERE p.author_id = u.id AND q.post_id IS NULL AND p.is_anonymous = 0),
+			(SELECT COUNT(*) FROM posts p INNER JOIN questionnaires q ON q.post_id = p.id WHERE p.author_id = u.id),
+			(SELECT COUNT(*) FROM comments WHERE author_id = u.id),
+			(SELECT COUNT(*) FROM posts p INNER JOIN post_reactions pl ON pl.post_id = p.id WHERE p.author_id = u.id AND p.is_anonymous = 0),
+			(SELECT COUNT(*) FROM follows WHERE following_id = u.id),
+			(SELECT COUNT(*) FROM follows WHERE follower_id = u.id)
+		FROM users u
+		WHERE u.id = ? AND u.deleted_at IS NULL;
+	`
+
+	var stats UserStats
+	err := u.db.QueryRow(sqlStatement, userID).Scan(
+		&stats.PostCount, &stats.QuestionnaireCount, &stats.CommentCount,
+		&stats.TotalLikesReceived, &stats.FollowerCount, &stats.FollowingCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return UserStats{}, ErrUserNotFound
+		}
+		return UserStats{}, err
+	}
+
+	u.statsCache.set(userID, stats)
+	return stats, nil
+}
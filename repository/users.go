@@ -3,24 +3,47 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"regexp"
-	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db            *sql.DB
+	statsCache    *userStatsCache
+	platformStats *platformStatsCache
+	leaderboard   *leaderboardCache
+	passwordCost  int
 }
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+)
+
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{
 		db: db,
+		statsCache: &userStatsCache{
+			stats: make(map[int]cachedUserStats),
+		},
+		platformStats: &platformStatsCache{},
+		leaderboard: &leaderboardCache{
+			periods: make(map[string]cachedLeaderboard),
+		},
+		passwordCost: bcrypt.DefaultCost,
 	}
 }
 
+// SetPasswordCost sets the bcrypt cost of new hashes, it's meant to be called once at startup. Hashes with a lower
+// cost are upgraded when their user logs in
+func (u *UserRepository) SetPasswordCost(cost int) {
+	u.passwordCost = cost
+}
+
 func (u *UserRepository) Login(email string, password string) (*int, error) {
 	statement := "SELECT id, password FROM users WHERE email = ?"
 	res := u.db.QueryRow(statement, email, password)
@@ -30,17 +53,104 @@ func (u *UserRepository) Login(email string, password string) (*int, error) {
 	if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) != nil {
 		return nil, errors.New("Login Failed")
 	}
+
+	// The password is only known here, a failed upgrade is retried on the next login instead of failing this one
+	if cost, err := bcrypt.Cost([]byte(hashedPassword)); err == nil && cost < u.passwordCost {
+		if rehashed, err := bcrypt.GenerateFromPassword([]byte(password), u.passwordCost); err == nil {
+			u.db.Exec("UPDATE users SET password = ? WHERE id = ?", rehashed, id)
+		}
+	}
+
 	return &id, nil
 }
 
 func (u *UserRepository) GetUserData(id int) (*User, error) {
-	statement := "SELECT users.id, name, email, role, avatar, institute, major, batch FROM user_details JOIN users ON users.id = user_details.user_id WHERE users.id = ?"
-	var user User
+	statement := "SELECT users.id, name, email, role, avatar, institute, major, batch, last_active_at FROM user_details JOIN users ON users.id = user_details.user_id WHERE users.id = ?"
+	var (
+		user         User
+		lastActiveAt sql.NullTime
+	)
 	res := u.db.QueryRow(statement, id)
-	err := res.Scan(&user.Id, &user.Name, &user.Email, &user.Role, &user.Avatar, &user.Institute, &user.Major, &user.Batch)
+	err := res.Scan(&user.Id, &user.Name, &user.Email, &user.Role, &user.Avatar, &user.Institute, &user.Major, &user.Batch, &lastActiveAt)
+	if lastActiveAt.Valid {
+		user.LastActiveAt = &lastActiveAt.Time
+	}
 	return &user, err
 }
 
+// TouchLastActive stores now as the time the user was last active
+func (u *UserRepository) TouchLastActive(userID int) error {
+	_, err := u.db.Exec("UPDATE users SET last_active_at = ? WHERE id = ?", time.Now().UTC(), userID)
+	return err
+}
+
+// FetchActiveUsers lists the users active since the given time, most recently active first
+func (u *UserRepository) FetchActiveUsers(since time.Time, viewerID, limit, offset int) ([]ActiveUser, error) {
+	statement := `
+		SELECT
+			u.id,
+			u.name,
+			u.role,
+			u.avatar,
+			ud.institute,
+			(SELECT EXISTS (SELECT 1 FROM follows WHERE follower_id = ? AND following_id = u.id)) AS is_following,
+			u.last_active_at
+		FROM users u
+		LEFT JOIN user_details ud ON ud.user_id = u.id
+		WHERE u.deleted_at IS NULL AND u.last_active_at >= ?
+		ORDER BY u.last_active_at DESC, u.id DESC
+		LIMIT ? OFFSET ?;`
+
+	rows, err := u.db.Query(statement, viewerID, since.UTC(), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []ActiveUser{}
+	for rows.Next() {
+		var user ActiveUser
+		if err := rows.Scan(&user.ID, &user.Name, &user.Role, &user.Avatar, &user.Institute, &user.IsFollowing, &user.LastActiveAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (u *UserRepository) UserExists(id int) (bool, error) {
+	statement := "SELECT EXISTS (SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL)"
+	var exists bool
+	err := u.db.QueryRow(statement, id).Scan(&exists)
+	return exists, err
+}
+
+// FetchUsersByIDs returns the users found by id, unknown ids are left out of the map
+func (u *UserRepository) FetchUsersByIDs(ids []int) (map[int]UserSummary, error) {
+	users := make(map[int]UserSummary)
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	placeholders, args := inClause(ids)
+	rows, err := u.db.Query(fmt.Sprintf(`SELECT id, name, role, avatar FROM users WHERE id IN (%s);`, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user UserSummary
+		if err := rows.Scan(&user.ID, &user.Name, &user.Role, &user.Avatar); err != nil {
+			return nil, err
+		}
+		users[user.ID] = user
+	}
+
+	return users, rows.Err()
+}
+
 func (u *UserRepository) UpdateUserData(id int, name, email string) error {
 	statement := "UPDATE users SET name = ?, email = ? WHERE id = ?"
 
@@ -96,11 +206,12 @@ func (u *UserRepository) CheckEmail(email string) (bool, error) {
 }
 
 func (u *UserRepository) InsertNewUser(name string, email string, password string, role string, institute string, major *string, batch *int) (userId int, responseCode int, err error) {
-	if strings.ToLower(role) != "mahasiswa" && strings.ToLower(role) != "siswa" {
-		return -1, http.StatusBadRequest, errors.New("role must be either 'mahasiswa' or 'siswa'")
+	role, err = NormalizeRole(role)
+	if err != nil || !IsRegistrationRole(role) {
+		return -1, http.StatusBadRequest, ErrRoleNotAllowed
 	}
 
-	if strings.ToLower(role) == "mahasiswa" {
+	if role == RoleMahasiswa {
 		if major == nil || batch == nil {
 			return -1, http.StatusBadRequest, errors.New("please fill major and batch correctly")
 		}
@@ -123,9 +234,9 @@ func (u *UserRepository) InsertNewUser(name string, email string, password strin
 	if !isValid {
 		return -1, http.StatusBadRequest, errors.New("invalid email")
 	}
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	statement := "INSERT INTO users (name, email, password, role) VALUES (?, ?, ?, ?)"
-	res, err := u.db.Exec(statement, name, email, hashedPassword, strings.ToLower(role))
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), u.passwordCost)
+	statement := "INSERT INTO users (name, email, password, role, registered_at) VALUES (?, ?, ?, ?, ?)"
+	res, err := u.db.Exec(statement, name, email, hashedPassword, role, time.Now().UTC())
 	if err != nil {
 		return -1, http.StatusInternalServerError, err
 	}
@@ -145,3 +256,188 @@ func (u *UserRepository) UpdateAvatar(userId int, filepath string) error {
 	_, err := u.db.Exec(statement, filepath, userId)
 	return err
 }
+
+func (u *UserRepository) AvatarExists(filepath string) (bool, error) {
+	statement := "SELECT EXISTS (SELECT 1 FROM users WHERE avatar = ? AND deleted_at IS NULL)"
+	var exists bool
+	err := u.db.QueryRow(statement, filepath).Scan(&exists)
+	return exists, err
+}
+
+func (u *UserRepository) BanUser(userID int, until time.Time, reason string) error {
+	statement := "UPDATE users SET banned_until = ?, ban_reason = ? WHERE id = ?"
+	res, err := u.db.Exec(statement, until, reason, userID)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := res.RowsAffected(); rows < 1 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (u *UserRepository) UnbanUser(userID int) error {
+	statement := "UPDATE users SET banned_until = NULL, ban_reason = NULL WHERE id = ?"
+	res, err := u.db.Exec(statement, userID)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := res.RowsAffected(); rows < 1 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetActiveBan returns nil when the user is not banned or the ban has already expired.
+// Deleted accounts are reported as ErrUserNotFound.
+func (u *UserRepository) GetActiveBan(userID int) (*UserBan, error) {
+	statement := "SELECT banned_until, ban_reason FROM users WHERE id = ? AND deleted_at IS NULL"
+
+	var (
+		bannedUntil sql.NullTime
+		reason      sql.NullString
+	)
+	err := u.db.QueryRow(statement, userID).Scan(&bannedUntil, &reason)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if !bannedUntil.Valid || !bannedUntil.Time.After(time.Now()) {
+		return nil, nil
+	}
+
+	return &UserBan{
+		BannedUntil: bannedUntil.Time,
+		Reason:      reason.String,
+	}, nil
+}
+
+// GetPrivacySettings reports deleted accounts as ErrUserNotFound
+func (u *UserRepository) GetPrivacySettings(userID int) (PrivacySettings, error) {
+	statement := "SELECT likes_public, bookmarks_public FROM users WHERE id = ? AND deleted_at IS NULL"
+
+	var settings PrivacySettings
+	err := u.db.QueryRow(statement, userID).Scan(&settings.LikesPublic, &settings.BookmarksPublic)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return PrivacySettings{}, ErrUserNotFound
+		}
+		return PrivacySettings{}, err
+	}
+
+	return settings, nil
+}
+
+func (u *UserRepository) UpdatePrivacySettings(userID int, settings PrivacySettings) error {
+	statement := "UPDATE users SET likes_public = ?, bookmarks_public = ? WHERE id = ?"
+	_, err := u.db.Exec(statement, settings.LikesPublic, settings.BookmarksPublic, userID)
+	return err
+}
+
+// DeleteAccount removes the user's personal data, likes and follows. When deleteContent is false the posts and
+// comments are kept and shown under an anonymized "Deleted User", otherwise they're removed along with their threads.
+// It returns the media files that should be removed once the deletion is committed.
+func (u *UserRepository) DeleteAccount(userID int, deleteContent bool) ([]string, error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var avatar sql.NullString
+	err = tx.QueryRow("SELECT avatar FROM users WHERE id = ? AND deleted_at IS NULL", userID).Scan(&avatar)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	filePaths := []string{}
+	if avatar.Valid {
+		filePaths = append(filePaths, avatar.String)
+	}
+
+	statements := []string{
+		"DELETE FROM user_details WHERE user_id = ?",
+		"DELETE FROM post_reactions WHERE user_id = ?",
+		"DELETE FROM comment_likes WHERE user_id = ?",
+		"DELETE FROM follows WHERE follower_id = ?1 OR following_id = ?1",
+		"DELETE FROM notifications WHERE user_id = ?1 OR actor_id = ?1",
+		"DELETE FROM notification_preferences WHERE user_id = ?",
+		"DELETE FROM mentions WHERE user_id = ?",
+		"DELETE FROM questionnaire_drafts WHERE author_id = ?",
+		"DELETE FROM saved_searches WHERE user_id = ?",
+		"DELETE FROM category_moderators WHERE user_id = ?",
+		"DELETE FROM idempotency_keys WHERE user_id = ?",
+		"DELETE FROM blocks WHERE blocker_id = ?1 OR blocked_id = ?1",
+	}
+
+	if deleteContent {
+		rows, err := tx.Query(`
+			SELECT pi.path FROM post_images pi JOIN posts p ON p.id = pi.post_id WHERE p.author_id = ?1
+			UNION ALL
+			SELECT qi.path FROM questionnaire_images qi JOIN posts p ON p.id = qi.questionnaire_id WHERE p.author_id = ?1`, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			filePaths = append(filePaths, path)
+		}
+		rows.Close()
+
+		statements = append(statements,
+			`WITH RECURSIVE removed(id) AS (
+				SELECT id FROM comments WHERE author_id = ?1 OR post_id IN (SELECT id FROM posts WHERE author_id = ?1)
+				UNION
+				SELECT c.id FROM comments c JOIN removed r ON c.comment_id = r.id
+			)
+			DELETE FROM comments WHERE id IN (SELECT id FROM removed)`,
+			"DELETE FROM comment_likes WHERE comment_id NOT IN (SELECT id FROM comments)",
+			"DELETE FROM mentions WHERE author_id = ?1 OR post_id IN (SELECT id FROM posts WHERE author_id = ?1)",
+			"DELETE FROM post_reactions WHERE post_id IN (SELECT id FROM posts WHERE author_id = ?)",
+			"DELETE FROM post_images WHERE post_id IN (SELECT id FROM posts WHERE author_id = ?)",
+			"DELETE FROM post_revisions WHERE post_id IN (SELECT id FROM posts WHERE author_id = ?)",
+			"DELETE FROM questionnaire_images WHERE questionnaire_id IN (SELECT id FROM posts WHERE author_id = ?)",
+			"DELETE FROM questionnaires WHERE post_id IN (SELECT id FROM posts WHERE author_id = ?)",
+			"DELETE FROM posts WHERE author_id = ?",
+			"DELETE FROM users WHERE id = ?",
+		)
+	} else {
+		statements = append(statements,
+			`UPDATE users SET
+				name = 'Deleted User',
+				email = 'deleted-' || id || '@discusspedia.invalid',
+				password = '',
+				avatar = NULL,
+				banned_until = NULL,
+				ban_reason = NULL,
+				deleted_at = CURRENT_TIMESTAMP
+			WHERE id = ?`,
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return filePaths, nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookEventPostCreated is sent when a post is published, right away or once it's approved
+const WebhookEventPostCreated = "post.created"
+
+// Webhook is a URL pinged about the new posts of its category, or of every category when CategoryID is nil. Secret
+// signs the payloads and is only shown when the webhook is created
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	CategoryID *int      `json:"category_id"`
+	Secret     string    `json:"-"`
+	CreatedBy  int       `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one attempt to deliver an event, StatusCode is nil when no response came back
+type WebhookDelivery struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	Event      string    `json:"event"`
+	PostID     int       `json:"post_id"`
+	Attempt    int       `json:"attempt"`
+	StatusCode *int      `json:"status_code"`
+	Error      *string   `json:"error"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+func (w *WebhookRepository) InsertWebhook(webhook Webhook) (int64, error) {
+	result, err := w.db.Exec(
+		`INSERT INTO webhooks (url, category_id, secret, created_by, created_at) VALUES (?, ?, ?, ?, ?);`,
+		webhook.URL, webhook.CategoryID, webhook.Secret, webhook.CreatedBy, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// DeleteWebhook also removes the delivery log of the webhook
+func (w *WebhookRepository) DeleteWebhook(id int) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?;`, id)
+	if err != nil {
+		return err
+	}
+
+	if removed, err := result.RowsAffected(); err != nil {
+		return err
+	} else if removed == 0 {
+		return ErrWebhookNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?;`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (w *WebhookRepository) FetchWebhooks() ([]Webhook, error) {
+	return w.fetchWebhooks(`SELECT id, url, category_id, secret, created_by, created_at FROM webhooks ORDER BY id;`)
+}
+
+// FetchWebhooksForCategory returns the webhooks to ping about a new post of the category
+func (w *WebhookRepository) FetchWebhooksForCategory(categoryID int) ([]Webhook, error) {
+	return w.fetchWebhooks(`
+		SELECT id, url, category_id, secret, created_by, created_at
+		FROM webhooks
+		WHERE category_id IS NULL OR category_id = ?
+		ORDER BY id;`, categoryID)
+}
+
+func (w *WebhookRepository) fetchWebhooks(query string, args ...interface{}) ([]Webhook, error) {
+	rows, err := w.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(
+			&webhook.ID, &webhook.URL, &webhook.CategoryID, &webhook.Secret, &webhook.CreatedBy, &webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (w *WebhookRepository) InsertWebhookDelivery(delivery WebhookDelivery) error {
+	_, err := w.db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, post_id, attempt, status_code, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		delivery.WebhookID, delivery.Event, delivery.PostID, delivery.Attempt, delivery.StatusCode, delivery.Error,
+		delivery.DurationMs, time.Now(),
+	)
+	return err
+}
+
+// FetchWebhookDeliveries returns the delivery attempts of the webhook, newest first
+func (w *WebhookRepository) FetchWebhookDeliveries(webhookID, limit, offset int) ([]WebhookDelivery, error) {
+	rows, err := w.db.Query(`
+		SELECT id, webhook_id, event, post_id, attempt, status_code, error, duration_ms, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?;`, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.PostID, &delivery.Attempt,
+			&delivery.StatusCode, &delivery.Error, &delivery.DurationMs, &delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,90 @@
+package repository_test
+
+import (
+	"database/sql"
+
+	"github.com/althafariq/discusspedia-be/db/migration"
+	"github.com/althafariq/discusspedia-be/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhook Repository Test", func() {
+	var (
+		db          *sql.DB
+		webhookRepo *repository.WebhookRepository
+	)
+
+	BeforeEach(func() {
+		var err error
+		db, err = sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			panic(err)
+		}
+
+		// Every connection to :memory: opens its own empty database
+		db.SetMaxOpenConns(1)
+		migration.Migrate(db)
+
+		webhookRepo = repository.NewWebhookRepository(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	insertWebhook := func(url string, categoryID *int) int {
+		id, err := webhookRepo.InsertWebhook(repository.Webhook{
+			URL: url, CategoryID: categoryID, Secret: "secret", CreatedBy: 3,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return int(id)
+	}
+
+	It("should return the webhooks of the category and the ones of every category", func() {
+		economy, psychology := 1, 3
+		all := insertWebhook("https://example.com/all", nil)
+		insertWebhook("https://example.com/psychology", &psychology)
+		economyHook := insertWebhook("https://example.com/economy", &economy)
+
+		webhooks, err := webhookRepo.FetchWebhooksForCategory(economy)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(webhooks).To(HaveLen(2))
+		Expect(webhooks[0].ID).To(Equal(all))
+		Expect(webhooks[0].CategoryID).To(BeNil())
+		Expect(webhooks[1].ID).To(Equal(economyHook))
+		Expect(webhooks[1].Secret).To(Equal("secret"))
+
+		webhooks, err = webhookRepo.FetchWebhooks()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(webhooks).To(HaveLen(3))
+	})
+
+	It("should log the deliveries newest first and remove them with the webhook", func() {
+		id := insertWebhook("https://example.com/all", nil)
+
+		failure := "timeout"
+		ok := 204
+		Expect(webhookRepo.InsertWebhookDelivery(repository.WebhookDelivery{
+			WebhookID: id, Event: repository.WebhookEventPostCreated, PostID: 1, Attempt: 1, Error: &failure,
+		})).To(Succeed())
+		Expect(webhookRepo.InsertWebhookDelivery(repository.WebhookDelivery{
+			WebhookID: id, Event: repository.WebhookEventPostCreated, PostID: 1, Attempt: 2, StatusCode: &ok,
+		})).To(Succeed())
+
+		deliveries, err := webhookRepo.FetchWebhookDeliveries(id, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deliveries).To(HaveLen(2))
+		Expect(deliveries[0].Attempt).To(Equal(2))
+		Expect(*deliveries[0].StatusCode).To(Equal(204))
+		Expect(deliveries[1].StatusCode).To(BeNil())
+		Expect(*deliveries[1].Error).To(Equal("timeout"))
+
+		Expect(webhookRepo.DeleteWebhook(id)).To(Succeed())
+		Expect(webhookRepo.DeleteWebhook(id)).To(MatchError(repository.ErrWebhookNotFound))
+
+		deliveries, err = webhookRepo.FetchWebhookDeliveries(id, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deliveries).To(BeEmpty())
+	})
+})
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"golang.org/x/net/html"
+)
+
+// The policies are safe to share between goroutines once built
+var (
+	ugcPolicy  = bluemonday.UGCPolicy()
+	textPolicy = bluemonday.StrictPolicy()
+
+	// bluemonday escapes every text node, this undoes it for the characters markdown needs (blockquotes, quotes, &)
+	// but keeps &lt; so escaped tags can't turn back into real ones
+	markdownTextUnescaper = strings.NewReplacer("&amp;", "&", "&gt;", ">", "&#34;", `"`, "&#39;", "'")
+)
+
+// SanitizeMarkdown strips dangerous HTML (scripts, event handlers, javascript: links...) from a markdown text
+// while leaving the markdown syntax untouched
+func SanitizeMarkdown(text string) string {
+	sanitized := ugcPolicy.Sanitize(text)
+
+	var buf strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(sanitized))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		raw := string(tokenizer.Raw())
+		if tokenType == html.TextToken {
+			raw = markdownTextUnescaper.Replace(raw)
+		}
+		buf.WriteString(raw)
+	}
+
+	return buf.String()
+}
+
+// RenderMarkdown converts a markdown text to HTML, raw HTML in the text is left out and the result is sanitized again
+func RenderMarkdown(text string) string {
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(text), &buf); err != nil {
+		log.Printf("render markdown: %v", err)
+		return ""
+	}
+
+	return ugcPolicy.Sanitize(buf.String())
+}
+
+// Snippet is the beginning of a markdown text as plain text on one line. A longer text is cut at the last space
+// within max characters, counted in runes, and ends with an ellipsis
+func Snippet(text string, max int) string {
+	plain := html.UnescapeString(textPolicy.Sanitize(RenderMarkdown(text)))
+	plain = strings.Join(strings.Fields(plain), " ")
+
+	runes := []rune(plain)
+	if len(runes) <= max {
+		return plain
+	}
+
+	cut := string(runes[:max])
+	// A single word longer than max is still cut in the middle
+	if runes[max] != ' ' {
+		if space := strings.LastIndex(cut, " "); space > 0 {
+			cut = cut[:space]
+		}
+	}
+	return strings.TrimRight(cut, " ") + "…"
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Usernames with spaces can be mentioned by replacing the spaces with underscores, e.g. @althaf_ariq
+var mentionRegex = regexp.MustCompile(`(^|[^\w@])@([\w.]+)`)
+
+type MentionToken struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// ParseMentions returns every @name token in text with character (rune) offsets, @ included
+func ParseMentions(text string) []MentionToken {
+	tokens := make([]MentionToken, 0)
+	for _, match := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		atIndex := match[4] - 1
+		name := strings.TrimRight(text[match[4]:match[5]], ".")
+		if name == "" {
+			continue
+		}
+
+		start := utf8.RuneCountInString(text[:atIndex])
+		tokens = append(tokens, MentionToken{
+			Name:  strings.ReplaceAll(name, "_", " "),
+			Start: start,
+			End:   start + 1 + utf8.RuneCountInString(name),
+		})
+	}
+
+	return tokens
+}
+
+// MentionContext is the text around the mention between the rune offsets start and end on one line, with up to radius
+// runes on each side. The sides are cut at a space when they can be and get an ellipsis when the text goes on
+func MentionContext(text string, start, end, radius int) string {
+	runes := []rune(text)
+	if start < 0 || end > len(runes) || start > end {
+		return ""
+	}
+
+	from, to := start-radius, end+radius
+	if from <= 0 {
+		from = 0
+	} else if space := strings.IndexRune(string(runes[from:start]), ' '); space >= 0 {
+		from += utf8.RuneCountInString(string(runes[from:start])[:space]) + 1
+	}
+	if to >= len(runes) {
+		to = len(runes)
+	} else if space := strings.LastIndex(string(runes[end:to]), " "); space >= 0 {
+		to = end + utf8.RuneCountInString(string(runes[end:to])[:space])
+	}
+
+	context := strings.Join(strings.Fields(string(runes[from:to])), " ")
+	if from > 0 {
+		context = "…" + context
+	}
+	if to < len(runes) {
+		context += "…"
+	}
+	return context
+}
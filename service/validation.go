@@ -2,20 +2,95 @@ package service
 
 import (
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 )
 
+type Severity int
+
+const (
+	SeverityMild Severity = iota + 1
+	SeverityModerate
+	SeveritySevere
+)
+
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "mild":
+		return SeverityMild, nil
+	case "moderate":
+		return SeverityModerate, nil
+	case "severe":
+		return SeveritySevere, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityMild:
+		return "mild"
+	case SeverityModerate:
+		return "moderate"
+	case SeveritySevere:
+		return "severe"
+	}
+	return "unknown"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// Locales of the bad words lists, each one is read from its own CSV file
+const (
+	LocaleIndonesian = "id"
+	LocaleEnglish    = "en"
+)
+
+var wordListFiles = map[string]string{
+	LocaleIndonesian: "badwords.csv",
+	LocaleEnglish:    "badwords_en.csv",
+}
+
+var ErrUnknownLocale = errors.New("unknown locale")
+
+// IsWordListLocale reports whether there's a bad words list for the locale
+func IsWordListLocale(locale string) bool {
+	_, ok := wordListFiles[locale]
+	return ok
+}
+
 // Singleton Design Pattern
 
 var mu = &sync.Mutex{}
 
+type wordList struct {
+	words   map[string]Severity
+	enabled bool
+}
+
 type validation struct {
-	badwords map[string]struct{}
+	// listsMu guards enabled, the lists can be turned on and off while requests are validated
+	listsMu   sync.RWMutex
+	lists     map[string]*wordList
+	threshold Severity
 }
 
 var validationInstance *validation
@@ -25,16 +100,132 @@ func GetValidationInstance() *validation {
 		mu.Lock()
 		defer mu.Unlock()
 		if validationInstance == nil {
-			badwords := loadCSV()
+			lists := make(map[string]*wordList, len(wordListFiles))
+			for locale, file := range wordListFiles {
+				lists[locale] = &wordList{words: loadCSV(file), enabled: true}
+			}
 			validationInstance = &validation{
-				badwords: badwords,
+				lists:     lists,
+				threshold: SeverityMild,
 			}
 		}
 	}
 	return validationInstance
 }
 
-func (v *validation) Validate(sentence string) bool {
+// SetThreshold is meant to be called once at startup, by default every listed word is blocked
+func (v *validation) SetThreshold(min Severity) {
+	v.threshold = min
+}
+
+type LocaleStatus struct {
+	Locale  string `json:"locale"`
+	Enabled bool   `json:"enabled"`
+	Words   int    `json:"words"`
+}
+
+// Locales lists every bad words list, sorted by locale
+func (v *validation) Locales() []LocaleStatus {
+	v.listsMu.RLock()
+	defer v.listsMu.RUnlock()
+
+	locales := make([]LocaleStatus, 0, len(v.lists))
+	for locale, list := range v.lists {
+		locales = append(locales, LocaleStatus{Locale: locale, Enabled: list.enabled, Words: len(list.words)})
+	}
+	sort.Slice(locales, func(i, j int) bool { return locales[i].Locale < locales[j].Locale })
+
+	return locales
+}
+
+// SetLocaleEnabled turns the list of the locale on or off for every request
+func (v *validation) SetLocaleEnabled(locale string, enabled bool) error {
+	v.listsMu.Lock()
+	defer v.listsMu.Unlock()
+
+	list, ok := v.lists[locale]
+	if !ok {
+		return ErrUnknownLocale
+	}
+	list.enabled = enabled
+	return nil
+}
+
+// SetActiveLocales enables only the lists of the given locales, it's meant to be called at startup
+func (v *validation) SetActiveLocales(locales []string) error {
+	for _, locale := range locales {
+		if !IsWordListLocale(locale) {
+			return fmt.Errorf("%w %q", ErrUnknownLocale, locale)
+		}
+	}
+
+	v.listsMu.Lock()
+	defer v.listsMu.Unlock()
+
+	for locale, list := range v.lists {
+		list.enabled = false
+		for _, active := range locales {
+			if locale == active {
+				list.enabled = true
+			}
+		}
+	}
+	return nil
+}
+
+// activeLists returns the enabled lists plus those of the requested locales, so a request can add the lists of the
+// languages it's written in but never skip an enabled one
+func (v *validation) activeLists(requested []string) map[string]map[string]Severity {
+	v.listsMu.RLock()
+	defer v.listsMu.RUnlock()
+
+	active := make(map[string]map[string]Severity, len(v.lists))
+	for locale, list := range v.lists {
+		if list.enabled {
+			active[locale] = list.words
+		}
+	}
+	for _, locale := range requested {
+		if list, ok := v.lists[locale]; ok {
+			active[locale] = list.words
+		}
+	}
+	return active
+}
+
+// lookup returns the highest severity the word has in the lists and the locale of that list
+func lookup(lists map[string]map[string]Severity, word string) (Severity, string, bool) {
+	var (
+		found    bool
+		severity Severity
+		locale   string
+	)
+	word = strings.ToLower(word)
+	for l, words := range lists {
+		s, ok := words[word]
+		if !ok {
+			continue
+		}
+		if !found || s > severity || (s == severity && l < locale) {
+			found, severity, locale = true, s, l
+		}
+	}
+	return severity, locale, found
+}
+
+// Validate blocks words at or above the threshold severity, milder ones are only logged as flagged. The sentence is
+// checked against the enabled lists and the lists of the given locales, usually from the Accept-Language header
+func (v *validation) Validate(sentence string, locales ...string) bool {
+	ok, flagged := v.ValidateWithThreshold(sentence, v.threshold, locales...)
+	if ok && len(flagged) > 0 {
+		log.Printf("flagged words below the profanity threshold: %s", strings.Join(flagged, ", "))
+	}
+	return ok
+}
+
+// ValidateWithThreshold returns false when the sentence contains a word at or above min,
+// the words below min are returned as flagged
+func (v *validation) ValidateWithThreshold(sentence string, min Severity, locales ...string) (bool, []string) {
 	reg, err := regexp.Compile("[^a-zA-Z0-9]+")
 	if err != nil {
 		log.Println(err)
@@ -42,18 +233,68 @@ func (v *validation) Validate(sentence string) bool {
 
 	sentence = reg.ReplaceAllString(sentence, " ")
 
+	lists := v.activeLists(locales)
+	flagged := make([]string, 0)
 	words := strings.Split(sentence, " ")
 	for _, word := range words {
-		if _, ok := v.badwords[strings.ToLower(word)]; ok {
-			return false
+		severity, _, ok := lookup(lists, word)
+		if !ok {
+			continue
+		}
+		if severity >= min {
+			return false, flagged
 		}
+		flagged = append(flagged, word)
 	}
-	return true
+	return true, flagged
+}
+
+type Match struct {
+	Word     string   `json:"word"`
+	Severity Severity `json:"severity"`
+	// Locale is the list the word was found in, the one with the highest severity when it's in several
+	Locale string `json:"locale"`
+	// Blocked is true when the severity is at or above the threshold, so the text would be rejected
+	Blocked bool `json:"blocked"`
 }
 
-func loadCSV() map[string]struct{} {
-	badwords := make(map[string]struct{})
-	file, err := os.Open("badwords.csv")
+type CheckResult struct {
+	OK       bool    `json:"ok"`
+	Matches  []Match `json:"matches"`
+	Censored string  `json:"censored"`
+}
+
+var wordPattern = regexp.MustCompile("[a-zA-Z0-9]+")
+
+// Check explains the result of Validate: every listed word in the sentence and the sentence with them masked.
+// OK comes from ValidateWithThreshold so it always agrees with Validate
+func (v *validation) Check(sentence string, locales ...string) CheckResult {
+	ok, _ := v.ValidateWithThreshold(sentence, v.threshold, locales...)
+
+	lists := v.activeLists(locales)
+	result := CheckResult{OK: ok, Matches: make([]Match, 0)}
+	censored := []byte(sentence)
+	for _, loc := range wordPattern.FindAllStringIndex(sentence, -1) {
+		word := sentence[loc[0]:loc[1]]
+		severity, locale, found := lookup(lists, word)
+		if !found {
+			continue
+		}
+
+		result.Matches = append(result.Matches, Match{Word: word, Severity: severity, Locale: locale, Blocked: severity >= v.threshold})
+		for i := loc[0]; i < loc[1]; i++ {
+			censored[i] = '*'
+		}
+	}
+	result.Censored = string(censored)
+
+	return result
+}
+
+// loadCSV reads "word,severity" records, words without a severity are treated as severe
+func loadCSV(name string) map[string]Severity {
+	badwords := make(map[string]Severity)
+	file, err := os.Open(name)
 	if err != nil {
 		panic(err)
 	}
@@ -61,6 +302,7 @@ func loadCSV() map[string]struct{} {
 	defer file.Close()
 
 	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = -1
 	for {
 		record, err := csvReader.Read()
 		if err == io.EOF {
@@ -68,7 +310,14 @@ func loadCSV() map[string]struct{} {
 		} else if err != nil {
 			panic(err)
 		}
-		badwords[record[0]] = struct{}{}
+
+		severity := SeveritySevere
+		if len(record) > 1 {
+			if severity, err = ParseSeverity(record[1]); err != nil {
+				panic(err)
+			}
+		}
+		badwords[record[0]] = severity
 	}
 
 	return badwords
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Short texts like "OK" or an acronym aren't shouting
+	shoutingMinLetters = 20
+	shoutingUpperRatio = 0.7
+	maxLinks           = 3
+)
+
+var linkRegex = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// ContentWarnings returns "are you sure?" hints about allowed but questionable text, the texts are checked together
+// so a post title and description count as one. Unlike the bad words validation these never block a submission
+func ContentWarnings(texts ...string) []string {
+	text := strings.Join(texts, "\n")
+	warnings := make([]string, 0)
+
+	// Links are mostly lowercase and would hide the shouting around them
+	if isShouting(linkRegex.ReplaceAllString(text, "")) {
+		warnings = append(warnings, "Most of your text is in capital letters, it may read as shouting")
+	}
+
+	if len(linkRegex.FindAllString(text, -1)) > maxLinks {
+		warnings = append(warnings, "Your text contains a lot of links, it may look like spam")
+	}
+
+	return warnings
+}
+
+func isShouting(text string) bool {
+	var letters, upper int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		letters++
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	return letters >= shoutingMinLetters && float64(upper)/float64(letters) >= shoutingUpperRatio
+}